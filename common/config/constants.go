@@ -36,6 +36,14 @@ var RequestBodyDecodeMaxLayers = 2
 // 是否开启用户月账单功能
 var UserInvoiceMonth = false
 
+// 月账单文档中的开具方信息，以及生成月账单后是否发送邮件
+var InvoiceCompanyName = ""
+var InvoiceCompanyAddress = ""
+var InvoiceCompanyTaxId = ""
+var InvoiceCompanyEmail = ""
+var InvoiceNotes = ""
+var InvoiceEmailEnabled = false
+
 // Any options with "Secret", "Token" in its key won't be return by GetOptions
 
 var SessionSecret = uuid.New().String()
@@ -184,6 +192,12 @@ var OIDCUsernameClaims = ""
 var QuotaForNewUser = 0
 var QuotaForInviter = 0
 var QuotaForInvitee = 0
+
+// 被邀请用户充值成功后按支付金额给邀请人的佣金比例，单位 %，0 表示不发放
+var AffCommissionRate = 0.0
+var AffCommissionMonths = 12 // 被邀请用户注册后多少个月内的充值发放佣金，0 表示不限
+var AffTransferMinQuota = 0  // 佣金转入余额的最低额度
+
 var ChannelDisableThreshold = 5.0
 var AutomaticDisableChannelEnabled = false
 var AutomaticEnableChannelEnabled = false
@@ -196,9 +210,40 @@ var RetryTimeOut = 10
 
 var DefaultChannelWeight = uint(1)
 var RetryCooldownSeconds = 5
+
+// 令牌轮换后旧 key 的默认宽限期（秒），以及新令牌 key 的默认可读前缀
+var TokenRotationGraceSeconds = 86400
+var TokenKeyPrefix = ""
 var PreferredChannelWaitMilliseconds = 0
 var PreferredChannelWaitPollMilliseconds = 50
 
+const (
+	ChannelBalancerStrategyWeight   = "weight"
+	ChannelBalancerStrategyCheapest = "cheapest"
+)
+
+// 同优先级渠道的选择策略：weight 按权重随机，cheapest 优先选择上游成本最低的健康渠道
+var ChannelBalancerStrategy = ChannelBalancerStrategyWeight
+
+// 渠道定时健康检查
+var ChannelHealthCheckEnabled = false
+var ChannelHealthCheckInterval = 10 // 分钟
+var ChannelHealthCheckConcurrency = 5
+var ChannelHealthCheckRecoverPasses = 3
+var ChannelHealthHistoryRetentionDays = 7
+
+// 渠道余额定时刷新
+var ChannelBalanceRefreshEnabled = false
+var ChannelBalanceRefreshInterval = 60 // 分钟
+var ChannelBalanceHistoryRetentionDays = 30
+
+// 分时价格调整使用的时区，为空时使用服务器时区
+var PriceTimezone = ""
+
+// 渠道上游模型列表定时同步
+var ChannelModelSyncEnabled = false
+var ChannelModelSyncInterval = 720 // 分钟
+
 var CFWorkerImageUrl = ""
 var CFWorkerImageKey = ""
 
@@ -3,3 +3,7 @@ package config
 var PaymentUSDRate = 7.3
 var PaymentMinAmount = 1
 var RechargeDiscount = ""
+
+// 主动向网关查询待支付订单的状态，补偿丢失的支付回调
+var PaymentReconcileEnabled = true
+var PaymentReconcileInterval = 5 // 分钟
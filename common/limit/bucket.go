@@ -0,0 +1,159 @@
+package limit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/redis"
+)
+
+const bucketFormat = "{%s}:bucket"
+
+var (
+	//go:embed bucket.lua
+	bucketLuaScript string
+	bucketScript    = redis.NewScript(bucketLuaScript)
+
+	memoryBuckets      = make(map[string]*memoryBucket)
+	memoryBucketsMutex sync.Mutex
+)
+
+type memoryBucket struct {
+	tokens float64
+	ts     time.Time
+	fullAt time.Time // 按当前速率回满的时间，之后可以清理
+}
+
+// TokenBucket 按分钟配额匀速回填的令牌桶，容量等于每分钟的配额。
+// 与 TokenLimiter 不同，它支持按实际用量补扣或退还，并返回剩余量和回满时间，用于 x-ratelimit-* 响应头。
+type TokenBucket struct {
+	capacity  int
+	ratePerMs float64
+}
+
+// BucketResult 一次操作后的桶状态
+type BucketResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration // 回满所需的时间
+}
+
+func NewTokenBucket(perMinute int) *TokenBucket {
+	return &TokenBucket{
+		capacity:  perMinute,
+		ratePerMs: float64(perMinute) / float64(time.Minute.Milliseconds()),
+	}
+}
+
+// Take 桶内剩余足够时扣除 n，否则不扣除并返回 Allowed 为 false
+func (b *TokenBucket) Take(keyPrefix string, n int) (*BucketResult, error) {
+	return b.apply(keyPrefix, n, false)
+}
+
+// Adjust 按实际用量修正：n 为正时补扣（允许透支），为负时退还
+func (b *TokenBucket) Adjust(keyPrefix string, n int) (*BucketResult, error) {
+	return b.apply(keyPrefix, n, true)
+}
+
+func (b *TokenBucket) apply(keyPrefix string, n int, force bool) (*BucketResult, error) {
+	var (
+		allowed bool
+		tokens  float64
+		err     error
+	)
+	if config.RedisEnabled {
+		allowed, tokens, err = b.applyRedis(keyPrefix, n, force)
+	} else {
+		allowed, tokens = b.applyMemory(keyPrefix, n, force)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &BucketResult{
+		Allowed:   allowed,
+		Limit:     b.capacity,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     time.Duration(math.Ceil((float64(b.capacity)-tokens)/b.ratePerMs)) * time.Millisecond,
+	}, nil
+}
+
+// SetHeaders 写入 x-ratelimit-* 响应头，kind 为 requests 或 tokens
+func (r *BucketResult) SetHeaders(header http.Header, kind string) {
+	header.Set("x-ratelimit-limit-"+kind, strconv.Itoa(r.Limit))
+	header.Set("x-ratelimit-remaining-"+kind, strconv.Itoa(r.Remaining))
+	header.Set("x-ratelimit-reset-"+kind, r.Reset.Round(time.Millisecond).String())
+}
+
+func (b *TokenBucket) applyRedis(keyPrefix string, n int, force bool) (bool, float64, error) {
+	forceArg := 0
+	if force {
+		forceArg = 1
+	}
+	resp, err := redis.ScriptRunCtx(context.Background(),
+		bucketScript,
+		[]string{fmt.Sprintf(bucketFormat, keyPrefix)},
+		b.capacity,
+		strconv.FormatFloat(b.ratePerMs, 'f', -1, 64),
+		time.Now().UnixMilli(),
+		n,
+		forceArg,
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	result, ok := resp.([]interface{})
+	if !ok || len(result) < 2 {
+		return false, 0, fmt.Errorf("unexpected bucket result: %v", resp)
+	}
+	allowed, _ := result[0].(int64)
+	tokensStr, _ := result[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed == 1, tokens, nil
+}
+
+func (b *TokenBucket) applyMemory(keyPrefix string, n int, force bool) (bool, float64) {
+	memoryBucketsMutex.Lock()
+	defer memoryBucketsMutex.Unlock()
+
+	now := time.Now()
+	key := fmt.Sprintf(bucketFormat, keyPrefix)
+	bucket, ok := memoryBuckets[key]
+	if !ok {
+		sweepMemoryBuckets(now)
+		bucket = &memoryBucket{tokens: float64(b.capacity), ts: now}
+		memoryBuckets[key] = bucket
+	}
+
+	elapsed := float64(now.Sub(bucket.ts).Milliseconds())
+	tokens := math.Min(float64(b.capacity), bucket.tokens+max(elapsed, 0)*b.ratePerMs)
+	allowed := force || tokens >= float64(n)
+	if allowed {
+		tokens = math.Min(float64(b.capacity), tokens-float64(n))
+	}
+	bucket.tokens = tokens
+	bucket.ts = now
+	bucket.fullAt = now.Add(time.Duration((float64(b.capacity)-tokens)/b.ratePerMs) * time.Millisecond)
+	return allowed, tokens
+}
+
+// sweepMemoryBuckets 清理已经回满的桶，回满的桶与不存在时一致，调用方需持有锁
+func sweepMemoryBuckets(now time.Time) {
+	for key, bucket := range memoryBuckets {
+		if now.After(bucket.fullAt) {
+			delete(memoryBuckets, key)
+		}
+	}
+}
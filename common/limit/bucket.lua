@@ -0,0 +1,34 @@
+-- KEYS[1] as bucket_key (hash: tokens, ts)
+-- ARGV[1] as capacity
+-- ARGV[2] as rate (tokens per millisecond)
+-- ARGV[3] as now (milliseconds)
+-- ARGV[4] as requested (negative to refund)
+-- ARGV[5] as force (1 to debit even if not enough tokens)
+
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local requested = tonumber(ARGV[4])
+local force = tonumber(ARGV[5])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+    tokens = capacity
+    ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if force == 1 or tokens >= requested then
+    tokens = math.min(capacity, tokens - requested)
+    allowed = 1
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+-- 桶回满后状态与不存在时一致，可以直接过期
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1000)
+
+return {allowed, tostring(tokens)}
@@ -0,0 +1,130 @@
+package limit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"sync"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/redis"
+	"one-api/common/utils"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const semaphoreFormat = "{%s}:semaphore"
+
+var (
+	//go:embed semaphore.lua
+	semaphoreLuaScript string
+	semaphoreScript    = redis.NewScript(semaphoreLuaScript)
+)
+
+// Semaphore 带租约的计数信号量，用于限制并发请求数。
+// 持有者需要在租约到期前续约，进程崩溃后租约到期自动释放。开启 Redis 时在所有节点间共享。
+type Semaphore struct {
+	lease time.Duration
+
+	mutex  sync.Mutex
+	memory map[string]map[string]time.Time
+}
+
+func NewSemaphore(lease time.Duration) *Semaphore {
+	return &Semaphore{
+		lease:  lease,
+		memory: make(map[string]map[string]time.Time),
+	}
+}
+
+func (s *Semaphore) Lease() time.Duration {
+	return s.lease
+}
+
+// Acquire 尝试占用一个名额，成功时返回持有者 ID，用于续约和释放
+func (s *Semaphore) Acquire(keyPrefix string, limit int) (string, bool, error) {
+	holder := utils.GetUUID()
+	key := fmt.Sprintf(semaphoreFormat, keyPrefix)
+	now := time.Now()
+
+	if config.RedisEnabled {
+		result, err := redis.ScriptRunCtx(context.Background(),
+			semaphoreScript,
+			[]string{key},
+			now.UnixMilli(),
+			limit,
+			now.Add(s.lease).UnixMilli(),
+			holder,
+			s.lease.Milliseconds(),
+		)
+		if err != nil {
+			return "", false, err
+		}
+		acquired, _ := result.(int64)
+		return holder, acquired == 1, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	holders := s.memory[key]
+	if holders == nil {
+		holders = make(map[string]time.Time)
+		s.memory[key] = holders
+	}
+	for id, expireAt := range holders {
+		if now.After(expireAt) {
+			delete(holders, id)
+		}
+	}
+	if len(holders) >= limit {
+		return "", false, nil
+	}
+	holders[holder] = now.Add(s.lease)
+	return holder, true, nil
+}
+
+// Renew 延长持有者的租约
+func (s *Semaphore) Renew(keyPrefix, holder string) error {
+	key := fmt.Sprintf(semaphoreFormat, keyPrefix)
+	expireAt := time.Now().Add(s.lease)
+
+	if config.RedisEnabled {
+		ctx := context.Background()
+		client := redis.GetRedisClient()
+		if err := client.ZAddXX(ctx, key, goredis.Z{Score: float64(expireAt.UnixMilli()), Member: holder}).Err(); err != nil {
+			return err
+		}
+		return client.PExpire(ctx, key, s.lease).Err()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if holders := s.memory[key]; holders != nil {
+		if _, ok := holders[holder]; ok {
+			holders[holder] = expireAt
+		}
+	}
+	return nil
+}
+
+func (s *Semaphore) Release(keyPrefix, holder string) error {
+	key := fmt.Sprintf(semaphoreFormat, keyPrefix)
+
+	if config.RedisEnabled {
+		return redis.GetRedisClient().ZRem(context.Background(), key, holder).Err()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if holders := s.memory[key]; holders != nil {
+		delete(holders, holder)
+		if len(holders) == 0 {
+			delete(s.memory, key)
+		}
+	}
+	return nil
+}
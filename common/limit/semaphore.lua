@@ -0,0 +1,17 @@
+-- KEYS[1] as semaphore_key (zset: holder -> lease expire at)
+-- ARGV[1] as now (milliseconds)
+-- ARGV[2] as limit
+-- ARGV[3] as lease expire at (milliseconds)
+-- ARGV[4] as holder
+-- ARGV[5] as lease (milliseconds)
+
+-- 清理租约已过期的持有者，进程崩溃时不会永久占用
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+    return 0
+end
+
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
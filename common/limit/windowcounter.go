@@ -0,0 +1,130 @@
+package limit
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/redis"
+)
+
+var (
+	//go:embed windowcounter.lua
+	windowCounterLuaScript string
+	windowCounterScript    = redis.NewScript(windowCounterLuaScript)
+
+	//go:embed windowcounterreserve.lua
+	windowCounterReserveLuaScript string
+	windowCounterReserveScript    = redis.NewScript(windowCounterReserveLuaScript)
+)
+
+// WindowCounter 按固定时间窗口累加计数，窗口结束后计数自动失效。
+// 开启 Redis 时计数在所有节点间共享，否则使用进程内计数。
+type WindowCounter struct {
+	mutex  sync.Mutex
+	memory map[string]*windowCount
+}
+
+type windowCount struct {
+	count    int64
+	expireAt time.Time
+}
+
+func NewWindowCounter() *WindowCounter {
+	return &WindowCounter{memory: make(map[string]*windowCount)}
+}
+
+// IncrBy 增加计数并返回增加后的值，key 应包含窗口的起始时间
+func (w *WindowCounter) IncrBy(key string, n int64, expireAt time.Time) (int64, error) {
+	if config.RedisEnabled {
+		result, err := redis.ScriptRunCtx(context.Background(), windowCounterScript, []string{key}, n, expireAt.Unix())
+		if err != nil {
+			return 0, err
+		}
+		count, _ := result.(int64)
+		return count, nil
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := time.Now()
+	entry, ok := w.memory[key]
+	if !ok || now.After(entry.expireAt) {
+		w.cleanup(now)
+		entry = &windowCount{expireAt: expireAt}
+		w.memory[key] = entry
+	}
+	entry.count += n
+	return entry.count, nil
+}
+
+// IncrByIfBelow 计数已达到 limit 或增加后超过 limit 时不增加，返回 false 和当前计数。
+// 检查和增加是原子的，用于并发请求下预占额度
+func (w *WindowCounter) IncrByIfBelow(key string, n, limit int64, expireAt time.Time) (int64, bool, error) {
+	if config.RedisEnabled {
+		result, err := redis.ScriptRunCtx(context.Background(), windowCounterReserveScript, []string{key}, n, limit, expireAt.Unix())
+		if err != nil {
+			return 0, false, err
+		}
+		values, ok := result.([]any)
+		if !ok || len(values) != 2 {
+			return 0, false, errors.New("unexpected window counter result")
+		}
+		allowed, _ := values[0].(int64)
+		count, _ := values[1].(int64)
+		return count, allowed == 1, nil
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := time.Now()
+	entry, ok := w.memory[key]
+	if !ok || now.After(entry.expireAt) {
+		w.cleanup(now)
+		entry = &windowCount{expireAt: expireAt}
+		w.memory[key] = entry
+	}
+	if entry.count >= limit || entry.count+n > limit {
+		return entry.count, false, nil
+	}
+	entry.count += n
+	return entry.count, true, nil
+}
+
+func (w *WindowCounter) Get(key string) (int64, error) {
+	if config.RedisEnabled {
+		value, err := redis.RedisGet(key)
+		if errors.Is(err, redis.Nil) {
+			// 计数不存在表示窗口内还没有记录
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseInt(value, 10, 64)
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	entry, ok := w.memory[key]
+	if !ok || time.Now().After(entry.expireAt) {
+		return 0, nil
+	}
+	return entry.count, nil
+}
+
+// cleanup 清理已过期的窗口，调用方需持有锁
+func (w *WindowCounter) cleanup(now time.Time) {
+	for key, entry := range w.memory {
+		if now.After(entry.expireAt) {
+			delete(w.memory, key)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+-- KEYS[1] as counter_key
+-- ARGV[1] as increment
+-- ARGV[2] as expire_at (unix seconds)
+
+local count = redis.call('INCRBY', KEYS[1], ARGV[1])
+if count == tonumber(ARGV[1]) then
+    -- 如果是第一次设置，设置到窗口结束时过期
+    redis.call('EXPIREAT', KEYS[1], ARGV[2])
+end
+
+return count
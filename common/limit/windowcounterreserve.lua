@@ -0,0 +1,18 @@
+-- KEYS[1] as counter_key
+-- ARGV[1] as increment
+-- ARGV[2] as limit
+-- ARGV[3] as expire_at (unix seconds)
+
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local increment = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+if current >= limit or current + increment > limit then
+    return {0, current}
+end
+
+local count = redis.call('INCRBY', KEYS[1], increment)
+if redis.call('TTL', KEYS[1]) < 0 then
+    redis.call('EXPIREAT', KEYS[1], ARGV[3])
+end
+
+return {1, count}
@@ -1,6 +1,7 @@
 package stmp
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -38,13 +39,24 @@ func NewStmp(host string, port int, username string, password string, from strin
 	}
 }
 
-func (s *StmpConfig) Send(to, subject, body string) error {
+// Attachment 邮件附件
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+func (s *StmpConfig) Send(to, subject, body string, attachments ...Attachment) error {
 	message := mail.NewMsg()
 	message.From(s.From)
 	message.To(to)
 	message.Subject(subject)
 	message.SetGenHeader("References", s.getReferences())
 	message.SetBodyString(mail.TypeTextHTML, body)
+	for _, attachment := range attachments {
+		if err := message.AttachReader(attachment.Name, bytes.NewReader(attachment.Data)); err != nil {
+			return err
+		}
+	}
 	message.SetUserAgent(fmt.Sprintf("One Hub %s // https://github.com/MartialBE/one-hub", config.Version))
 
 	client, err := mail.NewClient(
@@ -79,10 +91,10 @@ func (s *StmpConfig) getReferences() string {
 	return fmt.Sprintf("<%s.%s@%s>", froms[0], utils.GetUUID(), froms[1])
 }
 
-func (s *StmpConfig) Render(to, subject, content string) error {
+func (s *StmpConfig) Render(to, subject, content string, attachments ...Attachment) error {
 	body := getDefaultTemplate(content)
 
-	return s.Send(to, subject, body)
+	return s.Send(to, subject, body, attachments...)
 }
 
 func GetSystemStmp() (*StmpConfig, error) {
@@ -177,6 +189,62 @@ func SendQuotaWarningCodeEmail(userName, email string, quota int, noMoreQuota bo
 	return stmp.Render(email, subject, content)
 }
 
+func SendOrganizationInvitationEmail(email, organizationName, inviterName, link string, validDays int) error {
+	stmp, err := GetSystemStmp()
+
+	if err != nil {
+		return err
+	}
+
+	contentTemp := `
+	<p>
+		<strong>%s</strong> 邀请您加入组织「%s」，加入后可以使用组织的共享额度。
+	</p>
+	
+	<p style="text-align: center; font-size: 13px;">
+		<a target="__blank" href="%s" class="button" style="color: #ffffff;">接受邀请</a>
+	</p>
+	
+	<p style="color: #858585; padding-top: 15px;">
+		如果链接无法点击，请尝试点击下面的链接或将其复制到浏览器中打开<br> %s
+	</p>
+	<p style="color: #858585;">邀请 %d 天内有效，如果您不认识邀请人，请忽略。</p>`
+
+	subject := fmt.Sprintf("%s组织邀请", config.SystemName)
+	content := fmt.Sprintf(contentTemp, inviterName, organizationName, link, link, validDays)
+
+	return stmp.Render(email, subject, content)
+}
+
+func SendInvoiceEmail(userName, email, month, amount string, links map[string]string, attachments ...Attachment) error {
+	stmp, err := GetSystemStmp()
+
+	if err != nil {
+		return err
+	}
+
+	contentTemp := `<p style="font-size: 30px">Hi <strong>%s,</strong></p>
+	<p>
+		您 %s 的月度账单已生成，本月消费 <strong>%s</strong>，账单文件见附件。
+	</p>
+	%s
+	<p style="color: #858585; padding-top: 15px;">
+		您也可以随时在控制台的账单页面下载账单。
+	</p>`
+
+	linkContent := ""
+	for _, format := range []string{"pdf", "csv"} {
+		if link := links[format]; link != "" {
+			linkContent += fmt.Sprintf(`<p>%s: <a target="__blank" href="%s">%s</a></p>`, strings.ToUpper(format), link, link)
+		}
+	}
+
+	subject := fmt.Sprintf("%s %s 月度账单", config.SystemName, month)
+	content := fmt.Sprintf(contentTemp, userName, month, amount, linkContent)
+
+	return stmp.Render(email, subject, content, attachments...)
+}
+
 func DialAndSend(c *mail.Client, messages ...*mail.Msg) error {
 	ctx := context.Background()
 	if err := c.DialWithContext(ctx); err != nil {
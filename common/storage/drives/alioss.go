@@ -3,6 +3,8 @@ package drives
 import (
 	"bytes"
 	"fmt"
+	"time"
+
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 )
 
@@ -54,3 +56,40 @@ func (a *AliOSSUpload) Upload(data []byte, fileName string) (string, error) {
 
 	return objectURL, nil
 }
+
+func (a *AliOSSUpload) bucket() (*oss.Bucket, error) {
+	client, err := oss.New(a.Endpoint, a.AccessKeyId, a.AccessKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("creating OSS client: %w", err)
+	}
+	bucket, err := client.Bucket(a.BucketName)
+	if err != nil {
+		return nil, fmt.Errorf("getting bucket: %w", err)
+	}
+	return bucket, nil
+}
+
+// UploadPrivate 以私有读权限上传文件，不生成访问地址
+func (a *AliOSSUpload) UploadPrivate(data []byte, key string) error {
+	bucket, err := a.bucket()
+	if err != nil {
+		return err
+	}
+	if err := bucket.PutObject(key, bytes.NewReader(data), oss.ObjectACL(oss.ACLPrivate)); err != nil {
+		return fmt.Errorf("uploading file: %w", err)
+	}
+	return nil
+}
+
+// PresignURL 为私有文件生成有时效的下载地址
+func (a *AliOSSUpload) PresignURL(key string, expire time.Duration) (string, error) {
+	bucket, err := a.bucket()
+	if err != nil {
+		return "", err
+	}
+	objectURL, err := bucket.SignURL(key, oss.HTTPGet, int64(expire.Seconds()))
+	if err != nil {
+		return "", fmt.Errorf("signing object URL: %w", err)
+	}
+	return objectURL, nil
+}
@@ -100,3 +100,56 @@ func (a *S3Upload) Upload(data []byte, s3Key string) (string, error) {
 
 	return fmt.Sprintf("%s/%s", a.CustomDomain, datedKey), nil
 }
+
+func (a *S3Upload) client() (*s3.S3, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(
+			a.AccessKeyId,
+			a.AccessKeySecret,
+			"",
+		),
+		Endpoint:         aws.String(a.EndPoint),
+		Region:           aws.String("auto"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+	return s3.New(sess), nil
+}
+
+// UploadPrivate 上传私有文件，不加日期前缀也不设置图片的过期时间。
+// 不设置 ACL，兼容不支持 ACL 的 S3 服务，文件名需要带随机部分，避免被猜到
+
+func (a *S3Upload) UploadPrivate(data []byte, key string) error {
+	svc, err := a.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(a.BucketName),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload file to S3: %v", err)
+	}
+	return nil
+}
+
+// PresignURL 为私有文件生成有时效的下载地址
+func (a *S3Upload) PresignURL(key string, expire time.Duration) (string, error) {
+	svc, err := a.client()
+	if err != nil {
+		return "", err
+	}
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(a.BucketName),
+		Key:    aws.String(key),
+	})
+	objectURL, err := req.Presign(expire)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign S3 object: %v", err)
+	}
+	return objectURL, nil
+}
@@ -1,5 +1,7 @@
 package storage
 
+import "time"
+
 var storageDrives = New()
 
 type StorageDrive interface {
@@ -7,6 +9,12 @@ type StorageDrive interface {
 	Name() string
 }
 
+// PrivateStorageDrive 可以保存私有文件的对象存储，文件只能通过临时签名地址下载
+type PrivateStorageDrive interface {
+	UploadPrivate(data []byte, key string) error
+	PresignURL(key string, expire time.Duration) (string, error)
+}
+
 func New() *Storage {
 	storageDrive := &Storage{
 		drives: make(map[string]StorageDrive, 0),
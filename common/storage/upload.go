@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"one-api/common/logger"
+	"time"
 )
 
 func (s *Storage) Upload(ctx context.Context, data []byte, fileName string) string {
@@ -32,3 +33,37 @@ func Upload(data []byte, fileName string) string {
 
 	return storageDrives.Upload(ctx, data, fileName)
 }
+
+// privateDrives 可以保存账单等私有文件的对象存储，图床的地址是公开的，不能使用
+var privateDrives = []string{"S3", "AliOSS"}
+
+// UploadPrivate 把私有文件上传到对象存储，返回使用的存储名称，文件只能通过 PresignPrivate 生成的临时地址下载。
+// 没有配置对象存储时返回空的存储名称，全部上传失败时返回错误
+func UploadPrivate(data []byte, key string) (string, error) {
+	//lint:ignore SA1029 reason: 需要使用该类型作为错误处理
+	ctx := context.WithValue(context.Background(), logger.RequestIdKey, "UploadPrivate")
+
+	var lastErr error
+	for _, driveName := range privateDrives {
+		drive, ok := storageDrives.drives[driveName].(PrivateStorageDrive)
+		if !ok {
+			continue
+		}
+		if err := drive.UploadPrivate(data, key); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("%s err: %s", driveName, err.Error()))
+			lastErr = err
+			continue
+		}
+		return driveName, nil
+	}
+	return "", lastErr
+}
+
+// PresignPrivate 为 UploadPrivate 上传的文件生成有效期为 expire 的下载地址
+func PresignPrivate(driveName, key string, expire time.Duration) (string, error) {
+	drive, ok := storageDrives.drives[driveName].(PrivateStorageDrive)
+	if !ok {
+		return "", fmt.Errorf("storage %s is not available", driveName)
+	}
+	return drive.PresignURL(key, expire)
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"hash"
+	"strings"
 	"sync"
 
 	"github.com/spf13/viper"
@@ -51,8 +52,13 @@ func InitUserToken() error {
 	return err
 }
 
-func GenerateToken(tokenID, userID int) (string, error) {
-	payload, err := hashids.Encode([]uint64{uint64(tokenID), uint64(userID)})
+// GenerateToken version 为令牌的轮换次数，为 0 时与轮换功能之前生成的 key 保持一致
+func GenerateToken(tokenID, userID, version int) (string, error) {
+	numbers := []uint64{uint64(tokenID), uint64(userID)}
+	if version > 0 {
+		numbers = append(numbers, uint64(version))
+	}
+	payload, err := hashids.Encode(numbers)
 	if err != nil {
 		return "", err
 	}
@@ -69,7 +75,20 @@ func GenerateToken(tokenID, userID int) (string, error) {
 	return payload + "_" + signature, nil
 }
 
+// TokenKeyPrefixSeparator 令牌 key 的可读前缀与 key 之间的分隔符，便于密钥扫描工具识别
+const TokenKeyPrefixSeparator = "-"
+
+// SplitTokenKeyPrefix 拆分出 key 的可读前缀，payload 中不含 - 和 _，第一个 _ 之前的 - 即为分隔符
+func SplitTokenKeyPrefix(token string) (prefix, key string) {
+	index := strings.Index(token, TokenKeyPrefixSeparator)
+	if index <= 0 || index > strings.Index(token, "_") {
+		return "", token
+	}
+	return token[:index], token[index+1:]
+}
+
 func ValidateToken(token string) (tokenID, userID int, err error) {
+	_, token = SplitTokenKeyPrefix(token)
 	parts := bytes.SplitN([]byte(token), []byte("_"), 2)
 	if len(parts) != 2 {
 		return 0, 0, fmt.Errorf("无效的令牌")
@@ -96,7 +115,7 @@ func ValidateToken(token string) (tokenID, userID int, err error) {
 	}
 
 	numbers := hashids.Decode(string(payloadEncoded))
-	if len(numbers) != 2 {
+	if len(numbers) != 2 && len(numbers) != 3 {
 		return 0, 0, fmt.Errorf("无效的令牌")
 	}
 
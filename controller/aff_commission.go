@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"net/http"
+
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AffTransferRequest struct {
+	Quota int `json:"quota"`
+}
+
+// GetAffDashboard 邀请人的佣金概览和每个被邀请用户带来的佣金
+func GetAffDashboard(c *gin.Context) {
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	invitees, err := model.GetAffInviteeEarnings(user.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"aff_code":           user.AffCode,
+			"aff_count":          user.AffCount,
+			"aff_quota":          user.AffQuota,
+			"aff_history_quota":  user.AffHistoryQuota,
+			"commission_rate":    config.AffCommissionRate,
+			"commission_months":  config.AffCommissionMonths,
+			"transfer_min_quota": config.AffTransferMinQuota,
+			"invitees":           invitees,
+		},
+	})
+}
+
+// GetAffCommissions 邀请人的佣金明细
+func GetAffCommissions(c *gin.Context) {
+	var params model.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	commissions, err := model.GetAffCommissionsList(c.GetInt("id"), &params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    commissions,
+	})
+}
+
+// TransferAffQuota 把佣金转入可用余额
+func TransferAffQuota(c *gin.Context) {
+	var req AffTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.TransferAffQuota(c.GetInt("id"), req.Quota); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
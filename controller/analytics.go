@@ -57,10 +57,11 @@ func GetStatisticsByPeriod(c *gin.Context) {
 }
 
 type StatisticsDetail struct {
-	UserStatistics      *model.StatisticsUser         `json:"user_statistics"`
-	ChannelStatistics   []*model.ChannelStatistics    `json:"channel_statistics"`
-	RedemptionStatistic []*model.RedemptionStatistics `json:"redemption_statistic"`
-	OrderStatistics     []*model.OrderStatistics      `json:"order_statistics"`
+	UserStatistics      *model.StatisticsUser           `json:"user_statistics"`
+	ChannelStatistics   []*model.ChannelStatistics      `json:"channel_statistics"`
+	RedemptionStatistic []*model.RedemptionStatistics   `json:"redemption_statistic"`
+	OrderStatistics     []*model.OrderStatistics        `json:"order_statistics"`
+	BalanceBurnRates    []*model.ChannelBalanceBurnRate `json:"balance_burn_rates"`
 }
 
 func GetStatisticsDetail(c *gin.Context) {
@@ -85,9 +86,38 @@ func GetStatisticsDetail(c *gin.Context) {
 	if err == nil {
 		statisticsDetail.OrderStatistics = orderStatistics
 	}
+
+	balanceBurnRates, err := model.GetChannelBalanceBurnRates(7)
+	if err == nil {
+		statisticsDetail.BalanceBurnRates = balanceBurnRates
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
 		"data":    statisticsDetail,
 	})
 }
+
+func GetChannelMarginStatistics(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+
+	startDate := time.Unix(startTimestamp, 0).Format("2006-01-02")
+	endDate := time.Unix(endTimestamp, 0).Format("2006-01-02")
+
+	margins, err := model.GetChannelMarginStatisticsByPeriod(startDate, endDate, channelId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    margins,
+	})
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BillingTagStatsRequest struct {
+	StartTime  string `form:"start_time" binding:"required"`
+	EndTime    string `form:"end_time" binding:"required"`
+	GroupBy    string `form:"group_by"` // day 或 month，默认为 day
+	BillingTag string `form:"billing_tag"`
+}
+
+func getBillingTagStatistics(c *gin.Context) (*BillingTagStatsRequest, []*model.BillingTagStatisticSummary, error) {
+	var req BillingTagStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		return nil, nil, fmt.Errorf("invalid parameters: %v", err)
+	}
+	if _, err := time.Parse("2006-01-02", req.StartTime); err != nil {
+		return nil, nil, fmt.Errorf("invalid start_time format, expected YYYY-MM-DD")
+	}
+	if _, err := time.Parse("2006-01-02", req.EndTime); err != nil {
+		return nil, nil, fmt.Errorf("invalid end_time format, expected YYYY-MM-DD")
+	}
+	if req.GroupBy == "" {
+		req.GroupBy = "day"
+	}
+
+	statistics, err := model.GetBillingTagStatistics(req.StartTime, req.EndTime, req.GroupBy, req.BillingTag)
+	return &req, statistics, err
+}
+
+// GetBillingTagStatistics 按费用标签、模型汇总的用量
+func GetBillingTagStatistics(c *gin.Context) {
+	_, statistics, err := getBillingTagStatistics(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    statistics,
+	})
+}
+
+// ExportBillingTagStatisticsCSV 导出费用标签的统计数据为CSV
+func ExportBillingTagStatisticsCSV(c *gin.Context) {
+	req, statistics, err := getBillingTagStatistics(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	filename := fmt.Sprintf("billing_tag_stats_%s_%s.csv", req.StartTime, req.EndTime)
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+	writer := csv.NewWriter(c.Writer)
+	defer writer.Flush()
+
+	header := []string{
+		"Period",
+		"Billing Tag",
+		"Model",
+		"Request Count",
+		"Quota",
+		"Cost (USD)",
+		"Prompt Tokens",
+		"Completion Tokens",
+	}
+	if err := writer.Write(header); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("failed to write CSV header: %v", err))
+		return
+	}
+
+	for _, stat := range statistics {
+		row := []string{
+			stat.Period,
+			stat.BillingTag,
+			stat.ModelName,
+			fmt.Sprintf("%d", stat.RequestCount),
+			fmt.Sprintf("%d", stat.Quota),
+			fmt.Sprintf("%.6f", float64(stat.Quota)/config.QuotaPerUnit),
+			fmt.Sprintf("%d", stat.PromptTokens),
+			fmt.Sprintf("%d", stat.CompletionTokens),
+		}
+		if err := writer.Write(row); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("failed to write CSV row: %v", err))
+			return
+		}
+	}
+}
+
+func GetBillingTagBudgets(c *gin.Context) {
+	budgets, err := model.GetBillingTagBudgets()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    budgets,
+	})
+}
+
+type billingTagBudgetRequest struct {
+	BillingTag string  `json:"billing_tag"`
+	Budget     float64 `json:"budget"`
+	Block      bool    `json:"block"`
+}
+
+// SaveBillingTagBudget 创建或修改费用标签的月度预算
+func SaveBillingTagBudget(c *gin.Context) {
+	var req billingTagBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	budget, err := model.SaveBillingTagBudget(req.BillingTag, req.Budget, req.Block)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    budget,
+	})
+}
+
+func DeleteBillingTagBudget(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.DeleteBillingTagBudget(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
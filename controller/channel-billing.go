@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/notify"
 	"one-api/model"
 	"one-api/providers"
 	providersBase "one-api/providers/base"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -66,11 +69,145 @@ func updateChannelBalance(channel *model.Channel) (float64, error) {
 
 	balanceProvider, ok := provider.(providersBase.BalanceInterface)
 	if !ok {
-		return 0, errors.New("provider not implemented")
+		return 0, providersBase.ErrBalanceNotSupported
 	}
 
-	return balanceProvider.Balance()
+	balance, err := balanceProvider.Balance()
+	if err != nil {
+		return 0, err
+	}
+
+	handleChannelBalanceUpdated(channel, balance)
+	return balance, nil
+}
+
+// handleChannelBalanceUpdated 记录余额历史，并在余额跨越阈值时执行渠道配置的动作
+func handleChannelBalanceUpdated(channel *model.Channel, balance float64) {
+	if err := model.RecordChannelBalance(channel.Id, balance); err != nil {
+		logger.SysError(fmt.Sprintf("failed to record balance history for channel #%d: %s", channel.Id, err.Error()))
+	}
+
+	alert := channel.GetBalanceAlert()
+	if alert == nil {
+		return
+	}
+
+	low := balance < alert.Threshold
+	changed, err := model.SetChannelBalanceLow(channel.Id, low)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to update balance state for channel #%d: %s", channel.Id, err.Error()))
+		return
+	}
+	if !changed {
+		return
+	}
+
+	if low {
+		reason := fmt.Sprintf("余额 %.2f 低于阈值 %.2f", balance, alert.Threshold)
+		if alert.Action == model.ChannelBalanceActionDisable {
+			disabled, err := AutoDisableChannel(channel.Id, channel.Name, reason, true)
+			if err != nil {
+				logger.SysError(fmt.Sprintf("failed to auto disable channel #%d(%s): %s", channel.Id, channel.Name, err.Error()))
+				return
+			}
+			// 记录由余额规则禁用，余额恢复时不会启用因为其他原因被自动禁用的渠道
+			if disabled {
+				if _, err := model.SetChannelBalanceDisabled(channel.Id, true); err != nil {
+					logger.SysError(fmt.Sprintf("failed to mark balance disabled channel #%d: %s", channel.Id, err.Error()))
+				}
+			}
+			return
+		}
+		if alert.Action == model.ChannelBalanceActionLowerPriority {
+			reason += fmt.Sprintf("，优先级已降为 %d", alert.Priority)
+			reloadChannelGroupAfterBalanceChange()
+		}
+		notify.Send(fmt.Sprintf("通道「%s」（#%d）余额不足", channel.Name, channel.Id), fmt.Sprintf("通道「%s」（#%d）%s", channel.Name, channel.Id, reason))
+		return
+	}
 
+	if alert.Action == model.ChannelBalanceActionDisable {
+		balanceDisabled, err := model.SetChannelBalanceDisabled(channel.Id, false)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to clear balance disabled mark of channel #%d: %s", channel.Id, err.Error()))
+			return
+		}
+		if balanceDisabled {
+			if _, err := AutoEnableChannel(channel.Id, channel.Name, true); err != nil {
+				logger.SysError(fmt.Sprintf("failed to auto enable channel #%d(%s): %s", channel.Id, channel.Name, err.Error()))
+			}
+			return
+		}
+	}
+	if alert.Action == model.ChannelBalanceActionLowerPriority {
+		reloadChannelGroupAfterBalanceChange()
+	}
+	notify.Send(fmt.Sprintf("通道「%s」（#%d）余额已恢复", channel.Name, channel.Id), fmt.Sprintf("通道「%s」（#%d）余额 %.2f 已恢复到阈值 %.2f 以上", channel.Name, channel.Id, balance, alert.Threshold))
+}
+
+func reloadChannelGroupAfterBalanceChange() {
+	if err := model.ChannelGroup.Load(); err != nil {
+		logger.SysError("failed to reload channels after balance change: " + err.Error())
+	}
+}
+
+var (
+	channelBalanceRefreshRunning atomic.Bool
+	channelBalanceRefreshLastRun atomic.Int64
+
+	updateChannelBalanceFunc = updateChannelBalance
+)
+
+// RunScheduledChannelBalanceRefresh 由定时任务每分钟调用，按 ChannelBalanceRefreshInterval 控制实际执行频率
+func RunScheduledChannelBalanceRefresh() {
+	if !config.ChannelBalanceRefreshEnabled {
+		return
+	}
+
+	interval := time.Duration(max(config.ChannelBalanceRefreshInterval, 1)) * time.Minute
+	now := time.Now()
+	if last := channelBalanceRefreshLastRun.Load(); last > 0 && now.Sub(time.Unix(last, 0)) < interval {
+		return
+	}
+	channelBalanceRefreshLastRun.Store(now.Unix())
+
+	refreshAllChannelsBalance()
+
+	if config.ChannelBalanceHistoryRetentionDays > 0 {
+		before := now.AddDate(0, 0, -config.ChannelBalanceHistoryRetentionDays)
+		if _, err := model.DeleteChannelBalanceHistoryBefore(before); err != nil {
+			logger.SysError("failed to clean channel balance history: " + err.Error())
+		}
+	}
+}
+
+// refreshAllChannelsBalance 刷新所有实现了余额查询的渠道，未实现的渠道直接跳过
+func refreshAllChannelsBalance() {
+	if !channelBalanceRefreshRunning.CompareAndSwap(false, true) {
+		logger.SysLog("skip channel balance refresh: previous run is still in progress")
+		return
+	}
+	defer channelBalanceRefreshRunning.Store(false)
+
+	channels, err := model.GetChannelsForBalanceRefresh()
+	if err != nil {
+		logger.SysError("failed to load channels for balance refresh: " + err.Error())
+		return
+	}
+
+	refreshed := 0
+	for _, channel := range channels {
+		if _, err := updateChannelBalanceFunc(channel); err != nil {
+			if !errors.Is(err, providersBase.ErrBalanceNotSupported) {
+				logger.SysError(fmt.Sprintf("failed to refresh balance for channel #%d(%s): %s", channel.Id, channel.Name, err.Error()))
+			}
+			continue
+		}
+		refreshed++
+		time.Sleep(config.RequestInterval)
+	}
+
+	logger.SysLog(fmt.Sprintf("channel balance refresh finished, %d channels refreshed", refreshed))
 }
 
 func UpdateChannelBalance(c *gin.Context) {
@@ -99,12 +236,20 @@ func UpdateChannelBalance(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "",
-		"balance": balance,
+		"success":         true,
+		"message":         "",
+		"balance":         balance,
+		"source_currency": channelBalanceCurrency(channel),
 	})
 }
 
+// channelBalanceCurrency 返回渠道余额接口的原始币种，返回的余额已经换算为美元
+func channelBalanceCurrency(channel *model.Channel) string {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/balance", nil)
+	return providersBase.GetBalanceCurrency(providers.GetProvider(channel, c))
+}
+
 func updateAllChannelsBalance() error {
 	channels, err := model.GetAllChannels()
 	if err != nil {
@@ -162,3 +307,27 @@ func UpdateAllChannelsBalance(c *gin.Context) {
 // 		common.SysLog("channels update done")
 // 	}
 // }
+
+func GetChannelBalanceHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	days, _ := strconv.Atoi(c.Query("days"))
+	if days <= 0 {
+		days = 7
+	}
+
+	histories, err := model.GetChannelBalanceHistory(id, time.Now().AddDate(0, 0, -days).Unix())
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    histories,
+	})
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"one-api/common/config"
+	"one-api/model"
+	providersBase "one-api/providers/base"
+
+	"gorm.io/datatypes"
+)
+
+func useControllerTestBalanceDB(t *testing.T) {
+	t.Helper()
+
+	useControllerTestChannelDB(t)
+	if err := model.DB.AutoMigrate(&model.ChannelBalanceHistory{}); err != nil {
+		t.Fatalf("expected balance history schema migration, got %v", err)
+	}
+}
+
+func newBalanceAlertChannel(id int, alert model.ChannelBalanceAlert) *model.Channel {
+	balanceAlert := datatypes.NewJSONType(alert)
+	return &model.Channel{
+		Id:           id,
+		Name:         "balance",
+		Status:       config.ChannelStatusEnabled,
+		BalanceAlert: &balanceAlert,
+	}
+}
+
+func TestHandleChannelBalanceUpdatedDisablesAndRecovers(t *testing.T) {
+	useControllerTestBalanceDB(t)
+	resetChannelProbeTestState(t)
+
+	channel := newBalanceAlertChannel(1, model.ChannelBalanceAlert{Threshold: 10, Action: model.ChannelBalanceActionDisable})
+	insertControllerTestChannel(t, channel)
+
+	handleChannelBalanceUpdated(channel, 5)
+	stored, _ := model.GetChannelById(1)
+	if stored.Status != config.ChannelStatusAutoDisabled || !stored.BalanceLow || !stored.BalanceDisabled {
+		t.Fatalf("expected low balance to auto-disable channel, got status=%d low=%v disabled=%v", stored.Status, stored.BalanceLow, stored.BalanceDisabled)
+	}
+
+	handleChannelBalanceUpdated(stored, 20)
+	stored, _ = model.GetChannelById(1)
+	if stored.Status != config.ChannelStatusEnabled || stored.BalanceLow || stored.BalanceDisabled {
+		t.Fatalf("expected recovered balance to re-enable channel, got status=%d low=%v disabled=%v", stored.Status, stored.BalanceLow, stored.BalanceDisabled)
+	}
+
+	histories, err := model.GetChannelBalanceHistory(1, 0)
+	if err != nil || len(histories) != 2 {
+		t.Fatalf("expected every refresh to be recorded, got %d, %v", len(histories), err)
+	}
+}
+
+func TestHandleChannelBalanceUpdatedDoesNotEnableManuallyDisabledChannel(t *testing.T) {
+	useControllerTestBalanceDB(t)
+	resetChannelProbeTestState(t)
+
+	channel := newBalanceAlertChannel(2, model.ChannelBalanceAlert{Threshold: 10, Action: model.ChannelBalanceActionDisable})
+	channel.Status = config.ChannelStatusManuallyDisabled
+	channel.BalanceLow = true
+	insertControllerTestChannel(t, channel)
+	model.DB.Model(channel).Update("balance_low", true)
+
+	handleChannelBalanceUpdated(channel, 20)
+	stored, _ := model.GetChannelById(2)
+	if stored.Status != config.ChannelStatusManuallyDisabled {
+		t.Fatalf("expected manual disable to be kept, got %d", stored.Status)
+	}
+}
+
+func TestHandleChannelBalanceUpdatedKeepsChannelDisabledForOtherReasons(t *testing.T) {
+	useControllerTestBalanceDB(t)
+	resetChannelProbeTestState(t)
+
+	channel := newBalanceAlertChannel(3, model.ChannelBalanceAlert{Threshold: 10, Action: model.ChannelBalanceActionDisable})
+	insertControllerTestChannel(t, channel)
+
+	// 渠道先因为请求出错被自动禁用，之后余额才低于阈值
+	if _, err := AutoDisableChannel(channel.Id, channel.Name, "error rate", false); err != nil {
+		t.Fatalf("expected channel to be auto-disabled, got %v", err)
+	}
+	handleChannelBalanceUpdated(channel, 5)
+	stored, _ := model.GetChannelById(3)
+	if !stored.BalanceLow || stored.BalanceDisabled {
+		t.Fatalf("expected low balance without balance disable mark, got low=%v disabled=%v", stored.BalanceLow, stored.BalanceDisabled)
+	}
+
+	handleChannelBalanceUpdated(stored, 20)
+	stored, _ = model.GetChannelById(3)
+	if stored.Status != config.ChannelStatusAutoDisabled {
+		t.Fatalf("expected channel disabled for another reason to stay disabled, got %d", stored.Status)
+	}
+}
+
+func TestUpdateChannelBalanceReportsUnsupportedProviders(t *testing.T) {
+	proxy := ""
+	for _, channelType := range []int{config.ChannelTypeAnthropic, config.ChannelTypeZhipu, config.ChannelTypeAli, config.ChannelTypeXAI, config.ChannelTypeGroq} {
+		channel := &model.Channel{Id: channelType, Type: channelType, Key: "test-key", Proxy: &proxy}
+		if _, err := updateChannelBalance(channel); !errors.Is(err, providersBase.ErrBalanceNotSupported) {
+			t.Fatalf("expected channel type %d to report unsupported balance, got %v", channelType, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/notify"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	channelHealthCheckRunning atomic.Bool
+	channelHealthCheckLastRun atomic.Int64
+)
+
+// RunScheduledChannelHealthChecks 由定时任务每分钟调用，按 ChannelHealthCheckInterval 控制实际执行频率
+func RunScheduledChannelHealthChecks() {
+	if !config.ChannelHealthCheckEnabled {
+		return
+	}
+
+	interval := time.Duration(max(config.ChannelHealthCheckInterval, 1)) * time.Minute
+	now := currentTimeFunc()
+	if last := channelHealthCheckLastRun.Load(); last > 0 && now.Sub(time.Unix(last, 0)) < interval {
+		return
+	}
+	channelHealthCheckLastRun.Store(now.Unix())
+
+	RunChannelHealthChecks()
+
+	if config.ChannelHealthHistoryRetentionDays > 0 {
+		before := now.AddDate(0, 0, -config.ChannelHealthHistoryRetentionDays)
+		if _, err := model.DeleteChannelHealthChecksBefore(before); err != nil {
+			logger.SysError("failed to clean channel health history: " + err.Error())
+		}
+	}
+}
+
+// RunChannelHealthChecks 并发检查启用和自动禁用的渠道，记录检查历史，
+// 自动禁用的渠道连续通过 ChannelHealthCheckRecoverPasses 次后自动恢复
+func RunChannelHealthChecks() {
+	if !channelHealthCheckRunning.CompareAndSwap(false, true) {
+		logger.SysLog("skip channel health check: previous run is still in progress")
+		return
+	}
+	defer channelHealthCheckRunning.Store(false)
+
+	channels, err := model.GetChannelsForHealthCheck()
+	if err != nil {
+		logger.SysError("failed to load channels for health check: " + err.Error())
+		return
+	}
+
+	disableThreshold := channelDisableThresholdMilliseconds()
+	sem := make(chan struct{}, max(config.ChannelHealthCheckConcurrency, 1))
+	var wg sync.WaitGroup
+	for _, channel := range channels {
+		wg.Add(1)
+		sem <- struct{}{}
+		common.SafeGoroutine(func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			checkChannelHealth(channel, disableThreshold)
+		})
+	}
+	wg.Wait()
+
+	logger.SysLog(fmt.Sprintf("channel health check finished, %d channels checked", len(channels)))
+}
+
+func checkChannelHealth(channel *model.Channel, disableThreshold int64) {
+	result := probeChannelFunc(channel, "")
+	passed := result.isHealthy() && !result.exceedsThreshold(disableThreshold)
+
+	check := &model.ChannelHealthCheck{
+		ChannelId:     channel.Id,
+		Model:         channel.TestModel,
+		Passed:        passed,
+		ResponseTime:  result.milliseconds,
+		ChannelStatus: channel.Status,
+		CreatedAt:     currentTimeFunc().Unix(),
+	}
+	if result.err != nil {
+		check.Error = result.err.Error()
+	} else if !passed {
+		check.Error = fmt.Sprintf("响应时间 %.2fs 超过阈值 %.2fs", result.consumedSeconds(), float64(disableThreshold)/1000.0)
+	}
+	if err := check.Insert(); err != nil {
+		logger.SysError(fmt.Sprintf("failed to record health check for channel #%d: %s", channel.Id, err.Error()))
+	}
+
+	switch channel.Status {
+	case config.ChannelStatusEnabled:
+		if passed {
+			channel.UpdateResponseTime(result.milliseconds)
+			return
+		}
+		// 定时检查在后台运行，超时禁用同样遵循自动禁用开关
+		timeout := config.AutomaticDisableChannelEnabled && result.exceedsThreshold(disableThreshold)
+		if !timeout && !ShouldDisableChannel(channel.Type, result.openaiErr) {
+			return
+		}
+		if _, err := AutoDisableChannel(channel.Id, channel.Name, check.Error, true); err != nil {
+			logger.SysError(fmt.Sprintf("failed to auto-disable channel #%d in health check: %s", channel.Id, err.Error()))
+		}
+	case config.ChannelStatusAutoDisabled:
+		if !passed || !config.AutomaticEnableChannelEnabled || config.ChannelHealthCheckRecoverPasses <= 0 {
+			return
+		}
+		passes, err := model.CountChannelRecoveryPasses(channel.Id, config.ChannelHealthCheckRecoverPasses)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to count health checks for channel #%d: %s", channel.Id, err.Error()))
+			return
+		}
+		if passes < config.ChannelHealthCheckRecoverPasses {
+			return
+		}
+		updated, err := AutoEnableChannel(channel.Id, channel.Name, false)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to auto-enable channel #%d in health check: %s", channel.Id, err.Error()))
+			return
+		}
+		if updated {
+			channel.UpdateResponseTime(result.milliseconds)
+			subject := fmt.Sprintf("通道「%s」（#%d）已恢复", channel.Name, channel.Id)
+			content := fmt.Sprintf("通道「%s」（#%d）连续 %d 次健康检查通过，已自动启用", channel.Name, channel.Id, passes)
+			notify.Send(subject, content)
+		}
+	}
+}
+
+func GetChannelHealthHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	checks, err := model.GetChannelHealthHistory(id, limit)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    checks,
+	})
+}
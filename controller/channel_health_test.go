@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"one-api/common/config"
+	"one-api/model"
+	"one-api/types"
+)
+
+func useControllerTestHealthDB(t *testing.T) {
+	t.Helper()
+
+	useControllerTestChannelDB(t)
+	if err := model.DB.AutoMigrate(&model.ChannelHealthCheck{}); err != nil {
+		t.Fatalf("expected health check schema migration, got %v", err)
+	}
+
+	originalPasses := config.ChannelHealthCheckRecoverPasses
+	originalConcurrency := config.ChannelHealthCheckConcurrency
+	t.Cleanup(func() {
+		config.ChannelHealthCheckRecoverPasses = originalPasses
+		config.ChannelHealthCheckConcurrency = originalConcurrency
+	})
+}
+
+func TestRunChannelHealthChecksRecoversAfterConsecutivePasses(t *testing.T) {
+	useControllerTestHealthDB(t)
+	resetChannelProbeTestState(t)
+
+	config.AutomaticEnableChannelEnabled = true
+	config.ChannelDisableThreshold = 5
+	config.ChannelHealthCheckRecoverPasses = 2
+	config.ChannelHealthCheckConcurrency = 2
+
+	insertControllerTestChannel(t, &model.Channel{Id: 1, Name: "flaky", Status: config.ChannelStatusAutoDisabled, TestModel: "gpt-5"})
+	insertControllerTestChannel(t, &model.Channel{Id: 2, Name: "manual", Status: config.ChannelStatusManuallyDisabled, TestModel: "gpt-5"})
+
+	healthy := false
+	probeChannelFunc = func(channel *model.Channel, testModel string) channelProbeResult {
+		if channel.Id == 2 {
+			t.Fatal("expected manually disabled channel not to be probed")
+		}
+		if !healthy {
+			return channelProbeResult{err: errors.New("upstream down"), milliseconds: 100}
+		}
+		return channelProbeResult{milliseconds: 300}
+	}
+
+	steps := []struct {
+		healthy bool
+		status  int
+	}{
+		{healthy: true, status: config.ChannelStatusAutoDisabled},
+		// 失败会打断连续通过的计数
+		{healthy: false, status: config.ChannelStatusAutoDisabled},
+		{healthy: true, status: config.ChannelStatusAutoDisabled},
+		{healthy: true, status: config.ChannelStatusEnabled},
+	}
+	for i, step := range steps {
+		healthy = step.healthy
+		RunChannelHealthChecks()
+
+		channel, err := model.GetChannelById(1)
+		if err != nil {
+			t.Fatalf("expected channel lookup to succeed, got %v", err)
+		}
+		if channel.Status != step.status {
+			t.Fatalf("step %d: expected status %d, got %d", i, step.status, channel.Status)
+		}
+	}
+
+	history, err := model.GetChannelHealthHistory(1, 0)
+	if err != nil || len(history) != len(steps) {
+		t.Fatalf("expected one health record per run, got %d, %v", len(history), err)
+	}
+	if history[len(history)-2].Passed || history[len(history)-2].Error != "upstream down" {
+		t.Fatalf("expected failed check to be recorded with its error, got %+v", history[len(history)-2])
+	}
+}
+
+func TestRunChannelHealthChecksDisablesFailingChannel(t *testing.T) {
+	useControllerTestHealthDB(t)
+	resetChannelProbeTestState(t)
+
+	config.AutomaticDisableChannelEnabled = true
+
+	insertControllerTestChannel(t, &model.Channel{Id: 3, Name: "revoked", Type: config.ChannelTypeOpenAI, Status: config.ChannelStatusEnabled, TestModel: "gpt-5"})
+
+	probeChannelFunc = func(channel *model.Channel, testModel string) channelProbeResult {
+		return channelProbeResult{
+			openaiErr: &types.OpenAIErrorWithStatusCode{StatusCode: http.StatusUnauthorized},
+			err:       errors.New("invalid key"),
+		}
+	}
+
+	RunChannelHealthChecks()
+
+	channel, err := model.GetChannelById(3)
+	if err != nil {
+		t.Fatalf("expected channel lookup to succeed, got %v", err)
+	}
+	if channel.Status != config.ChannelStatusAutoDisabled {
+		t.Fatalf("expected failing channel to be auto-disabled, got %d", channel.Status)
+	}
+}
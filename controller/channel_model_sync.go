@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/notify"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	channelModelSyncRunning atomic.Bool
+	channelModelSyncLastRun atomic.Int64
+
+	fetchUpstreamModelsFunc = fetchUpstreamModels
+)
+
+// channelModelSyncResult 单个渠道的同步结果
+type channelModelSyncResult struct {
+	ChannelId int      `json:"channel_id"`
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Applied   bool     `json:"applied"`
+}
+
+func (result *channelModelSyncResult) changed() bool {
+	return len(result.Added) > 0 || len(result.Removed) > 0
+}
+
+func fetchUpstreamModels(channel *model.Channel) ([]string, error) {
+	// 多 Key 渠道只用第一个 Key 拉取模型列表
+	probe := *channel
+	probe.Key = strings.Split(channel.Key, "\n")[0]
+
+	req, err := http.NewRequest("GET", "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	provider := providers.GetProvider(&probe, c)
+	if provider == nil {
+		return nil, errors.New("provider not found")
+	}
+	modelProvider, ok := provider.(providersBase.ModelListInterface)
+	if !ok {
+		return nil, errors.New("channel not implemented")
+	}
+
+	return modelProvider.GetModelList()
+}
+
+// RunScheduledChannelModelSync 由定时任务每分钟调用，按 ChannelModelSyncInterval 控制实际执行频率
+func RunScheduledChannelModelSync() {
+	if !config.ChannelModelSyncEnabled {
+		return
+	}
+
+	interval := time.Duration(max(config.ChannelModelSyncInterval, 1)) * time.Minute
+	now := time.Now()
+	if last := channelModelSyncLastRun.Load(); last > 0 && now.Sub(time.Unix(last, 0)) < interval {
+		return
+	}
+	channelModelSyncLastRun.Store(now.Unix())
+
+	RunChannelModelSync()
+}
+
+// RunChannelModelSync 拉取开启了同步的渠道的上游模型列表，按渠道规则自动应用或生成待审核的差异
+func RunChannelModelSync() {
+	if !channelModelSyncRunning.CompareAndSwap(false, true) {
+		logger.SysLog("skip channel model sync: previous run is still in progress")
+		return
+	}
+	defer channelModelSyncRunning.Store(false)
+
+	channels, err := model.GetChannelsForModelSync()
+	if err != nil {
+		logger.SysError("failed to load channels for model sync: " + err.Error())
+		return
+	}
+
+	changed := 0
+	for _, channel := range channels {
+		result, err := syncChannelModels(channel)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to sync models for channel #%d(%s): %s", channel.Id, channel.Name, err.Error()))
+			continue
+		}
+		if result.changed() {
+			changed++
+		}
+		time.Sleep(config.RequestInterval)
+	}
+
+	logger.SysLog(fmt.Sprintf("channel model sync finished, %d of %d channels changed", changed, len(channels)))
+}
+
+func syncChannelModels(channel *model.Channel) (*channelModelSyncResult, error) {
+	upstream, err := fetchUpstreamModelsFunc(channel)
+	if err != nil {
+		return nil, err
+	}
+	if len(upstream) == 0 {
+		// 上游返回空列表多半是接口异常，不能据此下线全部模型
+		return nil, errors.New("upstream returned an empty model list")
+	}
+
+	added, removed := channel.DiffChannelModels(upstream)
+	result := &channelModelSyncResult{ChannelId: channel.Id, Added: added, Removed: removed}
+	if !result.changed() {
+		return result, model.ClearPendingChannelModelSyncDiff(channel.Id)
+	}
+
+	rule := channel.GetModelSyncRule()
+	if rule != nil && rule.AutoApply() {
+		if err := model.ApplyChannelModelSync(channel.Id, added, removed); err != nil {
+			return nil, err
+		}
+		if err := model.RecordAppliedChannelModelSyncDiff(channel.Id, added, removed); err != nil {
+			logger.SysError(fmt.Sprintf("failed to record model sync for channel #%d: %s", channel.Id, err.Error()))
+		}
+		result.Applied = true
+		notifyChannelModelSync(channel, result)
+		return result, nil
+	}
+
+	_, updated, err := model.SaveChannelModelSyncDiff(channel.Id, added, removed)
+	if err != nil {
+		return nil, err
+	}
+	// 相同的待审核差异只通知一次
+	if updated {
+		notifyChannelModelSync(channel, result)
+	}
+	return result, nil
+}
+
+func notifyChannelModelSync(channel *model.Channel, result *channelModelSyncResult) {
+	action := "等待审核"
+	if result.Applied {
+		action = "已自动应用"
+	}
+
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("渠道「%s」（#%d）的上游模型列表发生变化，%s。\n", channel.Name, channel.Id, action))
+	if len(result.Added) > 0 {
+		content.WriteString(fmt.Sprintf("新增模型：%s\n", strings.Join(result.Added, ", ")))
+	}
+	if len(result.Removed) > 0 {
+		content.WriteString(fmt.Sprintf("下线模型：%s\n", strings.Join(result.Removed, ", ")))
+	}
+
+	subject := fmt.Sprintf("渠道「%s」（#%d）模型列表变化", channel.Name, channel.Id)
+	notify.Send(subject, content.String())
+}
+
+func GetChannelModelSyncDiffs(c *gin.Context) {
+	diffs, err := model.GetChannelModelSyncDiffs(c.Query("status"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    diffs,
+	})
+}
+
+// SyncChannelModels 手动触发单个渠道的模型同步，即使渠道未开启定时同步也会生成差异
+func SyncChannelModels(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	channel, err := model.GetChannelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	result, err := syncChannelModels(channel)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    result,
+	})
+}
+
+// ReviewChannelModelSyncDiff 审核待处理的模型差异，action 为 approve 或 reject
+func ReviewChannelModelSyncDiff(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var approve bool
+	switch c.Param("action") {
+	case "approve":
+		approve = true
+	case "reject":
+	default:
+		common.APIRespondWithError(c, http.StatusOK, errors.New("unsupported action"))
+		return
+	}
+
+	diff, err := model.ReviewChannelModelSyncDiff(id, approve)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    diff,
+	})
+}
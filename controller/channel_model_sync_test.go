@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"slices"
+	"testing"
+
+	"one-api/common/config"
+	"one-api/model"
+
+	"gorm.io/datatypes"
+)
+
+func useControllerTestModelSyncDB(t *testing.T, upstream []string) {
+	t.Helper()
+
+	useControllerTestChannelDB(t)
+	if err := model.DB.AutoMigrate(&model.ChannelModelSyncDiff{}); err != nil {
+		t.Fatalf("expected model sync schema migration, got %v", err)
+	}
+
+	originalFetch := fetchUpstreamModelsFunc
+	fetchUpstreamModelsFunc = func(channel *model.Channel) ([]string, error) {
+		return upstream, nil
+	}
+	t.Cleanup(func() {
+		fetchUpstreamModelsFunc = originalFetch
+	})
+}
+
+func newModelSyncChannel(id int, mode string) *model.Channel {
+	rule := datatypes.NewJSONType(model.ChannelModelSyncRule{Enabled: true, Mode: mode})
+	return &model.Channel{
+		Id:        id,
+		Name:      "sync",
+		Status:    config.ChannelStatusEnabled,
+		Models:    "gpt-4o,gpt-4-turbo",
+		ModelSync: &rule,
+	}
+}
+
+func TestRunChannelModelSyncQueuesDiffForReview(t *testing.T) {
+	useControllerTestModelSyncDB(t, []string{"gpt-4o", "gpt-5"})
+	resetChannelProbeTestState(t)
+
+	insertControllerTestChannel(t, newModelSyncChannel(1, model.ChannelModelSyncModeReview))
+
+	RunChannelModelSync()
+	// 上游未变化时不会重复生成待审核记录
+	RunChannelModelSync()
+
+	diffs, err := model.GetChannelModelSyncDiffs(model.ChannelModelSyncDiffPending)
+	if err != nil || len(diffs) != 1 {
+		t.Fatalf("expected one pending diff, got %d, %v", len(diffs), err)
+	}
+	if !slices.Equal(diffs[0].Added, []string{"gpt-5"}) || !slices.Equal(diffs[0].Removed, []string{"gpt-4-turbo"}) {
+		t.Fatalf("unexpected diff %+v", diffs[0])
+	}
+	if channel, _ := model.GetChannelById(1); channel.Models != "gpt-4o,gpt-4-turbo" {
+		t.Fatalf("expected models to stay unchanged before review, got %q", channel.Models)
+	}
+
+	if _, err := model.ReviewChannelModelSyncDiff(diffs[0].Id, true); err != nil {
+		t.Fatalf("expected approval to succeed, got %v", err)
+	}
+	if channel, _ := model.GetChannelById(1); channel.Models != "gpt-4o,gpt-5" {
+		t.Fatalf("expected approved diff to be applied, got %q", channel.Models)
+	}
+	if _, err := model.ReviewChannelModelSyncDiff(diffs[0].Id, false); err == nil {
+		t.Fatal("expected reviewed diff not to be reviewed twice")
+	}
+}
+
+func TestRunChannelModelSyncAutoApplies(t *testing.T) {
+	useControllerTestModelSyncDB(t, []string{"gpt-4o", "gpt-4-turbo", "gpt-5"})
+	resetChannelProbeTestState(t)
+
+	insertControllerTestChannel(t, newModelSyncChannel(2, model.ChannelModelSyncModeAuto))
+
+	RunChannelModelSync()
+
+	if channel, _ := model.GetChannelById(2); channel.Models != "gpt-4o,gpt-4-turbo,gpt-5" {
+		t.Fatalf("expected new model to be applied automatically, got %q", channel.Models)
+	}
+	diffs, err := model.GetChannelModelSyncDiffs(model.ChannelModelSyncDiffApplied)
+	if err != nil || len(diffs) != 1 {
+		t.Fatalf("expected applied diff to be recorded, got %d, %v", len(diffs), err)
+	}
+}
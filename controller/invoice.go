@@ -5,6 +5,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/stmp"
+	"one-api/common/storage"
+	"one-api/common/utils"
+	"one-api/internal/invoice"
 	"one-api/model"
 	"time"
 )
@@ -108,3 +114,213 @@ func GetUserInvoiceDetail(c *gin.Context) {
 		"data":    invoices,
 	})
 }
+
+// buildUserInvoiceDocument 根据 statistics_months 中用户指定月份的数据生成账单，date 为当月一号
+func buildUserInvoiceDocument(user *model.User, date time.Time) (*invoice.Document, error) {
+	details, err := model.GetUserInvoiceDetail(&model.StatisticsMonthDetailSearchParams{
+		UserId: user.Id,
+		Date:   date.Format("2006-01-02"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(details) == 0 {
+		return nil, fmt.Errorf("no invoice data for %s", date.Format("2006-01"))
+	}
+
+	month := date.Format("2006-01")
+	customerName := user.DisplayName
+	if customerName == "" {
+		customerName = user.Username
+	}
+	document := &invoice.Document{
+		Number:   invoice.Number(month, user.Id),
+		Month:    month,
+		IssuedAt: time.Now(),
+		Company: invoice.Party{
+			Name:    config.InvoiceCompanyName,
+			Address: config.InvoiceCompanyAddress,
+			TaxId:   config.InvoiceCompanyTaxId,
+			Email:   config.InvoiceCompanyEmail,
+		},
+		Customer:     invoice.Party{Name: customerName, Email: user.Email},
+		QuotaPerUnit: config.QuotaPerUnit,
+		Notes:        config.InvoiceNotes,
+	}
+	if document.Company.Name == "" {
+		document.Company.Name = config.SystemName
+	}
+	for _, detail := range details {
+		document.Lines = append(document.Lines, invoice.Line{
+			ModelName:        detail.ModelName,
+			RequestCount:     detail.RequestCount,
+			PromptTokens:     detail.PromptTokens,
+			CompletionTokens: detail.CompletionTokens,
+			Quota:            detail.Quota,
+		})
+	}
+	return document, nil
+}
+
+func renderInvoiceDocument(document *invoice.Document, format string) ([]byte, string, error) {
+	switch format {
+	case model.InvoiceFormatCSV:
+		data, err := invoice.RenderCSV(document)
+		return data, "text/csv", err
+	case model.InvoiceFormatPDF:
+		data, err := invoice.RenderPDF(document)
+		return data, "application/pdf", err
+	default:
+		return nil, "", fmt.Errorf("unsupported invoice format: %s", format)
+	}
+}
+
+// invoiceDownloadExpire 账单文件临时下载地址的有效期
+const invoiceDownloadExpire = 10 * time.Minute
+
+// DownloadUserInvoice 下载用户指定月份的账单文件，format 为 pdf 或 csv
+func DownloadUserInvoice(c *gin.Context) {
+	invoiceTime, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("invalid date format"))
+		return
+	}
+	date := time.Date(invoiceTime.Year(), invoiceTime.Month(), 1, 0, 0, 0, 0, time.Local)
+	format := c.DefaultQuery("format", model.InvoiceFormatPDF)
+
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	// 已上传到对象存储的账单文件跳转到临时下载地址，失败时重新生成
+	if stored, err := model.GetInvoiceDocument(user.Id, date.Format("2006-01"), format); err == nil && stored.ObjectKey != "" {
+		url, err := storage.PresignPrivate(stored.Storage, stored.ObjectKey, invoiceDownloadExpire)
+		if err == nil {
+			c.Redirect(http.StatusFound, url)
+			return
+		}
+		logger.SysError(fmt.Sprintf("failed to presign invoice %s of user %d: %s", stored.Month, user.Id, err.Error()))
+	}
+	document, err := buildUserInvoiceDocument(user, date)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	data, contentType, err := renderInvoiceDocument(document, format)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", document.Number, format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// DeliverInvoice 管理员重新为指定月份生成账单文件并发送邮件，已发送过的用户会跳过
+func DeliverInvoice(c *gin.Context) {
+	invoiceTime, err := time.Parse("2006-01-02", c.Param("time"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("invalid time format"))
+		return
+	}
+	date := time.Date(invoiceTime.Year(), invoiceTime.Month(), 1, 0, 0, 0, 0, time.Local)
+	if !model.IsStatisticsMonthGenerated(date) {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("invoice the month data not generated"))
+		return
+	}
+	common.SafeGoroutine(func() {
+		deliverInvoices(date)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "账单发送任务提交成功，后台将自动生成账单文件并发送邮件",
+	})
+}
+
+// RunInvoiceDelivery 由定时任务在生成上个月的账单数据后调用
+func RunInvoiceDelivery() {
+	lastMonth := time.Now().AddDate(0, -1, 0)
+	date := time.Date(lastMonth.Year(), lastMonth.Month(), 1, 0, 0, 0, 0, time.Local)
+	if !model.IsStatisticsMonthGenerated(date) {
+		return
+	}
+	deliverInvoices(date)
+}
+
+func deliverInvoices(date time.Time) {
+	userIds, err := model.GetPendingInvoiceUserIds(date, config.InvoiceEmailEnabled)
+	if err != nil {
+		logger.SysError("failed to get pending invoice users: " + err.Error())
+		return
+	}
+	delivered := 0
+	for _, userId := range userIds {
+		if err := deliverUserInvoice(userId, date); err != nil {
+			logger.SysError(fmt.Sprintf("failed to deliver invoice %s of user %d: %s", date.Format("2006-01"), userId, err.Error()))
+			continue
+		}
+		delivered++
+	}
+	if delivered > 0 {
+		logger.SysLog(fmt.Sprintf("delivered %d invoices of %s", delivered, date.Format("2006-01")))
+	}
+}
+
+// deliverUserInvoice 生成 PDF 和 CSV 账单上传到对象存储，开启了账单邮件时发送给用户。
+// 已生成的账单文件不再重复上传，之前发送邮件失败时只重试发送邮件
+func deliverUserInvoice(userId int, date time.Time) error {
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return err
+	}
+	document, err := buildUserInvoiceDocument(user, date)
+	if err != nil {
+		return err
+	}
+	stored, err := model.GetInvoiceDocuments(userId, document.Month)
+	if err != nil {
+		return err
+	}
+	generated := make(map[string]bool, len(stored))
+	for _, item := range stored {
+		generated[item.Format] = true
+	}
+
+	links := make(map[string]string, len(model.InvoiceFormats))
+	attachments := make([]stmp.Attachment, 0, len(model.InvoiceFormats))
+	for _, format := range model.InvoiceFormats {
+		data, _, err := renderInvoiceDocument(document, format)
+		if err != nil {
+			return err
+		}
+		fileName := fmt.Sprintf("%s.%s", document.Number, format)
+		if !generated[format] {
+			// 账单只上传到对象存储，文件名带随机后缀，避免存储地址被猜到
+			objectKey := fmt.Sprintf("invoices/%s/%s-%s", document.Month, utils.GetUUID(), fileName)
+			driveName, err := storage.UploadPrivate(data, objectKey)
+			if err != nil {
+				// 不保存账单文件记录，下次投递时重试
+				return err
+			}
+			if driveName == "" {
+				objectKey = ""
+			}
+			if err := model.SaveInvoiceDocument(userId, document.Month, format, driveName, objectKey); err != nil {
+				return err
+			}
+		}
+		links[format] = fmt.Sprintf("%s/api/user/invoice/download?date=%s&format=%s", config.ServerAddress, date.Format("2006-01-02"), format)
+		attachments = append(attachments, stmp.Attachment{Name: fileName, Data: data})
+	}
+
+	if !config.InvoiceEmailEnabled || user.Email == "" {
+		return nil
+	}
+	_, _, amount := document.Total()
+	if err := stmp.SendInvoiceEmail(document.Customer.Name, user.Email, document.Month, fmt.Sprintf("$%.2f", amount), links, attachments...); err != nil {
+		return err
+	}
+	return model.MarkInvoiceDocumentsEmailed(userId, document.Month)
+}
@@ -10,6 +10,7 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/notify"
 	"one-api/common/utils"
 	"one-api/model"
 	"one-api/payment"
@@ -73,6 +74,7 @@ func CreateOrder(c *gin.Context) {
 		UserId:        userId,
 		GatewayId:     paymentService.Payment.ID,
 		TradeNo:       tradeNo,
+		GatewayNo:     payRequest.GatewayNo,
 		Amount:        orderReq.Amount,
 		OrderAmount:   payMoney,
 		OrderCurrency: paymentService.Payment.Currency,
@@ -136,7 +138,12 @@ func PaymentCallback(c *gin.Context) {
 	}
 
 	payNotify, err := paymentService.HandleCallback(c, paymentService.Payment.Config)
-	if err != nil {
+	if err != nil || payNotify == nil {
+		return
+	}
+
+	if payNotify.Refund != nil {
+		handleGatewayRefund(c, paymentService.Payment.ID, payNotify)
 		return
 	}
 
@@ -148,34 +155,299 @@ func PaymentCallback(c *gin.Context) {
 		logger.SysError(fmt.Sprintf("gateway callback failed to find order, trade_no: %s,", payNotify.TradeNo))
 		return
 	}
-	fmt.Println(order.Status, order.Status != model.OrderStatusPending)
 
-	if order.Status != model.OrderStatusPending {
+	if _, err := completePaidOrder(order, payNotify.GatewayNo, c.ClientIP()); err != nil {
+		logger.SysError(fmt.Sprintf("gateway callback failed to complete order, trade_no: %s, error: %s", payNotify.TradeNo, err.Error()))
+	}
+}
+
+// completePaidOrder 把待支付的订单标记为成功并发放额度或开通订阅。
+// 回调和对账都会调用，只有成功把订单从 pending 改为 success 的一方会发放额度。
+func completePaidOrder(order *model.Order, gatewayNo, ip string) (bool, error) {
+	if order.PlanId > 0 {
+		return completePlanOrder(order, gatewayNo)
+	}
+
+	completed, err := model.CompleteOrder(order, gatewayNo)
+	if err != nil || !completed {
+		return false, err
+	}
+
+	err = model.IncreaseUserQuota(order.UserId, order.Quota)
+	if err != nil {
+		return true, fmt.Errorf("failed to increase user quota: %w", err)
+	}
+
+	// Try to upgrade user group based on cumulative recharge amount
+	err = model.CheckAndUpgradeUserGroup(order.UserId, order.Quota)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to check and upgrade user group, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+	}
+
+	model.RecordQuotaLog(order.UserId, model.LogTypeTopup, order.Quota, ip, fmt.Sprintf("在线充值成功，充值积分: %d，支付金额：%.2f %s", order.Quota, order.OrderAmount, order.OrderCurrency))
+
+	if _, err := model.CreditAffCommission(order); err != nil {
+		logger.SysError(fmt.Sprintf("failed to credit aff commission, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+	}
+	return true, nil
+}
+
+// completePlanOrder 完成套餐订单并开通订阅，无法开通时订单标记为待退款并通知管理员
+func completePlanOrder(order *model.Order, gatewayNo string) (bool, error) {
+	plan, err := model.GetSubscriptionPlanById(order.PlanId)
+	if err != nil {
+		plan = nil
+	}
+
+	completed, err := model.CompletePlanOrder(order, gatewayNo, plan)
+	if errors.Is(err, model.ErrSubscriptionOrderNotActivated) {
+		notify.Send(fmt.Sprintf("订单 %s 需要退款", order.TradeNo), fmt.Sprintf("用户 #%d 的套餐订单 %s 已支付 %.2f %s，但无法开通订阅：%s。订单已标记为待退款，请在订单列表中退款", order.UserId, order.TradeNo, order.OrderAmount, order.OrderCurrency, err.Error()))
+	}
+	return completed, err
+}
+
+type OrderRefundRequest struct {
+	Money  float64 `json:"money"` // 0 表示退还剩余的全部金额
+	Reason string  `json:"reason"`
+}
+
+// RefundOrder 管理员通过原支付网关退款，并按比例扣回充值的额度
+func RefundOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	var req OrderRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
 		return
 	}
 
-	order.GatewayNo = payNotify.GatewayNo
-	order.Status = model.OrderStatusSuccess
-	err = order.Update()
+	order, err := model.GetOrderById(id)
 	if err != nil {
-		logger.SysError(fmt.Sprintf("gateway callback failed to update order, trade_no: %s,", payNotify.TradeNo))
+		common.APIRespondWithError(c, http.StatusOK, errors.New("订单不存在"))
 		return
 	}
+	LockOrder(order.TradeNo)
+	defer UnlockOrder(order.TradeNo)
 
-	err = model.IncreaseUserQuota(order.UserId, order.Quota)
+	// 加锁后重新读取，避免使用过期的退款金额
+	order, err = model.GetOrderById(id)
 	if err != nil {
-		logger.SysError(fmt.Sprintf("gateway callback failed to increase user quota, trade_no: %s,", payNotify.TradeNo))
+		common.APIRespondWithError(c, http.StatusOK, errors.New("订单不存在"))
+		return
+	}
+	if !isRefundableOrder(order) {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("只有支付成功的订单可以退款"))
 		return
 	}
+	originalStatus := order.Status
 
-	// Try to upgrade user group based on cumulative recharge amount
-	err = model.CheckAndUpgradeUserGroup(order.UserId, order.Quota)
+	money := utils.Decimal(req.Money, 2)
+	if money == 0 {
+		money = order.RefundableMoney()
+	}
+	if money <= 0 || money > order.RefundableMoney() {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrderRefundAmountInvalid)
+		return
+	}
+
+	paymentService, err := payment.NewPaymentServiceByID(order.GatewayId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if ok, err := order.UpdateStatus(originalStatus, model.OrderStatusRefunding); err != nil || !ok {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrderRefundConflict)
+		return
+	}
+
+	refundNo := utils.GenerateTradeNo()
+	result, err := paymentService.Refund(order, refundNo, money, req.Reason)
+	if err != nil {
+		if _, err := order.UpdateStatus(model.OrderStatusRefunding, originalStatus); err != nil {
+			logger.SysError(fmt.Sprintf("failed to restore order status, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		}
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("退款失败：%s", err.Error()))
+		return
+	}
+
+	refund := &model.OrderRefund{
+		RefundNo:        refundNo,
+		GatewayRefundNo: result.GatewayRefundNo,
+		Money:           money,
+		Source:          model.OrderRefundSourceAdmin,
+		Reason:          req.Reason,
+	}
+	if result.Status == types.RefundStatusProcessing {
+		// 退款结果由网关异步通知或对账时查询确认，确认前订单保持退款中
+		if err := model.CreateProcessingOrderRefund(order, refund, originalStatus); err != nil {
+			logger.SysError(fmt.Sprintf("gateway accepted refund but failed to record it, trade_no: %s, refund_no: %s, error: %s", order.TradeNo, refundNo, err.Error()))
+			common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("网关已受理退款，但记录退款失败：%s", err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "退款处理中，网关确认后扣回额度",
+			"data":    refund,
+		})
+		return
+	}
+	if err := applyOrderRefund(order, refund, originalStatus, c.ClientIP()); err != nil {
+		// 网关已经退款，本地记录失败时需要人工处理
+		logger.SysError(fmt.Sprintf("gateway refunded but failed to record refund, trade_no: %s, refund_no: %s, error: %s", order.TradeNo, refundNo, err.Error()))
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("网关已退款，但记录退款失败：%s", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    refund,
+	})
+}
+
+func GetOrderRefunds(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	refunds, err := model.GetOrderRefunds(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    refunds,
+	})
+}
+
+// handleGatewayRefund 处理网关通知的退款或拒付，重复通知按网关编号去重
+func handleGatewayRefund(c *gin.Context, gatewayId int, payNotify *types.PayNotify) {
+	order, err := model.GetOrderByGatewayNo(gatewayId, payNotify.GatewayNo)
 	if err != nil {
-		logger.SysError(fmt.Sprintf("failed to check and upgrade user group, trade_no: %s, error: %s", payNotify.TradeNo, err.Error()))
+		logger.SysError(fmt.Sprintf("gateway refund notify failed to find order, gateway_no: %s", payNotify.GatewayNo))
+		return
 	}
+	LockOrder(order.TradeNo)
+	defer UnlockOrder(order.TradeNo)
 
-	model.RecordQuotaLog(order.UserId, model.LogTypeTopup, order.Quota, c.ClientIP(), fmt.Sprintf("在线充值成功，充值积分: %d，支付金额：%.2f %s", order.Quota, order.OrderAmount, order.OrderCurrency))
+	order, err = model.GetOrderById(order.ID)
+	if err != nil {
+		return
+	}
+	if refund, err := model.GetProcessingOrderRefund(payNotify.Refund.RefundNo); err == nil && refund.OrderId == order.ID {
+		if err := settleProcessingRefund(order, refund, payNotify.Refund.Status, c.ClientIP()); err != nil && !errors.Is(err, model.ErrOrderRefundDuplicated) {
+			logger.SysError(fmt.Sprintf("gateway refund notify failed, trade_no: %s, refund_no: %s, error: %s", order.TradeNo, refund.RefundNo, err.Error()))
+		}
+		return
+	}
+	if payNotify.Refund.Status == types.RefundStatusFailed {
+		logger.SysLog(fmt.Sprintf("gateway refund failure notify ignored, trade_no: %s, refund_no: %s", order.TradeNo, payNotify.Refund.RefundNo))
+		return
+	}
+	// 退款中的订单发生拒付时，拒付取代未完成的退款，由拒付扣回额度
+	var superseded []*model.OrderRefund
+	if payNotify.Refund.Chargeback && order.Status == model.OrderStatusRefunding {
+		superseded, err = model.CancelProcessingOrderRefunds(order)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("gateway chargeback failed to cancel processing refunds, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+			return
+		}
+	}
+	if !isRefundableOrder(order) {
+		logger.SysLog(fmt.Sprintf("gateway refund notify ignored, trade_no: %s, status: %s", order.TradeNo, order.Status))
+		return
+	}
+
+	// 部分退款后发生的拒付，只扣回剩余部分
+	money := payNotify.Refund.Amount
+	if money == 0 || money > order.RefundableMoney() {
+		money = order.RefundableMoney()
+	}
+	source := model.OrderRefundSourceAdmin
+	if payNotify.Refund.Chargeback {
+		source = model.OrderRefundSourceChargeback
+	}
+	refund := &model.OrderRefund{
+		RefundNo: payNotify.Refund.RefundNo,
+		Money:    money,
+		Source:   source,
+		Reason:   payNotify.Refund.Reason,
+	}
+	if err := applyOrderRefund(order, refund, order.Status, c.ClientIP()); err != nil {
+		if !errors.Is(err, model.ErrOrderRefundDuplicated) {
+			logger.SysError(fmt.Sprintf("gateway refund notify failed, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		}
+		return
+	}
+
+	if payNotify.Refund.Chargeback {
+		content := fmt.Sprintf("用户 #%d 的订单 %s 发生拒付，原因：%s，金额：%.2f %s，已扣回积分 %d", order.UserId, order.TradeNo, refund.Reason, refund.Money, order.OrderCurrency, refund.Quota)
+		for _, processing := range superseded {
+			content += fmt.Sprintf("；处理中的退款 %s（%.2f %s）已作废，请在网关确认该退款未重复退还", processing.RefundNo, processing.Money, order.OrderCurrency)
+		}
+		notify.Send(fmt.Sprintf("订单 %s 发生拒付", order.TradeNo), content)
+	}
+}
+
+// settleProcessingRefund 网关确认处理中退款的结果：成功时扣回额度，失败时恢复订单并通知管理员
+func settleProcessingRefund(order *model.Order, refund *model.OrderRefund, status types.RefundStatus, ip string) error {
+	switch status {
+	case types.RefundStatusProcessing:
+		return nil
+	case types.RefundStatusFailed:
+		if err := model.FailOrderRefund(order, refund); err != nil {
+			return err
+		}
+		notify.Send(fmt.Sprintf("订单 %s 退款失败", order.TradeNo), fmt.Sprintf("用户 #%d 的订单 %s 退款 %.2f %s 失败，退款单号：%s，订单已恢复，请确认后重新处理", order.UserId, order.TradeNo, refund.Money, order.OrderCurrency, refund.RefundNo))
+		return nil
+	default:
+		return applyOrderRefund(order, refund, refund.PreviousStatus, ip)
+	}
+}
+
+// applyOrderRefund 记录退款、扣回额度，并写入一条负数的充值日志。
+// 部分退款后订单恢复为退款前的状态，待退款的订单仍保持待退款。
+func applyOrderRefund(order *model.Order, refund *model.OrderRefund, originalStatus model.OrderStatus, ip string) error {
+	if err := model.RefundOrder(order, refund); err != nil {
+		return err
+	}
+	if originalStatus == model.OrderStatusRefundPending && order.Status == model.OrderStatusSuccess {
+		if _, err := order.UpdateStatus(model.OrderStatusSuccess, model.OrderStatusRefundPending); err != nil {
+			logger.SysError(fmt.Sprintf("failed to restore order status, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		}
+	}
+
+	content := fmt.Sprintf("订单退款，扣回积分: %d，退款金额：%.2f %s，订单号：%s", refund.Quota, refund.Money, order.OrderCurrency, order.TradeNo)
+	if refund.Source == model.OrderRefundSourceChargeback {
+		content = fmt.Sprintf("订单拒付，扣回积分: %d，拒付金额：%.2f %s，订单号：%s", refund.Quota, refund.Money, order.OrderCurrency, order.TradeNo)
+	}
+	if order.PlanId > 0 {
+		if order.Status == model.OrderStatusRefunded {
+			content += "，订阅已取消"
+		}
+		if refund.Quota == 0 {
+			model.RecordLog(order.UserId, model.LogTypeTopup, content)
+			return nil
+		}
+	}
+	model.RecordQuotaLog(order.UserId, model.LogTypeTopup, -refund.Quota, ip, content)
+
+	if _, err := model.ReverseAffCommission(order, refund.Quota); err != nil {
+		logger.SysError(fmt.Sprintf("failed to reverse aff commission, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+	}
+	return nil
+}
 
+// isRefundableOrder 支付成功或等待退款的订单可以退款
+func isRefundableOrder(order *model.Order) bool {
+	return order.Status == model.OrderStatusSuccess || order.Status == model.OrderStatusRefundPending
 }
 
 func CheckOrderStatus(c *gin.Context) {
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/notify"
+	"one-api/model"
+	"one-api/payment"
+	"one-api/payment/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 给网关回调留出的时间，创建不久的订单不查询
+	orderReconcileGracePeriod = time.Minute
+	// 和 CloseUnfinishedOrder 一致，超过 3 小时仍未支付的订单关闭
+	orderReconcileExpiry    = 3 * time.Hour
+	orderReconcileBatchSize = 200
+)
+
+var (
+	orderReconcileLastRun atomic.Int64
+	orderReconcileRunning atomic.Bool
+	// 上次检查已关闭订单的时间，首次运行检查最近一天关闭的订单。
+	// 先检查已关闭的订单，本次对账关闭的订单留到下次检查
+	orderReconcileClosedSince atomic.Int64
+)
+
+var errOrderReconcileRunning = errors.New("对账正在进行中")
+
+type OrderReconcileReport struct {
+	Checked    int      `json:"checked"`
+	Completed  int      `json:"completed"`
+	Closed     int      `json:"closed"`
+	Refunded   int      `json:"refunded"` // 确认结果的处理中退款
+	Failed     int      `json:"failed"`
+	Mismatches []string `json:"mismatches"`
+}
+
+// RunScheduledOrderReconciliation 定时对账，实际执行间隔由 PaymentReconcileInterval 控制
+func RunScheduledOrderReconciliation() {
+	if !config.PaymentReconcileEnabled {
+		return
+	}
+
+	interval := time.Duration(max(config.PaymentReconcileInterval, 1)) * time.Minute
+	now := time.Now()
+	if last := orderReconcileLastRun.Load(); last > 0 && now.Sub(time.Unix(last, 0)) < interval {
+		return
+	}
+	orderReconcileLastRun.Store(now.Unix())
+
+	if _, err := reconcileOrders(now); err != nil {
+		logger.SysLog("skip order reconciliation: " + err.Error())
+	}
+}
+
+// ReconcileOrders 管理员手动触发一次对账
+func ReconcileOrders(c *gin.Context) {
+	report, err := reconcileOrders(time.Now())
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    report,
+	})
+}
+
+// reconcileOrders 向网关查询待支付订单的状态，已支付的补发额度，已关闭或超时的关闭。
+// 金额不一致、已关闭但网关显示已支付的订单不会自动处理，只通知管理员。
+func reconcileOrders(now time.Time) (*OrderReconcileReport, error) {
+	if !orderReconcileRunning.CompareAndSwap(false, true) {
+		return nil, errOrderReconcileRunning
+	}
+	defer orderReconcileRunning.Store(false)
+
+	report := &OrderReconcileReport{Mismatches: []string{}}
+	services := make(map[int]*payment.PaymentService)
+	getService := func(gatewayId int) *payment.PaymentService {
+		service, ok := services[gatewayId]
+		if !ok {
+			service, _ = payment.NewPaymentServiceByID(gatewayId)
+			if service != nil && !service.CanQuery() {
+				service = nil
+			}
+			services[gatewayId] = service
+		}
+		return service
+	}
+
+	closedSince := orderReconcileClosedSince.Load()
+	if closedSince == 0 {
+		closedSince = now.AddDate(0, 0, -1).Unix()
+	}
+	orderReconcileClosedSince.Store(now.Unix())
+	closed, err := model.GetClosedOrdersSince(closedSince, orderReconcileBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range closed {
+		service := getService(order.GatewayId)
+		if service == nil {
+			continue
+		}
+		report.Checked++
+		result, err := service.Query(order)
+		if err != nil {
+			report.Failed++
+			continue
+		}
+		if result.Status == types.QueryStatusPaid {
+			if ok, err := order.MarkMismatchNotified(); err != nil || !ok {
+				continue
+			}
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf("订单 %s（用户 #%d）已关闭，但网关显示已支付 %.2f %s", order.TradeNo, order.UserId, result.Money, order.OrderCurrency))
+		}
+	}
+
+	refunds, err := model.GetProcessingOrderRefunds(now.Add(-orderReconcileGracePeriod).Unix(), orderReconcileBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, refund := range refunds {
+		reconcileProcessingRefund(refund, report)
+	}
+
+	pending, err := model.GetPendingOrdersBefore(now.Add(-orderReconcileGracePeriod).Unix(), orderReconcileBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range pending {
+		service := getService(order.GatewayId)
+		if service == nil {
+			continue
+		}
+		reconcilePendingOrder(service, order, now, report)
+	}
+
+	if report.Completed > 0 || report.Closed > 0 || report.Refunded > 0 || len(report.Mismatches) > 0 {
+		logger.SysLog(fmt.Sprintf("order reconciliation: checked %d, completed %d, closed %d, refunded %d, failed %d, mismatches %d", report.Checked, report.Completed, report.Closed, report.Refunded, report.Failed, len(report.Mismatches)))
+	}
+	if len(report.Mismatches) > 0 {
+		notify.Send("支付对账发现异常订单", strings.Join(report.Mismatches, "\n"))
+	}
+	return report, nil
+}
+
+func reconcilePendingOrder(service *payment.PaymentService, order *model.Order, now time.Time, report *OrderReconcileReport) {
+	report.Checked++
+	result, err := service.Query(order)
+	if err != nil {
+		report.Failed++
+		logger.SysError(fmt.Sprintf("order reconciliation failed to query order, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		return
+	}
+
+	switch result.Status {
+	case types.QueryStatusPaid:
+		if result.Money > 0 && math.Abs(result.Money-order.OrderAmount) >= 0.01 {
+			// 订单保持待支付，每次对账都会查到，只通知一次
+			if ok, err := order.MarkMismatchNotified(); err != nil || !ok {
+				return
+			}
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf("订单 %s（用户 #%d）网关支付金额 %.2f 与订单金额 %.2f %s 不一致", order.TradeNo, order.UserId, result.Money, order.OrderAmount, order.OrderCurrency))
+			return
+		}
+		LockOrder(order.TradeNo)
+		defer UnlockOrder(order.TradeNo)
+		completed, err := completePaidOrder(order, result.GatewayNo, "")
+		if err != nil {
+			report.Failed++
+			logger.SysError(fmt.Sprintf("order reconciliation failed to complete order, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+			return
+		}
+		if completed {
+			report.Completed++
+			logger.SysLog(fmt.Sprintf("order reconciliation completed order missed by callback, trade_no: %s", order.TradeNo))
+		}
+	case types.QueryStatusClosed:
+		if ok, _ := order.UpdateStatus(model.OrderStatusPending, model.OrderStatusClosed); ok {
+			report.Closed++
+		}
+	default:
+		if now.Sub(time.Unix(int64(order.CreatedAt), 0)) < orderReconcileExpiry {
+			return
+		}
+		if ok, _ := order.UpdateStatus(model.OrderStatusPending, model.OrderStatusClosed); ok {
+			report.Closed++
+		}
+	}
+}
+
+// reconcileProcessingRefund 查询处理中退款的结果，补上丢失的退款通知
+func reconcileProcessingRefund(refund *model.OrderRefund, report *OrderReconcileReport) {
+	order, err := model.GetOrderById(refund.OrderId)
+	if err != nil {
+		return
+	}
+	service, err := payment.NewPaymentServiceByID(order.GatewayId)
+	if err != nil || !service.CanQueryRefund() {
+		return
+	}
+
+	report.Checked++
+	result, err := service.QueryRefund(order, refund)
+	if err != nil {
+		report.Failed++
+		logger.SysError(fmt.Sprintf("order reconciliation failed to query refund, trade_no: %s, refund_no: %s, error: %s", order.TradeNo, refund.RefundNo, err.Error()))
+		return
+	}
+	if result.Status == types.RefundStatusProcessing {
+		return
+	}
+
+	LockOrder(order.TradeNo)
+	defer UnlockOrder(order.TradeNo)
+	if err := settleProcessingRefund(order, refund, result.Status, ""); err != nil {
+		if !errors.Is(err, model.ErrOrderRefundDuplicated) {
+			report.Failed++
+			logger.SysError(fmt.Sprintf("order reconciliation failed to settle refund, trade_no: %s, refund_no: %s, error: %s", order.TradeNo, refund.RefundNo, err.Error()))
+		}
+		return
+	}
+	report.Refunded++
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/model"
+	"one-api/payment"
+	"one-api/payment/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type reconcileTestGateway struct {
+	results map[string]*types.QueryResult
+}
+
+func (g *reconcileTestGateway) Name() string { return "reconcile-test" }
+
+func (g *reconcileTestGateway) Pay(*types.PayConfig, string) (*types.PayRequest, error) {
+	return nil, nil
+}
+
+func (g *reconcileTestGateway) CreatedPay(string, *model.Payment) error { return nil }
+
+func (g *reconcileTestGateway) HandleCallback(*gin.Context, string) (*types.PayNotify, error) {
+	return nil, nil
+}
+
+func (g *reconcileTestGateway) Query(config *types.QueryConfig, _ string) (*types.QueryResult, error) {
+	if result, ok := g.results[config.TradeNo]; ok {
+		return result, nil
+	}
+	return &types.QueryResult{Status: types.QueryStatusPending}, nil
+}
+
+func useOrderReconcileTestDB(t *testing.T, gateway *reconcileTestGateway) {
+	t.Helper()
+
+	logger.Logger = zap.NewNop()
+	originalRedis := config.RedisEnabled
+	config.RedisEnabled = false
+
+	originalDB := model.DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&model.Payment{}, &model.Order{}, &model.OrderRefund{}, &model.User{}, &model.UserGroup{}, &model.Log{}); err != nil {
+		t.Fatalf("expected order schema migration for test database, got %v", err)
+	}
+	model.DB = testDB
+
+	payment.Gateways["reconcile-test"] = gateway
+	orderReconcileClosedSince.Store(0)
+	t.Cleanup(func() {
+		model.DB = originalDB
+		config.RedisEnabled = originalRedis
+		delete(payment.Gateways, "reconcile-test")
+	})
+
+	if err := testDB.Create(&model.Payment{ID: 1, Type: "reconcile-test", UUID: "reconcile", Name: "test"}).Error; err != nil {
+		t.Fatalf("expected payment fixture to persist, got %v", err)
+	}
+	if err := testDB.Create(&model.User{Id: 1, Username: "buyer", Quota: 0}).Error; err != nil {
+		t.Fatalf("expected user fixture to persist, got %v", err)
+	}
+}
+
+func insertReconcileTestOrder(t *testing.T, tradeNo string, status model.OrderStatus, createdAt time.Time) *model.Order {
+	t.Helper()
+	order := &model.Order{
+		UserId:        1,
+		GatewayId:     1,
+		TradeNo:       tradeNo,
+		Amount:        10,
+		OrderAmount:   10,
+		OrderCurrency: model.CurrencyTypeUSD,
+		Quota:         5000,
+		Status:        status,
+	}
+	if err := model.DB.Create(order).Error; err != nil {
+		t.Fatalf("expected order fixture to persist, got %v", err)
+	}
+	model.DB.Model(order).UpdateColumn("created_at", createdAt.Unix())
+	return order
+}
+
+func TestReconcileOrdersCompletesAndClosesPendingOrders(t *testing.T) {
+	gateway := &reconcileTestGateway{results: map[string]*types.QueryResult{
+		"paid":   {Status: types.QueryStatusPaid, GatewayNo: "gw-paid", Money: 10},
+		"closed": {Status: types.QueryStatusClosed},
+	}}
+	useOrderReconcileTestDB(t, gateway)
+
+	now := time.Now()
+	insertReconcileTestOrder(t, "paid", model.OrderStatusPending, now.Add(-10*time.Minute))
+	insertReconcileTestOrder(t, "closed", model.OrderStatusPending, now.Add(-10*time.Minute))
+	insertReconcileTestOrder(t, "expired", model.OrderStatusPending, now.Add(-4*time.Hour))
+	insertReconcileTestOrder(t, "waiting", model.OrderStatusPending, now.Add(-10*time.Minute))
+	insertReconcileTestOrder(t, "fresh", model.OrderStatusPending, now)
+
+	report, err := reconcileOrders(now)
+	if err != nil {
+		t.Fatalf("expected reconciliation to succeed, got %v", err)
+	}
+	if report.Checked != 4 || report.Completed != 1 || report.Closed != 2 || len(report.Mismatches) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	paid, _ := model.GetOrderByTradeNo("paid")
+	if paid.Status != model.OrderStatusSuccess || paid.GatewayNo != "gw-paid" {
+		t.Fatalf("expected paid order to be completed, got status=%s gateway_no=%s", paid.Status, paid.GatewayNo)
+	}
+	for tradeNo, status := range map[string]model.OrderStatus{
+		"closed":  model.OrderStatusClosed,
+		"expired": model.OrderStatusClosed,
+		"waiting": model.OrderStatusPending,
+		"fresh":   model.OrderStatusPending,
+	} {
+		order, _ := model.GetOrderByTradeNo(tradeNo)
+		if order.Status != status {
+			t.Fatalf("expected order %s to be %s, got %s", tradeNo, status, order.Status)
+		}
+	}
+
+	user, _ := model.GetUserById(1, false)
+	if user.Quota != 5000 {
+		t.Fatalf("expected quota to be credited once, got %d", user.Quota)
+	}
+
+	// 再次对账不会重复入账
+	if _, err := reconcileOrders(now.Add(time.Minute)); err != nil {
+		t.Fatalf("expected second reconciliation to succeed, got %v", err)
+	}
+	user, _ = model.GetUserById(1, false)
+	if user.Quota != 5000 {
+		t.Fatalf("expected quota to stay unchanged, got %d", user.Quota)
+	}
+}
+
+func TestReconcileOrdersReportsMismatches(t *testing.T) {
+	gateway := &reconcileTestGateway{results: map[string]*types.QueryResult{
+		"underpaid":   {Status: types.QueryStatusPaid, Money: 1},
+		"closed-paid": {Status: types.QueryStatusPaid, Money: 10},
+	}}
+	useOrderReconcileTestDB(t, gateway)
+
+	now := time.Now()
+	insertReconcileTestOrder(t, "underpaid", model.OrderStatusPending, now.Add(-10*time.Minute))
+	insertReconcileTestOrder(t, "closed-paid", model.OrderStatusClosed, now.Add(-time.Hour))
+
+	report, err := reconcileOrders(now)
+	if err != nil {
+		t.Fatalf("expected reconciliation to succeed, got %v", err)
+	}
+	if report.Completed != 0 || len(report.Mismatches) != 2 {
+		t.Fatalf("expected both orders to be reported without completion, got %+v", report)
+	}
+
+	for _, tradeNo := range []string{"underpaid", "closed-paid"} {
+		order, _ := model.GetOrderByTradeNo(tradeNo)
+		if order.Status == model.OrderStatusSuccess {
+			t.Fatalf("expected mismatched order %s not to be completed", tradeNo)
+		}
+	}
+	user, _ := model.GetUserById(1, false)
+	if user.Quota != 0 {
+		t.Fatalf("expected mismatched orders not to credit quota, got %d", user.Quota)
+	}
+
+	// 异常订单再次对账不重复通知
+	report, err = reconcileOrders(now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("expected second reconciliation to succeed, got %v", err)
+	}
+	if len(report.Mismatches) != 0 {
+		t.Fatalf("expected mismatch to be notified only once, got %+v", report.Mismatches)
+	}
+	underpaid, _ := model.GetOrderByTradeNo("underpaid")
+	if underpaid.Status != model.OrderStatusPending || !underpaid.MismatchNotified {
+		t.Fatalf("expected underpaid order to stay pending and be marked notified, got %+v", underpaid)
+	}
+}
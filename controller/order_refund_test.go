@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"one-api/model"
+	"one-api/payment/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandleGatewayChargebackFinalizesRefundingOrder(t *testing.T) {
+	useOrderReconcileTestDB(t, &reconcileTestGateway{})
+
+	order := insertReconcileTestOrder(t, "refunding", model.OrderStatusRefunding, time.Now())
+	model.DB.Model(order).Update("gateway_no", "gw-refunding")
+	model.DB.Model(&model.User{}).Where("id = ?", 1).Update("quota", 5000)
+
+	// 网关已受理但尚未完成的退款，额度还未扣回
+	processing := &model.OrderRefund{RefundNo: "R1", Money: 10, Source: model.OrderRefundSourceAdmin}
+	if err := model.CreateProcessingOrderRefund(order, processing, model.OrderStatusSuccess); err != nil {
+		t.Fatalf("expected processing refund fixture, got %v", err)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/api/payment/notify", nil)
+	handleGatewayRefund(c, 1, &types.PayNotify{
+		GatewayNo: "gw-refunding",
+		Refund:    &types.RefundNotify{RefundNo: "dispute-1", Chargeback: true, Reason: "fraudulent"},
+	})
+
+	stored, _ := model.GetOrderById(order.ID)
+	if stored.Status != model.OrderStatusRefunded || stored.RefundQuota != 5000 || stored.RefundAmount != 10 {
+		t.Fatalf("expected chargeback to finalize the order, got %+v", stored)
+	}
+	user, _ := model.GetUserById(1, false)
+	if user.Quota != 0 {
+		t.Fatalf("expected chargeback to reverse the quota, got %d", user.Quota)
+	}
+	refunds, _ := model.GetOrderRefunds(order.ID)
+	if len(refunds) != 2 || refunds[0].Source != model.OrderRefundSourceChargeback || refunds[1].Status != model.OrderRefundStatusFailed {
+		t.Fatalf("expected the processing refund to be superseded by the chargeback, got %+v", refunds)
+	}
+
+	// 被取代的退款随后成功也不会再次扣回
+	handleGatewayRefund(c, 1, &types.PayNotify{
+		GatewayNo: "gw-refunding",
+		Refund:    &types.RefundNotify{RefundNo: "R1"},
+	})
+	user, _ = model.GetUserById(1, false)
+	if user.Quota != 0 {
+		t.Fatalf("expected superseded refund not to reverse quota again, got %d", user.Quota)
+	}
+}
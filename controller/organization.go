@@ -0,0 +1,461 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/stmp"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getOrganizationMember 返回当前用户在路径参数 id 对应组织中的成员信息
+func getOrganizationMember(c *gin.Context, manager bool) (*model.Organization, *model.OrganizationMember, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, nil, err
+	}
+	organization, err := model.GetOrganizationById(id)
+	if err != nil {
+		return nil, nil, errors.New("组织不存在")
+	}
+	var member *model.OrganizationMember
+	if manager {
+		member, err = model.GetOrganizationManager(id, c.GetInt("id"))
+	} else {
+		member, err = model.GetOrganizationMember(id, c.GetInt("id"))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return organization, member, nil
+}
+
+func GetUserOrganizations(c *gin.Context) {
+	organizations, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organizations,
+	})
+}
+
+type organizationRequest struct {
+	Name string `json:"name"`
+}
+
+func CreateOrganization(c *gin.Context) {
+	var request organizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	organization, err := model.CreateOrganization(request.Name, c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organization,
+	})
+}
+
+func GetOrganization(c *gin.Context) {
+	organization, member, err := getOrganizationMember(c, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": &model.OrganizationWithRole{
+			Organization: *organization,
+			Role:         member.Role,
+			QuotaLimit:   member.QuotaLimit,
+			MemberUsed:   member.UsedQuota,
+		},
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	organization, _, err := getOrganizationMember(c, true)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	var request organizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := organization.UpdateName(request.Name); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organization,
+	})
+}
+
+// DeleteOrganization 只有所有者可以删除组织，剩余额度退回所有者
+func DeleteOrganization(c *gin.Context) {
+	organization, member, err := getOrganizationMember(c, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if member.Role != model.OrganizationRoleOwner {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermissionDenied)
+		return
+	}
+	refunded, err := model.DeleteOrganization(organization)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if refunded > 0 {
+		model.RecordQuotaLog(organization.OwnerId, model.LogTypeManage, refunded, c.ClientIP(),
+			fmt.Sprintf("删除组织「%s」，退回额度 %s", organization.Name, common.LogQuota(refunded)))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	organization, _, err := getOrganizationMember(c, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	members, err := model.GetOrganizationMembers(organization.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    members,
+	})
+}
+
+type organizationMemberRequest struct {
+	UserId     int    `json:"user_id"`
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+	ResetUsed  bool   `json:"reset_used"`
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	_, operator, err := getOrganizationMember(c, true)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	var request organizationMemberRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	member, err := model.UpdateOrganizationMember(operator, request.UserId, request.Role, request.QuotaLimit, request.ResetUsed)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    member,
+	})
+}
+
+func RemoveOrganizationMember(c *gin.Context) {
+	_, operator, err := getOrganizationMember(c, true)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.RemoveOrganizationMember(operator, userId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// LeaveOrganization 成员主动退出组织，所有者需要先删除组织
+func LeaveOrganization(c *gin.Context) {
+	_, member, err := getOrganizationMember(c, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.RemoveOrganizationMember(member, member.UserId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type organizationQuotaRequest struct {
+	Quota  int    `json:"quota"`
+	Remark string `json:"remark"`
+}
+
+// TransferOrganizationQuota 成员将个人额度转入组织钱包
+func TransferOrganizationQuota(c *gin.Context) {
+	organization, member, err := getOrganizationMember(c, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	var request organizationQuotaRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.TransferQuotaToOrganization(organization.Id, member.UserId, request.Quota); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	model.RecordQuotaLog(member.UserId, model.LogTypeManage, -request.Quota, c.ClientIP(),
+		fmt.Sprintf("转入组织「%s」额度 %s", organization.Name, common.LogQuota(request.Quota)))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetOrganizationInvitations(c *gin.Context) {
+	organization, _, err := getOrganizationMember(c, true)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	invitations, err := model.GetOrganizationInvitations(organization.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invitations,
+	})
+}
+
+type organizationInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// CreateOrganizationInvitation 创建邀请，填写邮箱时只有该邮箱的用户可以接受，并发送邀请邮件
+func CreateOrganizationInvitation(c *gin.Context) {
+	organization, operator, err := getOrganizationMember(c, true)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	var request organizationInvitationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if request.Role == model.OrganizationRoleAdmin && operator.Role != model.OrganizationRoleOwner {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermissionDenied)
+		return
+	}
+	invitation, err := model.CreateOrganizationInvitation(organization.Id, operator.UserId, request.Email, request.Role)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if invitation.Email != "" {
+		inviterName := c.GetString("username")
+		link := fmt.Sprintf("%s/panel/organization?invitation=%s", config.ServerAddress, invitation.Code)
+		if err := stmp.SendOrganizationInvitationEmail(invitation.Email, organization.Name, inviterName, link, model.OrganizationInvitationValidDays); err != nil {
+			logger.SysError("send organization invitation email failed: " + err.Error())
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invitation,
+	})
+}
+
+func RevokeOrganizationInvitation(c *gin.Context) {
+	organization, _, err := getOrganizationMember(c, true)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	invitationId, err := strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.RevokeOrganizationInvitation(organization.Id, invitationId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type acceptOrganizationInvitationRequest struct {
+	Code string `json:"code"`
+}
+
+func AcceptOrganizationInvitation(c *gin.Context) {
+	var request acceptOrganizationInvitationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	member, err := model.AcceptOrganizationInvitation(request.Code, user)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    member,
+	})
+}
+
+// GetOrganizationStatistics 组织用量统计，默认为最近 30 天按天汇总
+func GetOrganizationStatistics(c *gin.Context) {
+	organization, _, err := getOrganizationMember(c, true)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	now := time.Now()
+	startDate := c.DefaultQuery("start", now.AddDate(0, 0, -29).Format("2006-01-02"))
+	endDate := c.DefaultQuery("end", now.Format("2006-01-02"))
+	groupBy := c.DefaultQuery("group_by", "date")
+
+	summaries, err := model.GetOrganizationUsage(organization.Id, startDate, endDate, groupBy)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    summaries,
+	})
+}
+
+// GetOrganizationInvoice 组织的月度账单，默认为当月
+func GetOrganizationInvoice(c *gin.Context) {
+	organization, _, err := getOrganizationMember(c, true)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	invoice, err := model.GetOrganizationInvoice(organization, c.DefaultQuery("month", time.Now().Format("2006-01")))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invoice,
+	})
+}
+
+func GetOrganizationsList(c *gin.Context) {
+	var params model.SearchOrganizationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	organizations, err := model.GetOrganizationsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organizations,
+	})
+}
+
+// AdjustOrganizationQuota 管理员增减组织额度
+func AdjustOrganizationQuota(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	organization, err := model.GetOrganizationById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("组织不存在"))
+		return
+	}
+	var request organizationQuotaRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if request.Quota == 0 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("不能为0"))
+		return
+	}
+	if err := model.AdjustOrganizationQuota(organization.Id, request.Quota); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	remark := fmt.Sprintf("管理员增减组织「%s」额度 %s", organization.Name, common.LogQuota(request.Quota))
+	if request.Remark != "" {
+		remark = fmt.Sprintf("%s, 备注: %s", remark, request.Remark)
+	}
+	model.RecordQuotaLog(organization.OwnerId, model.LogTypeManage, 0, c.ClientIP(), remark)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
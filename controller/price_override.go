@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetPriceOverrides(c *gin.Context) {
+	var params model.SearchPriceOverrideParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	overrides, err := model.GetPriceOverridesList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    overrides,
+	})
+}
+
+func GetPriceOverrideById(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	override, err := model.GetPriceOverrideById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    override,
+	})
+}
+
+func AddPriceOverride(c *gin.Context) {
+	override := model.PriceOverride{}
+	if err := c.ShouldBindJSON(&override); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	override.Id = 0
+
+	if err := override.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    override,
+	})
+}
+
+func UpdatePriceOverride(c *gin.Context) {
+	override := model.PriceOverride{}
+	if err := c.ShouldBindJSON(&override); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if override.Id == 0 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("id is required"))
+		return
+	}
+
+	if err := override.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    override,
+	})
+}
+
+func DeletePriceOverride(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	override := model.PriceOverride{Id: id}
+
+	if err := override.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"net/url"
 	"one-api/common"
 	"one-api/model"
+	"strconv"
+	"time"
 
 	"github.com/spf13/viper"
 
@@ -22,6 +24,21 @@ func GetPricesList(c *gin.Context) {
 		return
 	}
 
+	if pricesType == "db" {
+		// 附带当前时段的价格调整。默认返回基础价格，管理后台编辑时依赖这一点；
+		// personal=true 时登录用户看到应用了协议价的价格
+		personal := c.Query("personal") == "true"
+		userId := c.GetInt("id")
+		group := c.GetString("group")
+		now := time.Now()
+		for i, price := range prices {
+			if personal && userId > 0 {
+				price, _ = model.GlobalPriceOverrides.ResolvePrice(price, price.Model, userId, group)
+			}
+			prices[i] = price.WithTimeModifier(now)
+		}
+	}
+
 	if pricesType == "old" {
 		c.JSON(http.StatusOK, prices)
 	} else {
@@ -205,3 +222,103 @@ func GetUpdatePriceService(c *gin.Context) {
 		"message": "",
 	})
 }
+
+func GetPriceHistory(c *gin.Context) {
+	modelName := c.Param("model")
+	if modelName == "" || len(modelName) < 2 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("model name is required"))
+		return
+	}
+	modelName = modelName[1:]
+	modelName, _ = url.PathUnescape(modelName)
+
+	versions, err := model.GetPriceHistory(modelName)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    versions,
+	})
+}
+
+// GetPriceVersion 按消费日志中的 price_version_id 查询计费时使用的价格
+func GetPriceVersion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	version, err := model.GetPriceVersionById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    version,
+	})
+}
+
+func GetScheduledPrices(c *gin.Context) {
+	versions, err := model.GetScheduledPrices()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    versions,
+	})
+}
+
+type SchedulePriceRequest struct {
+	model.Price
+	EffectiveFrom int64 `json:"effective_from" binding:"required"`
+}
+
+func SchedulePrice(c *gin.Context) {
+	var request SchedulePriceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	version, err := model.SchedulePrice(&request.Price, request.EffectiveFrom)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    version,
+	})
+}
+
+func CancelScheduledPrice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.CancelScheduledPrice(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
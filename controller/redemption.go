@@ -1,11 +1,18 @@
 package controller
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
 	"one-api/common/utils"
 	"one-api/model"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -77,41 +84,157 @@ func AddRedemption(c *gin.Context) {
 		})
 		return
 	}
-	if redemption.Count > 100 {
+	if redemption.Count > 1000 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
-			"message": "一次兑换码批量生成的个数不能大于 100",
+			"message": "一次兑换码批量生成的个数不能大于 1000",
 		})
 		return
 	}
-	var keys []string
+	if redemption.MaxUses == 0 {
+		redemption.MaxUses = 1
+	}
+	if err := redemption.Validate(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	redemptions := make([]*model.Redemption, 0, redemption.Count)
+	keys := make([]string, 0, redemption.Count)
 	for i := 0; i < redemption.Count; i++ {
-		key := utils.GetUUID()
-		cleanRedemption := model.Redemption{
+		key := redemption.Prefix + utils.GetUUID()
+		redemptions = append(redemptions, &model.Redemption{
 			UserId:      c.GetInt("id"),
 			Name:        redemption.Name,
 			Key:         key,
 			CreatedTime: utils.GetTimestamp(),
 			Quota:       redemption.Quota,
+			Prefix:      redemption.Prefix,
+			MaxUses:     redemption.MaxUses,
+			OncePerUser: redemption.OncePerUser,
+			ExpiredTime: redemption.ExpiredTime,
+			Group:       redemption.Group,
+			GroupDays:   redemption.GroupDays,
+		})
+		keys = append(keys, key)
+	}
+	err = model.InsertRedemptions(redemptions)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    keys,
+	})
+}
+
+// ExportRedemptionsCSV 按名称导出同一批生成的兑换码
+func ExportRedemptionsCSV(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("兑换码名称不能为空"))
+		return
+	}
+	redemptions, err := model.GetRedemptionsByName(name)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", url.PathEscape("redemptions_"+name+".csv")))
+
+	writer := csv.NewWriter(c.Writer)
+	defer writer.Flush()
+
+	header := []string{
+		"ID",
+		"Name",
+		"Key",
+		"Quota",
+		"Group",
+		"Group Days",
+		"Max Uses",
+		"Used Count",
+		"Once Per User",
+		"Expired Time",
+		"Status",
+	}
+	if err := writer.Write(header); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("failed to write CSV header: %v", err))
+		return
+	}
+
+	for _, redemption := range redemptions {
+		expiredTime := ""
+		if redemption.ExpiredTime != -1 {
+			expiredTime = time.Unix(redemption.ExpiredTime, 0).Format("2006-01-02 15:04:05")
 		}
-		err = cleanRedemption.Insert()
-		if err != nil {
-			c.JSON(http.StatusOK, gin.H{
-				"success": false,
-				"message": err.Error(),
-				"data":    keys,
-			})
+		row := []string{
+			strconv.Itoa(redemption.Id),
+			redemption.Name,
+			redemption.Key,
+			strconv.Itoa(redemption.Quota),
+			redemption.Group,
+			strconv.Itoa(redemption.GroupDays),
+			strconv.Itoa(redemption.MaxUses),
+			strconv.Itoa(redemption.UsedCount),
+			strconv.FormatBool(redemption.OncePerUser),
+			expiredTime,
+			strconv.Itoa(redemption.Status),
+		}
+		if err := writer.Write(row); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("failed to write CSV row: %v", err))
 			return
 		}
-		keys = append(keys, key)
+	}
+}
+
+// GetRedemptionRecords 兑换码的兑换记录
+func GetRedemptionRecords(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	var params model.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	records, err := model.GetRedemptionRecordsList(id, &params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    keys,
+		"data":    records,
 	})
 }
 
+// RunUserGroupGrantExpiry 由定时任务调用，恢复到期的限时分组升级
+func RunUserGroupGrantExpiry() {
+	expired, err := model.ExpireUserGroupGrants(time.Now())
+	if err != nil {
+		logger.SysError("failed to expire group grants: " + err.Error())
+		return
+	}
+	if expired > 0 {
+		logger.SysLog("group grant expiry finished, expired: " + strconv.Itoa(expired))
+	}
+}
+
 func DeleteRedemption(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	err := model.DeleteRedemptionById(id)
@@ -153,6 +276,24 @@ func UpdateRedemption(c *gin.Context) {
 		// If you add more fields, please also update redemption.Update()
 		cleanRedemption.Name = redemption.Name
 		cleanRedemption.Quota = redemption.Quota
+		cleanRedemption.MaxUses = redemption.MaxUses
+		cleanRedemption.OncePerUser = redemption.OncePerUser
+		cleanRedemption.ExpiredTime = redemption.ExpiredTime
+		cleanRedemption.Group = redemption.Group
+		cleanRedemption.GroupDays = redemption.GroupDays
+		if err := cleanRedemption.Validate(); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		// 可兑换次数调整后同步状态，调大次数时重新启用已用完的兑换码
+		if cleanRedemption.Status == config.RedemptionCodeStatusUsed && cleanRedemption.UsedCount < cleanRedemption.MaxUses {
+			cleanRedemption.Status = config.RedemptionCodeStatusEnabled
+		} else if cleanRedemption.Status == config.RedemptionCodeStatusEnabled && cleanRedemption.UsedCount >= cleanRedemption.MaxUses {
+			cleanRedemption.Status = config.RedemptionCodeStatusUsed
+		}
 	}
 	err = cleanRedemption.Update()
 	if err != nil {
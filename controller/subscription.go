@@ -0,0 +1,307 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/payment"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SubscriptionOrderRequest struct {
+	UUID   string `json:"uuid" binding:"required"`
+	PlanId int    `json:"plan_id" binding:"required"`
+}
+
+func GetSubscriptionPlans(c *gin.Context) {
+	var params model.SearchSubscriptionPlanParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	plans, err := model.GetSubscriptionPlansList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+func GetSubscriptionPlanById(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	plan, err := model.GetSubscriptionPlanById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func AddSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	plan.Id = 0
+
+	if err := plan.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func UpdateSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if plan.Id == 0 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("id is required"))
+		return
+	}
+
+	if err := plan.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func DeleteSubscriptionPlan(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	plan := model.SubscriptionPlan{Id: id}
+
+	if err := plan.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetUserSubscriptions(c *gin.Context) {
+	var params model.SearchUserSubscriptionParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	subs, err := model.GetUserSubscriptionsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subs,
+	})
+}
+
+// GetAvailableSubscriptionPlans 用户可购买的套餐
+func GetAvailableSubscriptionPlans(c *gin.Context) {
+	plans, err := model.GetEnabledSubscriptionPlans()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+// GetSelfSubscription 当前用户生效中的订阅，没有订阅时 data 为 null
+func GetSelfSubscription(c *gin.Context) {
+	sub, err := model.GetUserActiveSubscription(c.GetInt("id"))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err != nil {
+		sub = nil
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    sub,
+	})
+}
+
+func UpdateSelfSubscriptionAutoRenew(c *gin.Context) {
+	var req struct {
+		AutoRenew bool `json:"auto_renew"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	sub, err := model.SetSubscriptionAutoRenew(c.GetInt("id"), req.AutoRenew)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    sub,
+	})
+}
+
+// GetUserSubscriptionInvoice 用户指定月份的订阅账单，date 格式为 YYYY-MM-DD
+func GetUserSubscriptionInvoice(c *gin.Context) {
+	date, err := time.ParseInLocation("2006-01-02", c.Query("date"), time.Local)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的日期格式"))
+		return
+	}
+	start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	bills, err := model.GetUserSubscriptionBills(c.GetInt("id"), start.Unix(), start.AddDate(0, 1, 0).Unix())
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    bills,
+	})
+}
+
+// CreateSubscriptionOrder 通过支付网关购买订阅套餐，已订阅同一套餐时视为预付下一个周期
+func CreateSubscriptionOrder(c *gin.Context) {
+	var orderReq SubscriptionOrderRequest
+	if err := c.ShouldBindJSON(&orderReq); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	userId := c.GetInt("id")
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("用户不存在"))
+		return
+	}
+
+	plan, err := model.GetSubscriptionPlanById(orderReq.PlanId)
+	if err != nil || !plan.Enabled {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("套餐不存在"))
+		return
+	}
+	if sub, err := model.GetUserActiveSubscription(userId); err == nil && sub.PlanId != plan.Id {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("已有生效中的其他订阅"))
+		return
+	}
+
+	go model.CloseUnfinishedOrder()
+
+	paymentService, err := payment.NewPaymentService(orderReq.UUID)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	fee, payMoney := calculatePlanOrderAmount(paymentService.Payment, plan.Price)
+	tradeNo := utils.GenerateTradeNo()
+	payRequest, err := paymentService.Pay(tradeNo, payMoney, user)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("创建支付失败，请稍后再试"))
+		return
+	}
+
+	order := &model.Order{
+		UserId:        userId,
+		GatewayId:     paymentService.Payment.ID,
+		TradeNo:       tradeNo,
+		GatewayNo:     payRequest.GatewayNo,
+		OrderAmount:   payMoney,
+		OrderCurrency: paymentService.Payment.Currency,
+		Fee:           fee,
+		Status:        model.OrderStatusPending,
+		PlanId:        plan.Id,
+	}
+	if err := order.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("创建订单失败，请稍后再试"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": &OrderResponse{
+			TradeNo:    tradeNo,
+			PayRequest: payRequest,
+		},
+	})
+}
+
+// calculatePlanOrderAmount 套餐不参与充值折扣，只计算手续费和汇率
+func calculatePlanOrderAmount(payment *model.Payment, price float64) (fee, payMoney float64) {
+	if payment.PercentFee > 0 {
+		fee = utils.Decimal(price*payment.PercentFee, 2)
+	} else if payment.FixedFee > 0 {
+		fee = payment.FixedFee
+	}
+
+	payMoney = utils.Decimal(price+fee, 2)
+	if payment.Currency != model.CurrencyTypeUSD {
+		payMoney = utils.Decimal(payMoney*config.PaymentUSDRate, 2)
+	}
+	return
+}
+
+// RunSubscriptionRenewal 由定时任务调用，处理到期的订阅
+func RunSubscriptionRenewal() {
+	renewed, expired, err := model.RenewSubscriptions(time.Now())
+	if err != nil {
+		logger.SysError("failed to renew subscriptions: " + err.Error())
+		return
+	}
+	if renewed > 0 || expired > 0 {
+		logger.SysLog("subscription renewal finished, renewed: " + strconv.Itoa(renewed) + ", expired: " + strconv.Itoa(expired))
+	}
+}
@@ -2,10 +2,12 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/utils"
+	"one-api/internal/requesthints"
 	"one-api/model"
 	"strconv"
 
@@ -35,6 +37,9 @@ func GetUserTokensList(c *gin.Context) {
 			token.Setting.Set(setting)
 		}
 	}
+	for _, token := range *tokens.Data {
+		token.FillSpendWindows()
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -90,6 +95,8 @@ func GetToken(c *gin.Context) {
 		setting.BillingTag = nil
 		token.Setting.Set(setting)
 	}
+	token.FillSpendWindows()
+	token.FillPreviousKeys()
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -179,9 +186,23 @@ func AddToken(c *gin.Context) {
 		return
 	}
 
-	// 非可信用户不能设置 BillingTag
+	if err = validateTokenOrganization(token.OrganizationId, userId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if token.KeyPrefix == "" {
+		token.KeyPrefix = config.TokenKeyPrefix
+	}
+	if err = model.ValidateTokenKeyPrefix(token.KeyPrefix); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	// 非可信用户不能设置 BillingTag 和路由提示权限
 	if userRole < config.RoleReliableUser {
 		setting.BillingTag = nil
+		setting.RoutingHints = model.RoutingHintsSetting{}
 	}
 
 	cleanToken := model.Token{
@@ -195,6 +216,8 @@ func AddToken(c *gin.Context) {
 		UnlimitedQuota: token.UnlimitedQuota,
 		Group:          token.Group,
 		BackupGroup:    token.BackupGroup,
+		KeyPrefix:      token.KeyPrefix,
+		OrganizationId: token.OrganizationId,
 	}
 	cleanToken.Setting.Set(setting)
 	err = cleanToken.Insert()
@@ -302,6 +325,13 @@ func UpdateToken(c *gin.Context) {
 		}
 	}
 
+	if statusOnly == "" && token.OrganizationId != cleanToken.OrganizationId {
+		if err = validateTokenOrganization(token.OrganizationId, userId); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+
 	if statusOnly != "" {
 		cleanToken.Status = token.Status
 	} else {
@@ -312,12 +342,14 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.Group = token.Group
 		cleanToken.BackupGroup = token.BackupGroup
+		cleanToken.OrganizationId = token.OrganizationId
 
 		// 处理 BillingTag: 非可信用户保持原值不变
 		oldSetting := cleanToken.Setting.Data()
 		if userRole < config.RoleReliableUser {
-			// 非可信用户：保持原来的 BillingTag，忽略前端传入的值
+			// 非可信用户：保持原来的 BillingTag 和路由提示权限，忽略前端传入的值
 			newSetting.BillingTag = oldSetting.BillingTag
+			newSetting.RoutingHints = oldSetting.RoutingHints
 		}
 		// 可信用户：直接使用前端传入的值（包括空值，用于清除 BillingTag）
 
@@ -438,6 +470,13 @@ func UpdateTokenByAdmin(c *gin.Context) {
 		}
 	}
 
+	if statusOnly == "" && (token.OrganizationId != cleanToken.OrganizationId || targetUserId != cleanToken.UserId) {
+		if err = validateTokenOrganization(token.OrganizationId, targetUserId); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+
 	if statusOnly != "" {
 		cleanToken.Status = token.Status
 	} else {
@@ -447,6 +486,7 @@ func UpdateTokenByAdmin(c *gin.Context) {
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.Group = token.Group
 		cleanToken.BackupGroup = token.BackupGroup
+		cleanToken.OrganizationId = token.OrganizationId
 		cleanToken.Setting.Set(newSetting)
 
 		// 管理员可以转移token给其他用户
@@ -519,5 +559,43 @@ func validateTokenSetting(setting *model.TokenSetting) error {
 		}
 	}
 
+	if err := setting.Limits.LimitsSpendSetting.Validate(); err != nil {
+		return err
+	}
+
+	if setting.Limits.LimitsRateSetting.RPM < 0 || setting.Limits.LimitsRateSetting.TPM < 0 {
+		return errors.New("rate limits must not be negative")
+	}
+
+	if concurrency := setting.Limits.LimitsConcurrencySetting; concurrency.Enabled && concurrency.Max <= 0 {
+		return errors.New("max concurrency must be greater than 0")
+	}
+
+	if err := setting.Limits.LimitsScopeSetting.Validate(); err != nil {
+		return err
+	}
+
+	if err := setting.Limits.LimitsParamSetting.Validate(); err != nil {
+		return err
+	}
+
+	for _, hint := range setting.RoutingHints.Allowed {
+		if !requesthints.IsClientHint(hint) {
+			return fmt.Errorf("unsupported routing hint: %s", hint)
+		}
+	}
+
 	return nil
 }
+
+// validateTokenOrganization 令牌只能绑定到所有者所在的组织，0 表示使用个人额度
+func validateTokenOrganization(organizationId, userId int) error {
+	if organizationId == 0 {
+		return nil
+	}
+	if _, err := model.GetOrganizationById(organizationId); err != nil {
+		return errors.New("组织不存在")
+	}
+	_, err := model.GetOrganizationMember(organizationId, userId)
+	return err
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rotateTokenRequest struct {
+	GraceSeconds *int    `json:"grace_seconds"` // 为空时使用系统设置的宽限期
+	KeyPrefix    *string `json:"key_prefix"`    // 为空时沿用原来的前缀
+}
+
+// RotateToken 用户轮换自己的令牌 key
+func RotateToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	token, err := model.GetTokenByIds(id, c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	rotateToken(c, token)
+}
+
+// RotateTokenByAdmin 管理员轮换任意令牌的 key
+func RotateTokenByAdmin(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	token, err := model.GetTokenById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	rotateToken(c, token)
+}
+
+func rotateToken(c *gin.Context, token *model.Token) {
+	var request rotateTokenRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+	graceSeconds := config.TokenRotationGraceSeconds
+	if request.GraceSeconds != nil {
+		graceSeconds = *request.GraceSeconds
+	}
+
+	if err := model.RotateTokenKey(token, graceSeconds, request.KeyPrefix); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	model.RecordLog(token.UserId, model.LogTypeManage, "令牌「"+token.Name+"」的 key 已轮换，旧 key 宽限期 "+strconv.Itoa(graceSeconds)+" 秒")
+	token.FillPreviousKeys()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    token,
+	})
+}
+
+// ForceExpireTokenKeys 管理员立即作废令牌当前的 key 和所有旧 key，并生成新的 key
+func ForceExpireTokenKeys(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	token, err := model.GetTokenById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.ForceExpireTokenKeys(token); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	model.RecordLog(token.UserId, model.LogTypeManage, "管理员已强制作废令牌「"+token.Name+"」的 key")
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    token,
+	})
+}
@@ -443,6 +443,12 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if originUser.MaxConcurrency != updatedUser.MaxConcurrency {
+		if err := model.UpdateUserMaxConcurrency(updatedUser.Id, updatedUser.MaxConcurrency); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
 	}
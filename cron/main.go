@@ -2,11 +2,13 @@ package cron
 
 import (
 	"context"
+	"fmt"
 	"github.com/spf13/viper"
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/scheduler"
+	"one-api/controller"
 	"one-api/model"
 	"one-api/providers/codex"
 	"time"
@@ -38,7 +40,7 @@ func InitCron() {
 	}
 
 	if config.UserInvoiceMonth {
-		// 每月一号早上四点生成上个月的账单数据
+		// 每月一号早上四点生成上个月的账单数据，然后生成账单文件并发送邮件
 		err = scheduler.Manager.AddJob(
 			"generate_statistics_month",
 			gocron.DailyJob(1, gocron.NewAtTimes(gocron.NewAtTime(4, 0, 0))),
@@ -46,7 +48,9 @@ func InitCron() {
 				err := model.InsertStatisticsMonth()
 				if err != nil {
 					logger.SysError("Generate statistics month data error:" + err.Error())
+					return
 				}
+				controller.RunInvoiceDelivery()
 			}),
 		)
 		if err != nil {
@@ -83,6 +87,106 @@ func InitCron() {
 		})
 	}
 
+	// 每分钟按渠道调度规则切换渠道状态
+	err = scheduler.Manager.AddJob(
+		"apply_channel_schedules",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(func() {
+			if _, err := model.ApplyChannelSchedules(time.Now()); err != nil {
+				logger.SysError("Apply channel schedules error: " + err.Error())
+			}
+		}),
+	)
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+	}
+
+	// 渠道定时健康检查，实际执行间隔由 ChannelHealthCheckInterval 控制
+	err = scheduler.Manager.AddJob(
+		"channel_health_check",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(controller.RunScheduledChannelHealthChecks),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+	}
+
+	// 向支付网关查询待支付订单，补偿丢失的支付回调，实际执行间隔由 PaymentReconcileInterval 控制
+	err = scheduler.Manager.AddJob(
+		"order_reconciliation",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(controller.RunScheduledOrderReconciliation),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+	}
+
+	// 渠道余额定时刷新，实际执行间隔由 ChannelBalanceRefreshInterval 控制
+	err = scheduler.Manager.AddJob(
+		"channel_balance_refresh",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(controller.RunScheduledChannelBalanceRefresh),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+	}
+
+	// 渠道上游模型列表定时同步，实际执行间隔由 ChannelModelSyncInterval 控制
+	err = scheduler.Manager.AddJob(
+		"channel_model_sync",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(controller.RunScheduledChannelModelSync),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+	}
+
+	// 预约价格到达生效时间后写入价格表
+	err = scheduler.Manager.AddJob(
+		"activate_scheduled_prices",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(func() {
+			count, err := model.PricingInstance.ActivateScheduledPrices(time.Now())
+			if err != nil {
+				logger.SysError("failed to activate scheduled prices: " + err.Error())
+				return
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("activated %d scheduled prices", count))
+			}
+		}),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+	}
+
+	// 到期的订阅续费或过期
+	err = scheduler.Manager.AddJob(
+		"renew_subscriptions",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(controller.RunSubscriptionRenewal),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+	}
+
+	// 兑换码发放的限时分组升级到期后恢复原分组
+	err = scheduler.Manager.AddJob(
+		"expire_group_grants",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(controller.RunUserGroupGrantExpiry),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+	}
+
 	// 开启自动更新 并且设置了有效自动更新时间 同时自动更新模式不是system 则会从服务器拉取最新价格表
 	autoPriceUpdatesInterval := viper.GetInt("auto_price_updates_interval")
 	autoPriceUpdates := viper.GetBool("auto_price_updates")
@@ -89,6 +89,7 @@ type SettlementCommand struct {
 	RequestKind      SettlementRequestKind `json:"request_kind,omitempty"`
 	UserID           int                   `json:"user_id"`
 	TokenID          int                   `json:"token_id"`
+	OrganizationID   int                   `json:"organization_id,omitempty"` // 非 0 时从组织钱包结算
 	ChannelID        int                   `json:"channel_id"`
 	ModelName        string                `json:"model_name,omitempty"`
 	PreConsumedQuota int                   `json:"pre_consumed_quota"`
@@ -134,6 +135,10 @@ type SettlementProjection struct {
 	IsStream    bool           `json:"is_stream,omitempty"`
 	Metadata    map[string]any `json:"metadata,omitempty"`
 	SourceIP    string         `json:"source_ip,omitempty"`
+	// 令牌的消费上限，有预占时按实际消费修正预占，否则直接累加
+	SpendCaps        *model.LimitsSpendSetting    `json:"spend_caps,omitempty"`
+	SpendReservation *model.TokenSpendReservation `json:"spend_reservation,omitempty"`
+	BillingTag       string                       `json:"billing_tag,omitempty"` // 令牌的费用标签
 }
 
 type SettlementCleanup struct {
@@ -163,6 +168,7 @@ type settlementFingerprintPayload struct {
 	RequestKind      SettlementRequestKind `json:"request_kind"`
 	UserID           int                   `json:"user_id"`
 	TokenID          int                   `json:"token_id"`
+	OrganizationID   int                   `json:"organization_id,omitempty"`
 	ChannelID        int                   `json:"channel_id"`
 	ModelName        string                `json:"model_name,omitempty"`
 	PreConsumedQuota int                   `json:"pre_consumed_quota"`
@@ -176,6 +182,7 @@ func buildSettlementFingerprint(cmd SettlementCommand) string {
 		RequestKind:      cmd.RequestKind,
 		UserID:           cmd.UserID,
 		TokenID:          cmd.TokenID,
+		OrganizationID:   cmd.OrganizationID,
 		ChannelID:        cmd.ChannelID,
 		ModelName:        cmd.ModelName,
 		PreConsumedQuota: cmd.PreConsumedQuota,
@@ -215,7 +222,12 @@ func ApplySettlement(ctx context.Context, cmd SettlementCommand, opts *Settlemen
 		return result, nil
 	}
 
-	if err = model.ApplyTokenUserQuotaDeltaDirect(cmd.TokenID, cmd.UserID, cmd.UnlimitedQuota, result.Delta); err != nil {
+	if cmd.OrganizationID > 0 {
+		err = model.ApplyOrganizationQuotaDelta(cmd.OrganizationID, cmd.UserID, cmd.TokenID, cmd.UnlimitedQuota, result.Delta)
+	} else {
+		err = model.ApplyTokenUserQuotaDeltaDirect(cmd.TokenID, cmd.UserID, cmd.UnlimitedQuota, result.Delta)
+	}
+	if err != nil {
 		releaseSettlementGate(ctx, gateKey)
 		return result, err
 	}
@@ -307,9 +319,32 @@ func runSettlementProjection(ctx context.Context, cmd SettlementCommand, opts Se
 	if cmd.ChannelID > 0 && cmd.FinalQuota > 0 {
 		model.UpdateChannelUsedQuota(cmd.ChannelID, cmd.FinalQuota)
 	}
+	recordSettlementTokenSpend(ctx, cmd, opts)
+	if err := model.RecordBillingTagUsage(opts.Projection.BillingTag, cmd.ModelName, cmd.FinalQuota, usage.PromptTokens, usage.CompletionTokens, time.Now()); err != nil {
+		logger.LogError(ctx, "record billing tag usage failed: "+err.Error())
+	}
+	// 组织令牌的用量计入组织，不计入成员的个人用量
+	if cmd.OrganizationID > 0 {
+		if err := model.RecordOrganizationUsage(cmd.OrganizationID, cmd.UserID, cmd.ModelName, cmd.FinalQuota, usage.PromptTokens, usage.CompletionTokens, time.Now()); err != nil {
+			logger.LogError(ctx, "record organization usage failed: "+err.Error())
+		}
+		return
+	}
 	model.UpdateUserUsedQuotaAndRequestCount(cmd.UserID, cmd.FinalQuota)
 }
 
+func recordSettlementTokenSpend(ctx context.Context, cmd SettlementCommand, opts SettlementOptions) {
+	var err error
+	if opts.Projection.SpendReservation != nil {
+		err = opts.Projection.SpendReservation.Settle(cmd.FinalQuota)
+	} else {
+		err = model.RecordTokenSpend(cmd.TokenID, opts.Projection.SpendCaps, cmd.FinalQuota, time.Now())
+	}
+	if err != nil {
+		logger.LogError(ctx, "record token spend failed: "+err.Error())
+	}
+}
+
 func cloneSettlementExtraTokens(extraTokens map[string]int) map[string]int {
 	if len(extraTokens) == 0 {
 		return nil
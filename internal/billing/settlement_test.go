@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"one-api/common/config"
 	"one-api/common/logger"
@@ -27,7 +28,7 @@ func useSettlementTestDB(t *testing.T) {
 	if err != nil {
 		t.Fatalf("expected in-memory sqlite database, got %v", err)
 	}
-	if err := testDB.AutoMigrate(&model.User{}, &model.Token{}, &model.Channel{}, &model.Log{}); err != nil {
+	if err := testDB.AutoMigrate(&model.User{}, &model.Token{}, &model.Channel{}, &model.Log{}, &model.BillingTagStatistic{}, &model.BillingTagBudget{}); err != nil {
 		t.Fatalf("expected settlement schema migration to succeed, got %v", err)
 	}
 
@@ -410,3 +411,83 @@ func TestApplySettlementFallsBackWhenGateBackendErrors(t *testing.T) {
 		t.Fatalf("expected settlement fallback to charge user quota, got %d", user.Quota)
 	}
 }
+
+func TestApplySettlementProjectionRecordsBillingTagUsage(t *testing.T) {
+	useSettlementTestDB(t)
+	insertSettlementFixtures(t)
+
+	cmd := SettlementCommand{
+		RequestKind:      SettlementRequestKindAsyncTask,
+		Identity:         "task:1:finalize",
+		UserID:           1,
+		TokenID:          1,
+		ChannelID:        1,
+		ModelName:        "gpt-5",
+		PreConsumedQuota: 100,
+		FinalQuota:       250,
+	}
+	opts := SettlementOptions{Projection: SettlementProjection{BillingTag: "team-a"}}
+	if _, err := ApplySettlement(context.Background(), cmd, &opts); err != nil {
+		t.Fatalf("expected settlement to succeed, got %v", err)
+	}
+
+	var statistic model.BillingTagStatistic
+	if err := model.DB.Where("billing_tag = ?", "team-a").First(&statistic).Error; err != nil {
+		t.Fatalf("expected billing tag usage to be recorded, got %v", err)
+	}
+	if statistic.Quota != 250 || statistic.RequestCount != 1 || statistic.ModelName != "gpt-5" {
+		t.Fatalf("unexpected billing tag statistic: %+v", statistic)
+	}
+}
+
+func TestApplySettlementProjectionSettlesTokenSpend(t *testing.T) {
+	useSettlementTestDB(t)
+	insertSettlementFixtures(t)
+
+	originalRedis := config.RedisEnabled
+	config.RedisEnabled = false
+	t.Cleanup(func() {
+		config.RedisEnabled = originalRedis
+	})
+
+	caps := &model.LimitsSpendSetting{Enabled: true, Daily: 1}
+	now := time.Now()
+	reservation, err := model.ReserveTokenSpend(1, caps, 100, now)
+	if err != nil {
+		t.Fatalf("expected spend to be reserved, got %v", err)
+	}
+
+	// 异步任务的结算同样修正预占的消费
+	cmd := SettlementCommand{
+		RequestKind:      SettlementRequestKindAsyncTask,
+		Identity:         "task:1:finalize",
+		UserID:           1,
+		TokenID:          1,
+		ChannelID:        1,
+		ModelName:        "gpt-5",
+		PreConsumedQuota: 100,
+		FinalQuota:       250,
+	}
+	opts := SettlementOptions{
+		Deduplicate: true,
+		Projection:  SettlementProjection{SpendCaps: caps, SpendReservation: reservation},
+	}
+	if _, err := ApplySettlement(context.Background(), cmd, &opts); err != nil {
+		t.Fatalf("expected settlement to succeed, got %v", err)
+	}
+
+	windows, err := model.GetTokenSpendWindows(1, caps, now)
+	if err != nil || len(windows) != 1 || windows[0].Used != 250 {
+		t.Fatalf("expected final quota to replace the reservation, got %+v, %v", windows, err)
+	}
+
+	// 没有预占时直接累加
+	cmd.Identity = "task:2:finalize"
+	opts.Projection.SpendReservation = nil
+	if _, err := ApplySettlement(context.Background(), cmd, &opts); err != nil {
+		t.Fatalf("expected settlement to succeed, got %v", err)
+	}
+	if windows, _ := model.GetTokenSpendWindows(1, caps, now); windows[0].Used != 500 {
+		t.Fatalf("expected spend without reservation to be recorded, got %+v", windows[0])
+	}
+}
@@ -0,0 +1,204 @@
+package invoice
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Party 账单的开具方或接收方
+type Party struct {
+	Name    string
+	Address string
+	TaxId   string
+	Email   string
+}
+
+// Line 账单中一个模型的用量，Quota 为额度
+type Line struct {
+	ModelName        string
+	RequestCount     int
+	PromptTokens     int
+	CompletionTokens int
+	Quota            int
+}
+
+// Document 月度账单，金额由 Quota / QuotaPerUnit 换算为 USD
+type Document struct {
+	Number       string
+	Month        string // 2006-01
+	IssuedAt     time.Time
+	Company      Party
+	Customer     Party
+	Lines        []Line
+	QuotaPerUnit float64
+	Notes        string
+}
+
+// Number 生成账单编号，同一用户同一月份的编号固定
+func Number(month string, userId int) string {
+	return fmt.Sprintf("INV-%s%s-%06d", month[:4], month[5:], userId)
+}
+
+func (doc *Document) amount(quota int) float64 {
+	if doc.QuotaPerUnit <= 0 {
+		return 0
+	}
+	return float64(quota) / doc.QuotaPerUnit
+}
+
+func (doc *Document) Total() (requestCount, quota int, amount float64) {
+	for _, line := range doc.Lines {
+		requestCount += line.RequestCount
+		quota += line.Quota
+	}
+	return requestCount, quota, doc.amount(quota)
+}
+
+func formatAmount(amount float64) string {
+	return "$" + strconv.FormatFloat(amount, 'f', 4, 64)
+}
+
+// RenderCSV 每行一个模型，最后一行为合计
+func RenderCSV(doc *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	rows := [][]string{
+		{"Invoice Number", doc.Number},
+		{"Billing Month", doc.Month},
+		{"Issued At", doc.IssuedAt.Format("2006-01-02")},
+		{"Seller", doc.Company.Name},
+		{"Customer", doc.Customer.Name},
+		{"Customer Email", doc.Customer.Email},
+		{},
+		{"Model", "Request Count", "Prompt Tokens", "Completion Tokens", "Quota", "Amount (USD)"},
+	}
+	for _, line := range doc.Lines {
+		rows = append(rows, []string{
+			line.ModelName,
+			strconv.Itoa(line.RequestCount),
+			strconv.Itoa(line.PromptTokens),
+			strconv.Itoa(line.CompletionTokens),
+			strconv.Itoa(line.Quota),
+			strconv.FormatFloat(doc.amount(line.Quota), 'f', 6, 64),
+		})
+	}
+	requestCount, quota, amount := doc.Total()
+	rows = append(rows, []string{"Total", strconv.Itoa(requestCount), "", "", strconv.Itoa(quota), strconv.FormatFloat(amount, 'f', 6, 64)})
+
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 明细表格各列的右边界，第一列为左对齐的模型名称
+var pdfColumns = []struct {
+	title string
+	right float64
+}{
+	{"Model", pageMargin},
+	{"Requests", 290},
+	{"Prompt", 360},
+	{"Completion", 435},
+	{"Quota", 490},
+	{"Amount (USD)", pageWidth - pageMargin},
+}
+
+func writeParty(pdf *pdfDocument, title string, party Party) {
+	pdf.writeLine(defaultSize, title)
+	for _, text := range []string{party.Name, party.Address, party.Email} {
+		if text != "" {
+			pdf.writeLine(defaultSize, "  "+text)
+		}
+	}
+	if party.TaxId != "" {
+		pdf.writeLine(defaultSize, "  Tax ID: "+party.TaxId)
+	}
+}
+
+func writeTableHeader(pdf *pdfDocument) {
+	pdf.ensure(defaultSize * lineHeightPt * 2)
+	pdf.y -= defaultSize * lineHeightPt
+	for i, column := range pdfColumns {
+		if i == 0 {
+			pdf.text(column.right, pdf.y, defaultSize, column.title)
+		} else {
+			pdf.textRight(column.right, pdf.y, defaultSize, column.title)
+		}
+	}
+	pdf.line(pageMargin, pdf.y-4, pageWidth-pageMargin, pdf.y-4)
+	pdf.space(4)
+}
+
+func writeTableRow(pdf *pdfDocument, values []string) {
+	height := defaultSize * lineHeightPt
+	if pdf.y-height < pageBottom {
+		pdf.addPage()
+		writeTableHeader(pdf)
+	}
+	pdf.y -= height
+	for i, column := range pdfColumns {
+		if i == 0 {
+			// 模型名称过长时截断，避免和后面的列重叠
+			pdf.text(column.right, pdf.y, defaultSize, truncateText(values[i], pdfColumns[1].right-column.right-70, defaultSize))
+		} else {
+			pdf.textRight(column.right, pdf.y, defaultSize, values[i])
+		}
+	}
+}
+
+func truncateText(text string, maxWidth, size float64) string {
+	if textWidth(text, size) <= maxWidth {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && textWidth(string(runes)+"...", size) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// RenderPDF 生成 A4 的 PDF 账单，明细超过一页时自动分页并重复表头
+func RenderPDF(doc *Document) ([]byte, error) {
+	pdf := newPDFDocument()
+
+	pdf.writeLine(20, "INVOICE")
+	pdf.space(6)
+	pdf.writeLine(defaultSize, "Invoice Number: "+doc.Number)
+	pdf.writeLine(defaultSize, "Billing Month: "+doc.Month)
+	pdf.writeLine(defaultSize, "Issued At: "+doc.IssuedAt.Format("2006-01-02"))
+	pdf.space(8)
+	writeParty(pdf, "From:", doc.Company)
+	pdf.space(4)
+	writeParty(pdf, "Bill To:", doc.Customer)
+	pdf.space(8)
+
+	writeTableHeader(pdf)
+	for _, line := range doc.Lines {
+		writeTableRow(pdf, []string{
+			line.ModelName,
+			strconv.Itoa(line.RequestCount),
+			strconv.Itoa(line.PromptTokens),
+			strconv.Itoa(line.CompletionTokens),
+			strconv.Itoa(line.Quota),
+			formatAmount(doc.amount(line.Quota)),
+		})
+	}
+	requestCount, quota, amount := doc.Total()
+	pdf.line(pageMargin, pdf.y-4, pageWidth-pageMargin, pdf.y-4)
+	pdf.space(4)
+	writeTableRow(pdf, []string{"Total", strconv.Itoa(requestCount), "", "", strconv.Itoa(quota), formatAmount(amount)})
+
+	if doc.Notes != "" {
+		pdf.space(12)
+		for _, text := range strings.Split(doc.Notes, "\n") {
+			pdf.writeLine(defaultSize, strings.TrimRight(text, "\r"))
+		}
+	}
+	return pdf.bytes(), nil
+}
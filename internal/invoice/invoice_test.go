@@ -0,0 +1,95 @@
+package invoice
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDocument(lines int) *Document {
+	doc := &Document{
+		Number:       Number("2026-03", 42),
+		Month:        "2026-03",
+		IssuedAt:     time.Date(2026, 4, 1, 4, 0, 0, 0, time.UTC),
+		Company:      Party{Name: "示例科技有限公司", Address: "Shanghai", TaxId: "91310000XXXX"},
+		Customer:     Party{Name: "alice", Email: "alice@example.com"},
+		QuotaPerUnit: 500000,
+		Notes:        "Thanks\n谢谢",
+	}
+	for i := 0; i < lines; i++ {
+		doc.Lines = append(doc.Lines, Line{
+			ModelName:        fmt.Sprintf("model-%03d", i),
+			RequestCount:     2,
+			PromptTokens:     100,
+			CompletionTokens: 50,
+			Quota:            250000,
+		})
+	}
+	return doc
+}
+
+func TestRenderCSVIncludesLinesAndTotal(t *testing.T) {
+	data, err := RenderCSV(testDocument(2))
+	if err != nil {
+		t.Fatalf("expected csv to render, got %v", err)
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("expected valid csv, got %v", err)
+	}
+	if rows[0][1] != "INV-202603-000042" {
+		t.Fatalf("unexpected invoice number row: %v", rows[0])
+	}
+	last := rows[len(rows)-1]
+	if strings.Join(last, ",") != "Total,4,,,500000,1.000000" {
+		t.Fatalf("unexpected total row: %v", last)
+	}
+}
+
+func TestRenderPDFPaginatesAndEncodesText(t *testing.T) {
+	data, err := RenderPDF(testDocument(80))
+	if err != nil {
+		t.Fatalf("expected pdf to render, got %v", err)
+	}
+	content := string(data)
+	if !strings.HasPrefix(content, "%PDF-1.4") || !strings.HasSuffix(content, "%EOF\n") {
+		t.Fatal("expected pdf header and trailer")
+	}
+	if !strings.Contains(content, "/Count 3") {
+		t.Fatal("expected 80 lines to span three pages")
+	}
+	// 中文按 UTF-16BE 编码输出
+	if !strings.Contains(content, strings.Trim(encodeText("示例科技有限公司"), "<>")) {
+		t.Fatal("expected company name to be encoded")
+	}
+
+	// xref 中的偏移量必须指向对应的对象
+	offsets := regexp.MustCompile(`(?m)^(\d{10}) 00000 n $`).FindAllStringSubmatch(content, -1)
+	if len(offsets) == 0 {
+		t.Fatal("expected xref entries")
+	}
+	for i, match := range offsets {
+		var offset int
+		fmt.Sscanf(match[1], "%d", &offset)
+		if !strings.HasPrefix(content[offset:], fmt.Sprintf("%d 0 obj", i+1)) {
+			t.Fatalf("xref entry %d does not point to its object", i+1)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	long := strings.Repeat("a", 80)
+	truncated := truncateText(long, 100, defaultSize)
+	if !strings.HasSuffix(truncated, "...") || textWidth(truncated, defaultSize) > 100 {
+		t.Fatalf("expected truncated text within width, got %q", truncated)
+	}
+	if truncateText("gpt-4o", 100, defaultSize) != "gpt-4o" {
+		t.Fatal("expected short text to stay unchanged")
+	}
+}
@@ -0,0 +1,132 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// A4 纸张，单位为 pt
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	pageMargin   = 50.0
+	pageBottom   = 60.0
+	defaultSize  = 10.0
+	lineHeightPt = 1.6
+)
+
+// pdfDocument 只输出文字和直线的最小 PDF 生成器。
+// 使用 PDF 阅读器内置的 STSong-Light 字体，不需要嵌入字体文件也能显示中文。
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+func newPDFDocument() *pdfDocument {
+	doc := &pdfDocument{}
+	doc.addPage()
+	return doc
+}
+
+func (doc *pdfDocument) addPage() {
+	doc.page = &bytes.Buffer{}
+	doc.pages = append(doc.pages, doc.page)
+	doc.y = pageHeight - pageMargin
+}
+
+// ensure 当前页剩余空间不足 height 时换页
+func (doc *pdfDocument) ensure(height float64) {
+	if doc.y-height < pageBottom {
+		doc.addPage()
+	}
+}
+
+// textWidth ASCII 字符按半角、其他字符按全角估算宽度
+func textWidth(text string, size float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		if r < 0x80 {
+			width += 0.5
+		} else {
+			width += 1
+		}
+	}
+	return width * size
+}
+
+func encodeText(text string) string {
+	var builder strings.Builder
+	builder.WriteByte('<')
+	for _, unit := range utf16.Encode([]rune(text)) {
+		fmt.Fprintf(&builder, "%04X", unit)
+	}
+	builder.WriteByte('>')
+	return builder.String()
+}
+
+func (doc *pdfDocument) text(x, y float64, size float64, text string) {
+	if text == "" {
+		return
+	}
+	fmt.Fprintf(doc.page, "BT /F1 %.1f Tf %.2f %.2f Td %s Tj ET\n", size, x, y, encodeText(text))
+}
+
+// textRight 文字右对齐到 x
+func (doc *pdfDocument) textRight(x, y float64, size float64, text string) {
+	doc.text(x-textWidth(text, size), y, size, text)
+}
+
+func (doc *pdfDocument) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(doc.page, "%.2f w %.2f %.2f m %.2f %.2f l S\n", 0.5, x1, y1, x2, y2)
+}
+
+// writeLine 在当前位置输出一行文字并下移
+func (doc *pdfDocument) writeLine(size float64, text string) {
+	doc.ensure(size * lineHeightPt)
+	doc.y -= size * lineHeightPt
+	doc.text(pageMargin, doc.y, size, text)
+}
+
+func (doc *pdfDocument) space(height float64) {
+	doc.y -= height
+}
+
+func (doc *pdfDocument) bytes() []byte {
+	var out bytes.Buffer
+	offsets := make([]int, 0, 5+2*len(doc.pages))
+	writeObject := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 目录，2 页面树，3-5 字体，之后每页依次为页面对象和内容流
+	kids := make([]string, len(doc.pages))
+	for i := range doc.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(doc.pages)))
+	writeObject("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UTF16-H /DescendantFonts [4 0 R] >>")
+	writeObject("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	writeObject("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	for i, page := range doc.pages {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 7+2*i))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
@@ -0,0 +1,66 @@
+package requesthints
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 客户端可以通过请求头提示渠道选择，令牌需要在设置中显式允许对应的提示
+const (
+	ClientChannelTag    = "channel_tag"
+	ClientPreferChannel = "prefer_channel"
+	ClientMaxPrice      = "max_price"
+	ClientRegion        = "region"
+)
+
+type ClientHint struct {
+	Name   string
+	Header string
+}
+
+var ClientHints = []ClientHint{
+	{Name: ClientChannelTag, Header: "X-OneHub-Channel-Tag"},
+	{Name: ClientPreferChannel, Header: "X-OneHub-Prefer-Channel"},
+	{Name: ClientMaxPrice, Header: "X-OneHub-Max-Price"},
+	{Name: ClientRegion, Header: "X-OneHub-Region"},
+}
+
+func IsClientHint(name string) bool {
+	for _, hint := range ClientHints {
+		if hint.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientHintHeader 返回提示对应的请求头名称，用于错误提示
+func ClientHintHeader(name string) string {
+	for _, hint := range ClientHints {
+		if hint.Name == name {
+			return hint.Header
+		}
+	}
+	return name
+}
+
+// ResolveClient 读取请求中携带的客户端路由提示，返回 提示名称 -> 值
+func ResolveClient(c *gin.Context) map[string]string {
+	if c == nil || c.Request == nil {
+		return nil
+	}
+
+	var hints map[string]string
+	for _, hint := range ClientHints {
+		value := strings.TrimSpace(c.GetHeader(hint.Header))
+		if value == "" {
+			continue
+		}
+		if hints == nil {
+			hints = make(map[string]string, len(ClientHints))
+		}
+		hints[hint.Name] = value
+	}
+	return hints
+}
@@ -167,6 +167,7 @@ func SyncChannelCache(frequency int) {
 			logger.SysError("failed to sync channels from database: " + err.Error())
 		}
 		model.GlobalUserGroupRatio.Load()
+		model.GlobalPriceOverrides.Load()
 		model.PricingInstance.Init()
 		model.ModelOwnedBysInstance.Load()
 	}
@@ -3,6 +3,8 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"one-api/common/limit"
+	"one-api/common/logger"
 	"one-api/model"
 	"time"
 
@@ -10,10 +12,11 @@ import (
 )
 
 const (
-	LIMIT_KEY               = "api-limiter:%d"
-	INTERNAL                = 1 * time.Minute
-	RATE_LIMIT_EXCEEDED_MSG = "您的速率达到上限，请稍后再试。"
-	SERVER_ERROR_MSG        = "Server error"
+	LIMIT_KEY                     = "api-limiter:%d"
+	INTERNAL                      = 1 * time.Minute
+	RATE_LIMIT_EXCEEDED_MSG       = "您的速率达到上限，请稍后再试。"
+	TOKEN_RATE_LIMIT_EXCEEDED_MSG = "令牌的请求速率达到上限，请稍后再试。"
+	SERVER_ERROR_MSG              = "Server error"
 )
 
 func DynamicRedisRateLimiter() gin.HandlerFunc {
@@ -34,6 +37,46 @@ func DynamicRedisRateLimiter() gin.HandlerFunc {
 			return
 		}
 
+		if !checkTokenRPM(c) {
+			abortWithMessage(c, http.StatusTooManyRequests, TOKEN_RATE_LIMIT_EXCEEDED_MSG)
+			return
+		}
+
+		// 并发名额在整个 relay 结束后释放，包括流式响应和 realtime 连接
+		slots, ok := acquireConcurrency(c)
+		if !ok {
+			abortWithConcurrencyLimit(c)
+			return
+		}
+		if len(slots) > 0 {
+			stop := keepConcurrency(c, slots)
+			defer func() {
+				stop()
+				releaseConcurrency(c, slots)
+			}()
+		}
+
 		c.Next()
 	}
 }
+
+// checkTokenRPM 按令牌的 RPM 限制请求，同时写入 x-ratelimit-*-requests 响应头
+func checkTokenRPM(c *gin.Context) bool {
+	setting, ok := c.Value("token_setting").(*model.TokenSetting)
+	if !ok || setting == nil {
+		return true
+	}
+	rateSetting := setting.Limits.LimitsRateSetting
+	if !rateSetting.Enabled || rateSetting.RPM <= 0 {
+		return true
+	}
+
+	result, err := limit.NewTokenBucket(rateSetting.RPM).Take(fmt.Sprintf(model.TokenRPMLimitKey, c.GetInt("token_id")), 1)
+	if err != nil {
+		// 限流服务异常时不拦截请求
+		logger.LogError(c.Request.Context(), "token rpm limiter error: "+err.Error())
+		return true
+	}
+	result.SetHeaders(c.Writer.Header(), "requests")
+	return result.Allowed
+}
@@ -1,13 +1,17 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"one-api/common/authutil"
 	"one-api/common/config"
+	"one-api/common/logger"
 	"one-api/common/utils"
 	"one-api/model"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -133,11 +137,26 @@ func tokenAuth(c *gin.Context, credential authutil.Credential) {
 	c.Set("token_group", token.Group)
 	c.Set("token_backup_group", token.BackupGroup)
 	c.Set("token_unlimited_quota", token.UnlimitedQuota)
+	c.Set("token_organization_id", token.OrganizationId)
 	c.Set("token_setting", utils.GetPointer(token.Setting.Data()))
 	if err := checkLimitIP(c); err != nil {
 		abortWithMessage(c, http.StatusForbidden, err.Error())
 		return
 	}
+	if err := checkTokenScope(c); err != nil {
+		abortWithMessage(c, http.StatusForbidden, err.Error())
+		return
+	}
+	if err := checkTokenSpendCaps(c, token); err != nil {
+		var capErr *model.TokenSpendCapError
+		if errors.As(err, &capErr) {
+			c.Header("Retry-After", strconv.Itoa(capErr.RetryAfter()))
+			abortWithMessage(c, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		// 计数读取失败时不拦截请求
+		logger.LogError(c.Request.Context(), "check token spend caps failed: "+err.Error())
+	}
 	if len(credential.SelectorParts) > 0 {
 		if model.IsAdmin(token.UserId) {
 			selector := credential.SelectorParts[0]
@@ -204,6 +223,15 @@ func checkLimitIP(c *gin.Context) (error error) {
 	return fmt.Errorf("IP %s is not allowed to access", ip)
 }
 
+// 检测令牌的消费上限是否已经用完
+func checkTokenSpendCaps(c *gin.Context, token *model.Token) error {
+	setting, ok := c.MustGet("token_setting").(*model.TokenSetting)
+	if !ok || setting == nil {
+		return nil
+	}
+	return model.CheckTokenSpendCaps(token.Id, &setting.Limits.LimitsSpendSetting, 0, time.Now())
+}
+
 func OpenaiAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		tokenAuth(c, authutil.ExtractOpenAIRequestCredential(c.Request))
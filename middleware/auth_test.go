@@ -191,3 +191,41 @@ func TestTokenAuthAdminSelectorBranches(t *testing.T) {
 		t.Fatalf("expected non-admin channel selectors to be rejected, got %d", commonRecorder.Code)
 	}
 }
+
+func TestTokenEndpointScope(t *testing.T) {
+	cases := []struct {
+		method string
+		path   string
+		scope  string
+	}{
+		{http.MethodPost, "/v1/chat/completions", model.TokenScopeChat},
+		{http.MethodPost, "/claude/v1/messages", model.TokenScopeChat},
+		{http.MethodPost, "/v1/responses/compact", model.TokenScopeResponses},
+		{http.MethodPost, "/v1/rerank", model.TokenScopeEmbeddings},
+		{http.MethodPost, "/gemini/v1beta/models/text-embedding-004:embedContent", model.TokenScopeEmbeddings},
+		{http.MethodPost, "/gemini/v1beta/models/gemini-pro:streamGenerateContent", model.TokenScopeChat},
+		{http.MethodPost, "/recraftAI/v1/styles", model.TokenScopeImages},
+		{http.MethodPost, "/v1/audio/speech", model.TokenScopeAudio},
+		{http.MethodGet, "/v1/realtime", model.TokenScopeRealtime},
+		{http.MethodPost, "/fast/mj/submit/imagine", model.TokenScopeTasks},
+		{http.MethodPost, "/kling/v1/videos/text2video", model.TokenScopeTasks},
+		{http.MethodGet, "/v1/files/file-1", model.TokenScopeRaw},
+		{http.MethodDelete, "/v1/models/ft:gpt", model.TokenScopeRaw},
+		{http.MethodGet, "/v1/models", ""},
+		{http.MethodGet, "/gemini/v1beta/models", ""},
+		{http.MethodGet, "/dashboard/billing/usage", ""},
+	}
+	for _, item := range cases {
+		if scope := tokenEndpointScope(item.method, item.path); scope != item.scope {
+			t.Fatalf("expected %s %s to be scope %q, got %q", item.method, item.path, item.scope, scope)
+		}
+	}
+
+	setting := model.LimitsScopeSetting{Enabled: true, Scopes: []string{model.TokenScopeEmbeddings}}
+	if setting.Allows(model.TokenScopeImages) || setting.Allows(model.TokenScopeRealtime) {
+		t.Fatal("expected embeddings-only scope to reject images and realtime")
+	}
+	if !setting.Allows(model.TokenScopeEmbeddings) || !setting.Allows("") {
+		t.Fatal("expected embeddings and unscoped endpoints to be allowed")
+	}
+}
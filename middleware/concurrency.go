@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common/limit"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	USER_CONCURRENCY_KEY       = "concurrency:user:%d"
+	TOKEN_CONCURRENCY_KEY      = "concurrency:token:%d"
+	CONCURRENCY_LIMIT_CODE     = "concurrency_limit_exceeded"
+	CONCURRENCY_EXCEEDED_MSG   = "并发请求数达到上限，请等待之前的请求完成后再试。"
+	CONCURRENCY_LEASE          = 60 * time.Second
+	CONCURRENCY_RENEW_INTERVAL = CONCURRENCY_LEASE / 3
+)
+
+// 租约到期后自动释放，避免进程崩溃时名额被永久占用
+var concurrencySemaphore = limit.NewSemaphore(CONCURRENCY_LEASE)
+
+type concurrencySlot struct {
+	key    string
+	holder string
+}
+
+// acquireConcurrency 依次占用令牌和用户的并发名额，任一超限时释放已占用的名额并返回 false。
+// 限流服务异常时不拦截请求。
+func acquireConcurrency(c *gin.Context) ([]concurrencySlot, bool) {
+	type target struct {
+		key   string
+		limit int
+	}
+	targets := make([]target, 0, 2)
+
+	if setting, ok := c.Value("token_setting").(*model.TokenSetting); ok && setting != nil {
+		if concurrency := setting.Limits.LimitsConcurrencySetting; concurrency.Enabled && concurrency.Max > 0 {
+			targets = append(targets, target{fmt.Sprintf(TOKEN_CONCURRENCY_KEY, c.GetInt("token_id")), concurrency.Max})
+		}
+	}
+	if userLimit := getUserMaxConcurrency(c); userLimit > 0 {
+		targets = append(targets, target{fmt.Sprintf(USER_CONCURRENCY_KEY, c.GetInt("id")), userLimit})
+	}
+
+	slots := make([]concurrencySlot, 0, len(targets))
+	for _, item := range targets {
+		holder, ok, err := concurrencySemaphore.Acquire(item.key, item.limit)
+		if err != nil {
+			logger.LogError(c.Request.Context(), "concurrency limiter error: "+err.Error())
+			continue
+		}
+		if !ok {
+			releaseConcurrency(c, slots)
+			return nil, false
+		}
+		slots = append(slots, concurrencySlot{key: item.key, holder: holder})
+	}
+	return slots, true
+}
+
+// getUserMaxConcurrency 用户未单独设置时使用分组的设置，-1 表示不限制
+func getUserMaxConcurrency(c *gin.Context) int {
+	userLimit, err := model.CacheGetUserMaxConcurrency(c.GetInt("id"))
+	if err != nil {
+		logger.LogError(c.Request.Context(), "get user max concurrency error: "+err.Error())
+		return 0
+	}
+	if userLimit != 0 {
+		return userLimit
+	}
+	if userGroup := model.GlobalUserGroupRatio.GetBySymbol(c.GetString("group")); userGroup != nil {
+		return userGroup.MaxConcurrency
+	}
+	return 0
+}
+
+func releaseConcurrency(c *gin.Context, slots []concurrencySlot) {
+	for _, slot := range slots {
+		if err := concurrencySemaphore.Release(slot.key, slot.holder); err != nil {
+			logger.LogError(c.Request.Context(), "release concurrency error: "+err.Error())
+		}
+	}
+}
+
+// keepConcurrency 请求处理期间定时续约，流式和 realtime 请求可能远超租约时长
+func keepConcurrency(c *gin.Context, slots []concurrencySlot) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(CONCURRENCY_RENEW_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, slot := range slots {
+					if err := concurrencySemaphore.Renew(slot.key, slot.holder); err != nil {
+						logger.LogError(c.Request.Context(), "renew concurrency error: "+err.Error())
+					}
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func abortWithConcurrencyLimit(c *gin.Context) {
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": gin.H{
+			"message": utils.MessageWithRequestId(CONCURRENCY_EXCEEDED_MSG, c.GetString(logger.RequestIdKey)),
+			"type":    "one_hub_error",
+			"code":    CONCURRENCY_LIMIT_CODE,
+		},
+	})
+	c.Abort()
+	logger.LogError(c.Request.Context(), CONCURRENCY_EXCEEDED_MSG)
+}
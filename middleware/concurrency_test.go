@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"one-api/common/logger"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestConcurrencyLimitUserAndToken(t *testing.T) {
+	useTestAuthDB(t)
+	logger.Logger = zap.NewNop()
+
+	user := &model.User{Username: "concurrency", Password: "password123", AccessToken: "concurrency-access", AffCode: "conc", MaxConcurrency: 2}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatalf("expected user to be created, got %v", err)
+	}
+
+	newContext := func(tokenId int, tokenMax int) *gin.Context {
+		ctx, _ := newAuthTestContext(http.MethodPost, "/v1/chat/completions")
+		ctx.Set("id", user.Id)
+		ctx.Set("token_id", tokenId)
+		setting := &model.TokenSetting{}
+		setting.Limits.LimitsConcurrencySetting = model.LimitsConcurrencySetting{Enabled: tokenMax > 0, Max: tokenMax}
+		ctx.Set("token_setting", setting)
+		return ctx
+	}
+
+	first, ok := acquireConcurrency(newContext(1, 1))
+	if !ok || len(first) != 2 {
+		t.Fatalf("expected first request to hold token and user slots, got %v %v", first, ok)
+	}
+	if _, ok := acquireConcurrency(newContext(1, 1)); ok {
+		t.Fatal("expected token concurrency limit to reject the second request")
+	}
+
+	second, ok := acquireConcurrency(newContext(2, 0))
+	if !ok || len(second) != 1 {
+		t.Fatalf("expected another token to hold a user slot, got %v %v", second, ok)
+	}
+	if _, ok := acquireConcurrency(newContext(3, 0)); ok {
+		t.Fatal("expected user concurrency limit to reject the third request")
+	}
+
+	releaseConcurrency(newContext(1, 1), first)
+	third, ok := acquireConcurrency(newContext(1, 1))
+	if !ok {
+		t.Fatal("expected released slots to be reusable")
+	}
+	releaseConcurrency(newContext(1, 1), third)
+	releaseConcurrency(newContext(2, 0), second)
+}
+
+func TestAbortWithConcurrencyLimitUsesDistinctCode(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	ctx, recorder := newAuthTestContext(http.MethodPost, "/v1/chat/completions")
+
+	abortWithConcurrencyLimit(ctx)
+
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", recorder.Code)
+	}
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected json body, got %v", err)
+	}
+	if body.Error.Code != CONCURRENCY_LIMIT_CODE {
+		t.Fatalf("expected code %s, got %s", CONCURRENCY_LIMIT_CODE, body.Error.Code)
+	}
+}
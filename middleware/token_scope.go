@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/model"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var rawPassThroughPrefixes = []string{
+	"/v1/files",
+	"/v1/fine_tuning/",
+	"/v1/assistants",
+	"/v1/threads",
+	"/v1/batches/",
+	"/v1/vector_stores/",
+}
+
+// tokenEndpointScope 返回请求路径对应的令牌接口范围，模型列表、账单查询等接口返回空
+func tokenEndpointScope(method, path string) string {
+	switch {
+	case path == "/v1/realtime":
+		return model.TokenScopeRealtime
+	case strings.HasPrefix(path, "/v1/chat/completions"),
+		strings.HasPrefix(path, "/v1/completions"),
+		strings.HasPrefix(path, "/v1/moderations"),
+		strings.HasPrefix(path, "/claude/v1/messages"):
+		return model.TokenScopeChat
+	case strings.HasPrefix(path, "/v1/responses"):
+		return model.TokenScopeResponses
+	case strings.HasPrefix(path, "/v1/embeddings"), strings.HasPrefix(path, "/v1/rerank"):
+		return model.TokenScopeEmbeddings
+	case strings.HasPrefix(path, "/v1/images/"), strings.HasPrefix(path, "/recraftAI/"):
+		return model.TokenScopeImages
+	case strings.HasPrefix(path, "/v1/audio/"):
+		return model.TokenScopeAudio
+	case strings.HasPrefix(path, "/suno/"), strings.HasPrefix(path, "/kling/"), strings.Contains(path, "/mj/"):
+		return model.TokenScopeTasks
+	case strings.HasPrefix(path, "/gemini/") && method == http.MethodPost:
+		if strings.HasSuffix(path, ":embedContent") || strings.HasSuffix(path, ":batchEmbedContents") {
+			return model.TokenScopeEmbeddings
+		}
+		return model.TokenScopeChat
+	case strings.HasPrefix(path, "/v1/models/") && method == http.MethodDelete:
+		return model.TokenScopeRaw
+	}
+
+	for _, prefix := range rawPassThroughPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return model.TokenScopeRaw
+		}
+	}
+	return ""
+}
+
+// 检测令牌是否允许调用当前接口
+func checkTokenScope(c *gin.Context) error {
+	setting, ok := c.MustGet("token_setting").(*model.TokenSetting)
+	if !ok || setting == nil {
+		return nil
+	}
+	scope := tokenEndpointScope(c.Request.Method, c.Request.URL.Path)
+	if setting.Limits.LimitsScopeSetting.Allows(scope) {
+		return nil
+	}
+	return fmt.Errorf("Endpoint scope %s is not allowed for current token", scope)
+}
@@ -0,0 +1,273 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AffCommissionStatusCredited = "credited"
+	AffCommissionStatusBlocked  = "blocked" // 疑似自我邀请，未发放
+)
+
+var (
+	ErrAffTransferQuotaInvalid = errors.New("转入额度无效")
+	ErrAffQuotaInsufficient    = errors.New("佣金余额不足")
+
+	errAffCommissionDuplicated = errors.New("aff commission duplicated")
+)
+
+// AffCommission 邀请佣金账本，每笔被邀请用户的充值订单一条
+type AffCommission struct {
+	Id            int     `json:"id"`
+	InviterId     int     `json:"inviter_id" gorm:"index"`
+	InviteeId     int     `json:"invitee_id" gorm:"index"`
+	OrderId       int     `json:"order_id" gorm:"uniqueIndex"`
+	TradeNo       string  `json:"trade_no" gorm:"type:varchar(50)"`
+	BaseQuota     int     `json:"base_quota" gorm:"default:0"` // 按实际支付金额折算的额度
+	Rate          float64 `json:"rate" gorm:"type:decimal(5,2);default:0"`
+	Quota         int     `json:"quota" gorm:"default:0"`
+	ReversedQuota int     `json:"reversed_quota" gorm:"default:0"` // 订单退款后扣回的佣金
+	Status        string  `json:"status" gorm:"type:varchar(16)"`
+	Remark        string  `json:"remark" gorm:"type:varchar(255);default:''"`
+	CreatedTime   int64   `json:"created_time" gorm:"bigint;index"`
+}
+
+// affOrderPaidQuota 订单实际支付金额（不含手续费）折算的额度，折扣充值按折后金额计算
+func affOrderPaidQuota(order *Order) int {
+	paid := order.OrderAmount
+	if order.OrderCurrency != CurrencyTypeUSD && config.PaymentUSDRate > 0 {
+		paid = paid / config.PaymentUSDRate
+	}
+	paid -= order.Fee
+	return min(max(int(paid*config.QuotaPerUnit), 0), order.Quota)
+}
+
+// checkAffSelfReferral 邀请人和被邀请人疑似同一人时返回原因
+func checkAffSelfReferral(inviter, invitee *User) string {
+	switch {
+	case inviter.Id == invitee.Id:
+		return "邀请人与被邀请人相同"
+	case inviter.InviterId == invitee.Id:
+		return "邀请人与被邀请人互相邀请"
+	case inviter.Email != "" && inviter.Email == invitee.Email:
+		return "邀请人与被邀请人邮箱相同"
+	case inviter.LastLoginIp != "" && inviter.LastLoginIp == invitee.LastLoginIp:
+		return "邀请人与被邀请人登录 IP 相同"
+	}
+	return ""
+}
+
+// CreditAffCommission 被邀请用户的充值订单成功后给邀请人发放佣金到 AffQuota，
+// 不满足条件时返回 nil。同一订单只会发放一次。
+func CreditAffCommission(order *Order) (*AffCommission, error) {
+	if config.AffCommissionRate <= 0 || order.PlanId > 0 {
+		return nil, nil
+	}
+	invitee, err := GetUserById(order.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+	if invitee.InviterId == 0 {
+		return nil, nil
+	}
+	if config.AffCommissionMonths > 0 && time.Unix(invitee.CreatedTime, 0).AddDate(0, config.AffCommissionMonths, 0).Before(time.Now()) {
+		return nil, nil
+	}
+	inviter, err := GetUserById(invitee.InviterId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	baseQuota := affOrderPaidQuota(order)
+	commission := &AffCommission{
+		InviterId:   inviter.Id,
+		InviteeId:   invitee.Id,
+		OrderId:     order.ID,
+		TradeNo:     order.TradeNo,
+		BaseQuota:   baseQuota,
+		Rate:        config.AffCommissionRate,
+		Quota:       int(math.Round(float64(baseQuota) * config.AffCommissionRate / 100)),
+		Status:      AffCommissionStatusCredited,
+		CreatedTime: utils.GetTimestamp(),
+	}
+	if reason := checkAffSelfReferral(inviter, invitee); reason != "" {
+		commission.Status = AffCommissionStatusBlocked
+		commission.Remark = reason
+	}
+	if commission.Quota <= 0 {
+		return nil, nil
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&AffCommission{}).Where("order_id = ?", order.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errAffCommissionDuplicated
+		}
+		if err := tx.Create(commission).Error; err != nil {
+			return err
+		}
+		if commission.Status != AffCommissionStatusCredited {
+			return nil
+		}
+		return tx.Model(&User{}).Where("id = ?", inviter.Id).Updates(map[string]any{
+			"aff_quota":   gorm.Expr("aff_quota + ?", commission.Quota),
+			"aff_history": gorm.Expr("aff_history + ?", commission.Quota),
+		}).Error
+	})
+	if errors.Is(err, errAffCommissionDuplicated) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if commission.Status == AffCommissionStatusCredited {
+		RecordLog(inviter.Id, LogTypeSystem, fmt.Sprintf("邀请用户 #%d 充值，获得佣金 %s", invitee.Id, common.LogQuota(commission.Quota)))
+	}
+	return commission, nil
+}
+
+// ReverseAffCommission 订单退款后按扣回额度的比例扣回佣金，扣回后 AffQuota 可能为负
+func ReverseAffCommission(order *Order, refundQuota int) (int, error) {
+	commission := &AffCommission{}
+	err := DB.Where("order_id = ? AND status = ?", order.ID, AffCommissionStatusCredited).First(commission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil || order.Quota <= 0 {
+		return 0, err
+	}
+
+	reversed := int(math.Round(float64(commission.Quota) * float64(refundQuota) / float64(order.Quota)))
+	if order.RefundQuota >= order.Quota {
+		reversed = commission.Quota - commission.ReversedQuota
+	}
+	reversed = min(reversed, commission.Quota-commission.ReversedQuota)
+	if reversed <= 0 {
+		return 0, nil
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AffCommission{}).Where("id = ? AND reversed_quota = ?", commission.Id, commission.ReversedQuota).
+			Update("reversed_quota", gorm.Expr("reversed_quota + ?", reversed))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderRefundConflict
+		}
+		return tx.Model(&User{}).Where("id = ?", commission.InviterId).Updates(map[string]any{
+			"aff_quota":   gorm.Expr("aff_quota - ?", reversed),
+			"aff_history": gorm.Expr("aff_history - ?", reversed),
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	RecordLog(commission.InviterId, LogTypeSystem, fmt.Sprintf("邀请用户 #%d 的订单退款，扣回佣金 %s", commission.InviteeId, common.LogQuota(reversed)))
+	return reversed, nil
+}
+
+// TransferAffQuota 把佣金转入可用余额，单次转入不能低于 AffTransferMinQuota
+func TransferAffQuota(userId int, quota int) error {
+	if quota <= 0 {
+		return ErrAffTransferQuotaInvalid
+	}
+	if quota < config.AffTransferMinQuota {
+		return fmt.Errorf("单次转入额度不能低于 %s", common.LogQuota(config.AffTransferMinQuota))
+	}
+	result := DB.Model(&User{}).Where("id = ? AND aff_quota >= ?", userId, quota).Updates(map[string]any{
+		"aff_quota": gorm.Expr("aff_quota - ?", quota),
+		"quota":     gorm.Expr("quota + ?", quota),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAffQuotaInsufficient
+	}
+
+	refreshUserQuotaCache(userId)
+	RecordQuotaLog(userId, LogTypeTopup, quota, "", fmt.Sprintf("邀请佣金转入余额 %s", common.LogQuota(quota)))
+	return nil
+}
+
+var allowedAffCommissionOrderFields = map[string]bool{
+	"id":           true,
+	"invitee_id":   true,
+	"quota":        true,
+	"created_time": true,
+}
+
+func GetAffCommissionsList(inviterId int, params *PaginationParams) (*DataResult[AffCommission], error) {
+	var commissions []*AffCommission
+	db := DB.Where("inviter_id = ?", inviterId)
+
+	return PaginateAndOrder(db, params, &commissions, allowedAffCommissionOrderFields)
+}
+
+// AffInviteeEarning 邀请人从每个被邀请用户获得的佣金
+type AffInviteeEarning struct {
+	InviteeId     int    `json:"invitee_id"`
+	Username      string `json:"username"`
+	CreatedTime   int64  `json:"created_time"`
+	OrderCount    int64  `json:"order_count"`
+	Quota         int64  `json:"quota"`
+	ReversedQuota int64  `json:"reversed_quota"`
+}
+
+// GetAffInviteeEarnings 列出邀请的用户及其带来的佣金，用户名只显示前两位
+func GetAffInviteeEarnings(inviterId int) ([]*AffInviteeEarning, error) {
+	var invitees []*User
+	err := DB.Select("id", "username", "created_time").Where("inviter_id = ?", inviterId).Order("id desc").Find(&invitees).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var sums []*AffInviteeEarning
+	err = DB.Model(&AffCommission{}).
+		Select("invitee_id", "count(*) as order_count", "sum(quota) as quota", "sum(reversed_quota) as reversed_quota").
+		Where("inviter_id = ? AND status = ?", inviterId, AffCommissionStatusCredited).
+		Group("invitee_id").Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+	sumByInvitee := make(map[int]*AffInviteeEarning, len(sums))
+	for _, sum := range sums {
+		sumByInvitee[sum.InviteeId] = sum
+	}
+
+	earnings := make([]*AffInviteeEarning, 0, len(invitees))
+	for _, invitee := range invitees {
+		earning := &AffInviteeEarning{}
+		if sum, ok := sumByInvitee[invitee.Id]; ok {
+			earning = sum
+		}
+		earning.InviteeId = invitee.Id
+		earning.Username = maskAffUsername(invitee.Username)
+		earning.CreatedTime = invitee.CreatedTime
+		earnings = append(earnings, earning)
+	}
+	return earnings, nil
+}
+
+func maskAffUsername(username string) string {
+	runes := []rune(username)
+	if len(runes) <= 2 {
+		return string(runes[:min(len(runes), 1)]) + "***"
+	}
+	return string(runes[:2]) + "***"
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useAffCommissionTestDB(t *testing.T) {
+	t.Helper()
+
+	logger.Logger = zap.NewNop()
+	originalRate, originalMonths, originalMin := config.AffCommissionRate, config.AffCommissionMonths, config.AffTransferMinQuota
+	config.AffCommissionRate = 10
+	config.AffCommissionMonths = 12
+
+	originalDB := DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&User{}, &Log{}, &Order{}, &OrderRefund{}, &AffCommission{}); err != nil {
+		t.Fatalf("expected aff commission schema migration to succeed, got %v", err)
+	}
+
+	DB = testDB
+	t.Cleanup(func() {
+		DB = originalDB
+		config.AffCommissionRate, config.AffCommissionMonths, config.AffTransferMinQuota = originalRate, originalMonths, originalMin
+	})
+
+	users := []*User{
+		{Id: 1, Username: "inviter", LastLoginIp: "10.0.0.1"},
+		{Id: 2, Username: "invitee", InviterId: 1, LastLoginIp: "10.0.0.2"},
+		{Id: 3, Username: "sockpuppet", InviterId: 1, LastLoginIp: "10.0.0.1"},
+	}
+	for _, user := range users {
+		user.Password = "password123"
+		user.AccessToken = fmt.Sprintf("access-token-%d", user.Id)
+		user.AffCode = fmt.Sprintf("aff-%d", user.Id)
+		user.Status = config.UserStatusEnabled
+		user.CreatedTime = time.Now().Unix()
+		if err := DB.Create(user).Error; err != nil {
+			t.Fatalf("expected user fixture to persist, got %v", err)
+		}
+	}
+}
+
+func createAffTestOrder(t *testing.T, userId int, tradeNo string) *Order {
+	t.Helper()
+	// 充值 10 美元，9 折后实际支付 9 美元
+	order := &Order{
+		UserId:        userId,
+		TradeNo:       tradeNo,
+		Amount:        10,
+		OrderAmount:   9,
+		OrderCurrency: CurrencyTypeUSD,
+		Quota:         10 * int(config.QuotaPerUnit),
+		Status:        OrderStatusSuccess,
+	}
+	if err := DB.Create(order).Error; err != nil {
+		t.Fatalf("expected order fixture to persist, got %v", err)
+	}
+	return order
+}
+
+func getAffTestUser(t *testing.T, id int) *User {
+	t.Helper()
+	user, err := GetUserById(id, false)
+	if err != nil {
+		t.Fatalf("expected user %d, got %v", id, err)
+	}
+	return user
+}
+
+func TestCreditAffCommissionOnPaidAmountOnce(t *testing.T) {
+	useAffCommissionTestDB(t)
+	order := createAffTestOrder(t, 2, "T1")
+
+	commission, err := CreditAffCommission(order)
+	if err != nil || commission == nil {
+		t.Fatalf("expected commission to be credited, got %+v, %v", commission, err)
+	}
+	want := int(9 * config.QuotaPerUnit / 10)
+	if commission.Quota != want || commission.Status != AffCommissionStatusCredited {
+		t.Fatalf("expected 10%% of the paid amount, got quota=%d status=%s", commission.Quota, commission.Status)
+	}
+
+	// 重复的支付回调不会重复发放
+	if commission, err := CreditAffCommission(order); err != nil || commission != nil {
+		t.Fatalf("expected duplicated order to be ignored, got %+v, %v", commission, err)
+	}
+	if inviter := getAffTestUser(t, 1); inviter.AffQuota != want || inviter.AffHistoryQuota != want {
+		t.Fatalf("expected aff quota %d, got quota=%d history=%d", want, inviter.AffQuota, inviter.AffHistoryQuota)
+	}
+
+	// 退款一半后扣回一半佣金
+	order.RefundQuota = order.Quota / 2
+	reversed, err := ReverseAffCommission(order, order.Quota/2)
+	if err != nil || reversed != want/2 {
+		t.Fatalf("expected half of the commission reversed, got %d, %v", reversed, err)
+	}
+	if inviter := getAffTestUser(t, 1); inviter.AffQuota != want-want/2 {
+		t.Fatalf("expected aff quota to be reduced, got %d", inviter.AffQuota)
+	}
+}
+
+func TestCreditAffCommissionBlocksSelfReferral(t *testing.T) {
+	useAffCommissionTestDB(t)
+	order := createAffTestOrder(t, 3, "T2")
+
+	commission, err := CreditAffCommission(order)
+	if err != nil || commission == nil || commission.Status != AffCommissionStatusBlocked {
+		t.Fatalf("expected commission to be blocked, got %+v, %v", commission, err)
+	}
+	if inviter := getAffTestUser(t, 1); inviter.AffQuota != 0 {
+		t.Fatalf("expected blocked commission not to be credited, got %d", inviter.AffQuota)
+	}
+
+	earnings, err := GetAffInviteeEarnings(1)
+	if err != nil || len(earnings) != 2 {
+		t.Fatalf("expected both invitees to be listed, got %d, %v", len(earnings), err)
+	}
+	for _, earning := range earnings {
+		if earning.Quota != 0 || earning.Username == "sockpuppet" {
+			t.Fatalf("expected masked invitee without earnings, got %+v", earning)
+		}
+	}
+}
+
+func TestCreditAffCommissionSkipsExpiredInvitee(t *testing.T) {
+	useAffCommissionTestDB(t)
+	DB.Model(&User{}).Where("id = ?", 2).Update("created_time", time.Now().AddDate(-2, 0, 0).Unix())
+
+	commission, err := CreditAffCommission(createAffTestOrder(t, 2, "T3"))
+	if err != nil || commission != nil {
+		t.Fatalf("expected no commission after the commission period, got %+v, %v", commission, err)
+	}
+}
+
+func TestTransferAffQuotaRequiresMinimum(t *testing.T) {
+	useAffCommissionTestDB(t)
+	config.AffTransferMinQuota = 1000
+	DB.Model(&User{}).Where("id = ?", 1).Update("aff_quota", 1500)
+
+	if err := TransferAffQuota(1, 500); err == nil {
+		t.Fatal("expected transfer below the minimum to be rejected")
+	}
+	if err := TransferAffQuota(1, 2000); !errors.Is(err, ErrAffQuotaInsufficient) {
+		t.Fatalf("expected insufficient aff quota, got %v", err)
+	}
+	if err := TransferAffQuota(1, 1200); err != nil {
+		t.Fatalf("expected transfer to succeed, got %v", err)
+	}
+	if inviter := getAffTestUser(t, 1); inviter.AffQuota != 300 || inviter.Quota != 1200 {
+		t.Fatalf("expected aff quota moved to quota, got aff=%d quota=%d", inviter.AffQuota, inviter.Quota)
+	}
+}
@@ -92,6 +92,28 @@ func FilterDisabledStream(modelName string) ChannelsFilterFunc {
 	}
 }
 
+func FilterChannelTag(tag string) ChannelsFilterFunc {
+	return func(_ int, choice *ChannelChoice) bool {
+		return choice.Channel.Tag != tag
+	}
+}
+
+func FilterChannelRegion(region string) ChannelsFilterFunc {
+	return func(_ int, choice *ChannelChoice) bool {
+		return !strings.EqualFold(choice.Channel.Region, region)
+	}
+}
+
+// FilterMaxUpstreamPrice 跳过上游价格高于 maxPrice 的渠道，maxPrice 单位为美元：
+// 按 token 计费的模型为每百万 token 的输入/输出价格，按次计费的模型为每次请求价格
+func FilterMaxUpstreamPrice(modelName string, maxPrice float64) ChannelsFilterFunc {
+	price := lookupModelPrice(modelName)
+	return func(_ int, choice *ChannelChoice) bool {
+		input, output := choice.Channel.UpstreamPrice(modelName, price)
+		return upstreamPriceUSD(price, max(input, output)) > maxPrice
+	}
+}
+
 func init() {
 	// 每小时清理一次过期的冷却时间
 	go func() {
@@ -156,6 +178,10 @@ func (cc *ChannelsChooser) Enable(channelId int) {
 	if _, ok := cc.Channels[channelId]; !ok {
 		return
 	}
+	// 处于调度禁用窗口的渠道由定时任务负责恢复
+	if cc.Channels[channelId].Channel.scheduleDisabled() {
+		return
+	}
 
 	cc.Channels[channelId].Disable = false
 }
@@ -233,6 +259,17 @@ func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFu
 		return validChannels[0].Channel
 	}
 
+	if cheapest := cc.cheapestChannels(validChannels, modelName); len(cheapest) != len(validChannels) {
+		validChannels = cheapest
+		if len(validChannels) == 1 {
+			return validChannels[0].Channel
+		}
+		totalWeight = 0
+		for _, choice := range validChannels {
+			totalWeight += int(*choice.Channel.Weight)
+		}
+	}
+
 	choiceWeight := rand.Intn(totalWeight)
 	for _, choice := range validChannels {
 		weight := int(*choice.Channel.Weight)
@@ -439,6 +476,7 @@ func normalizeChannelForChooser(channel *Channel) {
 
 	channel.SetProxy()
 	channel.ParseRuntimeConfig()
+	applyChannelScheduleWeight(channel)
 	if channel.Weight == nil || *channel.Weight == 0 {
 		channel.Weight = &config.DefaultChannelWeight
 	}
@@ -542,7 +580,7 @@ func (cc *ChannelsChooser) loadLocked() error {
 		newChannels[channel.Id] = &ChannelChoice{
 			Channel:       channel,
 			CooldownsTime: 0,
-			Disable:       false,
+			Disable:       channel.scheduleDisabled(),
 		}
 
 		// 处理groups和models
@@ -567,7 +605,7 @@ func (cc *ChannelsChooser) loadLocked() error {
 				}
 
 				// 按priority分组存储channelId
-				priority := *channel.Priority
+				priority := channel.balancePriority(channel.schedulePriority())
 				channelGroups[key][priority] = append(channelGroups[key][priority], channel.Id)
 
 				// 处理通配符模型
@@ -0,0 +1,317 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"one-api/common/cache"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/notify"
+	"one-api/common/redis"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 预算使用达到这些百分比时发送告警，每个月每个档位只发送一次
+var BillingTagBudgetAlertLevels = []int{50, 80, 100}
+
+var (
+	BillingTagBudgetCacheKey = "billing_tag_budget:%s"
+
+	ErrBillingTagBudgetExhausted = errors.New("费用标签的本月预算已用完")
+)
+
+// BillingTagStatistic 按天、费用标签、模型汇总的用量
+type BillingTagStatistic struct {
+	Id               int    `json:"-"`
+	Date             string `json:"date" gorm:"type:varchar(10);uniqueIndex:idx_billing_tag_statistic"`
+	BillingTag       string `json:"billing_tag" gorm:"type:varchar(64);uniqueIndex:idx_billing_tag_statistic"`
+	ModelName        string `json:"model_name" gorm:"type:varchar(255);uniqueIndex:idx_billing_tag_statistic"`
+	RequestCount     int    `json:"request_count"`
+	Quota            int    `json:"quota"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+// BillingTagBudget 费用标签的月度预算，Budget 单位为 USD
+type BillingTagBudget struct {
+	Id          int     `json:"id"`
+	BillingTag  string  `json:"billing_tag" gorm:"type:varchar(64);uniqueIndex"`
+	Budget      float64 `json:"budget" gorm:"default:0"`
+	Block       bool    `json:"block" gorm:"default:false"`    // 预算用完后拒绝请求
+	Period      string  `json:"period" gorm:"type:varchar(7)"` // UsedQuota 所属的月份
+	UsedQuota   int     `json:"used_quota" gorm:"default:0"`   // 本月已使用的额度
+	AlertLevel  int     `json:"alert_level" gorm:"default:0"`  // 本月已告警的最高百分比
+	CreatedTime int64   `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64   `json:"updated_time" gorm:"bigint"`
+}
+
+func billingTagPeriod(now time.Time) string {
+	return now.Format("2006-01")
+}
+
+func (budget *BillingTagBudget) budgetQuota() int {
+	return int(budget.Budget * config.QuotaPerUnit)
+}
+
+// alertLevel 返回 used 对应的最高告警档位，没有达到任何档位时返回 0
+func (budget *BillingTagBudget) alertLevel(used int) int {
+	limit := budget.budgetQuota()
+	if limit <= 0 {
+		return 0
+	}
+	level := 0
+	for _, item := range BillingTagBudgetAlertLevels {
+		if used*100 >= limit*item {
+			level = item
+		}
+	}
+	return level
+}
+
+// normalize 跨月后还没有新的消费时，按本月未使用展示
+func (budget *BillingTagBudget) normalize(now time.Time) {
+	if period := billingTagPeriod(now); budget.Period != period {
+		budget.Period = period
+		budget.UsedQuota = 0
+		budget.AlertLevel = 0
+	}
+}
+
+func GetBillingTagBudgets() ([]*BillingTagBudget, error) {
+	var budgets []*BillingTagBudget
+	if err := DB.Order("billing_tag asc").Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, budget := range budgets {
+		budget.normalize(now)
+	}
+	return budgets, nil
+}
+
+func GetBillingTagBudget(tag string) (*BillingTagBudget, error) {
+	var budget BillingTagBudget
+	err := DB.Where("billing_tag = ?", tag).First(&budget).Error
+	return &budget, err
+}
+
+// SaveBillingTagBudget 创建或修改费用标签的预算。新建时以本月已有的用量作为起点，
+// 修改预算时按新的预算重新计算已告警的档位，不会补发告警。
+func SaveBillingTagBudget(tag string, amount float64, block bool) (*BillingTagBudget, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || len(tag) > 64 {
+		return nil, errors.New("费用标签不能为空且不超过 64 个字符")
+	}
+	if amount < 0 {
+		return nil, errors.New("预算不能为负数")
+	}
+
+	now := time.Now()
+	budget, err := GetBillingTagBudget(tag)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		used, err := getBillingTagMonthQuota(tag, now)
+		if err != nil {
+			return nil, err
+		}
+		budget = &BillingTagBudget{
+			BillingTag:  tag,
+			Period:      billingTagPeriod(now),
+			UsedQuota:   used,
+			CreatedTime: now.Unix(),
+		}
+	}
+	budget.normalize(now)
+	budget.Budget = amount
+	budget.Block = block
+	budget.AlertLevel = budget.alertLevel(budget.UsedQuota)
+	budget.UpdatedTime = now.Unix()
+	if err := DB.Save(budget).Error; err != nil {
+		return nil, err
+	}
+	refreshBillingTagBudgetCache(tag)
+	return budget, nil
+}
+
+func DeleteBillingTagBudget(id int) error {
+	budget := &BillingTagBudget{}
+	if err := DB.First(budget, id).Error; err != nil {
+		return errors.New("预算不存在")
+	}
+	if err := DB.Delete(budget).Error; err != nil {
+		return err
+	}
+	refreshBillingTagBudgetCache(budget.BillingTag)
+	return nil
+}
+
+// CacheGetBillingTagBudget 没有设置预算时返回 Id 为 0 的预算
+func CacheGetBillingTagBudget(tag string) (*BillingTagBudget, error) {
+	load := func() (*BillingTagBudget, error) {
+		budget, err := GetBillingTagBudget(tag)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &BillingTagBudget{}, nil
+		}
+		return budget, err
+	}
+	if !config.RedisEnabled {
+		return load()
+	}
+
+	return cache.GetOrSetCache(
+		fmt.Sprintf(BillingTagBudgetCacheKey, tag),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		load,
+		cache.CacheTimeout)
+}
+
+func refreshBillingTagBudgetCache(tag string) {
+	if !config.RedisEnabled {
+		return
+	}
+	redis.RedisDel(fmt.Sprintf(BillingTagBudgetCacheKey, tag))
+}
+
+func getBillingTagMonthQuota(tag string, now time.Time) (int, error) {
+	var quota int
+	err := DB.Model(&BillingTagStatistic{}).
+		Select("COALESCE(SUM(quota), 0)").
+		Where("billing_tag = ? AND date >= ?", tag, billingTagPeriod(now)+"-01").
+		Scan(&quota).Error
+	return quota, err
+}
+
+// CheckBillingTagBudget 预算设置为用完后拒绝请求且本月预算已用完时返回错误。
+// 查询失败时只记录日志，不影响请求。
+func CheckBillingTagBudget(tag string, now time.Time) error {
+	if tag == "" {
+		return nil
+	}
+	budget, err := CacheGetBillingTagBudget(tag)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to get budget of billing tag %s: %s", tag, err.Error()))
+		return nil
+	}
+	if budget.Id == 0 || !budget.Block || budget.budgetQuota() <= 0 || budget.Period != billingTagPeriod(now) {
+		return nil
+	}
+	if budget.UsedQuota >= budget.budgetQuota() {
+		return ErrBillingTagBudgetExhausted
+	}
+	return nil
+}
+
+// RecordBillingTagUsage 结算后累加费用标签的用量和本月预算的使用额度
+func RecordBillingTagUsage(tag, modelName string, quota, promptTokens, completionTokens int, now time.Time) error {
+	if tag == "" {
+		return nil
+	}
+	statistic := &BillingTagStatistic{
+		Date:             now.Format("2006-01-02"),
+		BillingTag:       tag,
+		ModelName:        modelName,
+		RequestCount:     1,
+		Quota:            quota,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+	}
+	err := DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "date"}, {Name: "billing_tag"}, {Name: "model_name"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"request_count":     gorm.Expr("billing_tag_statistics.request_count + ?", 1),
+			"quota":             gorm.Expr("billing_tag_statistics.quota + ?", quota),
+			"prompt_tokens":     gorm.Expr("billing_tag_statistics.prompt_tokens + ?", promptTokens),
+			"completion_tokens": gorm.Expr("billing_tag_statistics.completion_tokens + ?", completionTokens),
+		}),
+	}).Create(statistic).Error
+	if err != nil || quota <= 0 {
+		return err
+	}
+
+	// MySQL 按顺序执行 SET，period 必须放在最后，前面的 CASE 才能读到旧的月份
+	period := billingTagPeriod(now)
+	result := DB.Exec("UPDATE billing_tag_budgets SET "+
+		"used_quota = CASE WHEN period = ? THEN used_quota + ? ELSE ? END, "+
+		"alert_level = CASE WHEN period = ? THEN alert_level ELSE 0 END, "+
+		"period = ? WHERE billing_tag = ?",
+		period, quota, quota, period, period, tag)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	return checkBillingTagBudgetAlert(tag)
+}
+
+// checkBillingTagBudgetAlert 预算使用跨过新的档位时发送告警，多个实例同时结算时只有一个会发送
+func checkBillingTagBudgetAlert(tag string) error {
+	budget, err := GetBillingTagBudget(tag)
+	if err != nil {
+		return err
+	}
+	// 预算用完时让缓存的预算失效，后续请求立即被拒绝
+	if budget.Block && budget.budgetQuota() > 0 && budget.UsedQuota >= budget.budgetQuota() {
+		refreshBillingTagBudgetCache(tag)
+	}
+	level := budget.alertLevel(budget.UsedQuota)
+	if level <= budget.AlertLevel {
+		return nil
+	}
+	result := DB.Model(&BillingTagBudget{}).
+		Where("id = ? AND period = ? AND alert_level < ?", budget.Id, budget.Period, level).
+		Update("alert_level", level)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	subject := fmt.Sprintf("费用标签「%s」本月预算已使用 %d%%", tag, level)
+	content := fmt.Sprintf("费用标签「%s」%s 月预算 $%.2f，已使用 $%.2f。", tag, budget.Period, budget.Budget, float64(budget.UsedQuota)/config.QuotaPerUnit)
+	if level >= 100 && budget.Block {
+		content += "后续请求将被拒绝，直到下个月或预算调整。"
+	}
+	notify.Send(subject, content)
+	return nil
+}
+
+// BillingTagStatisticSummary 费用标签用量的汇总行，Period 为日期或月份
+type BillingTagStatisticSummary struct {
+	Period           string `gorm:"column:period" json:"period"`
+	BillingTag       string `gorm:"column:billing_tag" json:"billing_tag"`
+	ModelName        string `gorm:"column:model_name" json:"model_name"`
+	RequestCount     int64  `gorm:"column:request_count" json:"request_count"`
+	Quota            int64  `gorm:"column:quota" json:"quota"`
+	PromptTokens     int64  `gorm:"column:prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int64  `gorm:"column:completion_tokens" json:"completion_tokens"`
+}
+
+var billingTagPeriodExpressions = map[string]string{
+	"day":   "date",
+	"month": "SUBSTR(date, 1, 7)",
+}
+
+// GetBillingTagStatistics 按天（day）或按月（month）汇总 [startDate, endDate] 内每个费用标签、每个模型的用量，
+// tag 为空时返回所有标签
+func GetBillingTagStatistics(startDate, endDate, groupBy, tag string) ([]*BillingTagStatisticSummary, error) {
+	periodExpression, ok := billingTagPeriodExpressions[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("不支持的统计维度: %s", groupBy)
+	}
+
+	db := DB.Model(&BillingTagStatistic{}).
+		Select(periodExpression+" AS period, billing_tag, model_name, SUM(request_count) AS request_count, SUM(quota) AS quota, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens").
+		Where("date >= ? AND date <= ?", startDate, endDate)
+	if tag != "" {
+		db = db.Where("billing_tag = ?", tag)
+	}
+
+	var summaries []*BillingTagStatisticSummary
+	err := db.Group(periodExpression + ", billing_tag, model_name").
+		Order(periodExpression + ", billing_tag, model_name").
+		Scan(&summaries).Error
+	return summaries, err
+}
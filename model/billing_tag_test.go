@@ -0,0 +1,140 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useBillingTagTestDB(t *testing.T) {
+	t.Helper()
+
+	logger.Logger = zap.NewNop()
+	originalDB := DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&BillingTagStatistic{}, &BillingTagBudget{}); err != nil {
+		t.Fatalf("expected billing tag schema migration to succeed, got %v", err)
+	}
+	DB = testDB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+}
+
+func TestBillingTagBudgetBlocksAndAlertsOnce(t *testing.T) {
+	useBillingTagTestDB(t)
+	now := time.Now()
+	unit := int(config.QuotaPerUnit)
+
+	// 预算创建前的用量也计入本月
+	if err := RecordBillingTagUsage("research", "gpt-4o", unit*4, 100, 50, now); err != nil {
+		t.Fatalf("expected usage to be recorded, got %v", err)
+	}
+	budget, err := SaveBillingTagBudget("research", 10, true)
+	if err != nil {
+		t.Fatalf("expected budget to be saved, got %v", err)
+	}
+	if budget.UsedQuota != unit*4 || budget.AlertLevel != 0 {
+		t.Fatalf("expected existing usage without alert, got %+v", budget)
+	}
+
+	if err := RecordBillingTagUsage("research", "gpt-4o", unit*2, 100, 50, now); err != nil {
+		t.Fatalf("expected usage to be recorded, got %v", err)
+	}
+	budget, _ = GetBillingTagBudget("research")
+	if budget.AlertLevel != 50 {
+		t.Fatalf("expected 50%% alert level, got %d", budget.AlertLevel)
+	}
+	if err := CheckBillingTagBudget("research", now); err != nil {
+		t.Fatalf("expected budget to allow requests, got %v", err)
+	}
+
+	if err := RecordBillingTagUsage("research", "claude-3", unit*5, 100, 50, now); err != nil {
+		t.Fatalf("expected usage to be recorded, got %v", err)
+	}
+	budget, _ = GetBillingTagBudget("research")
+	if budget.AlertLevel != 100 || budget.UsedQuota != unit*11 {
+		t.Fatalf("expected exhausted budget, got %+v", budget)
+	}
+	if err := CheckBillingTagBudget("research", now); !errors.Is(err, ErrBillingTagBudgetExhausted) {
+		t.Fatalf("expected exhausted budget error, got %v", err)
+	}
+	if err := CheckBillingTagBudget("marketing", now); err != nil {
+		t.Fatalf("expected tag without budget to pass, got %v", err)
+	}
+
+	// 下个月重新计算
+	nextMonth := now.AddDate(0, 1, 0)
+	if err := CheckBillingTagBudget("research", nextMonth); err != nil {
+		t.Fatalf("expected new month to reset the budget, got %v", err)
+	}
+	if err := RecordBillingTagUsage("research", "gpt-4o", unit, 100, 50, nextMonth); err != nil {
+		t.Fatalf("expected usage to be recorded, got %v", err)
+	}
+	budget, _ = GetBillingTagBudget("research")
+	if budget.Period != billingTagPeriod(nextMonth) || budget.UsedQuota != unit || budget.AlertLevel != 0 {
+		t.Fatalf("expected budget to roll over, got %+v", budget)
+	}
+}
+
+func TestGetBillingTagStatisticsGroupsByPeriod(t *testing.T) {
+	useBillingTagTestDB(t)
+	day := time.Date(2026, 3, 15, 10, 0, 0, 0, time.Local)
+
+	records := []struct {
+		tag   string
+		model string
+		at    time.Time
+	}{
+		{"research", "gpt-4o", day},
+		{"research", "gpt-4o", day.AddDate(0, 0, 1)},
+		{"research", "claude-3", day},
+		{"marketing", "gpt-4o", day},
+		{"research", "gpt-4o", day.AddDate(0, 1, 0)},
+	}
+	for _, record := range records {
+		if err := RecordBillingTagUsage(record.tag, record.model, 100, 10, 5, record.at); err != nil {
+			t.Fatalf("expected usage to be recorded, got %v", err)
+		}
+	}
+
+	daily, err := GetBillingTagStatistics("2026-03-01", "2026-04-30", "day", "research")
+	if err != nil {
+		t.Fatalf("expected daily statistics, got %v", err)
+	}
+	if len(daily) != 4 {
+		t.Fatalf("expected 4 daily rows, got %+v", daily)
+	}
+
+	monthly, err := GetBillingTagStatistics("2026-03-01", "2026-04-30", "month", "")
+	if err != nil {
+		t.Fatalf("expected monthly statistics, got %v", err)
+	}
+	if len(monthly) != 4 {
+		t.Fatalf("expected 4 monthly rows, got %+v", monthly)
+	}
+	first := monthly[0]
+	if first.Period != "2026-03" || first.BillingTag != "marketing" || first.Quota != 100 {
+		t.Fatalf("unexpected first monthly row: %+v", first)
+	}
+	for _, row := range monthly {
+		if row.Period == "2026-03" && row.BillingTag == "research" && row.ModelName == "gpt-4o" && (row.RequestCount != 2 || row.Quota != 200) {
+			t.Fatalf("expected research gpt-4o to be merged by month, got %+v", row)
+		}
+	}
+
+	if _, err := GetBillingTagStatistics("2026-03-01", "2026-04-30", "week", ""); err == nil {
+		t.Fatal("expected unsupported group_by to be rejected")
+	}
+}
@@ -19,6 +19,7 @@ var (
 	UserQuotaCacheKey           = "user_quota:%d"
 	UserEnabledCacheKey         = "user_enabled:%d"
 	UserRealtimeQuotaKey        = "user_realtime_quota:%d"
+	UserConcurrencyCacheKey     = "user_concurrency:%d"
 	UserRealtimeQuotaExpiration = 24 * time.Hour
 
 	OldUserTokensCacheKey = "old_user_tokens_cache"
@@ -60,6 +61,20 @@ func CacheGetUserGroup(id int) (group string, err error) {
 	return group, err
 }
 
+func CacheGetUserMaxConcurrency(id int) (maxConcurrency int, err error) {
+	if !config.RedisEnabled {
+		return GetUserMaxConcurrency(id)
+	}
+
+	return cache.GetOrSetCache(
+		fmt.Sprintf(UserConcurrencyCacheKey, id),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		func() (int, error) {
+			return GetUserMaxConcurrency(id)
+		},
+		cache.CacheTimeout)
+}
+
 func CacheGetUserQuota(id int) (quota int, err error) {
 	if !config.RedisEnabled {
 		return GetUserQuota(id)
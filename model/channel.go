@@ -32,9 +32,12 @@ type Channel struct {
 	Other              string  `json:"other" form:"other"`
 	Balance            float64 `json:"balance"` // in USD
 	BalanceUpdatedTime int64   `json:"balance_updated_time" gorm:"bigint"`
+	BalanceLow         bool    `json:"balance_low" gorm:"default:false"`      // 由余额定时任务维护
+	BalanceDisabled    bool    `json:"balance_disabled" gorm:"default:false"` // 由余额规则自动禁用，余额恢复后只自动启用这类渠道
 	Models             string  `json:"models" form:"models"`
 	Group              string  `json:"group" form:"group" gorm:"type:varchar(32);default:'default'"`
 	Tag                string  `json:"tag" form:"tag" gorm:"type:varchar(32);default:''"`
+	Region             string  `json:"region" form:"region" gorm:"type:varchar(32);default:''"`
 	UsedQuota          int64   `json:"used_quota" gorm:"bigint;default:0"`
 	ModelMapping       *string `json:"model_mapping" gorm:"type:text"`
 	ModelHeaders       *string `json:"model_headers" gorm:"type:varchar(1024);default:''"`
@@ -49,6 +52,16 @@ type Channel struct {
 
 	DisabledStream *datatypes.JSONSlice[string] `json:"disabled_stream,omitempty" gorm:"type:json"`
 
+	CostConfig *datatypes.JSONType[ChannelCostConfig] `json:"cost_config,omitempty" gorm:"type:json"`
+
+	ScheduleRules *datatypes.JSONSlice[ChannelScheduleRule] `json:"schedule_rules,omitempty" gorm:"type:json"`
+	// 调度状态只由定时任务写入，编辑渠道时不会覆盖
+	ScheduleState *datatypes.JSONType[ChannelScheduleState] `json:"schedule_state,omitempty" gorm:"type:json"`
+
+	BalanceAlert *datatypes.JSONType[ChannelBalanceAlert] `json:"balance_alert,omitempty" gorm:"type:json"`
+
+	ModelSync *datatypes.JSONType[ChannelModelSyncRule] `json:"model_sync,omitempty" gorm:"type:json"`
+
 	Plugin    *datatypes.JSONType[PluginType] `json:"plugin" form:"plugin" gorm:"type:json"`
 	DeletedAt gorm.DeletedAt                  `json:"-" gorm:"index"`
 
@@ -304,7 +317,7 @@ func BatchInsertChannels(channels []Channel) error {
 			return err
 		}
 	}
-	err := DB.Omit("UsedQuota").Create(&channels).Error
+	err := DB.Omit("UsedQuota", "ScheduleState", "BalanceLow", "BalanceDisabled").Create(&channels).Error
 	if err != nil {
 		return err
 	}
@@ -619,7 +632,7 @@ func (channel *Channel) Insert() error {
 	if err := channel.ValidateRuntimeConfigJSON(); err != nil {
 		return err
 	}
-	err := DB.Omit("UsedQuota").Create(channel).Error
+	err := DB.Omit("UsedQuota", "ScheduleState", "BalanceLow", "BalanceDisabled").Create(channel).Error
 	if err == nil {
 		refreshChannelGroupAfterMutation("insert channel", nil)
 	}
@@ -652,9 +665,9 @@ func (channel *Channel) UpdateRaw(overwrite bool) error {
 	}
 
 	if overwrite {
-		err = DB.Model(channel).Select("*").Omit("UsedQuota").Updates(channel).Error
+		err = DB.Model(channel).Select("*").Omit("UsedQuota", "ScheduleState", "BalanceLow", "BalanceDisabled").Updates(channel).Error
 	} else {
-		err = DB.Model(channel).Omit("UsedQuota").Updates(channel).Error
+		err = DB.Model(channel).Omit("UsedQuota", "ScheduleState", "BalanceLow", "BalanceDisabled").Updates(channel).Error
 	}
 	if err != nil {
 		return err
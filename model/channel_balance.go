@@ -0,0 +1,192 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/utils"
+)
+
+const (
+	ChannelBalanceActionNotify        = "notify"
+	ChannelBalanceActionLowerPriority = "lower_priority"
+	ChannelBalanceActionDisable       = "disable"
+)
+
+// ChannelBalanceAlert 渠道的低余额阈值。余额低于 Threshold 时按 Action 处理：
+// notify 仅通知，lower_priority 将渠道优先级降为 Priority，disable 自动禁用渠道。
+type ChannelBalanceAlert struct {
+	Threshold float64 `json:"threshold"`
+	Action    string  `json:"action"`
+	Priority  int64   `json:"priority,omitempty"`
+}
+
+func (alert *ChannelBalanceAlert) Validate() error {
+	if alert == nil {
+		return nil
+	}
+	if alert.Threshold < 0 {
+		return errors.New("balance_alert.threshold must not be negative")
+	}
+	switch alert.Action {
+	case ChannelBalanceActionNotify, ChannelBalanceActionLowerPriority, ChannelBalanceActionDisable:
+		return nil
+	}
+	return fmt.Errorf("unsupported balance_alert.action %q", alert.Action)
+}
+
+func (channel *Channel) GetBalanceAlert() *ChannelBalanceAlert {
+	if channel == nil || channel.BalanceAlert == nil {
+		return nil
+	}
+	alert := channel.BalanceAlert.Data()
+	return &alert
+}
+
+// balancePriority 余额不足且配置了降低优先级时，返回降低后的优先级
+func (channel *Channel) balancePriority(priority int64) int64 {
+	if !channel.BalanceLow {
+		return priority
+	}
+	alert := channel.GetBalanceAlert()
+	if alert == nil || alert.Action != ChannelBalanceActionLowerPriority {
+		return priority
+	}
+	return min(priority, alert.Priority)
+}
+
+// SetChannelBalanceLow 更新渠道的低余额标记，返回是否发生了变化
+func SetChannelBalanceLow(channelId int, low bool) (bool, error) {
+	result := DB.Model(&Channel{}).Where("id = ? AND balance_low = ?", channelId, !low).Update("balance_low", low)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// SetChannelBalanceDisabled 更新渠道由余额规则自动禁用的标记，返回是否发生了变化
+func SetChannelBalanceDisabled(channelId int, disabled bool) (bool, error) {
+	result := DB.Model(&Channel{}).Where("id = ? AND balance_disabled = ?", channelId, !disabled).Update("balance_disabled", disabled)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ChannelBalanceHistory 渠道余额的历史记录，用于计算消耗速度
+type ChannelBalanceHistory struct {
+	Id        int     `json:"id"`
+	ChannelId int     `json:"channel_id" gorm:"index:idx_channel_balance_channel_created"`
+	Balance   float64 `json:"balance"`
+	CreatedAt int64   `json:"created_at" gorm:"bigint;index:idx_channel_balance_channel_created"`
+}
+
+func RecordChannelBalance(channelId int, balance float64) error {
+	return DB.Create(&ChannelBalanceHistory{
+		ChannelId: channelId,
+		Balance:   balance,
+		CreatedAt: utils.GetTimestamp(),
+	}).Error
+}
+
+func GetChannelBalanceHistory(channelId int, startTime int64) ([]*ChannelBalanceHistory, error) {
+	var histories []*ChannelBalanceHistory
+	err := DB.Where("channel_id = ? AND created_at >= ?", channelId, startTime).Order("created_at asc, id asc").Find(&histories).Error
+	return histories, err
+}
+
+func DeleteChannelBalanceHistoryBefore(before time.Time) (int64, error) {
+	result := DB.Where("created_at < ?", before.Unix()).Delete(&ChannelBalanceHistory{})
+	return result.RowsAffected, result.Error
+}
+
+// GetChannelsForBalanceRefresh 返回需要定时刷新余额的渠道，自动禁用的渠道也需要刷新以便余额恢复后重新启用
+func GetChannelsForBalanceRefresh() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Where("status IN ?", []int{config.ChannelStatusEnabled, config.ChannelStatusAutoDisabled}).Order("id desc").Find(&channels).Error
+	return channels, err
+}
+
+type ChannelBalanceBurnRate struct {
+	ChannelId   int     `json:"channel_id"`
+	ChannelName string  `json:"channel_name"`
+	Balance     float64 `json:"balance"`
+	DailyBurn   float64 `json:"daily_burn"`
+	DaysLeft    float64 `json:"days_left"` // -1 表示无法估算
+}
+
+// GetChannelBalanceBurnRates 按最近 days 天的余额历史估算每个渠道的日均消耗和剩余天数
+func GetChannelBalanceBurnRates(days int) ([]*ChannelBalanceBurnRate, error) {
+	if days <= 0 {
+		days = 7
+	}
+	startTime := time.Now().AddDate(0, 0, -days).Unix()
+
+	var histories []*ChannelBalanceHistory
+	err := DB.Where("created_at >= ?", startTime).Order("channel_id asc, created_at asc, id asc").Find(&histories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[int][]*ChannelBalanceHistory)
+	channelIds := make([]int, 0)
+	for _, history := range histories {
+		if _, ok := grouped[history.ChannelId]; !ok {
+			channelIds = append(channelIds, history.ChannelId)
+		}
+		grouped[history.ChannelId] = append(grouped[history.ChannelId], history)
+	}
+
+	channels, err := GetChannelsByIDs(channelIds)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make([]*ChannelBalanceBurnRate, 0, len(channels))
+	for _, channel := range channels {
+		rate := calculateBalanceBurnRate(grouped[channel.Id])
+		rate.ChannelId = channel.Id
+		rate.ChannelName = channel.Name
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].ChannelId < rates[j].ChannelId
+	})
+
+	return rates, nil
+}
+
+// calculateBalanceBurnRate 只累计余额下降的部分，充值带来的上涨不计入消耗
+func calculateBalanceBurnRate(histories []*ChannelBalanceHistory) *ChannelBalanceBurnRate {
+	rate := &ChannelBalanceBurnRate{DaysLeft: -1}
+	if len(histories) == 0 {
+		return rate
+	}
+
+	last := histories[len(histories)-1]
+	rate.Balance = last.Balance
+	if len(histories) < 2 {
+		return rate
+	}
+
+	consumed := 0.0
+	for i := 1; i < len(histories); i++ {
+		if diff := histories[i-1].Balance - histories[i].Balance; diff > 0 {
+			consumed += diff
+		}
+	}
+
+	elapsed := float64(last.CreatedAt-histories[0].CreatedAt) / 86400
+	if elapsed <= 0 {
+		return rate
+	}
+	rate.DailyBurn = consumed / elapsed
+	if rate.DailyBurn > 0 {
+		rate.DaysLeft = max(rate.Balance, 0) / rate.DailyBurn
+	}
+
+	return rate
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"gorm.io/datatypes"
+)
+
+func TestCalculateBalanceBurnRateIgnoresTopUps(t *testing.T) {
+	histories := []*ChannelBalanceHistory{
+		{Balance: 100, CreatedAt: 0},
+		{Balance: 90, CreatedAt: 43200},
+		// 充值
+		{Balance: 150, CreatedAt: 86400},
+		{Balance: 140, CreatedAt: 172800},
+	}
+
+	rate := calculateBalanceBurnRate(histories)
+	if rate.Balance != 140 || rate.DailyBurn != 10 || math.Abs(rate.DaysLeft-14) > 1e-9 {
+		t.Fatalf("unexpected burn rate: %+v", rate)
+	}
+
+	if rate := calculateBalanceBurnRate(histories[:1]); rate.DaysLeft != -1 || rate.DailyBurn != 0 {
+		t.Fatalf("expected single record not to be estimated, got %+v", rate)
+	}
+}
+
+func TestChannelBalancePriority(t *testing.T) {
+	alert := datatypes.NewJSONType(ChannelBalanceAlert{Threshold: 10, Action: ChannelBalanceActionLowerPriority, Priority: -5})
+	channel := &Channel{BalanceAlert: &alert}
+
+	if priority := channel.balancePriority(3); priority != 3 {
+		t.Fatalf("expected priority to be kept while balance is healthy, got %d", priority)
+	}
+	channel.BalanceLow = true
+	if priority := channel.balancePriority(3); priority != -5 {
+		t.Fatalf("expected low balance to lower priority, got %d", priority)
+	}
+
+	if err := (&ChannelBalanceAlert{Threshold: 1, Action: "pause"}).Validate(); err == nil {
+		t.Fatal("expected unsupported action to be rejected")
+	}
+}
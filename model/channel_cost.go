@@ -0,0 +1,272 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"one-api/common/config"
+)
+
+// ChannelCostConfig 描述渠道的上游成本。Prices 为按模型的绝对价格（与 Price 同单位），
+// 未命中时使用 Multiplier 乘以系统模型价格；两者都未配置时视为与系统价格一致。
+type ChannelCostConfig struct {
+	Multiplier float64                     `json:"multiplier,omitempty"`
+	Prices     map[string]ChannelModelCost `json:"prices,omitempty"`
+}
+
+type ChannelModelCost struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+func (cost *ChannelCostConfig) Validate() error {
+	if cost == nil {
+		return nil
+	}
+	if cost.Multiplier < 0 || math.IsNaN(cost.Multiplier) || math.IsInf(cost.Multiplier, 0) {
+		return errors.New("cost_config.multiplier must be a non-negative number")
+	}
+	for modelName, price := range cost.Prices {
+		if strings.TrimSpace(modelName) == "" {
+			return errors.New("cost_config.prices must not contain an empty model name")
+		}
+		if price.Input < 0 || price.Output < 0 {
+			return fmt.Errorf("cost_config.prices.%s must not be negative", modelName)
+		}
+	}
+	return nil
+}
+
+func (channel *Channel) GetCostConfig() *ChannelCostConfig {
+	if channel == nil || channel.CostConfig == nil {
+		return nil
+	}
+	cost := channel.CostConfig.Data()
+	return &cost
+}
+
+// UpstreamPrice 返回渠道在指定模型上的上游输入/输出价格
+func (channel *Channel) UpstreamPrice(modelName string, price *Price) (input, output float64) {
+	if price != nil {
+		input = price.GetInput()
+		output = price.GetOutput()
+	}
+
+	cost := channel.GetCostConfig()
+	if cost == nil {
+		return input, output
+	}
+
+	if modelCost, ok := lookupChannelModelCost(cost.Prices, modelName); ok {
+		if price != nil && price.Type == TimesPriceType {
+			return modelCost.Input, 0
+		}
+		return modelCost.Input, modelCost.Output
+	}
+
+	if cost.Multiplier > 0 {
+		input *= cost.Multiplier
+		output *= cost.Multiplier
+	}
+
+	return input, output
+}
+
+func lookupChannelModelCost(prices map[string]ChannelModelCost, modelName string) (ChannelModelCost, bool) {
+	if len(prices) == 0 {
+		return ChannelModelCost{}, false
+	}
+	if modelCost, ok := prices[modelName]; ok {
+		return modelCost, true
+	}
+
+	// 与渠道模型的通配符保持一致，取最长的前缀匹配
+	matched := ""
+	for pattern := range prices {
+		if !strings.HasSuffix(pattern, "*") {
+			continue
+		}
+		prefix := strings.TrimSuffix(pattern, "*")
+		if strings.HasPrefix(modelName, prefix) && len(pattern) > len(matched) {
+			matched = pattern
+		}
+	}
+	if matched == "" {
+		return ChannelModelCost{}, false
+	}
+	return prices[matched], true
+}
+
+// upstreamCostScore 用于同优先级渠道之间的成本比较，输入输出价格相加即可满足排序需求
+func (channel *Channel) upstreamCostScore(modelName string, price *Price) float64 {
+	input, output := channel.UpstreamPrice(modelName, price)
+	return input + output
+}
+
+// EstimateUpstreamQuota 按渠道成本估算一段用量的上游花费（单位与 quota 一致，不含分组倍率）
+func (channel *Channel) EstimateUpstreamQuota(modelName string, price *Price, requestCount, promptTokens, completionTokens int64) float64 {
+	input, output := channel.UpstreamPrice(modelName, price)
+	if price != nil && price.Type == TimesPriceType {
+		return float64(requestCount) * 1000 * input
+	}
+	return float64(promptTokens)*input + float64(completionTokens)*output
+}
+
+func upstreamPriceUSD(price *Price, value float64) float64 {
+	if price != nil && price.Type == TimesPriceType {
+		return value * DollarRate
+	}
+	return value * DollarRate * 1000
+}
+
+func lookupModelPrice(modelName string) *Price {
+	if PricingInstance == nil {
+		return nil
+	}
+	return PricingInstance.GetPrice(modelName)
+}
+
+func (cc *ChannelsChooser) cheapestChannels(validChannels []*ChannelChoice, modelName string) []*ChannelChoice {
+	if config.ChannelBalancerStrategy != config.ChannelBalancerStrategyCheapest || len(validChannels) < 2 {
+		return validChannels
+	}
+
+	price := lookupModelPrice(modelName)
+	cheapest := make([]*ChannelChoice, 0, len(validChannels))
+	minScore := math.MaxFloat64
+	for _, choice := range validChannels {
+		score := choice.Channel.upstreamCostScore(modelName, price)
+		switch {
+		case score < minScore:
+			minScore = score
+			cheapest = append(cheapest[:0], choice)
+		case score == minScore:
+			cheapest = append(cheapest, choice)
+		}
+	}
+
+	return cheapest
+}
+
+type ChannelMarginStatistic struct {
+	ChannelId        int     `json:"channel_id" gorm:"column:channel_id"`
+	ChannelName      string  `json:"channel_name" gorm:"column:channel_name"`
+	ModelName        string  `json:"model_name" gorm:"column:model_name"`
+	RequestCount     int64   `json:"request_count" gorm:"column:request_count"`
+	PromptTokens     int64   `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens" gorm:"column:completion_tokens"`
+	Quota            int64   `json:"quota" gorm:"column:quota"`
+	EstimatedCost    float64 `json:"estimated_cost" gorm:"-"`
+	Margin           float64 `json:"margin" gorm:"-"`
+	MarginRate       float64 `json:"margin_rate" gorm:"-"`
+}
+
+type ChannelMarginSummary struct {
+	ChannelId     int                       `json:"channel_id"`
+	ChannelName   string                    `json:"channel_name"`
+	RequestCount  int64                     `json:"request_count"`
+	Quota         int64                     `json:"quota"`
+	EstimatedCost float64                   `json:"estimated_cost"`
+	Margin        float64                   `json:"margin"`
+	MarginRate    float64                   `json:"margin_rate"`
+	Models        []*ChannelMarginStatistic `json:"models"`
+}
+
+// GetChannelMarginStatisticsByPeriod 对比用户实际扣费(quota)与按渠道成本估算的上游花费
+func GetChannelMarginStatisticsByPeriod(startDate, endDate string, channelId int) ([]*ChannelMarginSummary, error) {
+	var rows []*ChannelMarginStatistic
+
+	tx := DB.Table("statistics").
+		Select("statistics.channel_id, MAX(channels.name) as channel_name, statistics.model_name, "+
+			"sum(statistics.request_count) as request_count, sum(statistics.prompt_tokens) as prompt_tokens, "+
+			"sum(statistics.completion_tokens) as completion_tokens, sum(statistics.quota) as quota").
+		Joins("LEFT JOIN channels ON statistics.channel_id = channels.id").
+		Where("statistics.date BETWEEN ? AND ?", startDate, endDate)
+	if channelId > 0 {
+		tx = tx.Where("statistics.channel_id = ?", channelId)
+	}
+	err := tx.Group("statistics.channel_id, statistics.model_name").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	channelIds := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, row := range rows {
+		if !seen[row.ChannelId] {
+			seen[row.ChannelId] = true
+			channelIds = append(channelIds, row.ChannelId)
+		}
+	}
+
+	channels, err := GetChannelsByIDs(channelIds)
+	if err != nil {
+		return nil, err
+	}
+	channelMap := make(map[int]*Channel, len(channels))
+	for _, channel := range channels {
+		channelMap[channel.Id] = channel
+	}
+
+	return buildChannelMarginSummaries(rows, channelMap), nil
+}
+
+func buildChannelMarginSummaries(rows []*ChannelMarginStatistic, channelMap map[int]*Channel) []*ChannelMarginSummary {
+	summaryMap := make(map[int]*ChannelMarginSummary)
+	for _, row := range rows {
+		channel, ok := channelMap[row.ChannelId]
+		if !ok {
+			// 渠道已删除时按系统价格估算
+			channel = &Channel{Id: row.ChannelId}
+		}
+		row.EstimatedCost = channel.EstimateUpstreamQuota(row.ModelName, lookupModelPrice(row.ModelName), row.RequestCount, row.PromptTokens, row.CompletionTokens)
+		row.Margin = float64(row.Quota) - row.EstimatedCost
+		row.MarginRate = marginRate(row.Margin, row.Quota)
+
+		summary, ok := summaryMap[row.ChannelId]
+		if !ok {
+			summary = &ChannelMarginSummary{
+				ChannelId:   row.ChannelId,
+				ChannelName: row.ChannelName,
+			}
+			summaryMap[row.ChannelId] = summary
+		}
+		summary.RequestCount += row.RequestCount
+		summary.Quota += row.Quota
+		summary.EstimatedCost += row.EstimatedCost
+		summary.Models = append(summary.Models, row)
+	}
+
+	summaries := make([]*ChannelMarginSummary, 0, len(summaryMap))
+	for _, summary := range summaryMap {
+		summary.Margin = float64(summary.Quota) - summary.EstimatedCost
+		summary.MarginRate = marginRate(summary.Margin, summary.Quota)
+		sort.Slice(summary.Models, func(i, j int) bool {
+			return summary.Models[i].ModelName < summary.Models[j].ModelName
+		})
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].ChannelId < summaries[j].ChannelId
+	})
+
+	return summaries
+}
+
+func marginRate(margin float64, quota int64) float64 {
+	if quota <= 0 {
+		return 0
+	}
+	return margin / float64(quota)
+}
+
+func validateChannelBalancerStrategy(value string) error {
+	switch strings.TrimSpace(value) {
+	case config.ChannelBalancerStrategyWeight, config.ChannelBalancerStrategyCheapest:
+		return nil
+	}
+	return fmt.Errorf("渠道选择策略仅支持 %s 或 %s", config.ChannelBalancerStrategyWeight, config.ChannelBalancerStrategyCheapest)
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"testing"
+
+	"one-api/common/config"
+
+	"gorm.io/datatypes"
+)
+
+func testCostChannel(id int, cost *ChannelCostConfig) *Channel {
+	channel := testWeightedChannel(id, config.ChannelTypeOpenAI)
+	if cost != nil {
+		costConfig := datatypes.NewJSONType(*cost)
+		channel.CostConfig = &costConfig
+	}
+	return channel
+}
+
+func TestChannelUpstreamPriceResolution(t *testing.T) {
+	price := &Price{Type: TokensPriceType, Input: 2, Output: 6}
+
+	if input, output := testCostChannel(1, nil).UpstreamPrice("gpt-4o", price); input != 2 || output != 6 {
+		t.Fatalf("expected channel without cost config to use system price, got %v/%v", input, output)
+	}
+
+	multiplier := testCostChannel(2, &ChannelCostConfig{Multiplier: 0.5})
+	if input, output := multiplier.UpstreamPrice("gpt-4o", price); input != 1 || output != 3 {
+		t.Fatalf("expected multiplier to scale system price, got %v/%v", input, output)
+	}
+
+	absolute := testCostChannel(3, &ChannelCostConfig{
+		Multiplier: 0.5,
+		Prices: map[string]ChannelModelCost{
+			"gpt-4o":   {Input: 0.1, Output: 0.2},
+			"gpt-4*":   {Input: 0.3, Output: 0.4},
+			"gpt-4o-*": {Input: 0.5, Output: 0.6},
+		},
+	})
+	if input, output := absolute.UpstreamPrice("gpt-4o", price); input != 0.1 || output != 0.2 {
+		t.Fatalf("expected exact model cost to win, got %v/%v", input, output)
+	}
+	if input, output := absolute.UpstreamPrice("gpt-4o-mini", price); input != 0.5 || output != 0.6 {
+		t.Fatalf("expected longest wildcard cost to win, got %v/%v", input, output)
+	}
+	if input, output := absolute.UpstreamPrice("claude-3", price); input != 1 || output != 3 {
+		t.Fatalf("expected unmatched model to fall back to multiplier, got %v/%v", input, output)
+	}
+
+	times := &Price{Type: TimesPriceType, Input: 10, Output: 10}
+	if cost := absolute.EstimateUpstreamQuota("gpt-4o", times, 3, 100, 100); cost != 300 {
+		t.Fatalf("expected times-priced cost to use request count, got %v", cost)
+	}
+}
+
+func TestChannelCostConfigValidateRejectsNegativeValues(t *testing.T) {
+	if err := (&ChannelCostConfig{Multiplier: -1}).Validate(); err == nil {
+		t.Fatal("expected negative multiplier to be rejected")
+	}
+	if err := (&ChannelCostConfig{Prices: map[string]ChannelModelCost{"gpt-4o": {Input: -1}}}).Validate(); err == nil {
+		t.Fatal("expected negative model price to be rejected")
+	}
+	if err := (&ChannelCostConfig{Multiplier: 0.8}).Validate(); err != nil {
+		t.Fatalf("expected valid cost config, got %v", err)
+	}
+}
+
+func TestChannelsChooserCheapestStrategyPrefersLowestCost(t *testing.T) {
+	originalStrategy := config.ChannelBalancerStrategy
+	originalPricing := PricingInstance
+	t.Cleanup(func() {
+		config.ChannelBalancerStrategy = originalStrategy
+		PricingInstance = originalPricing
+	})
+	PricingInstance = &Pricing{
+		Prices: map[string]*Price{
+			"gpt-4o": {Model: "gpt-4o", Type: TokensPriceType, Input: 2, Output: 6},
+		},
+	}
+
+	chooser := &ChannelsChooser{
+		Channels: map[int]*ChannelChoice{
+			1: {Channel: testCostChannel(1, &ChannelCostConfig{Multiplier: 1.2})},
+			2: {Channel: testCostChannel(2, &ChannelCostConfig{Multiplier: 0.6})},
+			3: {Channel: testCostChannel(3, nil)},
+		},
+	}
+
+	config.ChannelBalancerStrategy = config.ChannelBalancerStrategyCheapest
+	for i := 0; i < 20; i++ {
+		channel := chooser.balancer([]int{1, 2, 3}, nil, "gpt-4o")
+		if channel == nil || channel.Id != 2 {
+			t.Fatalf("expected cheapest channel 2, got %+v", channel)
+		}
+	}
+
+	chooser.Channels[2].Disable = true
+	if channel := chooser.balancer([]int{1, 2, 3}, nil, "gpt-4o"); channel == nil || channel.Id != 3 {
+		t.Fatalf("expected next cheapest healthy channel 3, got %+v", channel)
+	}
+}
+
+func TestBuildChannelMarginSummaries(t *testing.T) {
+	originalPricing := PricingInstance
+	t.Cleanup(func() {
+		PricingInstance = originalPricing
+	})
+	PricingInstance = &Pricing{
+		Prices: map[string]*Price{
+			"gpt-4o": {Model: "gpt-4o", Type: TokensPriceType, Input: 2, Output: 6},
+		},
+	}
+
+	rows := []*ChannelMarginStatistic{
+		{ChannelId: 1, ChannelName: "reseller", ModelName: "gpt-4o", RequestCount: 2, PromptTokens: 100, CompletionTokens: 10, Quota: 300},
+	}
+	channels := map[int]*Channel{
+		1: testCostChannel(1, &ChannelCostConfig{Multiplier: 0.5}),
+	}
+
+	summaries := buildChannelMarginSummaries(rows, channels)
+	if len(summaries) != 1 {
+		t.Fatalf("expected one channel summary, got %d", len(summaries))
+	}
+	// 100*1 + 10*3 = 130
+	if summaries[0].EstimatedCost != 130 || summaries[0].Margin != 170 {
+		t.Fatalf("unexpected margin summary: %+v", summaries[0])
+	}
+}
+
+func TestFilterMaxUpstreamPrice(t *testing.T) {
+	originalPricing := PricingInstance
+	t.Cleanup(func() {
+		PricingInstance = originalPricing
+	})
+	PricingInstance = &Pricing{
+		Prices: map[string]*Price{
+			// 输入 $2/M，输出 $6/M
+			"gpt-4o": {Model: "gpt-4o", Type: TokensPriceType, Input: 1, Output: 3},
+		},
+	}
+
+	filter := FilterMaxUpstreamPrice("gpt-4o", 4)
+	if !filter(1, &ChannelChoice{Channel: testCostChannel(1, nil)}) {
+		t.Fatal("expected channel above max price to be skipped")
+	}
+	if filter(2, &ChannelChoice{Channel: testCostChannel(2, &ChannelCostConfig{Multiplier: 0.5})}) {
+		t.Fatal("expected discounted channel within max price to be kept")
+	}
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/utils"
+)
+
+// ChannelHealthCheck 渠道定时健康检查的历史记录
+type ChannelHealthCheck struct {
+	Id            int    `json:"id"`
+	ChannelId     int    `json:"channel_id" gorm:"index:idx_channel_health_channel_created"`
+	Model         string `json:"model" gorm:"type:varchar(100);default:''"`
+	Passed        bool   `json:"passed"`
+	ResponseTime  int64  `json:"response_time"` // in milliseconds
+	Error         string `json:"error" gorm:"type:text"`
+	ChannelStatus int    `json:"channel_status"` // 检查时渠道所处的状态
+	CreatedAt     int64  `json:"created_at" gorm:"bigint;index:idx_channel_health_channel_created"`
+}
+
+func (check *ChannelHealthCheck) Insert() error {
+	if check.CreatedAt == 0 {
+		check.CreatedAt = utils.GetTimestamp()
+	}
+	return DB.Create(check).Error
+}
+
+// GetChannelsForHealthCheck 返回需要定时检查的渠道：启用的和被自动禁用的，手动禁用的不参与
+func GetChannelsForHealthCheck() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Where("status IN ?", []int{config.ChannelStatusEnabled, config.ChannelStatusAutoDisabled}).Order("id desc").Find(&channels).Error
+	return channels, err
+}
+
+func GetChannelHealthHistory(channelId int, limit int) ([]*ChannelHealthCheck, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var checks []*ChannelHealthCheck
+	err := DB.Where("channel_id = ?", channelId).Order("id desc").Limit(limit).Find(&checks).Error
+	return checks, err
+}
+
+// CountChannelRecoveryPasses 统计渠道在自动禁用状态下最近连续通过检查的次数，最多统计 limit 条
+func CountChannelRecoveryPasses(channelId int, limit int) (int, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+
+	var checks []*ChannelHealthCheck
+	err := DB.Select("passed", "channel_status").
+		Where("channel_id = ?", channelId).
+		Order("id desc").
+		Limit(limit).
+		Find(&checks).Error
+	if err != nil {
+		return 0, err
+	}
+
+	passes := 0
+	for _, check := range checks {
+		if !check.Passed || check.ChannelStatus != config.ChannelStatusAutoDisabled {
+			break
+		}
+		passes++
+	}
+	return passes, nil
+}
+
+func DeleteChannelHealthChecksBefore(before time.Time) (int64, error) {
+	result := DB.Where("created_at < ?", before.Unix()).Delete(&ChannelHealthCheck{})
+	return result.RowsAffected, result.Error
+}
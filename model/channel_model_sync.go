@@ -0,0 +1,275 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"one-api/common/config"
+	"one-api/common/utils"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	ChannelModelSyncModeReview = "review"
+	ChannelModelSyncModeAuto   = "auto"
+
+	ChannelModelSyncDiffPending  = "pending"
+	ChannelModelSyncDiffApplied  = "applied"
+	ChannelModelSyncDiffRejected = "rejected"
+)
+
+// ChannelModelSyncRule 渠道上游模型列表同步规则。
+// Include/Exclude 为正则表达式，只有匹配 Include 且不匹配 Exclude 的模型才会被新增或移除；
+// Mode 为 auto 时直接应用变更，为 review 时生成待审核的差异记录。
+type ChannelModelSyncRule struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode"`
+	Include string `json:"include,omitempty"`
+	Exclude string `json:"exclude,omitempty"`
+}
+
+func (rule *ChannelModelSyncRule) Validate() error {
+	if rule == nil {
+		return nil
+	}
+	switch rule.Mode {
+	case "", ChannelModelSyncModeReview, ChannelModelSyncModeAuto:
+	default:
+		return fmt.Errorf("unsupported model_sync.mode %q", rule.Mode)
+	}
+	if _, err := compileModelSyncPattern(rule.Include); err != nil {
+		return fmt.Errorf("invalid model_sync.include: %w", err)
+	}
+	if _, err := compileModelSyncPattern(rule.Exclude); err != nil {
+		return fmt.Errorf("invalid model_sync.exclude: %w", err)
+	}
+	return nil
+}
+
+func (rule *ChannelModelSyncRule) AutoApply() bool {
+	return rule.Mode == ChannelModelSyncModeAuto
+}
+
+func compileModelSyncPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// matcher 返回规则的模型过滤函数，调用前规则应已通过 Validate
+func (rule *ChannelModelSyncRule) matcher() func(string) bool {
+	include, _ := compileModelSyncPattern(rule.Include)
+	exclude, _ := compileModelSyncPattern(rule.Exclude)
+	return func(modelName string) bool {
+		if include != nil && !include.MatchString(modelName) {
+			return false
+		}
+		return exclude == nil || !exclude.MatchString(modelName)
+	}
+}
+
+func (channel *Channel) GetModelSyncRule() *ChannelModelSyncRule {
+	if channel == nil || channel.ModelSync == nil {
+		return nil
+	}
+	rule := channel.ModelSync.Data()
+	return &rule
+}
+
+func splitChannelModels(models string) []string {
+	list := make([]string, 0)
+	for _, modelName := range strings.Split(models, ",") {
+		modelName = strings.TrimSpace(modelName)
+		if modelName != "" && !slices.Contains(list, modelName) {
+			list = append(list, modelName)
+		}
+	}
+	return list
+}
+
+// DiffChannelModels 对比上游模型列表和渠道当前的模型，返回规则范围内新增和下线的模型。
+// 模型映射中的自定义模型名不会出现在上游列表里，因此不会被当作下线模型。
+func (channel *Channel) DiffChannelModels(upstream []string) (added, removed []string) {
+	rule := channel.GetModelSyncRule()
+	if rule == nil {
+		rule = &ChannelModelSyncRule{}
+	}
+	match := rule.matcher()
+
+	current := splitChannelModels(channel.Models)
+	upstreamSet := make(map[string]bool, len(upstream))
+	added = make([]string, 0)
+	for _, modelName := range upstream {
+		modelName = strings.TrimSpace(modelName)
+		if modelName == "" || upstreamSet[modelName] {
+			continue
+		}
+		upstreamSet[modelName] = true
+		if match(modelName) && !slices.Contains(current, modelName) {
+			added = append(added, modelName)
+		}
+	}
+
+	mapping, _ := channel.GetModelMappingMap()
+	removed = make([]string, 0)
+	for _, modelName := range current {
+		if upstreamSet[modelName] || !match(modelName) {
+			continue
+		}
+		if _, ok := mapping[modelName]; ok {
+			continue
+		}
+		removed = append(removed, modelName)
+	}
+
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed
+}
+
+func applyChannelModelDiff(models string, added, removed []string) string {
+	list := make([]string, 0)
+	for _, modelName := range splitChannelModels(models) {
+		if !slices.Contains(removed, modelName) {
+			list = append(list, modelName)
+		}
+	}
+	for _, modelName := range added {
+		if !slices.Contains(list, modelName) {
+			list = append(list, modelName)
+		}
+	}
+	return strings.Join(list, ",")
+}
+
+// ChannelModelSyncDiff 一次上游模型同步产生的差异，待审核的差异每个渠道只保留最新一条
+type ChannelModelSyncDiff struct {
+	Id         int                         `json:"id"`
+	ChannelId  int                         `json:"channel_id" gorm:"index"`
+	Added      datatypes.JSONSlice[string] `json:"added" gorm:"type:json"`
+	Removed    datatypes.JSONSlice[string] `json:"removed" gorm:"type:json"`
+	Status     string                      `json:"status" gorm:"type:varchar(16);index"`
+	CreatedAt  int64                       `json:"created_at" gorm:"bigint"`
+	ReviewedAt int64                       `json:"reviewed_at" gorm:"bigint"`
+}
+
+// GetChannelsForModelSync 返回开启了模型同步的渠道，手动禁用的渠道不参与
+func GetChannelsForModelSync() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Where("model_sync IS NOT NULL AND status <> ?", config.ChannelStatusManuallyDisabled).Order("id desc").Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if rule := channel.GetModelSyncRule(); rule != nil && rule.Enabled {
+			enabled = append(enabled, channel)
+		}
+	}
+	return enabled, nil
+}
+
+// SaveChannelModelSyncDiff 保存待审核的差异，返回差异是否与上一条待审核记录不同
+func SaveChannelModelSyncDiff(channelId int, added, removed []string) (*ChannelModelSyncDiff, bool, error) {
+	var pending ChannelModelSyncDiff
+	err := DB.Where("channel_id = ? AND status = ?", channelId, ChannelModelSyncDiffPending).Order("id desc").First(&pending).Error
+	if err == nil {
+		if slices.Equal(pending.Added, added) && slices.Equal(pending.Removed, removed) {
+			return &pending, false, nil
+		}
+		pending.Added = added
+		pending.Removed = removed
+		pending.CreatedAt = utils.GetTimestamp()
+		return &pending, true, DB.Save(&pending).Error
+	}
+
+	diff := &ChannelModelSyncDiff{
+		ChannelId: channelId,
+		Added:     added,
+		Removed:   removed,
+		Status:    ChannelModelSyncDiffPending,
+		CreatedAt: utils.GetTimestamp(),
+	}
+	return diff, true, DB.Create(diff).Error
+}
+
+// RecordAppliedChannelModelSyncDiff 自动应用的差异也保留记录，并替换掉之前的待审核记录
+func RecordAppliedChannelModelSyncDiff(channelId int, added, removed []string) error {
+	if err := ClearPendingChannelModelSyncDiff(channelId); err != nil {
+		return err
+	}
+	now := utils.GetTimestamp()
+	return DB.Create(&ChannelModelSyncDiff{
+		ChannelId:  channelId,
+		Added:      added,
+		Removed:    removed,
+		Status:     ChannelModelSyncDiffApplied,
+		CreatedAt:  now,
+		ReviewedAt: now,
+	}).Error
+}
+
+// ClearPendingChannelModelSyncDiff 上游与渠道一致时删除过期的待审核记录
+func ClearPendingChannelModelSyncDiff(channelId int) error {
+	return DB.Where("channel_id = ? AND status = ?", channelId, ChannelModelSyncDiffPending).Delete(&ChannelModelSyncDiff{}).Error
+}
+
+func GetChannelModelSyncDiffs(status string) ([]*ChannelModelSyncDiff, error) {
+	var diffs []*ChannelModelSyncDiff
+	tx := DB.Order("id desc")
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	err := tx.Limit(500).Find(&diffs).Error
+	return diffs, err
+}
+
+// ApplyChannelModelSync 将差异应用到渠道的模型列表并刷新渠道缓存
+func ApplyChannelModelSync(channelId int, added, removed []string) error {
+	channel, err := GetChannelById(channelId)
+	if err != nil {
+		return err
+	}
+
+	models := applyChannelModelDiff(channel.Models, added, removed)
+	if models == "" {
+		return errors.New("同步后渠道没有可用模型")
+	}
+	if err := DB.Model(&Channel{}).Where("id = ?", channelId).Update("models", models).Error; err != nil {
+		return err
+	}
+	refreshChannelGroupAfterMutation("sync channel models", nil)
+	return nil
+}
+
+// ReviewChannelModelSyncDiff 审核待处理的差异，approve 为 true 时应用到渠道
+func ReviewChannelModelSyncDiff(id int, approve bool) (*ChannelModelSyncDiff, error) {
+	var diff ChannelModelSyncDiff
+	if err := DB.First(&diff, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if diff.Status != ChannelModelSyncDiffPending {
+		return nil, errors.New("该差异已处理")
+	}
+
+	status := ChannelModelSyncDiffRejected
+	if approve {
+		if err := ApplyChannelModelSync(diff.ChannelId, diff.Added, diff.Removed); err != nil {
+			return nil, err
+		}
+		status = ChannelModelSyncDiffApplied
+	}
+
+	diff.Status = status
+	diff.ReviewedAt = utils.GetTimestamp()
+	if err := DB.Model(&diff).Select("status", "reviewed_at").Updates(&diff).Error; err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"slices"
+	"testing"
+
+	"gorm.io/datatypes"
+)
+
+func TestDiffChannelModelsAppliesRuleFilters(t *testing.T) {
+	rule := datatypes.NewJSONType(ChannelModelSyncRule{Enabled: true, Include: "^gpt-", Exclude: "-preview$"})
+	mapping := `{"gpt-custom":"gpt-4o"}`
+	channel := &Channel{
+		Models:       "gpt-4o,gpt-4-turbo,gpt-custom,claude-3-opus",
+		ModelMapping: &mapping,
+		ModelSync:    &rule,
+	}
+
+	added, removed := channel.DiffChannelModels([]string{"gpt-4o", "gpt-5", "gpt-5-preview", "o3", "gpt-5"})
+	if !slices.Equal(added, []string{"gpt-5"}) {
+		t.Fatalf("expected only matching new models to be added, got %v", added)
+	}
+	// claude-3-opus 不在规则范围内，gpt-custom 来自模型映射，都不应被下线
+	if !slices.Equal(removed, []string{"gpt-4-turbo"}) {
+		t.Fatalf("expected only retired matching models to be removed, got %v", removed)
+	}
+
+	if models := applyChannelModelDiff(channel.Models, added, removed); models != "gpt-4o,gpt-custom,claude-3-opus,gpt-5" {
+		t.Fatalf("unexpected merged models %q", models)
+	}
+}
+
+func TestChannelModelSyncRuleValidate(t *testing.T) {
+	if err := (&ChannelModelSyncRule{Mode: "manual"}).Validate(); err == nil {
+		t.Fatal("expected unsupported mode to be rejected")
+	}
+	if err := (&ChannelModelSyncRule{Mode: ChannelModelSyncModeAuto, Include: "gpt-("}).Validate(); err == nil {
+		t.Fatal("expected invalid include pattern to be rejected")
+	}
+}
@@ -0,0 +1,348 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"one-api/common/logger"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/datatypes"
+)
+
+const (
+	// 仅在时间窗口内启用渠道，窗口外禁用
+	ChannelScheduleActionEnable = "enable"
+	// 在时间窗口内禁用渠道
+	ChannelScheduleActionDisable = "disable"
+	// 在时间窗口内调整渠道的优先级/权重
+	ChannelScheduleActionAdjust = "adjust"
+)
+
+// ChannelScheduleRule 渠道的时间窗口规则。时间窗口可以使用 cron 表达式 + 持续分钟数，
+// 也可以使用星期 + 起止时间（HH:MM，结束时间小于开始时间表示跨天）。
+type ChannelScheduleRule struct {
+	Name     string `json:"name,omitempty"`
+	Action   string `json:"action"`
+	Timezone string `json:"timezone,omitempty"`
+	Cron     string `json:"cron,omitempty"`
+	Duration int    `json:"duration,omitempty"` // 分钟，仅 cron 规则使用
+	Weekdays []int  `json:"weekdays,omitempty"` // 0 表示周日，为空表示每天
+	Start    string `json:"start,omitempty"`
+	End      string `json:"end,omitempty"`
+	Priority *int64 `json:"priority,omitempty"`
+	Weight   *uint  `json:"weight,omitempty"`
+}
+
+// ChannelScheduleState 由主节点定时任务计算并写入数据库，所有节点加载渠道时按此状态生效
+type ChannelScheduleState struct {
+	Disabled    bool     `json:"disabled"`
+	Priority    *int64   `json:"priority,omitempty"`
+	Weight      *uint    `json:"weight,omitempty"`
+	ActiveRules []string `json:"active_rules,omitempty"`
+	ChangedAt   int64    `json:"changed_at"`
+}
+
+func (rule *ChannelScheduleRule) label(index int) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+func (rule *ChannelScheduleRule) location() (*time.Location, error) {
+	if rule.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(rule.Timezone)
+}
+
+func (rule *ChannelScheduleRule) Validate() error {
+	switch rule.Action {
+	case ChannelScheduleActionEnable, ChannelScheduleActionDisable:
+	case ChannelScheduleActionAdjust:
+		if rule.Priority == nil && rule.Weight == nil {
+			return errors.New("adjust rule requires priority or weight")
+		}
+		if rule.Weight != nil && *rule.Weight == 0 {
+			return errors.New("weight must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("unsupported action %q", rule.Action)
+	}
+
+	if _, err := rule.location(); err != nil {
+		return fmt.Errorf("invalid timezone %q", rule.Timezone)
+	}
+
+	if rule.Cron != "" {
+		if _, err := cron.ParseStandard(rule.Cron); err != nil {
+			return fmt.Errorf("invalid cron %q: %v", rule.Cron, err)
+		}
+		if rule.Duration <= 0 {
+			return errors.New("cron rule requires a positive duration")
+		}
+		return nil
+	}
+
+	return validateClockWindow(rule.Weekdays, rule.Start, rule.End)
+}
+
+func validateClockWindow(weekdays []int, start, end string) error {
+	for _, weekday := range weekdays {
+		if weekday < 0 || weekday > 6 {
+			return fmt.Errorf("invalid weekday %d", weekday)
+		}
+	}
+	if _, err := parseScheduleClock(start); err != nil {
+		return err
+	}
+	if _, err := parseScheduleClock(end); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseScheduleClock 将 HH:MM 转换为当天的分钟数，空字符串视为 00:00
+func parseScheduleClock(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// Active 判断规则在 now 时刻是否处于生效窗口
+func (rule *ChannelScheduleRule) Active(now time.Time) bool {
+	loc, err := rule.location()
+	if err != nil {
+		return false
+	}
+	now = now.In(loc)
+
+	if rule.Cron != "" {
+		schedule, err := cron.ParseStandard(rule.Cron)
+		if err != nil || rule.Duration <= 0 {
+			return false
+		}
+		// 最近一次触发时间落在 (now-duration, now] 内即视为生效
+		return !schedule.Next(now.Add(-time.Duration(rule.Duration) * time.Minute)).After(now)
+	}
+
+	return clockWindowActive(now, rule.Weekdays, rule.Start, rule.End)
+}
+
+// clockWindowActive 判断 now 是否处于每天 start 到 end 的时间窗口内，weekdays 为空表示每天，
+// start 等于 end 表示全天，end 小于 start 表示跨天窗口
+func clockWindowActive(now time.Time, weekdays []int, start, end string) bool {
+	startMinutes, err := parseScheduleClock(start)
+	if err != nil {
+		return false
+	}
+	endMinutes, err := parseScheduleClock(end)
+	if err != nil {
+		return false
+	}
+
+	matchWeekday := func(weekday int) bool {
+		return len(weekdays) == 0 || slices.Contains(weekdays, weekday)
+	}
+	minutes := now.Hour()*60 + now.Minute()
+	weekday := int(now.Weekday())
+	yesterday := (weekday + 6) % 7
+
+	switch {
+	case startMinutes == endMinutes:
+		return matchWeekday(weekday)
+	case startMinutes < endMinutes:
+		return matchWeekday(weekday) && minutes >= startMinutes && minutes < endMinutes
+	default:
+		// 跨天窗口：今天开始之后，或昨天开始、今天结束之前
+		return (matchWeekday(weekday) && minutes >= startMinutes) ||
+			(matchWeekday(yesterday) && minutes < endMinutes)
+	}
+}
+
+func (channel *Channel) GetScheduleRules() []ChannelScheduleRule {
+	if channel == nil || channel.ScheduleRules == nil {
+		return nil
+	}
+	return *channel.ScheduleRules
+}
+
+func (channel *Channel) GetScheduleState() *ChannelScheduleState {
+	if channel == nil || channel.ScheduleState == nil {
+		return nil
+	}
+	state := channel.ScheduleState.Data()
+	return &state
+}
+
+func validateChannelScheduleRules(rules []ChannelScheduleRule) error {
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return fmt.Errorf("schedule_rules[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// EvaluateSchedule 计算渠道在 now 时刻应处于的调度状态，没有任何规则生效时返回 nil
+func (channel *Channel) EvaluateSchedule(now time.Time) *ChannelScheduleState {
+	rules := channel.GetScheduleRules()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	state := &ChannelScheduleState{}
+	hasEnableRule := false
+	enabledByRule := false
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Action == ChannelScheduleActionEnable {
+			hasEnableRule = true
+		}
+		if !rule.Active(now) {
+			continue
+		}
+
+		state.ActiveRules = append(state.ActiveRules, rule.label(i))
+		switch rule.Action {
+		case ChannelScheduleActionEnable:
+			enabledByRule = true
+		case ChannelScheduleActionDisable:
+			state.Disabled = true
+		case ChannelScheduleActionAdjust:
+			// 多条调整规则同时生效时，以排在前面的规则为准
+			if state.Priority == nil && rule.Priority != nil {
+				priority := *rule.Priority
+				state.Priority = &priority
+			}
+			if state.Weight == nil && rule.Weight != nil {
+				weight := *rule.Weight
+				state.Weight = &weight
+			}
+		}
+	}
+
+	if hasEnableRule && !enabledByRule {
+		state.Disabled = true
+	}
+	if !state.Disabled && state.Priority == nil && state.Weight == nil {
+		return nil
+	}
+	if state.Disabled {
+		// 禁用时调整项没有意义，避免无效的状态变更
+		state.Priority = nil
+		state.Weight = nil
+	}
+	state.ChangedAt = now.Unix()
+
+	return state
+}
+
+func (state *ChannelScheduleState) Equal(other *ChannelScheduleState) bool {
+	if state == nil || other == nil {
+		return state == nil && other == nil
+	}
+	return state.Disabled == other.Disabled &&
+		equalOptional(state.Priority, other.Priority) &&
+		equalOptional(state.Weight, other.Weight) &&
+		slices.Equal(state.ActiveRules, other.ActiveRules)
+}
+
+func equalOptional[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func (state *ChannelScheduleState) String() string {
+	if state == nil {
+		return "默认"
+	}
+	parts := make([]string, 0, 4)
+	if state.Disabled {
+		parts = append(parts, "禁用")
+	}
+	if state.Priority != nil {
+		parts = append(parts, fmt.Sprintf("优先级=%d", *state.Priority))
+	}
+	if state.Weight != nil {
+		parts = append(parts, fmt.Sprintf("权重=%d", *state.Weight))
+	}
+	if len(state.ActiveRules) > 0 {
+		parts = append(parts, "规则="+strings.Join(state.ActiveRules, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (channel *Channel) scheduleDisabled() bool {
+	state := channel.GetScheduleState()
+	return state != nil && state.Disabled
+}
+
+// schedulePriority 返回调度规则调整后的优先级
+func (channel *Channel) schedulePriority() int64 {
+	if state := channel.GetScheduleState(); state != nil && state.Priority != nil {
+		return *state.Priority
+	}
+	if channel.Priority == nil {
+		return 0
+	}
+	return *channel.Priority
+}
+
+func applyChannelScheduleWeight(channel *Channel) {
+	if state := channel.GetScheduleState(); state != nil && state.Weight != nil && *state.Weight > 0 {
+		weight := *state.Weight
+		channel.Weight = &weight
+	}
+}
+
+// ApplyChannelSchedules 计算所有渠道的调度状态，写入发生变化的渠道并记录每一次状态切换。
+// 只在主节点执行，其他节点通过同步渠道缓存获得新的状态。
+func ApplyChannelSchedules(now time.Time) (int, error) {
+	var channels []*Channel
+	if err := DB.Select("id", "name", "schedule_rules", "schedule_state").Find(&channels).Error; err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, channel := range channels {
+		current := channel.GetScheduleState()
+		desired := channel.EvaluateSchedule(now)
+		if current.Equal(desired) {
+			continue
+		}
+
+		var value any
+		if desired != nil {
+			value = datatypes.NewJSONType(*desired)
+		}
+		if err := DB.Model(&Channel{}).Where("id = ?", channel.Id).Update("schedule_state", value).Error; err != nil {
+			logger.SysError(fmt.Sprintf("failed to apply schedule for channel #%d: %s", channel.Id, err.Error()))
+			continue
+		}
+
+		changed++
+		content := fmt.Sprintf("渠道 #%d %s 调度状态切换：%s -> %s", channel.Id, channel.Name, current.String(), desired.String())
+		logger.SysLog(content)
+		RecordLog(0, LogTypeSystem, content)
+	}
+
+	if changed > 0 {
+		if err := ChannelGroup.Load(); err != nil {
+			return changed, err
+		}
+	}
+
+	return changed, nil
+}
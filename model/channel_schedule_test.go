@@ -0,0 +1,160 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"one-api/common/config"
+
+	"gorm.io/datatypes"
+)
+
+func TestChannelScheduleRuleActiveWindows(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	// 2026-10-19 是周一
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 19, hour, minute, 0, 0, shanghai)
+	}
+
+	businessHours := &ChannelScheduleRule{Action: ChannelScheduleActionDisable, Timezone: "Asia/Shanghai", Weekdays: []int{1, 2, 3, 4, 5}, Start: "09:00", End: "18:00"}
+	if !businessHours.Active(monday(9, 0)) || businessHours.Active(monday(18, 0)) {
+		t.Fatal("expected business hours window to be [09:00, 18:00)")
+	}
+	if businessHours.Active(monday(10, 0).AddDate(0, 0, -1)) {
+		t.Fatal("expected business hours window to skip sunday")
+	}
+	// 同一时刻换算到 UTC 也应得到相同结果
+	if !businessHours.Active(monday(10, 0).UTC()) {
+		t.Fatal("expected rule timezone to be applied to the evaluated time")
+	}
+
+	overnight := &ChannelScheduleRule{Action: ChannelScheduleActionEnable, Timezone: "Asia/Shanghai", Weekdays: []int{1}, Start: "22:00", End: "06:00"}
+	if !overnight.Active(monday(23, 0)) || !overnight.Active(monday(23, 0).Add(6*time.Hour)) {
+		t.Fatal("expected overnight window to span into tuesday morning")
+	}
+	if overnight.Active(monday(5, 0)) {
+		t.Fatal("expected overnight window not to start from sunday")
+	}
+
+	cronRule := &ChannelScheduleRule{Action: ChannelScheduleActionDisable, Timezone: "Asia/Shanghai", Cron: "0 12 * * *", Duration: 90}
+	if !cronRule.Active(monday(12, 0)) || !cronRule.Active(monday(13, 29)) || cronRule.Active(monday(13, 30)) || cronRule.Active(monday(11, 59)) {
+		t.Fatal("expected cron window to last 90 minutes from 12:00")
+	}
+}
+
+func TestChannelScheduleRuleValidate(t *testing.T) {
+	weight := uint(0)
+	invalid := []ChannelScheduleRule{
+		{Action: "pause"},
+		{Action: ChannelScheduleActionAdjust},
+		{Action: ChannelScheduleActionAdjust, Weight: &weight},
+		{Action: ChannelScheduleActionDisable, Timezone: "Mars/Base"},
+		{Action: ChannelScheduleActionDisable, Cron: "bad cron", Duration: 10},
+		{Action: ChannelScheduleActionDisable, Cron: "0 * * * *"},
+		{Action: ChannelScheduleActionDisable, Weekdays: []int{7}},
+		{Action: ChannelScheduleActionDisable, Start: "25:00"},
+	}
+	for i, rule := range invalid {
+		if err := rule.Validate(); err == nil {
+			t.Fatalf("expected rule %d to be rejected: %+v", i, rule)
+		}
+	}
+
+	if err := validateChannelScheduleRules([]ChannelScheduleRule{{Action: ChannelScheduleActionDisable, Start: "09:00", End: "18:00"}}); err != nil {
+		t.Fatalf("expected valid schedule rules, got %v", err)
+	}
+}
+
+func TestChannelEvaluateSchedule(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	priority := int64(100)
+	weight := uint(5)
+
+	channel := testWeightedChannel(1, config.ChannelTypeOpenAI)
+	channel.ScheduleRules = &datatypes.JSONSlice[ChannelScheduleRule]{
+		{Name: "peak", Action: ChannelScheduleActionAdjust, Timezone: "UTC", Start: "09:00", End: "12:00", Priority: &priority, Weight: &weight},
+	}
+	state := channel.EvaluateSchedule(now)
+	if state == nil || state.Disabled || *state.Priority != 100 || *state.Weight != 5 || state.ActiveRules[0] != "peak" {
+		t.Fatalf("expected adjust rule to override priority and weight, got %+v", state)
+	}
+	if state := channel.EvaluateSchedule(now.Add(3 * time.Hour)); state != nil {
+		t.Fatalf("expected no schedule state outside window, got %+v", state)
+	}
+
+	channel.ScheduleRules = &datatypes.JSONSlice[ChannelScheduleRule]{
+		{Action: ChannelScheduleActionEnable, Timezone: "UTC", Start: "00:00", End: "06:00"},
+	}
+	if state := channel.EvaluateSchedule(now); state == nil || !state.Disabled {
+		t.Fatalf("expected enable rule to disable channel outside window, got %+v", state)
+	}
+	if state := channel.EvaluateSchedule(now.Add(-8 * time.Hour)); state != nil {
+		t.Fatalf("expected channel to keep default state inside enable window, got %+v", state)
+	}
+}
+
+func TestApplyChannelSchedulesUpdatesChooser(t *testing.T) {
+	useTestChannelDB(t)
+	if err := DB.AutoMigrate(&Log{}); err != nil {
+		t.Fatalf("expected log schema migration, got %v", err)
+	}
+	snapshot := snapshotTestChannelGroup(t)
+	t.Cleanup(func() {
+		restoreTestChannelGroup(t, snapshot)
+	})
+
+	priority := int64(0)
+	weight := uint(1)
+	rules := datatypes.JSONSlice[ChannelScheduleRule]{
+		{Name: "office", Action: ChannelScheduleActionDisable, Timezone: "UTC", Start: "09:00", End: "18:00"},
+	}
+	insertTestChannel(t, &Channel{Id: 1, Name: "scheduled", Type: config.ChannelTypeOpenAI, Status: config.ChannelStatusEnabled, Group: "default", Models: "gpt-4o", Priority: &priority, Weight: &weight, ScheduleRules: &rules})
+	insertTestChannel(t, &Channel{Id: 2, Name: "fallback", Type: config.ChannelTypeOpenAI, Status: config.ChannelStatusEnabled, Group: "default", Models: "gpt-4o", Priority: &priority, Weight: &weight})
+	requireChannelGroupLoad(t)
+
+	inWindow := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	changed, err := ApplyChannelSchedules(inWindow)
+	if err != nil || changed != 1 {
+		t.Fatalf("expected one schedule transition, got %d, %v", changed, err)
+	}
+	for i := 0; i < 10; i++ {
+		channel, err := ChannelGroup.Next("default", "gpt-4o")
+		if err != nil || channel.Id != 2 {
+			t.Fatalf("expected scheduled channel to be skipped, got %+v, %v", channel, err)
+		}
+	}
+
+	if changed, err := ApplyChannelSchedules(inWindow.Add(time.Minute)); err != nil || changed != 0 {
+		t.Fatalf("expected unchanged schedule not to be rewritten, got %d, %v", changed, err)
+	}
+
+	// 管理员编辑渠道时不会覆盖调度状态
+	channel, err := GetChannelById(1)
+	if err != nil {
+		t.Fatalf("expected channel fixture, got %v", err)
+	}
+	channel.ScheduleState = nil
+	if err := channel.UpdateRaw(true); err != nil {
+		t.Fatalf("expected channel update to succeed, got %v", err)
+	}
+	if channel, _ = GetChannelById(1); !channel.scheduleDisabled() {
+		t.Fatal("expected schedule state to survive channel update")
+	}
+
+	changed, err = ApplyChannelSchedules(inWindow.Add(9 * time.Hour))
+	if err != nil || changed != 1 {
+		t.Fatalf("expected schedule to revert after window, got %d, %v", changed, err)
+	}
+	if channel, _ = GetChannelById(1); channel.ScheduleState != nil {
+		t.Fatalf("expected schedule state to be cleared, got %+v", channel.GetScheduleState())
+	}
+
+	var logs int64
+	DB.Model(&Log{}).Where("type = ?", LogTypeSystem).Count(&logs)
+	if logs != 2 {
+		t.Fatalf("expected every transition to be logged, got %d", logs)
+	}
+}
@@ -159,6 +159,7 @@ func UpdateChannelsTag(tag string, channel *Channel) error {
 			Models:             channel.Models,
 			Group:              channel.Group,
 			Tag:                channel.Tag,
+			Region:             channel.Region,
 			ModelMapping:       channel.ModelMapping,
 			ModelHeaders:       channel.ModelHeaders,
 			CustomParameter:    channel.CustomParameter,
@@ -168,6 +169,10 @@ func UpdateChannelsTag(tag string, channel *Channel) error {
 			Plugin:             channel.Plugin,
 			PreCost:            channel.PreCost,
 			DisabledStream:     channel.DisabledStream,
+			CostConfig:         channel.CostConfig,
+			ScheduleRules:      channel.ScheduleRules,
+			BalanceAlert:       channel.BalanceAlert,
+			ModelSync:          channel.ModelSync,
 			CompatibleResponse: channel.CompatibleResponse,
 		}).Error
 
@@ -33,6 +33,18 @@ func (channel *Channel) ValidateRuntimeConfigJSONWithType(channelType int) error
 	if err := validateOptionalJSONObject("custom_parameter", channel.GetCustomParameter()); err != nil {
 		return err
 	}
+	if err := channel.GetCostConfig().Validate(); err != nil {
+		return err
+	}
+	if err := validateChannelScheduleRules(channel.GetScheduleRules()); err != nil {
+		return err
+	}
+	if err := channel.GetBalanceAlert().Validate(); err != nil {
+		return err
+	}
+	if err := channel.GetModelSyncRule().Validate(); err != nil {
+		return err
+	}
 	if channelType == config.ChannelTypeCustom {
 		if err := validateCustomChannelClaudePlugin(channel); err != nil {
 			return err
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	InvoiceFormatPDF = "pdf"
+	InvoiceFormatCSV = "csv"
+)
+
+var InvoiceFormats = []string{InvoiceFormatPDF, InvoiceFormatCSV}
+
+// InvoiceDocument 已生成的月账单文件，记录存在即表示文件已生成，EmailedTime 记录邮件的发送时间。
+// 文件保存在对象存储时记录存储名称和对象 key，下载时再生成临时地址；没有配置对象存储时为空
+type InvoiceDocument struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_invoice_document"`
+	Month       string `json:"month" gorm:"type:varchar(7);uniqueIndex:idx_invoice_document"`
+	Format      string `json:"format" gorm:"type:varchar(8);uniqueIndex:idx_invoice_document"`
+	Storage     string `json:"storage" gorm:"type:varchar(16);default:''"`
+	ObjectKey   string `json:"-" gorm:"type:varchar(255);default:''"`
+	EmailedTime int64  `json:"emailed_time" gorm:"bigint;default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func SaveInvoiceDocument(userId int, month, format, storage, objectKey string) error {
+	document := &InvoiceDocument{
+		UserId:      userId,
+		Month:       month,
+		Format:      format,
+		Storage:     storage,
+		ObjectKey:   objectKey,
+		CreatedTime: time.Now().Unix(),
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "month"}, {Name: "format"}},
+		DoUpdates: clause.AssignmentColumns([]string{"storage", "object_key", "created_time"}),
+	}).Create(document).Error
+}
+
+func GetInvoiceDocument(userId int, month, format string) (*InvoiceDocument, error) {
+	document := &InvoiceDocument{}
+	err := DB.Where("user_id = ? AND month = ? AND format = ?", userId, month, format).First(document).Error
+	return document, err
+}
+
+func GetInvoiceDocuments(userId int, month string) ([]*InvoiceDocument, error) {
+	var documents []*InvoiceDocument
+	err := DB.Where("user_id = ? AND month = ?", userId, month).Find(&documents).Error
+	return documents, err
+}
+
+func MarkInvoiceDocumentsEmailed(userId int, month string) error {
+	return DB.Model(&InvoiceDocument{}).Where("user_id = ? AND month = ?", userId, month).
+		Update("emailed_time", time.Now().Unix()).Error
+}
+
+// GetPendingInvoiceUserIds 返回指定月份有账单数据但还没有生成文件的用户，
+// requireEmailed 为 true 时还包括已生成文件但还没有发送邮件、并且设置了邮箱的用户
+func GetPendingInvoiceUserIds(date time.Time, requireEmailed bool) ([]int, error) {
+	var userIds []int
+	err := DB.Model(&StatisticsMonth{}).Distinct("user_id").
+		Where("date = ?", date.Format("2006-01-02")).
+		Order("user_id").
+		Pluck("user_id", &userIds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	done := DB.Model(&InvoiceDocument{}).Where("month = ? AND format = ?", date.Format("2006-01"), InvoiceFormatPDF)
+	if requireEmailed {
+		// 没有邮箱的用户生成文件后就不再重试发送
+		noEmail := DB.Model(&User{}).Select("id").Where("email = '' OR email IS NULL")
+		done = done.Where("emailed_time > 0 OR user_id IN (?)", noEmail)
+	}
+	var doneIds []int
+	if err := done.Pluck("user_id", &doneIds).Error; err != nil {
+		return nil, err
+	}
+	doneSet := make(map[int]struct{}, len(doneIds))
+	for _, id := range doneIds {
+		doneSet[id] = struct{}{}
+	}
+
+	pending := make([]int, 0, len(userIds))
+	for _, id := range userIds {
+		if _, ok := doneSet[id]; !ok {
+			pending = append(pending, id)
+		}
+	}
+	return pending, nil
+}
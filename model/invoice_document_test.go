@@ -0,0 +1,89 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"one-api/common/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGetPendingInvoiceUserIds(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	originalDB := DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&User{}, &StatisticsMonth{}, &InvoiceDocument{}); err != nil {
+		t.Fatalf("expected invoice schema migration to succeed, got %v", err)
+	}
+	DB = testDB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+	for _, userId := range []int{1, 2, 3} {
+		if err := DB.Exec("INSERT INTO statistics_months (date, user_id, model_name, quota) VALUES (?, ?, ?, ?)", "2026-03-01", userId, "gpt-4o", 100).Error; err != nil {
+			t.Fatalf("expected statistics fixture, got %v", err)
+		}
+	}
+	if err := DB.Exec("INSERT INTO statistics_months (date, user_id, model_name, quota) VALUES (?, ?, ?, ?)", "2026-02-01", 4, "gpt-4o", 100).Error; err != nil {
+		t.Fatalf("expected statistics fixture, got %v", err)
+	}
+
+	for _, format := range InvoiceFormats {
+		if err := SaveInvoiceDocument(1, "2026-03", format, "", ""); err != nil {
+			t.Fatalf("expected invoice document to be saved, got %v", err)
+		}
+		if err := SaveInvoiceDocument(2, "2026-03", format, "", ""); err != nil {
+			t.Fatalf("expected invoice document to be saved, got %v", err)
+		}
+	}
+	// 重复保存只更新存储位置
+	if err := SaveInvoiceDocument(2, "2026-03", InvoiceFormatPDF, "S3", "invoices/2026-03/a.pdf"); err != nil {
+		t.Fatalf("expected invoice document to be updated, got %v", err)
+	}
+	if err := MarkInvoiceDocumentsEmailed(1, "2026-03"); err != nil {
+		t.Fatalf("expected invoice documents to be marked, got %v", err)
+	}
+
+	pending, err := GetPendingInvoiceUserIds(date, false)
+	if err != nil {
+		t.Fatalf("expected pending users, got %v", err)
+	}
+	if fmt.Sprint(pending) != "[3]" {
+		t.Fatalf("expected only user 3 without documents, got %v", pending)
+	}
+
+	pending, err = GetPendingInvoiceUserIds(date, true)
+	if err != nil {
+		t.Fatalf("expected pending users, got %v", err)
+	}
+	if fmt.Sprint(pending) != "[2 3]" {
+		t.Fatalf("expected users 2 and 3 without email, got %v", pending)
+	}
+
+	documents, _ := GetInvoiceDocuments(2, "2026-03")
+	if len(documents) != 2 {
+		t.Fatalf("expected two documents for user 2, got %d", len(documents))
+	}
+	document, err := GetInvoiceDocument(2, "2026-03", InvoiceFormatPDF)
+	if err != nil || document.Storage != "S3" || document.ObjectKey != "invoices/2026-03/a.pdf" {
+		t.Fatalf("expected stored object key to be updated, got %+v, %v", document, err)
+	}
+
+	// 没有邮箱的用户生成文件后不再等待发送邮件
+	if err := DB.Create(&User{Id: 2, Username: "user2", Password: "password123", AccessToken: "invoice-2", AffCode: "invoice-2"}).Error; err != nil {
+		t.Fatalf("expected user fixture, got %v", err)
+	}
+	pending, err = GetPendingInvoiceUserIds(date, true)
+	if err != nil || fmt.Sprint(pending) != "[3]" {
+		t.Fatalf("expected user 2 without email to be skipped, got %v, %v", pending, err)
+	}
+}
@@ -29,6 +29,7 @@ func SetupDB() {
 		logger.FatalLog("failed to load channels: " + err.Error())
 	}
 	GlobalUserGroupRatio.Load()
+	GlobalPriceOverrides.Load()
 	config.RootUserEmail = GetRootUserEmail()
 	NewModelOwnedBys()
 
@@ -127,7 +128,7 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
-		err = db.AutoMigrate(&Token{})
+		err = db.AutoMigrate(&Token{}, &TokenPreviousKey{})
 		if err != nil {
 			return err
 		}
@@ -139,7 +140,7 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
-		err = db.AutoMigrate(&Redemption{})
+		err = db.AutoMigrate(&Redemption{}, &RedemptionRecord{}, &UserGroupGrant{})
 		if err != nil {
 			return err
 		}
@@ -168,6 +169,14 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
+		err = db.AutoMigrate(&OrderRefund{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&AffCommission{})
+		if err != nil {
+			return err
+		}
 		err = db.AutoMigrate(&Task{})
 		if err != nil {
 			return err
@@ -192,6 +201,46 @@ func InitDB() (err error) {
 			return err
 		}
 
+		err = db.AutoMigrate(&ChannelHealthCheck{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&ChannelBalanceHistory{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&ChannelModelSyncDiff{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&PriceVersion{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&PriceOverride{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&SubscriptionPlan{}, &UserSubscription{}, &SubscriptionBill{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&Organization{}, &OrganizationMember{}, &OrganizationInvitation{}, &OrganizationUsage{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&BillingTagStatistic{}, &BillingTagBudget{})
+		if err != nil {
+			return err
+		}
+
 		err = DB.AutoMigrate(&WebAuthnCredential{})
 		if err != nil {
 			return err
@@ -207,6 +256,11 @@ func InitDB() (err error) {
 			if err != nil {
 				return err
 			}
+
+			err = db.AutoMigrate(&InvoiceDocument{})
+			if err != nil {
+				return err
+			}
 		}
 
 		migrationAfter(DB)
@@ -104,6 +104,9 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterIntOption("QuotaForNewUser", &config.QuotaForNewUser, publicOption())
 	config.GlobalOption.RegisterIntOption("QuotaForInviter", &config.QuotaForInviter, publicOption())
 	config.GlobalOption.RegisterIntOption("QuotaForInvitee", &config.QuotaForInvitee, publicOption())
+	config.GlobalOption.RegisterFloatOption("AffCommissionRate", &config.AffCommissionRate, publicOption())
+	config.GlobalOption.RegisterIntOption("AffCommissionMonths", &config.AffCommissionMonths, publicOption())
+	config.GlobalOption.RegisterIntOption("AffTransferMinQuota", &config.AffTransferMinQuota, publicOption())
 	config.GlobalOption.RegisterIntOption("QuotaRemindThreshold", &config.QuotaRemindThreshold, publicOption())
 	config.GlobalOption.RegisterIntOption("PreConsumedQuota", &config.PreConsumedQuota, publicOption())
 	config.GlobalOption.RegisterStringOption("TopUpLink", &config.TopUpLink, publicOption())
@@ -114,10 +117,42 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterIntOption("RetryCooldownSeconds", &config.RetryCooldownSeconds, publicOption())
 	config.GlobalOption.RegisterIntOption("PreferredChannelWaitMilliseconds", &config.PreferredChannelWaitMilliseconds, publicOption())
 	config.GlobalOption.RegisterIntOption("PreferredChannelWaitPollMilliseconds", &config.PreferredChannelWaitPollMilliseconds, publicOption())
+	config.GlobalOption.RegisterCustomOptionWithValidator("ChannelBalancerStrategy", func() string {
+		return config.ChannelBalancerStrategy
+	}, func(value string) error {
+		config.ChannelBalancerStrategy = strings.TrimSpace(value)
+		return nil
+	}, validateChannelBalancerStrategy, publicOption(), config.ChannelBalancerStrategyWeight)
+	config.GlobalOption.RegisterBoolOption("ChannelHealthCheckEnabled", &config.ChannelHealthCheckEnabled, publicOption())
+	config.GlobalOption.RegisterIntOption("ChannelHealthCheckInterval", &config.ChannelHealthCheckInterval, publicOption())
+	config.GlobalOption.RegisterIntOption("ChannelHealthCheckConcurrency", &config.ChannelHealthCheckConcurrency, publicOption())
+	config.GlobalOption.RegisterIntOption("ChannelHealthCheckRecoverPasses", &config.ChannelHealthCheckRecoverPasses, publicOption())
+	config.GlobalOption.RegisterIntOption("ChannelHealthHistoryRetentionDays", &config.ChannelHealthHistoryRetentionDays, publicOption())
+	config.GlobalOption.RegisterBoolOption("ChannelBalanceRefreshEnabled", &config.ChannelBalanceRefreshEnabled, publicOption())
+	config.GlobalOption.RegisterIntOption("ChannelBalanceRefreshInterval", &config.ChannelBalanceRefreshInterval, publicOption())
+	config.GlobalOption.RegisterIntOption("ChannelBalanceHistoryRetentionDays", &config.ChannelBalanceHistoryRetentionDays, publicOption())
+	config.GlobalOption.RegisterBoolOption("ChannelModelSyncEnabled", &config.ChannelModelSyncEnabled, publicOption())
+	config.GlobalOption.RegisterIntOption("ChannelModelSyncInterval", &config.ChannelModelSyncInterval, publicOption())
 	config.GlobalOption.RegisterBoolOption("MjNotifyEnabled", &config.MjNotifyEnabled, publicOption())
 	config.GlobalOption.RegisterStringOption("ChatImageRequestProxy", &config.ChatImageRequestProxy, publicOption())
 	config.GlobalOption.RegisterFloatOption("PaymentUSDRate", &config.PaymentUSDRate, publicOption())
 	config.GlobalOption.RegisterIntOption("PaymentMinAmount", &config.PaymentMinAmount, publicOption())
+	config.GlobalOption.RegisterBoolOption("PaymentReconcileEnabled", &config.PaymentReconcileEnabled, publicOption())
+	config.GlobalOption.RegisterIntOption("PaymentReconcileInterval", &config.PaymentReconcileInterval, publicOption())
+
+	config.GlobalOption.RegisterCustomOptionWithValidator("PriceTimezone", func() string {
+		return config.PriceTimezone
+	}, func(value string) error {
+		config.PriceTimezone = strings.TrimSpace(value)
+		return nil
+	}, func(value string) error {
+		_, err := time.LoadLocation(strings.TrimSpace(value))
+		return err
+	}, publicOption(), "")
+	config.GlobalOption.RegisterCustomOptionWithValidator("PriceTimeModifiers", PriceTimeModifiersToJSONString, UpdatePriceTimeModifiersByJSONString, func(value string) error {
+		_, err := ParsePriceTimeModifiers(value)
+		return err
+	}, publicOption(), "[]")
 
 	config.GlobalOption.RegisterCustomOptionWithValidator("RechargeDiscount", func() string {
 		return common.RechargeDiscount2JSONString()
@@ -147,6 +182,15 @@ func InitOptionMap() {
 	}, publicOption(), common.GetDefaultDisableChannelKeywords())
 
 	config.GlobalOption.RegisterIntOption("RetryTimeOut", &config.RetryTimeOut, publicOption())
+	config.GlobalOption.RegisterIntOption("TokenRotationGraceSeconds", &config.TokenRotationGraceSeconds, publicOption())
+	config.GlobalOption.RegisterStringOption("TokenKeyPrefix", &config.TokenKeyPrefix, publicOption())
+
+	config.GlobalOption.RegisterStringOption("InvoiceCompanyName", &config.InvoiceCompanyName, publicOption())
+	config.GlobalOption.RegisterStringOption("InvoiceCompanyAddress", &config.InvoiceCompanyAddress, publicOption())
+	config.GlobalOption.RegisterStringOption("InvoiceCompanyTaxId", &config.InvoiceCompanyTaxId, publicOption())
+	config.GlobalOption.RegisterStringOption("InvoiceCompanyEmail", &config.InvoiceCompanyEmail, publicOption())
+	config.GlobalOption.RegisterStringOption("InvoiceNotes", &config.InvoiceNotes, publicOption())
+	config.GlobalOption.RegisterBoolOption("InvoiceEmailEnabled", &config.InvoiceEmailEnabled, publicOption())
 
 	config.GlobalOption.RegisterBoolOption("EnableSafe", &config.EnableSafe, publicOption())
 	config.GlobalOption.RegisterStringOption("SafeToolName", &config.SafeToolName, publicOption())
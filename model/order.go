@@ -3,6 +3,8 @@ package model
 import (
 	"time"
 
+	"one-api/common/utils"
+
 	"gorm.io/gorm"
 )
 
@@ -13,6 +15,11 @@ const (
 	OrderStatusSuccess OrderStatus = "success"
 	OrderStatusFailed  OrderStatus = "failed"
 	OrderStatusClosed  OrderStatus = "closed"
+	// 退款中表示已向网关发起退款，部分退款完成后订单恢复为 success
+	OrderStatusRefunding OrderStatus = "refunding"
+	OrderStatusRefunded  OrderStatus = "refunded"
+	// 已支付但无法发放（如套餐订单开通订阅失败），等待管理员退款
+	OrderStatusRefundPending OrderStatus = "refund_pending"
 )
 
 type Order struct {
@@ -25,12 +32,18 @@ type Order struct {
 	OrderAmount   float64        `json:"order_amount" gorm:"type:decimal(10,2);default:0"`
 	OrderCurrency CurrencyType   `json:"order_currency" gorm:"type:varchar(16)"`
 	Quota         int            `json:"quota" gorm:"type:int;default:0"`
+	PlanId        int            `json:"plan_id" gorm:"default:0"` // 订阅套餐订单，0 表示充值订单
 	Fee           float64        `json:"fee" gorm:"type:decimal(10,2);default:0"`
 	Discount      float64        `json:"discount" gorm:"type:decimal(10,2);default:0"`
 	Status        OrderStatus    `json:"status" gorm:"type:varchar(32)"`
+	RefundAmount  float64        `json:"refund_amount" gorm:"type:decimal(10,2);default:0"` // 已退款的支付金额
+	RefundQuota   int            `json:"refund_quota" gorm:"default:0"`                     // 已扣回的额度
 	CreatedAt     int            `json:"created_at"`
 	UpdatedAt     int            `json:"-"`
 	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 对账发现的异常已通知管理员，之后的对账不再重复通知
+	MismatchNotified bool `json:"mismatch_notified" gorm:"default:false"`
 }
 
 // 查询并关闭未完成的订单
@@ -52,6 +65,18 @@ func GetUserOrder(userId int, tradeNo string) (*Order, error) {
 	return &order, err
 }
 
+func GetOrderById(id int) (*Order, error) {
+	var order Order
+	err := DB.First(&order, id).Error
+	return &order, err
+}
+
+func GetOrderByGatewayNo(gatewayId int, gatewayNo string) (*Order, error) {
+	var order Order
+	err := DB.Where("gateway_id = ? AND gateway_no = ?", gatewayId, gatewayNo).First(&order).Error
+	return &order, err
+}
+
 func (o *Order) Insert() error {
 	return DB.Create(o).Error
 }
@@ -60,6 +85,68 @@ func (o *Order) Update() error {
 	return DB.Save(o).Error
 }
 
+// CompleteOrder 把待支付的订单标记为成功，订单已被其他回调处理时返回 false
+func CompleteOrder(order *Order, gatewayNo string) (bool, error) {
+	updates := map[string]any{"status": OrderStatusSuccess}
+	if gatewayNo != "" {
+		updates["gateway_no"] = gatewayNo
+	}
+	result := DB.Model(&Order{}).Where("id = ? AND status = ?", order.ID, OrderStatusPending).Updates(updates)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	order.Status = OrderStatusSuccess
+	if gatewayNo != "" {
+		order.GatewayNo = gatewayNo
+	}
+	return true, nil
+}
+
+// GetPendingOrdersBefore 获取创建时间早于 createdBefore 的待支付订单
+func GetPendingOrdersBefore(createdBefore int64, limit int) ([]*Order, error) {
+	var orders []*Order
+	err := DB.Where("status = ? AND created_at <= ?", OrderStatusPending, createdBefore).Order("id").Limit(limit).Find(&orders).Error
+	return orders, err
+}
+
+// GetClosedOrdersSince 获取 updatedAfter 之后被关闭的订单
+func GetClosedOrdersSince(updatedAfter int64, limit int) ([]*Order, error) {
+	var orders []*Order
+	err := DB.Where("status = ? AND updated_at >= ?", OrderStatusClosed, updatedAfter).Order("id").Limit(limit).Find(&orders).Error
+	return orders, err
+}
+
+// RefundableMoney 剩余可退款的支付金额
+func (o *Order) RefundableMoney() float64 {
+	return utils.Decimal(o.OrderAmount-o.RefundAmount, 2)
+}
+
+// UpdateStatus 仅在订单当前状态为 from 时修改状态，返回是否修改成功
+func (o *Order) UpdateStatus(from, to OrderStatus) (bool, error) {
+	result := DB.Model(&Order{}).Where("id = ? AND status = ?", o.ID, from).Update("status", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	o.Status = to
+	return true, nil
+}
+
+// MarkMismatchNotified 记录对账异常已通知，不更新 updated_at，已记录过时返回 false
+func (o *Order) MarkMismatchNotified() (bool, error) {
+	result := DB.Model(&Order{}).Where("id = ? AND mismatch_notified = ?", o.ID, false).UpdateColumn("mismatch_notified", true)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	o.MismatchNotified = true
+	return true, nil
+}
+
 var allowedOrderFields = map[string]bool{
 	"id":         true,
 	"gateway_id": true,
@@ -119,7 +206,7 @@ type OrderStatistics struct {
 }
 
 func GetStatisticsOrder() (orderStatistics []*OrderStatistics, err error) {
-	err = DB.Model(&Order{}).Select("sum(quota) as quota, sum(order_amount) as money, order_currency").Where("status = ?", OrderStatusSuccess).Group("order_currency").Scan(&orderStatistics).Error
+	err = DB.Model(&Order{}).Select("sum(quota - refund_quota) as quota, sum(order_amount - refund_amount) as money, order_currency").Where("status IN ?", []OrderStatus{OrderStatusSuccess, OrderStatusRefunding}).Group("order_currency").Scan(&orderStatistics).Error
 	return orderStatistics, err
 }
 
@@ -133,13 +220,13 @@ func GetStatisticsOrderByPeriod(startTimestamp, endTimestamp int64) (orderStatis
 
 	err = DB.Raw(`
 		SELECT `+groupSelect+`,
-		sum(order_amount) as order_amount
+		sum(order_amount - refund_amount) as order_amount
 		FROM orders
-		WHERE status= ?
+		WHERE status IN (?, ?)
 		AND created_at BETWEEN ? AND ?
 		GROUP BY date
 		ORDER BY date
-	`, OrderStatusSuccess, startTimestamp, endTimestamp).Scan(&orderStatistics).Error
+	`, OrderStatusSuccess, OrderStatusRefunding, startTimestamp, endTimestamp).Scan(&orderStatistics).Error
 
 	return orderStatistics, err
 }
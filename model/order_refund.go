@@ -0,0 +1,234 @@
+package model
+
+import (
+	"errors"
+	"math"
+
+	"one-api/common/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrderRefundSourceAdmin      = "admin"
+	OrderRefundSourceChargeback = "chargeback"
+)
+
+const (
+	OrderRefundStatusSuccess    = "success"
+	OrderRefundStatusProcessing = "processing" // 网关已受理，等待退款通知或查询确认
+	OrderRefundStatusFailed     = "failed"
+)
+
+var (
+	ErrOrderRefundAmountInvalid = errors.New("退款金额无效")
+	ErrOrderRefundDuplicated    = errors.New("退款已处理")
+	ErrOrderRefundConflict      = errors.New("订单已被修改，请刷新后重试")
+)
+
+// OrderRefund 订单的一次退款或拒付，RefundNo 为本地退款单号或网关的争议编号
+type OrderRefund struct {
+	ID              int     `json:"id"`
+	OrderId         int     `json:"order_id" gorm:"index"`
+	UserId          int     `json:"user_id" gorm:"index"`
+	TradeNo         string  `json:"trade_no" gorm:"type:varchar(50)"`
+	RefundNo        string  `json:"refund_no" gorm:"type:varchar(100);uniqueIndex"`
+	GatewayRefundNo string  `json:"gateway_refund_no" gorm:"type:varchar(100);default:''"`
+	Money           float64 `json:"money" gorm:"type:decimal(10,2);default:0"`
+	Quota           int     `json:"quota" gorm:"default:0"`
+	Source          string  `json:"source" gorm:"type:varchar(16)"`
+	Reason          string  `json:"reason" gorm:"type:varchar(255);default:''"`
+	Status          string  `json:"status" gorm:"type:varchar(16);default:'success'"`
+	// 处理中的退款失败时订单恢复为退款前的状态
+	PreviousStatus OrderStatus `json:"-" gorm:"type:varchar(32);default:''"`
+	CreatedAt      int64       `json:"created_at" gorm:"bigint"`
+}
+
+func GetOrderRefunds(orderId int) ([]*OrderRefund, error) {
+	var refunds []*OrderRefund
+	err := DB.Where("order_id = ?", orderId).Order("id desc").Find(&refunds).Error
+	return refunds, err
+}
+
+// GetProcessingOrderRefund 按本地退款单号查找处理中的退款
+func GetProcessingOrderRefund(refundNo string) (*OrderRefund, error) {
+	refund := &OrderRefund{}
+	err := DB.Where("refund_no = ? AND status = ?", refundNo, OrderRefundStatusProcessing).First(refund).Error
+	return refund, err
+}
+
+// GetProcessingOrderRefunds 查找创建时间早于 before 的处理中退款
+func GetProcessingOrderRefunds(before int64, limit int) ([]*OrderRefund, error) {
+	var refunds []*OrderRefund
+	err := DB.Where("status = ? AND created_at <= ?", OrderRefundStatusProcessing, before).Order("id asc").Limit(limit).Find(&refunds).Error
+	return refunds, err
+}
+
+// CreateProcessingOrderRefund 记录网关已受理但尚未完成的退款，订单保持退款中，
+// 退款成功后再调用 RefundOrder 扣回额度，失败时调用 FailOrderRefund 恢复订单。
+func CreateProcessingOrderRefund(order *Order, refund *OrderRefund, previousStatus OrderStatus) error {
+	refund.Money = utils.Decimal(refund.Money, 2)
+	if refund.Money <= 0 || refund.Money > order.RefundableMoney() {
+		return ErrOrderRefundAmountInvalid
+	}
+	refund.OrderId = order.ID
+	refund.UserId = order.UserId
+	refund.TradeNo = order.TradeNo
+	refund.Quota = 0
+	refund.Status = OrderRefundStatusProcessing
+	refund.PreviousStatus = previousStatus
+	refund.CreatedAt = utils.GetTimestamp()
+	return DB.Create(refund).Error
+}
+
+// FailOrderRefund 处理中的退款失败，订单恢复为退款前的状态
+func FailOrderRefund(order *Order, refund *OrderRefund) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&OrderRefund{}).Where("id = ? AND status = ?", refund.ID, OrderRefundStatusProcessing).Update("status", OrderRefundStatusFailed)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderRefundDuplicated
+		}
+		refund.Status = OrderRefundStatusFailed
+
+		result = tx.Model(&Order{}).Where("id = ? AND status = ?", order.ID, OrderStatusRefunding).Update("status", refund.PreviousStatus)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderRefundConflict
+		}
+		order.Status = refund.PreviousStatus
+		return nil
+	})
+}
+
+// CancelProcessingOrderRefunds 拒付取代订单尚未完成的退款：处理中的退款标记为失败，
+// 订单恢复为退款前的状态，之后由拒付扣回额度。没有处理中的退款时订单恢复为 success。
+func CancelProcessingOrderRefunds(order *Order) ([]*OrderRefund, error) {
+	var refunds []*OrderRefund
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_id = ? AND status = ?", order.ID, OrderRefundStatusProcessing).Order("id asc").Find(&refunds).Error; err != nil {
+			return err
+		}
+		previousStatus := OrderStatusSuccess
+		if len(refunds) > 0 && refunds[0].PreviousStatus != "" {
+			previousStatus = refunds[0].PreviousStatus
+		}
+		if len(refunds) > 0 {
+			if err := tx.Model(&OrderRefund{}).Where("order_id = ? AND status = ?", order.ID, OrderRefundStatusProcessing).Update("status", OrderRefundStatusFailed).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&Order{}).Where("id = ? AND status = ?", order.ID, OrderStatusRefunding).Update("status", previousStatus)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderRefundConflict
+		}
+		order.Status = previousStatus
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, refund := range refunds {
+		refund.Status = OrderRefundStatusFailed
+	}
+	return refunds, nil
+}
+
+// RefundOrder 记录退款并按退款金额占实付金额的比例扣回额度，扣回后用户余额可能为负。
+// 剩余金额全部退还时订单标记为已退款，否则恢复为 success。
+// refund 为处理中的退款时确认该退款，不再新建记录。
+// 套餐订单全额退款时取消该订单开通或预付的订阅周期，扣回的是本周期未使用的包含额度。
+func RefundOrder(order *Order, refund *OrderRefund) error {
+	remaining := order.RefundableMoney()
+	refund.Money = utils.Decimal(refund.Money, 2)
+	if refund.Money <= 0 || refund.Money > remaining {
+		return ErrOrderRefundAmountInvalid
+	}
+
+	status := OrderStatusSuccess
+	if refund.Money == remaining {
+		status = OrderStatusRefunded
+		refund.Quota = order.Quota - order.RefundQuota
+	} else {
+		refund.Quota = int(math.Round(float64(order.Quota) * refund.Money / order.OrderAmount))
+	}
+	refund.OrderId = order.ID
+	refund.UserId = order.UserId
+	refund.TradeNo = order.TradeNo
+	if refund.CreatedAt == 0 {
+		refund.CreatedAt = utils.GetTimestamp()
+	}
+
+	processing := refund.ID > 0 && refund.Status == OrderRefundStatusProcessing
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if !processing {
+			var count int64
+			if err := tx.Model(&OrderRefund{}).Where("refund_no = ?", refund.RefundNo).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrOrderRefundDuplicated
+			}
+		}
+		if status == OrderStatusRefunded && order.PlanId > 0 {
+			reclaimed, err := cancelOrderSubscription(tx, order)
+			if err != nil {
+				return err
+			}
+			refund.Quota = reclaimed
+		}
+		refund.Status = OrderRefundStatusSuccess
+		if processing {
+			result := tx.Model(&OrderRefund{}).Where("id = ? AND status = ?", refund.ID, OrderRefundStatusProcessing).Updates(map[string]any{
+				"quota":  refund.Quota,
+				"status": OrderRefundStatusSuccess,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrOrderRefundDuplicated
+			}
+		} else if err := tx.Create(refund).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&Order{}).Where("id = ? AND refund_quota = ?", order.ID, order.RefundQuota).Updates(map[string]any{
+			"refund_amount": gorm.Expr("refund_amount + ?", refund.Money),
+			"refund_quota":  gorm.Expr("refund_quota + ?", refund.Quota),
+			"status":        status,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderRefundConflict
+		}
+
+		if refund.Quota > 0 && order.PlanId == 0 {
+			return tx.Model(&User{}).Where("id = ?", order.UserId).Update("quota", gorm.Expr("quota - ?", refund.Quota)).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	order.RefundAmount = utils.Decimal(order.RefundAmount+refund.Money, 2)
+	order.RefundQuota += refund.Quota
+	order.Status = status
+	if order.PlanId > 0 {
+		refreshSubscriptionUserCache(order.UserId)
+	} else if refund.Quota > 0 {
+		refreshUserQuotaCache(order.UserId)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useOrderRefundTestDB(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	originalDB := DB
+	originalRedis := config.RedisEnabled
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&User{}, &Log{}, &Order{}, &OrderRefund{}, &SubscriptionPlan{}, &UserSubscription{}, &SubscriptionBill{}); err != nil {
+		t.Fatalf("expected order schema migration to succeed, got %v", err)
+	}
+	DB = testDB
+	config.RedisEnabled = false
+	t.Cleanup(func() {
+		DB = originalDB
+		config.RedisEnabled = originalRedis
+	})
+}
+
+func TestRefundOrderClawsBackQuotaProportionally(t *testing.T) {
+	useOrderRefundTestDB(t)
+
+	user := &User{Username: "refund-user", Quota: 500, AccessToken: "refund-user-token"}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatalf("expected user fixture, got %v", err)
+	}
+	order := &Order{UserId: user.Id, TradeNo: "T1", OrderAmount: 30, Quota: 1000, Status: OrderStatusSuccess}
+	if err := order.Insert(); err != nil {
+		t.Fatalf("expected order fixture, got %v", err)
+	}
+
+	// 部分退款按比例扣回，订单保持 success
+	if err := RefundOrder(order, &OrderRefund{RefundNo: "R1", Money: 10, Source: OrderRefundSourceAdmin}); err != nil {
+		t.Fatalf("expected partial refund to succeed, got %v", err)
+	}
+	if order.Status != OrderStatusSuccess || order.RefundQuota != 333 || order.RefundableMoney() != 20 {
+		t.Fatalf("unexpected order after partial refund: %+v", order)
+	}
+
+	if err := RefundOrder(order, &OrderRefund{RefundNo: "R1", Money: 1}); !errors.Is(err, ErrOrderRefundDuplicated) {
+		t.Fatalf("expected duplicated refund number to be rejected, got %v", err)
+	}
+	if err := RefundOrder(order, &OrderRefund{RefundNo: "R2", Money: 20.01}); !errors.Is(err, ErrOrderRefundAmountInvalid) {
+		t.Fatalf("expected refund above remaining amount to be rejected, got %v", err)
+	}
+
+	// 退还剩余金额时扣回剩余额度，余额可以变为负数
+	if err := RefundOrder(order, &OrderRefund{RefundNo: "R3", Money: 20, Source: OrderRefundSourceChargeback}); err != nil {
+		t.Fatalf("expected full refund to succeed, got %v", err)
+	}
+	stored, _ := GetOrderById(order.ID)
+	if stored.Status != OrderStatusRefunded || stored.RefundQuota != 1000 || stored.RefundAmount != 30 {
+		t.Fatalf("unexpected order after full refund: %+v", stored)
+	}
+	quota, _ := GetUserQuota(user.Id)
+	if quota != -500 {
+		t.Fatalf("expected all order quota to be clawed back, got %d", quota)
+	}
+	refunds, _ := GetOrderRefunds(order.ID)
+	if len(refunds) != 2 || refunds[0].Quota != 667 {
+		t.Fatalf("unexpected refund records: %+v", refunds)
+	}
+}
+
+func TestRefundPlanOrderCancelsSubscription(t *testing.T) {
+	useOrderRefundTestDB(t)
+
+	user := &User{Username: "plan-user", Group: "default", AccessToken: "plan-user-token"}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatalf("expected user fixture, got %v", err)
+	}
+	plan := &SubscriptionPlan{Name: "pro", Price: 10, Quota: 500, Group: "vip", Enabled: true}
+	if err := DB.Create(plan).Error; err != nil {
+		t.Fatalf("expected plan fixture, got %v", err)
+	}
+	order := &Order{UserId: user.Id, TradeNo: "P1", OrderAmount: 10, PlanId: plan.Id, Status: OrderStatusPending}
+	if err := order.Insert(); err != nil {
+		t.Fatalf("expected order fixture, got %v", err)
+	}
+	if _, err := CompletePlanOrder(order, "G1", plan); err != nil {
+		t.Fatalf("expected plan order to activate, got %v", err)
+	}
+	// 本周期已经使用了 200
+	DB.Model(&User{}).Where("id = ?", user.Id).Updates(map[string]any{"quota": 300, "used_quota": 200})
+
+	if err := RefundOrder(order, &OrderRefund{RefundNo: "R1", Money: 10, Source: OrderRefundSourceChargeback}); err != nil {
+		t.Fatalf("expected plan order refund to succeed, got %v", err)
+	}
+	if order.Status != OrderStatusRefunded || order.RefundQuota != 300 {
+		t.Fatalf("expected unused included quota to be clawed back, got %+v", order)
+	}
+
+	stored := &User{}
+	DB.First(stored, user.Id)
+	if stored.Quota != 0 || stored.Group != "default" {
+		t.Fatalf("expected quota reclaimed and group restored, got quota=%d group=%s", stored.Quota, stored.Group)
+	}
+	sub := &UserSubscription{}
+	DB.First(sub, "user_id = ?", user.Id)
+	if sub.Status != SubscriptionStatusExpired {
+		t.Fatalf("expected subscription to be canceled, got %s", sub.Status)
+	}
+}
+
+func TestProcessingOrderRefundWaitsForConfirmation(t *testing.T) {
+	useOrderRefundTestDB(t)
+
+	user := &User{Username: "processing-user", Quota: 1000, AccessToken: "processing-user-token"}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatalf("expected user fixture, got %v", err)
+	}
+	order := &Order{UserId: user.Id, TradeNo: "T2", OrderAmount: 10, Quota: 1000, Status: OrderStatusRefunding}
+	if err := order.Insert(); err != nil {
+		t.Fatalf("expected order fixture, got %v", err)
+	}
+
+	// 退款失败时订单恢复，额度不变
+	failed := &OrderRefund{RefundNo: "R1", Money: 10, Source: OrderRefundSourceAdmin}
+	if err := CreateProcessingOrderRefund(order, failed, OrderStatusSuccess); err != nil {
+		t.Fatalf("expected processing refund to be recorded, got %v", err)
+	}
+	if err := FailOrderRefund(order, failed); err != nil {
+		t.Fatalf("expected failed refund to restore order, got %v", err)
+	}
+	if err := FailOrderRefund(order, failed); !errors.Is(err, ErrOrderRefundDuplicated) {
+		t.Fatalf("expected repeated failure notify to be ignored, got %v", err)
+	}
+	stored, _ := GetOrderById(order.ID)
+	if stored.Status != OrderStatusSuccess || stored.RefundQuota != 0 {
+		t.Fatalf("expected order to be restored, got %+v", stored)
+	}
+
+	// 退款成功后确认同一条记录并扣回额度
+	DB.Model(&Order{}).Where("id = ?", order.ID).Update("status", OrderStatusRefunding)
+	processing := &OrderRefund{RefundNo: "R2", Money: 10, Source: OrderRefundSourceAdmin}
+	if err := CreateProcessingOrderRefund(order, processing, OrderStatusSuccess); err != nil {
+		t.Fatalf("expected processing refund to be recorded, got %v", err)
+	}
+	confirmed, err := GetProcessingOrderRefund("R2")
+	if err != nil {
+		t.Fatalf("expected processing refund to be found, got %v", err)
+	}
+	if err := RefundOrder(order, confirmed); err != nil {
+		t.Fatalf("expected processing refund to be confirmed, got %v", err)
+	}
+	stored, _ = GetOrderById(order.ID)
+	if stored.Status != OrderStatusRefunded || stored.RefundQuota != 1000 {
+		t.Fatalf("expected order to be refunded, got %+v", stored)
+	}
+	refunds, _ := GetOrderRefunds(order.ID)
+	if len(refunds) != 2 || refunds[0].Status != OrderRefundStatusSuccess || refunds[0].Quota != 1000 || refunds[1].Status != OrderRefundStatusFailed {
+		t.Fatalf("unexpected refund records: %+v", refunds)
+	}
+}
@@ -0,0 +1,626 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+const (
+	OrganizationInvitationPending  = "pending"
+	OrganizationInvitationAccepted = "accepted"
+	OrganizationInvitationRevoked  = "revoked"
+)
+
+// 邀请的有效期
+const OrganizationInvitationValidDays = 7
+
+var (
+	ErrOrganizationNotMember            = errors.New("不是该组织的成员")
+	ErrOrganizationPermissionDenied     = errors.New("没有管理该组织的权限")
+	ErrOrganizationQuotaNotEnough       = errors.New("组织额度不足")
+	ErrOrganizationMemberQuotaExhausted = errors.New("已达到组织分配给你的额度上限")
+)
+
+// Organization 组织共享额度钱包，绑定到组织的令牌从这里扣费，不影响成员的个人额度
+type Organization struct {
+	Id          int            `json:"id"`
+	Name        string         `json:"name" gorm:"type:varchar(50)"`
+	OwnerId     int            `json:"owner_id" gorm:"index"`
+	Quota       int            `json:"quota" gorm:"type:int;default:0"`
+	UsedQuota   int            `json:"used_quota" gorm:"type:int;default:0"`
+	CreatedTime int64          `json:"created_time" gorm:"bigint"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// OrganizationMember 组织成员，QuotaLimit 为成员最多可使用的组织额度，0 表示不限制
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_organization_member"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_organization_member;index"`
+	Role           string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	QuotaLimit     int    `json:"quota_limit" gorm:"type:int;default:0"`
+	UsedQuota      int    `json:"used_quota" gorm:"type:int;default:0"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+
+	Username    string `json:"username" gorm:"-"`
+	DisplayName string `json:"display_name" gorm:"-"`
+}
+
+func (member *OrganizationMember) CanManage() bool {
+	return member.Role == OrganizationRoleOwner || member.Role == OrganizationRoleAdmin
+}
+
+type OrganizationInvitation struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"index"`
+	Code           string `json:"code" gorm:"type:varchar(32);uniqueIndex"`
+	Email          string `json:"email" gorm:"type:varchar(50);default:''"`
+	Role           string `json:"role" gorm:"type:varchar(16)"`
+	InviterId      int    `json:"inviter_id"`
+	Status         string `json:"status" gorm:"type:varchar(16);default:'pending'"`
+	AcceptedUserId int    `json:"accepted_user_id" gorm:"default:0"`
+	ExpiresAt      int64  `json:"expires_at" gorm:"bigint"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationUsage 组织按天、成员、模型汇总的用量
+type OrganizationUsage struct {
+	Id               int    `json:"-"`
+	OrganizationId   int    `json:"organization_id" gorm:"uniqueIndex:idx_organization_usage"`
+	Date             string `json:"date" gorm:"type:varchar(10);uniqueIndex:idx_organization_usage"`
+	UserId           int    `json:"user_id" gorm:"uniqueIndex:idx_organization_usage"`
+	ModelName        string `json:"model_name" gorm:"type:varchar(255);uniqueIndex:idx_organization_usage"`
+	RequestCount     int    `json:"request_count"`
+	Quota            int    `json:"quota"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+// OrganizationWithRole 用户所在的组织及其角色
+type OrganizationWithRole struct {
+	Organization
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+	MemberUsed int    `json:"member_used_quota"`
+}
+
+func validateOrganizationRole(role string, allowOwner bool) error {
+	switch role {
+	case OrganizationRoleAdmin, OrganizationRoleMember:
+		return nil
+	case OrganizationRoleOwner:
+		if allowOwner {
+			return nil
+		}
+	}
+	return fmt.Errorf("无效的角色: %s", role)
+}
+
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 50 {
+		return nil, errors.New("组织名称不能为空且不超过 50 个字符")
+	}
+
+	now := utils.GetTimestamp()
+	organization := &Organization{Name: name, OwnerId: ownerId, CreatedTime: now}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: organization.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    now,
+		}).Error
+	})
+	return organization, err
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	var organization Organization
+	err := DB.First(&organization, "id = ?", id).Error
+	return &organization, err
+}
+
+func GetOrganizationMember(organizationId, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := DB.Where("organization_id = ? AND user_id = ?", organizationId, userId).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrganizationNotMember
+	}
+	return &member, err
+}
+
+// GetOrganizationManager 返回有管理权限的成员，否则返回错误
+func GetOrganizationManager(organizationId, userId int) (*OrganizationMember, error) {
+	member, err := GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !member.CanManage() {
+		return nil, ErrOrganizationPermissionDenied
+	}
+	return member, nil
+}
+
+func GetUserOrganizations(userId int) ([]*OrganizationWithRole, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	organizations := make([]*OrganizationWithRole, 0, len(members))
+	for _, member := range members {
+		organization, err := GetOrganizationById(member.OrganizationId)
+		if err != nil {
+			continue
+		}
+		organizations = append(organizations, &OrganizationWithRole{
+			Organization: *organization,
+			Role:         member.Role,
+			QuotaLimit:   member.QuotaLimit,
+			MemberUsed:   member.UsedQuota,
+		})
+	}
+	return organizations, nil
+}
+
+type SearchOrganizationParams struct {
+	Keyword string `form:"keyword"`
+	OwnerId int    `form:"owner_id"`
+	PaginationParams
+}
+
+var allowedOrganizationOrderFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"quota":        true,
+	"used_quota":   true,
+	"created_time": true,
+}
+
+func GetOrganizationsList(params *SearchOrganizationParams) (*DataResult[Organization], error) {
+	var organizations []*Organization
+	db := DB.Model(&Organization{})
+	if params.Keyword != "" {
+		db = db.Where("name LIKE ?", params.Keyword+"%")
+	}
+	if params.OwnerId > 0 {
+		db = db.Where("owner_id = ?", params.OwnerId)
+	}
+	return PaginateAndOrder(db, &params.PaginationParams, &organizations, allowedOrganizationOrderFields)
+}
+
+func GetOrganizationMembers(organizationId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("organization_id = ?", organizationId).Order("id asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	userIds := make([]int, 0, len(members))
+	for _, member := range members {
+		userIds = append(userIds, member.UserId)
+	}
+	var users []User
+	DB.Select("id, username, display_name").Where("id IN ?", userIds).Find(&users)
+	userMap := make(map[int]User, len(users))
+	for _, user := range users {
+		userMap[user.Id] = user
+	}
+	for _, member := range members {
+		member.Username = userMap[member.UserId].Username
+		member.DisplayName = userMap[member.UserId].DisplayName
+	}
+	return members, nil
+}
+
+func (organization *Organization) UpdateName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 50 {
+		return errors.New("组织名称不能为空且不超过 50 个字符")
+	}
+	organization.Name = name
+	return DB.Model(organization).Update("name", name).Error
+}
+
+// DeleteOrganization 删除组织，剩余额度退回给所有者，绑定到组织的令牌将无法继续使用，返回实际退回的额度
+func DeleteOrganization(organization *Organization) (int, error) {
+	refunded := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定组织后重新读取额度，避免退回已被并发请求扣除或充值前的旧额度
+		locked := &Organization{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "quota").First(locked, "id = ?", organization.Id).Error; err != nil {
+			return err
+		}
+		if locked.Quota > 0 {
+			if err := tx.Model(&Organization{}).Where("id = ?", organization.Id).Update("quota", 0).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&User{}).Where("id = ?", organization.OwnerId).Update("quota", gorm.Expr("quota + ?", locked.Quota)).Error; err != nil {
+				return err
+			}
+			refunded = locked.Quota
+		}
+		if err := tx.Where("organization_id = ?", organization.Id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&OrganizationInvitation{}).Where("organization_id = ? AND status = ?", organization.Id, OrganizationInvitationPending).
+			Update("status", OrganizationInvitationRevoked).Error; err != nil {
+			return err
+		}
+		return tx.Delete(organization).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	if refunded > 0 {
+		refreshUserQuotaCache(organization.OwnerId)
+	}
+	return refunded, nil
+}
+
+// UpdateOrganizationMember 修改成员的角色和额度上限，所有者不能被修改，只有所有者可以修改管理员
+func UpdateOrganizationMember(operator *OrganizationMember, userId int, role string, quotaLimit int, resetUsed bool) (*OrganizationMember, error) {
+	if quotaLimit < 0 {
+		return nil, errors.New("额度上限不能为负数")
+	}
+	member, err := GetOrganizationMember(operator.OrganizationId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		role = member.Role
+	}
+	if member.Role == OrganizationRoleOwner && role != OrganizationRoleOwner {
+		return nil, errors.New("不能修改所有者的角色")
+	}
+	if member.Role != OrganizationRoleOwner {
+		if err := validateOrganizationRole(role, false); err != nil {
+			return nil, err
+		}
+	}
+	if operator.Role != OrganizationRoleOwner && (member.Role != OrganizationRoleMember || role != OrganizationRoleMember) {
+		return nil, ErrOrganizationPermissionDenied
+	}
+
+	updates := map[string]any{"role": role, "quota_limit": quotaLimit}
+	if resetUsed {
+		updates["used_quota"] = 0
+	}
+	if err := DB.Model(member).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return GetOrganizationMember(operator.OrganizationId, userId)
+}
+
+// RemoveOrganizationMember 移除成员，所有者不能被移除，管理员只能移除普通成员
+func RemoveOrganizationMember(operator *OrganizationMember, userId int) error {
+	member, err := GetOrganizationMember(operator.OrganizationId, userId)
+	if err != nil {
+		return err
+	}
+	if member.Role == OrganizationRoleOwner {
+		return errors.New("不能移除组织所有者")
+	}
+	if operator.UserId != userId && operator.Role != OrganizationRoleOwner && member.Role != OrganizationRoleMember {
+		return ErrOrganizationPermissionDenied
+	}
+	return DB.Delete(member).Error
+}
+
+// TransferQuotaToOrganization 成员将个人额度转入组织钱包
+func TransferQuotaToOrganization(organizationId, userId, quota int) error {
+	if quota <= 0 {
+		return errors.New("转入额度必须大于 0")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("个人额度不足")
+		}
+		return tx.Model(&Organization{}).Where("id = ?", organizationId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+	refreshUserQuotaCache(userId)
+	return nil
+}
+
+// AdjustOrganizationQuota 管理员调整组织额度，quota 可以为负数
+func AdjustOrganizationQuota(organizationId, quota int) error {
+	result := DB.Model(&Organization{}).Where("id = ? AND quota + ? >= 0", organizationId, quota).Update("quota", gorm.Expr("quota + ?", quota))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrganizationQuotaNotEnough
+	}
+	return nil
+}
+
+func refreshUserQuotaCache(userId int) {
+	if err := CacheUpdateUserQuota(userId); err != nil {
+		logger.SysError("refresh user quota cache failed: " + err.Error())
+	}
+}
+
+func CreateOrganizationInvitation(organizationId, inviterId int, email, role string) (*OrganizationInvitation, error) {
+	if role == "" {
+		role = OrganizationRoleMember
+	}
+	if err := validateOrganizationRole(role, false); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invitation := &OrganizationInvitation{
+		OrganizationId: organizationId,
+		Code:           utils.GetUUID(),
+		Email:          strings.TrimSpace(email),
+		Role:           role,
+		InviterId:      inviterId,
+		Status:         OrganizationInvitationPending,
+		ExpiresAt:      now.AddDate(0, 0, OrganizationInvitationValidDays).Unix(),
+		CreatedTime:    now.Unix(),
+	}
+	return invitation, DB.Create(invitation).Error
+}
+
+func GetOrganizationInvitations(organizationId int) ([]*OrganizationInvitation, error) {
+	var invitations []*OrganizationInvitation
+	err := DB.Where("organization_id = ?", organizationId).Order("id desc").Find(&invitations).Error
+	return invitations, err
+}
+
+func RevokeOrganizationInvitation(organizationId, invitationId int) error {
+	result := DB.Model(&OrganizationInvitation{}).
+		Where("id = ? AND organization_id = ? AND status = ?", invitationId, organizationId, OrganizationInvitationPending).
+		Update("status", OrganizationInvitationRevoked)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("邀请不存在或已失效")
+	}
+	return nil
+}
+
+// AcceptOrganizationInvitation 通过邀请码加入组织，指定了邮箱的邀请只能由该邮箱的用户接受
+func AcceptOrganizationInvitation(code string, user *User) (*OrganizationMember, error) {
+	var invitation OrganizationInvitation
+	if err := DB.Where("code = ?", code).First(&invitation).Error; err != nil {
+		return nil, errors.New("邀请不存在或已失效")
+	}
+	if invitation.Status != OrganizationInvitationPending || invitation.ExpiresAt < utils.GetTimestamp() {
+		return nil, errors.New("邀请不存在或已失效")
+	}
+	if invitation.Email != "" && !strings.EqualFold(invitation.Email, user.Email) {
+		return nil, errors.New("该邀请不是发送给你的")
+	}
+	if _, err := GetOrganizationById(invitation.OrganizationId); err != nil {
+		return nil, errors.New("组织不存在")
+	}
+	if _, err := GetOrganizationMember(invitation.OrganizationId, user.Id); err == nil {
+		return nil, errors.New("你已经是该组织的成员")
+	}
+
+	member := &OrganizationMember{
+		OrganizationId: invitation.OrganizationId,
+		UserId:         user.Id,
+		Role:           invitation.Role,
+		CreatedTime:    utils.GetTimestamp(),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&invitation).Where("status = ?", OrganizationInvitationPending).Updates(map[string]any{
+			"status":           OrganizationInvitationAccepted,
+			"accepted_user_id": user.Id,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("邀请不存在或已失效")
+		}
+		return tx.Create(member).Error
+	})
+	return member, err
+}
+
+// PreConsumeOrganizationQuota 从组织钱包预扣额度，同时检查组织余额、成员额度上限和令牌额度
+func PreConsumeOrganizationQuota(organizationId, userId, tokenId int, unlimitedQuota bool, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Organization{}).Where("id = ? AND quota >= ?", organizationId, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationQuotaNotEnough
+		}
+
+		result = tx.Model(&OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ? AND (quota_limit = 0 OR used_quota + ? <= quota_limit)", organizationId, userId, quota).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationMemberQuotaExhausted
+		}
+
+		if unlimitedQuota {
+			return nil
+		}
+		result = tx.Model(&Token{}).Where("id = ? AND remain_quota >= ?", tokenId, quota).Updates(map[string]any{
+			"remain_quota":  gorm.Expr("remain_quota - ?", quota),
+			"used_quota":    gorm.Expr("used_quota + ?", quota),
+			"accessed_time": utils.GetTimestamp(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("令牌额度不足")
+		}
+		return nil
+	})
+}
+
+// ApplyOrganizationQuotaDelta 结算或退还组织钱包的额度，quota 为负数时退还。
+// 组织已删除时改为结算所有者的个人额度，避免差额丢失
+func ApplyOrganizationQuotaDelta(organizationId, userId, tokenId int, unlimitedQuota bool, quota int) error {
+	if quota == 0 {
+		return nil
+	}
+	ownerId := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Organization{}).Where("id = ?", organizationId).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// 组织已删除，剩余额度已退回所有者，之后的结算差额也由所有者的个人额度承担
+			organization := &Organization{}
+			if err := tx.Unscoped().Select("id", "owner_id").First(organization, "id = ?", organizationId).Error; err != nil {
+				return err
+			}
+			ownerId = organization.OwnerId
+			if err := tx.Model(&User{}).Where("id = ?", ownerId).Update("quota", gorm.Expr("quota - ?", quota)).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", organizationId, userId).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error; err != nil {
+			return err
+		}
+		if unlimitedQuota {
+			return nil
+		}
+		return tx.Model(&Token{}).Where("id = ?", tokenId).Updates(map[string]any{
+			"remain_quota":  gorm.Expr("remain_quota - ?", quota),
+			"used_quota":    gorm.Expr("used_quota + ?", quota),
+			"accessed_time": utils.GetTimestamp(),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if ownerId > 0 {
+		refreshUserQuotaCache(ownerId)
+		logger.SysLog(fmt.Sprintf("organization #%d is deleted, quota delta %d of user #%d applied to owner #%d", organizationId, quota, userId, ownerId))
+	}
+	return nil
+}
+
+// RecordOrganizationUsage 结算后累加组织的用量
+func RecordOrganizationUsage(organizationId, userId int, modelName string, quota, promptTokens, completionTokens int, now time.Time) error {
+	usage := &OrganizationUsage{
+		OrganizationId:   organizationId,
+		Date:             now.Format("2006-01-02"),
+		UserId:           userId,
+		ModelName:        modelName,
+		RequestCount:     1,
+		Quota:            quota,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+	}
+	err := DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "organization_id"}, {Name: "date"}, {Name: "user_id"}, {Name: "model_name"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"request_count":     gorm.Expr("organization_usages.request_count + ?", 1),
+			"quota":             gorm.Expr("organization_usages.quota + ?", quota),
+			"prompt_tokens":     gorm.Expr("organization_usages.prompt_tokens + ?", promptTokens),
+			"completion_tokens": gorm.Expr("organization_usages.completion_tokens + ?", completionTokens),
+		}),
+	}).Create(usage).Error
+	if err != nil {
+		return err
+	}
+	return DB.Model(&Organization{}).Where("id = ?", organizationId).Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+}
+
+// OrganizationUsageSummary 组织用量的汇总行，按查询的维度填充 Date、UserId 或 ModelName
+type OrganizationUsageSummary struct {
+	Date             string `json:"date,omitempty"`
+	UserId           int    `json:"user_id,omitempty"`
+	ModelName        string `json:"model_name,omitempty"`
+	RequestCount     int    `json:"request_count"`
+	Quota            int    `json:"quota"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+var organizationUsageGroupColumns = map[string]string{
+	"date":   "date",
+	"member": "user_id",
+	"model":  "model_name",
+}
+
+// GetOrganizationUsage 按 groupBy（date、member、model）汇总 [startDate, endDate] 内的用量，日期格式为 2006-01-02
+func GetOrganizationUsage(organizationId int, startDate, endDate, groupBy string) ([]*OrganizationUsageSummary, error) {
+	column, ok := organizationUsageGroupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("不支持的统计维度: %s", groupBy)
+	}
+
+	var summaries []*OrganizationUsageSummary
+	err := DB.Model(&OrganizationUsage{}).
+		Select(column+", SUM(request_count) AS request_count, SUM(quota) AS quota, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens").
+		Where("organization_id = ? AND date >= ? AND date <= ?", organizationId, startDate, endDate).
+		Group(column).
+		Order(column).
+		Scan(&summaries).Error
+	return summaries, err
+}
+
+// OrganizationInvoice 组织的月度账单
+type OrganizationInvoice struct {
+	OrganizationId int                         `json:"organization_id"`
+	Name           string                      `json:"name"`
+	Month          string                      `json:"month"`
+	Quota          int                         `json:"quota"`
+	RequestCount   int                         `json:"request_count"`
+	Models         []*OrganizationUsageSummary `json:"models"`
+	Members        []*OrganizationUsageSummary `json:"members"`
+}
+
+// GetOrganizationInvoice month 格式为 2006-01
+func GetOrganizationInvoice(organization *Organization, month string) (*OrganizationInvoice, error) {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return nil, errors.New("月份格式错误")
+	}
+	startDate := start.Format("2006-01-02")
+	endDate := start.AddDate(0, 1, -1).Format("2006-01-02")
+
+	invoice := &OrganizationInvoice{OrganizationId: organization.Id, Name: organization.Name, Month: month}
+	if invoice.Models, err = GetOrganizationUsage(organization.Id, startDate, endDate, "model"); err != nil {
+		return nil, err
+	}
+	if invoice.Members, err = GetOrganizationUsage(organization.Id, startDate, endDate, "member"); err != nil {
+		return nil, err
+	}
+	for _, item := range invoice.Models {
+		invoice.Quota += item.Quota
+		invoice.RequestCount += item.RequestCount
+	}
+	return invoice, nil
+}
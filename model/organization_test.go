@@ -0,0 +1,208 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useOrganizationTestDB(t *testing.T) *Organization {
+	t.Helper()
+
+	logger.Logger = zap.NewNop()
+	originalRedisEnabled := config.RedisEnabled
+	config.RedisEnabled = false
+
+	originalDB := DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&User{}, &Organization{}, &OrganizationMember{}, &OrganizationInvitation{}, &OrganizationUsage{}); err != nil {
+		t.Fatalf("expected organization schema migration to succeed, got %v", err)
+	}
+	DB = testDB
+	t.Cleanup(func() {
+		DB = originalDB
+		config.RedisEnabled = originalRedisEnabled
+	})
+
+	users := []User{
+		{Id: 1, Username: "owner", Password: "password123", AccessToken: "org-owner", AffCode: "o1", Email: "owner@example.com", Quota: 1000, Status: config.UserStatusEnabled},
+		{Id: 2, Username: "bob", Password: "password123", AccessToken: "org-bob", AffCode: "o2", Email: "bob@example.com", Quota: 0, Status: config.UserStatusEnabled},
+	}
+	if err := DB.Create(&users).Error; err != nil {
+		t.Fatalf("expected user fixtures, got %v", err)
+	}
+	organization, err := CreateOrganization("acme", 1)
+	if err != nil {
+		t.Fatalf("expected organization to be created, got %v", err)
+	}
+	return organization
+}
+
+func joinOrganization(t *testing.T, organization *Organization, user *User, email string) *OrganizationMember {
+	t.Helper()
+
+	invitation, err := CreateOrganizationInvitation(organization.Id, organization.OwnerId, email, OrganizationRoleMember)
+	if err != nil {
+		t.Fatalf("expected invitation to be created, got %v", err)
+	}
+	member, err := AcceptOrganizationInvitation(invitation.Code, user)
+	if err != nil {
+		t.Fatalf("expected invitation to be accepted, got %v", err)
+	}
+	return member
+}
+
+func TestOrganizationInvitationRestrictsEmail(t *testing.T) {
+	organization := useOrganizationTestDB(t)
+
+	invitation, err := CreateOrganizationInvitation(organization.Id, 1, "carol@example.com", "")
+	if err != nil {
+		t.Fatalf("expected invitation to be created, got %v", err)
+	}
+	if _, err := AcceptOrganizationInvitation(invitation.Code, &User{Id: 2, Email: "bob@example.com"}); err == nil {
+		t.Fatal("expected invitation for another email to be rejected")
+	}
+
+	member := joinOrganization(t, organization, &User{Id: 2, Email: "bob@example.com"}, "BOB@example.com")
+	if member.Role != OrganizationRoleMember {
+		t.Fatalf("expected member role, got %q", member.Role)
+	}
+	if _, err := GetOrganizationMember(organization.Id, 2); err != nil {
+		t.Fatalf("expected bob to be a member, got %v", err)
+	}
+
+	owner, _ := GetOrganizationMember(organization.Id, 1)
+	if _, err := UpdateOrganizationMember(member, 1, OrganizationRoleMember, 0, false); err == nil {
+		t.Fatal("expected member to be unable to change the owner")
+	}
+	if err := RemoveOrganizationMember(owner, 1); err == nil {
+		t.Fatal("expected owner removal to be rejected")
+	}
+}
+
+func TestOrganizationQuotaPreConsumeAndSettle(t *testing.T) {
+	organization := useOrganizationTestDB(t)
+	joinOrganization(t, organization, &User{Id: 2, Email: "bob@example.com"}, "")
+
+	if err := TransferQuotaToOrganization(organization.Id, 1, 600); err != nil {
+		t.Fatalf("expected transfer to succeed, got %v", err)
+	}
+	if err := TransferQuotaToOrganization(organization.Id, 1, 600); err == nil {
+		t.Fatal("expected transfer beyond personal quota to fail")
+	}
+
+	owner, _ := GetOrganizationMember(organization.Id, 1)
+	if _, err := UpdateOrganizationMember(owner, 2, OrganizationRoleMember, 150, false); err != nil {
+		t.Fatalf("expected member limit to be set, got %v", err)
+	}
+
+	if err := PreConsumeOrganizationQuota(organization.Id, 2, 0, true, 100); err != nil {
+		t.Fatalf("expected pre-consume within limit, got %v", err)
+	}
+	if err := PreConsumeOrganizationQuota(organization.Id, 2, 0, true, 100); !errors.Is(err, ErrOrganizationMemberQuotaExhausted) {
+		t.Fatalf("expected member limit error, got %v", err)
+	}
+	// 结算时实际消费少于预扣，退还差额
+	if err := ApplyOrganizationQuotaDelta(organization.Id, 2, 0, true, -40); err != nil {
+		t.Fatalf("expected settlement delta to apply, got %v", err)
+	}
+
+	organization, _ = GetOrganizationById(organization.Id)
+	if organization.Quota != 540 {
+		t.Fatalf("expected organization quota 540, got %d", organization.Quota)
+	}
+	member, _ := GetOrganizationMember(organization.Id, 2)
+	if member.UsedQuota != 60 {
+		t.Fatalf("expected member used quota 60, got %d", member.UsedQuota)
+	}
+	user, _ := GetUserById(2, true)
+	if user.Quota != 0 {
+		t.Fatalf("expected personal quota to stay untouched, got %d", user.Quota)
+	}
+
+	if err := PreConsumeOrganizationQuota(organization.Id, 1, 0, true, 1000); !errors.Is(err, ErrOrganizationQuotaNotEnough) {
+		t.Fatalf("expected organization quota error, got %v", err)
+	}
+}
+
+func TestOrganizationUsageAndInvoice(t *testing.T) {
+	organization := useOrganizationTestDB(t)
+	now := time.Date(2026, 3, 15, 10, 0, 0, 0, time.Local)
+
+	records := []struct {
+		userId int
+		model  string
+		quota  int
+		at     time.Time
+	}{
+		{1, "gpt-4o", 100, now},
+		{1, "gpt-4o", 50, now},
+		{2, "claude-3", 30, now.AddDate(0, 0, 1)},
+		{2, "gpt-4o", 20, now.AddDate(0, 1, 0)},
+	}
+	for _, record := range records {
+		if err := RecordOrganizationUsage(organization.Id, record.userId, record.model, record.quota, 10, 5, record.at); err != nil {
+			t.Fatalf("expected usage to be recorded, got %v", err)
+		}
+	}
+
+	byDate, err := GetOrganizationUsage(organization.Id, "2026-03-01", "2026-03-31", "date")
+	if err != nil {
+		t.Fatalf("expected usage summary, got %v", err)
+	}
+	if len(byDate) != 2 || byDate[0].Quota != 150 || byDate[0].RequestCount != 2 || byDate[0].PromptTokens != 20 {
+		t.Fatalf("unexpected daily summary: %+v", byDate)
+	}
+	if _, err := GetOrganizationUsage(organization.Id, "2026-03-01", "2026-03-31", "token"); err == nil {
+		t.Fatal("expected unsupported group_by to be rejected")
+	}
+
+	invoice, err := GetOrganizationInvoice(organization, "2026-03")
+	if err != nil {
+		t.Fatalf("expected invoice, got %v", err)
+	}
+	if invoice.Quota != 180 || invoice.RequestCount != 3 || len(invoice.Models) != 2 || len(invoice.Members) != 2 {
+		t.Fatalf("unexpected invoice: %+v", invoice)
+	}
+
+	organization, _ = GetOrganizationById(organization.Id)
+	if organization.UsedQuota != 200 {
+		t.Fatalf("expected organization used quota 200, got %d", organization.UsedQuota)
+	}
+}
+
+func TestDeleteOrganizationRefundsCurrentQuota(t *testing.T) {
+	organization := useOrganizationTestDB(t)
+	if err := TransferQuotaToOrganization(organization.Id, 1, 600); err != nil {
+		t.Fatalf("expected transfer to succeed, got %v", err)
+	}
+	// organization 中的额度已过期，删除时应退回当前的额度
+	if err := PreConsumeOrganizationQuota(organization.Id, 1, 0, true, 100); err != nil {
+		t.Fatalf("expected pre-consume to succeed, got %v", err)
+	}
+
+	refunded, err := DeleteOrganization(organization)
+	if err != nil || refunded != 500 {
+		t.Fatalf("expected 500 to be refunded, got %d, %v", refunded, err)
+	}
+	user, _ := GetUserById(1, true)
+	if user.Quota != 900 {
+		t.Fatalf("expected owner quota 900, got %d", user.Quota)
+	}
+	var deleted Organization
+	DB.Unscoped().First(&deleted, organization.Id)
+	if deleted.Quota != 0 {
+		t.Fatalf("expected deleted organization quota to be zeroed, got %d", deleted.Quota)
+	}
+}
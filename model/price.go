@@ -62,7 +62,14 @@ type Price struct {
 	Locked      bool    `json:"locked" gorm:"default:false"` // 如果模型为locked 则覆盖模式不会更新locked的模型价格
 
 	ExtraRatios *datatypes.JSONType[map[string]float64] `json:"extra_ratios,omitempty" gorm:"type:json"`
+	Tiers       *datatypes.JSONSlice[PriceTier]         `json:"tiers,omitempty" gorm:"type:json"`
 	ModelInfo   *ModelInfoResponse                      `json:"model_info,omitempty" gorm:"-"`
+	VersionId   int64                                   `json:"version_id,omitempty" gorm:"-"` // 当前生效的价格版本
+
+	// 当前时段的价格调整，只在公开价格列表中填充
+	TimeModifier    *PriceTimeModifier `json:"time_modifier,omitempty" gorm:"-"`
+	EffectiveInput  *float64           `json:"effective_input,omitempty" gorm:"-"`
+	EffectiveOutput *float64           `json:"effective_output,omitempty" gorm:"-"`
 }
 
 func GetAllPrices() ([]*Price, error) {
@@ -155,6 +162,7 @@ func UpdatePrices(tx *gorm.DB, models []string, prices *Price) error {
 			Output:      prices.Output,
 			Locked:      prices.Locked,
 			ExtraRatios: prices.ExtraRatios,
+			Tiers:       prices.Tiers,
 		}).Error
 
 	return err
@@ -266,6 +274,8 @@ func GetDefaultPrice() []*Price {
 		"claude-3-sonnet-20240229": {[]float64{1.3, 3.9}, config.ChannelTypeAnthropic},
 		//  $0.25 / M $1.25 / M  0.00025$ / 1k tokens 0.00125$ / 1k tokens
 		"claude-3-haiku-20240307": {[]float64{0.125, 0.625}, config.ChannelTypeAnthropic},
+		//  $3 / M $15 / M，超过 200k tokens 见 defaultPriceTiers
+		"claude-sonnet-4-20250514": {[]float64{1.5, 7.5}, config.ChannelTypeAnthropic},
 
 		// ￥0.004 / 1k tokens ￥0.008 / 1k tokens
 		"ERNIE-Speed": {[]float64{0.2857, 0.5714}, config.ChannelTypeBaidu},
@@ -296,6 +306,8 @@ func GetDefaultPrice() []*Price {
 		"gemini-1.5-flash":        {[]float64{0.175, 0.265}, config.ChannelTypeGemini},
 		"gemini-1.5-flash-latest": {[]float64{0.175, 0.265}, config.ChannelTypeGemini},
 		"gemini-ultra":            {[]float64{1, 1}, config.ChannelTypeGemini},
+		// $1.25 / 1 million tokens  $10 / 1 million tokens，超过 200k tokens 见 defaultPriceTiers
+		"gemini-2.5-pro": {[]float64{0.625, 5}, config.ChannelTypeGemini},
 
 		// ￥0.005 / 1k tokens
 		"glm-3-turbo": {[]float64{0.3572, 0.3572}, config.ChannelTypeZhipu},
@@ -412,14 +424,20 @@ func GetDefaultPrice() []*Price {
 
 	var prices []*Price
 
+	priceTiers := defaultPriceTiers()
 	for model, modelType := range ModelTypes {
-		prices = append(prices, &Price{
+		price := &Price{
 			Model:       model,
 			Type:        TokensPriceType,
 			ChannelType: modelType.Type,
 			Input:       modelType.Ratio[0],
 			Output:      modelType.Ratio[1],
-		})
+		}
+		if tiers, ok := priceTiers[model]; ok {
+			priceTier := datatypes.NewJSONSlice(tiers)
+			price.Tiers = &priceTier
+		}
+		prices = append(prices, price)
 	}
 
 	var DefaultMJPrice = map[string]float64{
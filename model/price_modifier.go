@@ -0,0 +1,149 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+)
+
+// PriceTimeModifier 按时间段调整模型价格，例如 DeepSeek 的夜间优惠或自定义的促销时段。
+// Models 为空时对所有模型生效，支持以 * 结尾的前缀匹配；Multiplier 作用于输入和输出价格。
+type PriceTimeModifier struct {
+	Name       string   `json:"name"`
+	Models     []string `json:"models,omitempty"`
+	Weekdays   []int    `json:"weekdays,omitempty"` // 0 表示周日，为空表示每天
+	Start      string   `json:"start"`              // HH:MM，按 PriceTimezone 计算
+	End        string   `json:"end"`                // HH:MM，小于 Start 表示跨天
+	Multiplier float64  `json:"multiplier"`
+}
+
+func (modifier *PriceTimeModifier) Validate() error {
+	if modifier.Multiplier < 0 {
+		return errors.New("multiplier must not be negative")
+	}
+	return validateClockWindow(modifier.Weekdays, modifier.Start, modifier.End)
+}
+
+func (modifier *PriceTimeModifier) global() bool {
+	return len(modifier.Models) == 0
+}
+
+func (modifier *PriceTimeModifier) matchModel(modelName string) bool {
+	if modifier.global() {
+		return true
+	}
+	for _, pattern := range modifier.Models {
+		if pattern == modelName {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(modelName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	priceTimeModifiers     = make([]PriceTimeModifier, 0)
+	priceTimeModifiersLock sync.RWMutex
+)
+
+func ParsePriceTimeModifiers(value string) ([]PriceTimeModifier, error) {
+	modifiers := make([]PriceTimeModifier, 0)
+	if strings.TrimSpace(value) == "" {
+		return modifiers, nil
+	}
+	if err := json.Unmarshal([]byte(value), &modifiers); err != nil {
+		return nil, err
+	}
+	for i := range modifiers {
+		if err := modifiers[i].Validate(); err != nil {
+			return nil, fmt.Errorf("price modifier %q: %w", modifiers[i].Name, err)
+		}
+	}
+	return modifiers, nil
+}
+
+func PriceTimeModifiersToJSONString() string {
+	priceTimeModifiersLock.RLock()
+	defer priceTimeModifiersLock.RUnlock()
+
+	jsonBytes, err := json.Marshal(priceTimeModifiers)
+	if err != nil {
+		logger.SysError("error marshalling price time modifiers: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdatePriceTimeModifiersByJSONString(value string) error {
+	modifiers, err := ParsePriceTimeModifiers(value)
+	if err != nil {
+		return err
+	}
+
+	priceTimeModifiersLock.Lock()
+	priceTimeModifiers = modifiers
+	priceTimeModifiersLock.Unlock()
+	return nil
+}
+
+func priceLocation() *time.Location {
+	if config.PriceTimezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(config.PriceTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// GetPriceTimeModifier 返回模型在 now 时刻生效的价格调整，指定模型的规则优先于全局规则
+func GetPriceTimeModifier(modelName string, now time.Time) *PriceTimeModifier {
+	priceTimeModifiersLock.RLock()
+	defer priceTimeModifiersLock.RUnlock()
+
+	if len(priceTimeModifiers) == 0 {
+		return nil
+	}
+
+	now = now.In(priceLocation())
+	var matched *PriceTimeModifier
+	for i := range priceTimeModifiers {
+		modifier := &priceTimeModifiers[i]
+		if !modifier.matchModel(modelName) || !clockWindowActive(now, modifier.Weekdays, modifier.Start, modifier.End) {
+			continue
+		}
+		if !modifier.global() {
+			copied := *modifier
+			return &copied
+		}
+		if matched == nil {
+			copied := *modifier
+			matched = &copied
+		}
+	}
+	return matched
+}
+
+// WithTimeModifier 返回附带当前时段实际价格的副本，用于公开的价格列表
+func (price *Price) WithTimeModifier(now time.Time) *Price {
+	modifier := GetPriceTimeModifier(price.Model, now)
+	if modifier == nil {
+		return price
+	}
+
+	cloned := *price
+	cloned.TimeModifier = modifier
+	effectiveInput := price.GetInput() * modifier.Multiplier
+	effectiveOutput := price.GetOutput() * modifier.Multiplier
+	cloned.EffectiveInput = &effectiveInput
+	cloned.EffectiveOutput = &effectiveOutput
+	return &cloned
+}
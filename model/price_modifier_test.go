@@ -0,0 +1,49 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"one-api/common/config"
+)
+
+func TestGetPriceTimeModifierPrefersModelRules(t *testing.T) {
+	originalTimezone := config.PriceTimezone
+	originalModifiers := PriceTimeModifiersToJSONString()
+	t.Cleanup(func() {
+		config.PriceTimezone = originalTimezone
+		_ = UpdatePriceTimeModifiersByJSONString(originalModifiers)
+	})
+
+	config.PriceTimezone = "Asia/Shanghai"
+	err := UpdatePriceTimeModifiersByJSONString(`[
+		{"name":"weekend","weekdays":[0,6],"multiplier":0.8},
+		{"name":"deepseek-night","models":["deepseek-*"],"start":"00:30","end":"08:30","multiplier":0.5}
+	]`)
+	if err != nil {
+		t.Fatalf("expected modifiers to parse, got %v", err)
+	}
+
+	// 2025-06-07 是周六，UTC 17:00 为北京时间次日（周日）01:00
+	night := time.Date(2025, 6, 7, 17, 0, 0, 0, time.UTC)
+	if modifier := GetPriceTimeModifier("deepseek-chat", night); modifier == nil || modifier.Name != "deepseek-night" {
+		t.Fatalf("expected model rule to win over global rule, got %+v", modifier)
+	}
+	if modifier := GetPriceTimeModifier("gpt-4o", night); modifier == nil || modifier.Name != "weekend" {
+		t.Fatalf("expected global rule for other models, got %+v", modifier)
+	}
+
+	weekdayNoon := time.Date(2025, 6, 10, 4, 0, 0, 0, time.UTC)
+	if modifier := GetPriceTimeModifier("deepseek-chat", weekdayNoon); modifier != nil {
+		t.Fatalf("expected no modifier outside the windows, got %+v", modifier)
+	}
+
+	price := (&Price{Model: "deepseek-chat", Type: TokensPriceType, Input: 1, Output: 2}).WithTimeModifier(night)
+	if price.EffectiveInput == nil || *price.EffectiveInput != 0.5 || *price.EffectiveOutput != 1 {
+		t.Fatalf("expected effective price to be discounted, got %+v", price)
+	}
+
+	if err := UpdatePriceTimeModifiersByJSONString(`[{"name":"bad","start":"25:00","multiplier":1}]`); err == nil {
+		t.Fatal("expected invalid window to be rejected")
+	}
+}
@@ -0,0 +1,247 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"one-api/common/utils"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	PriceOverrideTypePrice = "price" // 使用固定的输入、输出价格
+	PriceOverrideTypeRatio = "ratio" // 在模型价格基础上乘以倍率
+)
+
+// PriceOverride 针对分组或用户的模型价格覆盖，用户覆盖优先于分组覆盖。
+// Model 支持以 * 结尾的前缀匹配，覆盖后的价格仍会再乘以分组倍率。
+type PriceOverride struct {
+	Id        int     `json:"id"`
+	Group     string  `json:"group" gorm:"type:varchar(50);default:'';index"`
+	UserId    int     `json:"user_id" gorm:"default:0;index"`
+	Model     string  `json:"model" gorm:"type:varchar(100)" binding:"required"`
+	Type      string  `json:"type" gorm:"type:varchar(16);default:'ratio'"`
+	Input     float64 `json:"input" gorm:"default:0"`
+	Output    float64 `json:"output" gorm:"default:0"`
+	Ratio     float64 `json:"ratio" gorm:"default:1"`
+	Remark    string  `json:"remark" gorm:"type:varchar(255);default:''"`
+	CreatedAt int64   `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64   `json:"updated_at" gorm:"bigint"`
+}
+
+func (override *PriceOverride) Validate() error {
+	if (override.Group == "") == (override.UserId == 0) {
+		return errors.New("exactly one of group and user_id is required")
+	}
+	if strings.TrimSpace(override.Model) == "" {
+		return errors.New("model is required")
+	}
+	switch override.Type {
+	case PriceOverrideTypePrice:
+		if override.Input < 0 || override.Output < 0 {
+			return errors.New("price must not be negative")
+		}
+	case PriceOverrideTypeRatio:
+		if override.Ratio < 0 {
+			return errors.New("ratio must not be negative")
+		}
+	default:
+		return errors.New("unsupported override type")
+	}
+	return nil
+}
+
+// Apply 返回覆盖后的价格副本，不修改缓存中的价格
+func (override *PriceOverride) Apply(price *Price) *Price {
+	cloned := *price
+	switch override.Type {
+	case PriceOverrideTypePrice:
+		// 协议价为固定价格，不再按提示词长度分段
+		cloned.Input = override.Input
+		cloned.Output = override.Output
+		cloned.Tiers = nil
+	case PriceOverrideTypeRatio:
+		cloned.Input = price.Input * override.Ratio
+		cloned.Output = price.Output * override.Ratio
+		if tiers := price.GetTiers(); len(tiers) > 0 {
+			scaled := make([]PriceTier, len(tiers))
+			for i, tier := range tiers {
+				scaled[i] = PriceTier{
+					PromptTokensAbove: tier.PromptTokensAbove,
+					Input:             tier.Input * override.Ratio,
+					Output:            tier.Output * override.Ratio,
+				}
+			}
+			scaledTiers := datatypes.NewJSONSlice(scaled)
+			cloned.Tiers = &scaledTiers
+		}
+	}
+	return &cloned
+}
+
+type SearchPriceOverrideParams struct {
+	Group  string `form:"group"`
+	UserId int    `form:"user_id"`
+	Model  string `form:"model"`
+	PaginationParams
+}
+
+var allowedPriceOverrideOrderFields = map[string]bool{
+	"id":      true,
+	"group":   true,
+	"user_id": true,
+	"model":   true,
+}
+
+func GetPriceOverridesList(params *SearchPriceOverrideParams) (*DataResult[PriceOverride], error) {
+	var overrides []*PriceOverride
+	db := DB
+	if params.Group != "" {
+		db = db.Where(quotePostgresField("group")+" = ?", params.Group)
+	}
+	if params.UserId > 0 {
+		db = db.Where("user_id = ?", params.UserId)
+	}
+	if params.Model != "" {
+		db = db.Where("model LIKE ?", params.Model+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &overrides, allowedPriceOverrideOrderFields)
+}
+
+func GetPriceOverrideById(id int) (*PriceOverride, error) {
+	var override PriceOverride
+	err := DB.First(&override, "id = ?", id).Error
+	return &override, err
+}
+
+func (override *PriceOverride) Insert() error {
+	if err := override.Validate(); err != nil {
+		return err
+	}
+	override.CreatedAt = utils.GetTimestamp()
+	override.UpdatedAt = override.CreatedAt
+	if err := DB.Create(override).Error; err != nil {
+		return err
+	}
+	GlobalPriceOverrides.Load()
+	return nil
+}
+
+func (override *PriceOverride) Update() error {
+	if err := override.Validate(); err != nil {
+		return err
+	}
+	override.UpdatedAt = utils.GetTimestamp()
+	err := DB.Model(override).Select("group", "user_id", "model", "type", "input", "output", "ratio", "remark", "updated_at").Updates(override).Error
+	if err != nil {
+		return err
+	}
+	GlobalPriceOverrides.Load()
+	return nil
+}
+
+func (override *PriceOverride) Delete() error {
+	if err := DB.Delete(override).Error; err != nil {
+		return err
+	}
+	GlobalPriceOverrides.Load()
+	return nil
+}
+
+// priceOverrideSet 同一个分组或用户的覆盖规则，精确匹配优先于通配符
+type priceOverrideSet struct {
+	exact    map[string]*PriceOverride
+	wildcard []*PriceOverride
+}
+
+func (set *priceOverrideSet) add(override *PriceOverride) {
+	if strings.HasSuffix(override.Model, "*") {
+		set.wildcard = append(set.wildcard, override)
+		return
+	}
+	set.exact[override.Model] = override
+}
+
+func (set *priceOverrideSet) match(modelName string) *PriceOverride {
+	if set == nil {
+		return nil
+	}
+	if override, ok := set.exact[modelName]; ok {
+		return override
+	}
+	// 多个通配符命中时取前缀最长的
+	var matched *PriceOverride
+	for _, override := range set.wildcard {
+		prefix := strings.TrimSuffix(override.Model, "*")
+		if strings.HasPrefix(modelName, prefix) && (matched == nil || len(override.Model) > len(matched.Model)) {
+			matched = override
+		}
+	}
+	return matched
+}
+
+type PriceOverrides struct {
+	sync.RWMutex
+	groups map[string]*priceOverrideSet
+	users  map[int]*priceOverrideSet
+}
+
+var GlobalPriceOverrides = PriceOverrides{}
+
+func (po *PriceOverrides) Load() {
+	var overrides []*PriceOverride
+	if err := DB.Find(&overrides).Error; err != nil {
+		return
+	}
+
+	groups := make(map[string]*priceOverrideSet)
+	users := make(map[int]*priceOverrideSet)
+	for _, override := range overrides {
+		var set *priceOverrideSet
+		if override.UserId > 0 {
+			if set = users[override.UserId]; set == nil {
+				set = &priceOverrideSet{exact: make(map[string]*PriceOverride)}
+				users[override.UserId] = set
+			}
+		} else {
+			if set = groups[override.Group]; set == nil {
+				set = &priceOverrideSet{exact: make(map[string]*PriceOverride)}
+				groups[override.Group] = set
+			}
+		}
+		set.add(override)
+	}
+
+	po.Lock()
+	defer po.Unlock()
+	po.groups = groups
+	po.users = users
+}
+
+// Get 返回用户或分组对模型生效的覆盖规则，用户覆盖优先
+func (po *PriceOverrides) Get(modelName string, userId int, group string) *PriceOverride {
+	po.RLock()
+	defer po.RUnlock()
+
+	if userId > 0 {
+		if override := po.users[userId].match(modelName); override != nil {
+			return override
+		}
+	}
+	if group != "" {
+		return po.groups[group].match(modelName)
+	}
+	return nil
+}
+
+// ResolvePrice 返回应用了用户或分组覆盖后的价格
+func (po *PriceOverrides) ResolvePrice(price *Price, modelName string, userId int, group string) (*Price, *PriceOverride) {
+	override := po.Get(modelName, userId, group)
+	if override == nil {
+		return price, nil
+	}
+	return override.Apply(price), override
+}
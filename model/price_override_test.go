@@ -0,0 +1,95 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useTestPriceOverrideDB(t *testing.T) {
+	t.Helper()
+
+	originalDB := DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&PriceOverride{}); err != nil {
+		t.Fatalf("expected price override schema migration for test database, got %v", err)
+	}
+
+	DB = testDB
+	t.Cleanup(func() {
+		DB = originalDB
+		GlobalPriceOverrides.Lock()
+		GlobalPriceOverrides.groups = nil
+		GlobalPriceOverrides.users = nil
+		GlobalPriceOverrides.Unlock()
+	})
+}
+
+func TestPriceOverrideUserTakesPriorityOverGroup(t *testing.T) {
+	useTestPriceOverrideDB(t)
+
+	overrides := []*PriceOverride{
+		{Group: "vip", Model: "gpt-4o", Type: PriceOverrideTypeRatio, Ratio: 0.8},
+		{Group: "vip", Model: "gpt-*", Type: PriceOverrideTypeRatio, Ratio: 0.9},
+		{UserId: 7, Model: "gpt-4o", Type: PriceOverrideTypePrice, Input: 1, Output: 2},
+	}
+	for _, override := range overrides {
+		if err := override.Insert(); err != nil {
+			t.Fatalf("expected override to be inserted, got %v", err)
+		}
+	}
+
+	if override := GlobalPriceOverrides.Get("gpt-4o", 7, "vip"); override == nil || override.UserId != 7 {
+		t.Fatalf("expected user override to win, got %+v", override)
+	}
+	if override := GlobalPriceOverrides.Get("gpt-4o", 8, "vip"); override == nil || override.Ratio != 0.8 {
+		t.Fatalf("expected exact group override to win over wildcard, got %+v", override)
+	}
+	if override := GlobalPriceOverrides.Get("gpt-4.1", 8, "vip"); override == nil || override.Ratio != 0.9 {
+		t.Fatalf("expected wildcard group override, got %+v", override)
+	}
+	if override := GlobalPriceOverrides.Get("claude-3", 8, "vip"); override != nil {
+		t.Fatalf("expected no override for unmatched model, got %+v", override)
+	}
+}
+
+func TestPriceOverrideValidate(t *testing.T) {
+	cases := []PriceOverride{
+		{Model: "gpt-4o", Type: PriceOverrideTypeRatio, Ratio: 1},
+		{Group: "vip", UserId: 1, Model: "gpt-4o", Type: PriceOverrideTypeRatio, Ratio: 1},
+		{Group: "vip", Model: "gpt-4o", Type: "discount"},
+		{Group: "vip", Model: "gpt-4o", Type: PriceOverrideTypePrice, Input: -1},
+	}
+	for i, override := range cases {
+		if err := override.Validate(); err == nil {
+			t.Fatalf("case %d: expected validation error", i)
+		}
+	}
+}
+
+func TestPriceOverrideApply(t *testing.T) {
+	tiers := datatypes.NewJSONSlice([]PriceTier{{PromptTokensAbove: 200000, Input: 4, Output: 8}})
+	price := &Price{Model: "gemini-2.5-pro", Type: TokensPriceType, Input: 2, Output: 4, Tiers: &tiers}
+
+	ratio := (&PriceOverride{Type: PriceOverrideTypeRatio, Ratio: 0.5}).Apply(price)
+	if ratio.Input != 1 || ratio.Output != 2 {
+		t.Fatalf("expected ratio override to scale price, got %v/%v", ratio.Input, ratio.Output)
+	}
+	if tier := ratio.GetTier(300000); tier == nil || tier.Input != 2 || tier.Output != 4 {
+		t.Fatalf("expected ratio override to scale tiers, got %+v", tier)
+	}
+	if price.Input != 2 || price.GetTiers()[0].Input != 4 {
+		t.Fatal("expected original price to stay unchanged")
+	}
+
+	fixed := (&PriceOverride{Type: PriceOverrideTypePrice, Input: 1.5, Output: 3}).Apply(price)
+	if fixed.Input != 1.5 || fixed.Output != 3 || fixed.GetTier(300000) != nil {
+		t.Fatalf("expected fixed override to replace price and drop tiers, got %+v", fixed)
+	}
+}
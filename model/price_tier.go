@@ -0,0 +1,85 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"gorm.io/datatypes"
+)
+
+// PriceTier 按提示词长度分段的价格，提示词 token 数超过 PromptTokensAbove 时使用该段的 Input/Output
+// 例如 Gemini 2.5 Pro 超过 200k tokens 的请求按更高的价格计费
+type PriceTier struct {
+	PromptTokensAbove int     `json:"prompt_tokens_above"`
+	Input             float64 `json:"input"`
+	Output            float64 `json:"output"`
+}
+
+func (price *Price) GetTiers() []PriceTier {
+	if price == nil || price.Tiers == nil {
+		return nil
+	}
+	return *price.Tiers
+}
+
+// GetTier 返回提示词 token 数对应的分段价格，没有命中时返回 nil
+func (price *Price) GetTier(promptTokens int) *PriceTier {
+	if price.Type != TokensPriceType {
+		return nil
+	}
+
+	var matched *PriceTier
+	for _, tier := range price.GetTiers() {
+		if promptTokens > tier.PromptTokensAbove && (matched == nil || tier.PromptTokensAbove > matched.PromptTokensAbove) {
+			matched = &tier
+		}
+	}
+	return matched
+}
+
+func (price *Price) ValidateTiers() error {
+	tiers := price.GetTiers()
+	if len(tiers) == 0 {
+		return nil
+	}
+	if price.Type != TokensPriceType {
+		return errors.New("tiers are only supported for tokens price type")
+	}
+
+	thresholds := make([]int, 0, len(tiers))
+	for _, tier := range tiers {
+		if tier.PromptTokensAbove <= 0 {
+			return errors.New("tiers.prompt_tokens_above must be positive")
+		}
+		if tier.Input < 0 || tier.Output < 0 {
+			return errors.New("tiers price must not be negative")
+		}
+		if slices.Contains(thresholds, tier.PromptTokensAbove) {
+			return fmt.Errorf("duplicate tier threshold %d", tier.PromptTokensAbove)
+		}
+		thresholds = append(thresholds, tier.PromptTokensAbove)
+	}
+	return nil
+}
+
+func tiersKey(tiers *datatypes.JSONSlice[PriceTier]) string {
+	if tiers == nil || len(*tiers) == 0 {
+		return ""
+	}
+	sorted := slices.Clone(*tiers)
+	slices.SortFunc(sorted, func(a, b PriceTier) int {
+		return a.PromptTokensAbove - b.PromptTokensAbove
+	})
+	return fmt.Sprintf("%v", sorted)
+}
+
+// defaultPriceTiers 内置价格表中按提示词长度分段计费的模型
+func defaultPriceTiers() map[string][]PriceTier {
+	return map[string][]PriceTier{
+		// > 200k tokens: $2.50 / M  $15 / M
+		"gemini-2.5-pro": {{PromptTokensAbove: 200000, Input: 1.25, Output: 7.5}},
+		// > 200k tokens: $6 / M  $22.50 / M
+		"claude-sonnet-4-20250514": {{PromptTokensAbove: 200000, Input: 3, Output: 11.25}},
+	}
+}
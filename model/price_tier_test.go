@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+
+	"gorm.io/datatypes"
+)
+
+func TestPriceGetTierSelectsHighestMatchedThreshold(t *testing.T) {
+	tiers := datatypes.NewJSONSlice([]PriceTier{
+		{PromptTokensAbove: 500000, Input: 4, Output: 8},
+		{PromptTokensAbove: 200000, Input: 2, Output: 4},
+	})
+	price := &Price{Type: TokensPriceType, Input: 1, Output: 2, Tiers: &tiers}
+
+	if tier := price.GetTier(200000); tier != nil {
+		t.Fatalf("expected threshold itself to use base price, got %+v", tier)
+	}
+	if tier := price.GetTier(300000); tier == nil || tier.Input != 2 {
+		t.Fatalf("expected 200k tier, got %+v", tier)
+	}
+	if tier := price.GetTier(600000); tier == nil || tier.Input != 4 {
+		t.Fatalf("expected 500k tier, got %+v", tier)
+	}
+
+	price.Type = TimesPriceType
+	if err := price.ValidateTiers(); err == nil {
+		t.Fatal("expected tiers on times price to be rejected")
+	}
+	price.Type = TokensPriceType
+	duplicated := datatypes.NewJSONSlice([]PriceTier{{PromptTokensAbove: 1, Input: 1}, {PromptTokensAbove: 1, Input: 2}})
+	price.Tiers = &duplicated
+	if err := price.ValidateTiers(); err == nil {
+		t.Fatal("expected duplicated thresholds to be rejected")
+	}
+}
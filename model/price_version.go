@@ -0,0 +1,252 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+
+	"gorm.io/datatypes"
+)
+
+// PriceVersion 模型价格的历史版本。已生效的版本不会再被修改，
+// 消费日志通过 price_version_id 引用计费时使用的版本，便于复现历史账单。
+// ActivatedAt 为 0 表示尚未生效的预约价格。
+type PriceVersion struct {
+	Id          int64                                   `json:"id"`
+	Model       string                                  `json:"model" gorm:"type:varchar(100);index:idx_price_version_model;index:idx_price_version_latest,priority:1"`
+	Type        string                                  `json:"type" gorm:"default:'tokens'"`
+	ChannelType int                                     `json:"channel_type" gorm:"default:0"`
+	Input       float64                                 `json:"input" gorm:"default:0"`
+	Output      float64                                 `json:"output" gorm:"default:0"`
+	Locked      bool                                    `json:"locked" gorm:"default:false"`
+	ExtraRatios *datatypes.JSONType[map[string]float64] `json:"extra_ratios,omitempty" gorm:"type:json"`
+	Tiers       *datatypes.JSONSlice[PriceTier]         `json:"tiers,omitempty" gorm:"type:json"`
+	Deleted     bool                                    `json:"deleted" gorm:"default:false"` // 该版本表示价格被删除
+	// 生效时间，预约价格到达该时间后由定时任务生效
+	EffectiveFrom int64 `json:"effective_from" gorm:"bigint;index:idx_price_version_model"`
+	ActivatedAt   int64 `json:"activated_at" gorm:"bigint;index;index:idx_price_version_latest,priority:2"`
+	CreatedAt     int64 `json:"created_at" gorm:"bigint"`
+}
+
+func newPriceVersion(price *Price) *PriceVersion {
+	return &PriceVersion{
+		Model:       price.Model,
+		Type:        price.Type,
+		ChannelType: price.ChannelType,
+		Input:       price.Input,
+		Output:      price.Output,
+		Locked:      price.Locked,
+		ExtraRatios: price.ExtraRatios,
+		Tiers:       price.Tiers,
+	}
+}
+
+func (version *PriceVersion) ToPrice() *Price {
+	return &Price{
+		Model:       version.Model,
+		Type:        version.Type,
+		ChannelType: version.ChannelType,
+		Input:       version.Input,
+		Output:      version.Output,
+		Locked:      version.Locked,
+		ExtraRatios: version.ExtraRatios,
+		Tiers:       version.Tiers,
+	}
+}
+
+// samePrice 判断版本与当前价格的计费字段是否一致
+func (version *PriceVersion) samePrice(price *Price) bool {
+	if version.Deleted || version.Type != price.Type || version.ChannelType != price.ChannelType ||
+		version.Input != price.Input || version.Output != price.Output {
+		return false
+	}
+	return extraRatiosKey(version.ExtraRatios) == extraRatiosKey(price.ExtraRatios) &&
+		tiersKey(version.Tiers) == tiersKey(price.Tiers)
+}
+
+func extraRatiosKey(ratios *datatypes.JSONType[map[string]float64]) string {
+	if ratios == nil || len(ratios.Data()) == 0 {
+		return ""
+	}
+	// json 序列化 map 时按 key 排序，可直接比较
+	raw, _ := json.Marshal(ratios.Data())
+	return string(raw)
+}
+
+var priceVersionLock sync.Mutex
+
+// syncPriceVersions 为当前价格表中发生变化的模型记录新版本，并返回每个模型当前生效的版本 ID。
+// 价格的各种修改路径最终都会调用 Pricing.Init，因此在这里统一记录，只有主节点写入。
+func syncPriceVersions(prices []*Price) (map[string]int64, error) {
+	priceVersionLock.Lock()
+	defer priceVersionLock.Unlock()
+
+	latest, err := getLatestPriceVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	versionIds := make(map[string]int64, len(prices))
+	if !config.IsMasterNode {
+		for modelName, version := range latest {
+			versionIds[modelName] = version.Id
+		}
+		return versionIds, nil
+	}
+
+	now := utils.GetTimestamp()
+	changes := make([]*PriceVersion, 0)
+	current := make(map[string]bool, len(prices))
+	for _, price := range prices {
+		current[price.Model] = true
+		if version, ok := latest[price.Model]; ok && version.samePrice(price) {
+			versionIds[price.Model] = version.Id
+			continue
+		}
+		version := newPriceVersion(price)
+		version.EffectiveFrom = now
+		version.ActivatedAt = now
+		version.CreatedAt = now
+		changes = append(changes, version)
+	}
+	for modelName, version := range latest {
+		if current[modelName] || version.Deleted {
+			continue
+		}
+		changes = append(changes, &PriceVersion{
+			Model:         modelName,
+			Type:          version.Type,
+			Deleted:       true,
+			EffectiveFrom: now,
+			ActivatedAt:   now,
+			CreatedAt:     now,
+		})
+	}
+
+	if len(changes) > 0 {
+		if err := DB.CreateInBatches(changes, 100).Error; err != nil {
+			return versionIds, err
+		}
+		logger.SysLog(fmt.Sprintf("recorded %d price versions", len(changes)))
+	}
+	for _, version := range changes {
+		if !version.Deleted {
+			versionIds[version.Model] = version.Id
+		}
+	}
+
+	return versionIds, nil
+}
+
+// getLatestPriceVersions 返回每个模型最近生效的版本
+func getLatestPriceVersions() (map[string]*PriceVersion, error) {
+	var versions []*PriceVersion
+	// 预约价格的 id 可能比之后直接修改的价格小，按生效时间而不是 id 取最新的版本。
+	// 同一时间生效的多个版本按写入价格表的顺序，取最后写入的一个
+	latestQuery := DB.Model(&PriceVersion{}).Select("model, MAX(activated_at) AS max_activated_at").Where("activated_at > 0").Group("model")
+	err := DB.Model(&PriceVersion{}).
+		Joins("JOIN (?) AS latest ON price_versions.model = latest.model AND price_versions.activated_at = latest.max_activated_at", latestQuery).
+		Order("price_versions.effective_from desc, price_versions.id desc").
+		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]*PriceVersion)
+	for _, version := range versions {
+		if _, ok := latest[version.Model]; !ok {
+			latest[version.Model] = version
+		}
+	}
+	return latest, nil
+}
+
+// GetPriceHistory 返回模型的所有价格版本（包括未生效的预约价格），按生效时间倒序
+func GetPriceHistory(modelName string) ([]*PriceVersion, error) {
+	var versions []*PriceVersion
+	err := DB.Where("model = ?", modelName).Order("effective_from desc, id desc").Find(&versions).Error
+	return versions, err
+}
+
+func GetPriceVersionById(id int64) (*PriceVersion, error) {
+	var version PriceVersion
+	err := DB.First(&version, "id = ?", id).Error
+	return &version, err
+}
+
+// GetScheduledPrices 返回所有尚未生效的预约价格
+func GetScheduledPrices() ([]*PriceVersion, error) {
+	var versions []*PriceVersion
+	err := DB.Where("activated_at = 0").Order("effective_from asc, id asc").Find(&versions).Error
+	return versions, err
+}
+
+// SchedulePrice 预约一个在 effectiveFrom 生效的价格
+func SchedulePrice(price *Price, effectiveFrom int64) (*PriceVersion, error) {
+	if price.Model == "" {
+		return nil, errors.New("model name is required")
+	}
+	if effectiveFrom <= time.Now().Unix() {
+		return nil, errors.New("effective_from must be in the future")
+	}
+	if err := price.ValidateTiers(); err != nil {
+		return nil, err
+	}
+
+	version := newPriceVersion(price)
+	version.EffectiveFrom = effectiveFrom
+	version.CreatedAt = utils.GetTimestamp()
+	if err := DB.Create(version).Error; err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// CancelScheduledPrice 取消尚未生效的预约价格，已生效的版本不可删除
+func CancelScheduledPrice(id int64) error {
+	result := DB.Where("id = ? AND activated_at = 0", id).Delete(&PriceVersion{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("scheduled price not found")
+	}
+	return nil
+}
+
+// ActivateScheduledPrices 将已到生效时间的预约价格写入价格表，返回生效的数量
+func (p *Pricing) ActivateScheduledPrices(now time.Time) (int, error) {
+	var versions []*PriceVersion
+	err := DB.Where("activated_at = 0 AND effective_from <= ?", now.Unix()).Order("effective_from asc, id asc").Find(&versions).Error
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+
+	tx := DB.Begin()
+	for _, version := range versions {
+		if err := tx.Where("model = ?", version.Model).Delete(&Price{}).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if err := tx.Create(version.ToPrice()).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		// 同一模型多个预约同时到期时，后面的会覆盖前面的，保留各自的生效记录
+		if err := tx.Model(version).Update("activated_at", now.Unix()).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+
+	return len(versions), p.Init()
+}
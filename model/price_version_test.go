@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"one-api/common/logger"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useTestPricingDB(t *testing.T) *Pricing {
+	t.Helper()
+
+	if logger.Logger == nil {
+		logger.SetupLogger()
+	}
+
+	originalDB := DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&Price{}, &PriceVersion{}, &ModelInfo{}); err != nil {
+		t.Fatalf("expected pricing schema migration for test database, got %v", err)
+	}
+
+	DB = testDB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	return &Pricing{Prices: make(map[string]*Price), Match: make([]string, 0)}
+}
+
+func TestPricingRecordsVersionsOnChange(t *testing.T) {
+	pricing := useTestPricingDB(t)
+
+	if err := pricing.AddPrice(&Price{Model: "gpt-4o", Type: TokensPriceType, Input: 1.25, Output: 5}); err != nil {
+		t.Fatalf("expected price to be added, got %v", err)
+	}
+	first := pricing.GetPrice("gpt-4o").VersionId
+	if first == 0 {
+		t.Fatal("expected current price to reference a version")
+	}
+
+	// 重新加载但价格没有变化时不产生新版本
+	if err := pricing.Init(); err != nil || pricing.GetPrice("gpt-4o").VersionId != first {
+		t.Fatalf("expected unchanged price to keep version %d, got %d, %v", first, pricing.GetPrice("gpt-4o").VersionId, err)
+	}
+
+	if err := pricing.UpdatePrice("gpt-4o", &Price{Model: "gpt-4o", Type: TokensPriceType, Input: 1, Output: 4}); err != nil {
+		t.Fatalf("expected price to be updated, got %v", err)
+	}
+	if err := pricing.DeletePrice("gpt-4o"); err != nil {
+		t.Fatalf("expected price to be deleted, got %v", err)
+	}
+
+	history, err := GetPriceHistory("gpt-4o")
+	if err != nil || len(history) != 3 {
+		t.Fatalf("expected three versions, got %d, %v", len(history), err)
+	}
+	if !history[0].Deleted || history[1].Input != 1 || history[2].Id != first {
+		t.Fatalf("unexpected history %+v %+v %+v", history[0], history[1], history[2])
+	}
+}
+
+func TestActivateScheduledPrices(t *testing.T) {
+	pricing := useTestPricingDB(t)
+
+	if err := pricing.AddPrice(&Price{Model: "gpt-4o", Type: TokensPriceType, Input: 1.25, Output: 5}); err != nil {
+		t.Fatalf("expected price to be added, got %v", err)
+	}
+	effectiveFrom := time.Now().Add(time.Hour)
+	scheduled, err := SchedulePrice(&Price{Model: "gpt-4o", Type: TokensPriceType, Input: 2, Output: 8}, effectiveFrom.Unix())
+	if err != nil {
+		t.Fatalf("expected price to be scheduled, got %v", err)
+	}
+
+	if count, err := pricing.ActivateScheduledPrices(time.Now()); err != nil || count != 0 {
+		t.Fatalf("expected future price not to be activated yet, got %d, %v", count, err)
+	}
+	if count, err := pricing.ActivateScheduledPrices(effectiveFrom); err != nil || count != 1 {
+		t.Fatalf("expected scheduled price to be activated, got %d, %v", count, err)
+	}
+
+	price := pricing.GetPrice("gpt-4o")
+	if price.Input != 2 || price.VersionId != scheduled.Id {
+		t.Fatalf("expected scheduled version to be current, got input=%v version=%d", price.Input, price.VersionId)
+	}
+	if err := CancelScheduledPrice(scheduled.Id); err == nil {
+		t.Fatal("expected activated version not to be cancelable")
+	}
+}
+
+func TestScheduledPriceActivatedAfterLaterChangeBecomesCurrent(t *testing.T) {
+	pricing := useTestPricingDB(t)
+
+	if err := pricing.AddPrice(&Price{Model: "gpt-4o", Type: TokensPriceType, Input: 1.25, Output: 5}); err != nil {
+		t.Fatalf("expected price to be added, got %v", err)
+	}
+	effectiveFrom := time.Now().Add(time.Hour)
+	scheduled, err := SchedulePrice(&Price{Model: "gpt-4o", Type: TokensPriceType, Input: 2, Output: 8}, effectiveFrom.Unix())
+	if err != nil {
+		t.Fatalf("expected price to be scheduled, got %v", err)
+	}
+	// 预约之后直接修改的价格 id 更大，但生效时间更早
+	if err := pricing.UpdatePrice("gpt-4o", &Price{Model: "gpt-4o", Type: TokensPriceType, Input: 1, Output: 4}); err != nil {
+		t.Fatalf("expected price to be updated, got %v", err)
+	}
+	if count, err := pricing.ActivateScheduledPrices(effectiveFrom); err != nil || count != 1 {
+		t.Fatalf("expected scheduled price to be activated, got %d, %v", count, err)
+	}
+
+	if price := pricing.GetPrice("gpt-4o"); price.Input != 2 || price.VersionId != scheduled.Id {
+		t.Fatalf("expected scheduled version to be current, got input=%v version=%d", price.Input, price.VersionId)
+	}
+	history, err := GetPriceHistory("gpt-4o")
+	if err != nil || len(history) != 3 {
+		t.Fatalf("expected no duplicate version to be recorded, got %d, %v", len(history), err)
+	}
+}
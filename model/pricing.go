@@ -73,6 +73,14 @@ func (p *Pricing) Init() error {
 		return err
 	}
 
+	versionIds, err := syncPriceVersions(prices)
+	if err != nil {
+		logger.SysError("Failed to sync price versions: " + err.Error())
+	}
+	for _, price := range prices {
+		price.VersionId = versionIds[price.Model]
+	}
+
 	if len(prices) == 0 {
 		return nil
 	}
@@ -155,6 +163,10 @@ func (p *Pricing) GetAllPricesList() []*Price {
 }
 
 func (p *Pricing) updateRawPrice(modelName string, price *Price) error {
+	if err := price.ValidateTiers(); err != nil {
+		return err
+	}
+
 	if _, ok := p.Prices[modelName]; !ok {
 		return errors.New("model not found")
 	}
@@ -183,6 +195,10 @@ func (p *Pricing) UpdatePrice(modelName string, price *Price) error {
 }
 
 func (p *Pricing) addRawPrice(price *Price) error {
+	if err := price.ValidateTiers(); err != nil {
+		return err
+	}
+
 	if _, ok := p.Prices[price.Model]; ok {
 		return errors.New("model already exists")
 	}
@@ -469,6 +485,10 @@ func (p *Pricing) BatchDeletePrices(models []string) error {
 }
 
 func (p *Pricing) BatchSetPrices(batchPrices *BatchPrices, originalModels []string) error {
+	if err := batchPrices.Price.ValidateTiers(); err != nil {
+		return err
+	}
+
 	// 查找需要删除的model
 	var deletePrices []string
 	var addPrices []*Price
@@ -7,22 +7,77 @@ import (
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/utils"
+	"regexp"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Redemption struct {
 	Id           int    `json:"id"`
 	UserId       int    `json:"user_id"`
-	Key          string `json:"key" gorm:"type:char(32);uniqueIndex"`
+	Key          string `json:"key" gorm:"type:varchar(64);uniqueIndex"`
 	Status       int    `json:"status" gorm:"default:1"`
 	Name         string `json:"name" gorm:"index"`
 	Quota        int    `json:"quota" gorm:"default:100"`
 	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
 	RedeemedTime int64  `json:"redeemed_time" gorm:"bigint"`
+	Prefix       string `json:"prefix" gorm:"type:varchar(16);default:''"`
+	MaxUses      int    `json:"max_uses" gorm:"default:1"` // 可兑换的总次数，用完后状态变为已使用
+	UsedCount    int    `json:"used_count" gorm:"default:0"`
+	OncePerUser  bool   `json:"once_per_user" gorm:"default:false"`
+	ExpiredTime  int64  `json:"expired_time" gorm:"bigint;default:-1"`    // -1 means never expired
+	Group        string `json:"group" gorm:"type:varchar(32);default:''"` // 兑换后限时升级到的分组，为空不升级
+	GroupDays    int    `json:"group_days" gorm:"default:0"`
 	Count        int    `json:"count" gorm:"-:all"` // only for api request
 }
 
+// RedemptionRecord 兑换码的一次兑换记录
+type RedemptionRecord struct {
+	Id               int    `json:"id"`
+	RedemptionId     int    `json:"redemption_id" gorm:"index"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	Quota            int    `json:"quota" gorm:"default:0"`
+	Group            string `json:"group" gorm:"type:varchar(32);default:''"`
+	GroupExpiredTime int64  `json:"group_expired_time" gorm:"bigint;default:0"`
+	Ip               string `json:"ip" gorm:"type:varchar(64);default:''"`
+	CreatedTime      int64  `json:"created_time" gorm:"bigint;index"`
+}
+
+var redemptionPrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,16}$`)
+
+// Validate 检查兑换码的设置，兑换码至少要发放额度或升级分组
+func (redemption *Redemption) Validate() error {
+	if redemption.Prefix != "" && !redemptionPrefixPattern.MatchString(redemption.Prefix) {
+		return errors.New("兑换码前缀只能包含字母、数字、- 和 _，且不超过 16 个字符")
+	}
+	if redemption.Quota < 0 {
+		return errors.New("额度不能为负数")
+	}
+	if redemption.MaxUses <= 0 {
+		return errors.New("可兑换次数必须大于0")
+	}
+	if redemption.ExpiredTime == 0 {
+		redemption.ExpiredTime = -1
+	}
+	if redemption.Group != "" {
+		if GlobalUserGroupRatio.GetBySymbol(redemption.Group) == nil {
+			return fmt.Errorf("分组 %s 不存在", redemption.Group)
+		}
+		if redemption.GroupDays <= 0 {
+			return errors.New("分组升级天数必须大于0")
+		}
+	} else {
+		redemption.GroupDays = 0
+	}
+	if redemption.Quota == 0 && redemption.Group == "" {
+		return errors.New("兑换码必须发放额度或升级分组")
+	}
+	return nil
+}
+
 var allowedRedemptionslOrderFields = map[string]bool{
 	"id":            true,
 	"name":          true,
@@ -30,6 +85,8 @@ var allowedRedemptionslOrderFields = map[string]bool{
 	"quota":         true,
 	"created_time":  true,
 	"redeemed_time": true,
+	"expired_time":  true,
+	"used_count":    true,
 }
 
 func GetRedemptionsList(params *GenericParams) (*DataResult[Redemption], error) {
@@ -52,6 +109,7 @@ func GetRedemptionById(id int) (*Redemption, error) {
 	return &redemption, err
 }
 
+// Redeem 使用兑换码，返回发放的额度。多次兑换的兑换码在次数用完后标记为已使用。
 func Redeem(key string, userId int, ip string) (quota int, err error) {
 	if key == "" {
 		return 0, errors.New("未提供兑换码")
@@ -60,40 +118,95 @@ func Redeem(key string, userId int, ip string) (quota int, err error) {
 		return 0, errors.New("无效的 user id")
 	}
 	redemption := &Redemption{}
+	record := &RedemptionRecord{}
 
 	keyCol := "`key`"
 	if common.UsingPostgreSQL {
 		keyCol = `"key"`
 	}
 
+	now := time.Now()
 	err = DB.Transaction(func(tx *gorm.DB) error {
-		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(keyCol+" = ?", key).First(redemption).Error
+		// 锁定兑换码，同一兑换码的兑换串行执行，每人限兑一次的检查不会被并发绕过
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(keyCol+" = ?", strings.TrimSpace(key)).First(redemption).Error
 		if err != nil {
 			return errors.New("无效的兑换码")
 		}
-		if redemption.Status != config.RedemptionCodeStatusEnabled {
+		switch {
+		case redemption.Status == config.RedemptionCodeStatusDisabled:
+			return errors.New("该兑换码已被禁用")
+		case redemption.Status != config.RedemptionCodeStatusEnabled:
 			return errors.New("该兑换码已被使用")
+		case redemption.ExpiredTime != -1 && redemption.ExpiredTime < now.Unix():
+			return errors.New("该兑换码已过期")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
-		if err != nil {
-			return err
+		if redemption.OncePerUser {
+			var count int64
+			if err := tx.Model(&RedemptionRecord{}).Where("redemption_id = ? AND user_id = ?", redemption.Id, userId).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errors.New("您已使用过该兑换码")
+			}
 		}
-		redemption.RedeemedTime = utils.GetTimestamp()
-		redemption.Status = config.RedemptionCodeStatusUsed
-		err = tx.Save(redemption).Error
-		return err
+
+		// 按剩余次数条件更新，并发兑换时不会超过可兑换次数。
+		// gorm 按字段名排序生成 SET，status 在 used_count 之前，MySQL 中读取的也是更新前的值
+		result := tx.Model(&Redemption{}).Where("id = ? AND status = ? AND used_count < max_uses", redemption.Id, config.RedemptionCodeStatusEnabled).Updates(map[string]any{
+			"used_count":    gorm.Expr("used_count + 1"),
+			"redeemed_time": now.Unix(),
+			"status":        gorm.Expr("CASE WHEN used_count + 1 >= max_uses THEN ? ELSE status END", config.RedemptionCodeStatusUsed),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("该兑换码已被使用")
+		}
+
+		if redemption.Quota > 0 {
+			err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		record.RedemptionId = redemption.Id
+		record.UserId = userId
+		record.Quota = redemption.Quota
+		record.Ip = ip
+		record.CreatedTime = now.Unix()
+		if redemption.Group != "" && redemption.GroupDays > 0 {
+			grant, err := grantUserGroup(tx, userId, redemption.Group, redemption.GroupDays, redemption.Id, now)
+			if err != nil {
+				return err
+			}
+			record.Group = grant.Group
+			record.GroupExpiredTime = grant.ExpiredTime
+		}
+		return tx.Create(record).Error
 	})
 	if err != nil {
 		return 0, errors.New("兑换失败，" + err.Error())
 	}
 
-	// Try to upgrade user group based on cumulative recharge amount
-	err = CheckAndUpgradeUserGroup(userId, redemption.Quota)
-	if err != nil {
-		logger.SysError("failed to check and upgrade user group: " + err.Error())
+	content := fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota))
+	if redemption.Quota > 0 {
+		// Try to upgrade user group based on cumulative recharge amount
+		err = CheckAndUpgradeUserGroup(userId, redemption.Quota)
+		if err != nil {
+			logger.SysError("failed to check and upgrade user group: " + err.Error())
+		}
+	}
+	if record.Group != "" {
+		refreshUserGroupCache(userId)
+		content = fmt.Sprintf("通过兑换码升级到分组 %s，有效期至 %s", record.Group, time.Unix(record.GroupExpiredTime, 0).Format("2006-01-02 15:04:05"))
+		if redemption.Quota > 0 {
+			content = fmt.Sprintf("通过兑换码充值 %s，并升级到分组 %s，有效期至 %s", common.LogQuota(redemption.Quota), record.Group, time.Unix(record.GroupExpiredTime, 0).Format("2006-01-02 15:04:05"))
+		}
 	}
 
-	RecordQuotaLog(userId, LogTypeTopup, redemption.Quota, ip, fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota)))
+	RecordQuotaLog(userId, LogTypeTopup, redemption.Quota, ip, content)
 	return redemption.Quota, nil
 }
 
@@ -103,6 +216,31 @@ func (redemption *Redemption) Insert() error {
 	return err
 }
 
+// InsertRedemptions 批量生成兑换码
+func InsertRedemptions(redemptions []*Redemption) error {
+	return DB.CreateInBatches(redemptions, 100).Error
+}
+
+// GetRedemptionsByName 按名称获取同一批生成的兑换码，用于导出
+func GetRedemptionsByName(name string) ([]*Redemption, error) {
+	var redemptions []*Redemption
+	err := DB.Where("name = ?", name).Order("id").Find(&redemptions).Error
+	return redemptions, err
+}
+
+var allowedRedemptionRecordOrderFields = map[string]bool{
+	"id":           true,
+	"user_id":      true,
+	"created_time": true,
+}
+
+func GetRedemptionRecordsList(redemptionId int, params *PaginationParams) (*DataResult[RedemptionRecord], error) {
+	var records []*RedemptionRecord
+	db := DB.Where("redemption_id = ?", redemptionId)
+
+	return PaginateAndOrder(db, params, &records, allowedRedemptionRecordOrderFields)
+}
+
 func (redemption *Redemption) SelectUpdate() error {
 	// This can update zero values
 	return DB.Model(redemption).Select("redeemed_time", "status").Updates(redemption).Error
@@ -111,7 +249,7 @@ func (redemption *Redemption) SelectUpdate() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (redemption *Redemption) Update() error {
 	var err error
-	err = DB.Model(redemption).Select("name", "status", "quota", "redeemed_time").Updates(redemption).Error
+	err = DB.Model(redemption).Select("name", "status", "quota", "redeemed_time", "max_uses", "once_per_user", "expired_time", "group", "group_days").Updates(redemption).Error
 	return err
 }
 
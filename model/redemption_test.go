@@ -0,0 +1,163 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useRedemptionTestDB(t *testing.T) {
+	t.Helper()
+
+	logger.Logger = zap.NewNop()
+
+	originalDB := DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&User{}, &Log{}, &UserGroup{}, &UserSubscription{}, &Redemption{}, &RedemptionRecord{}, &UserGroupGrant{}); err != nil {
+		t.Fatalf("expected redemption schema migration to succeed, got %v", err)
+	}
+
+	DB = testDB
+	originalGroups := GlobalUserGroupRatio.UserGroup
+	t.Cleanup(func() {
+		DB = originalDB
+		GlobalUserGroupRatio.Lock()
+		GlobalUserGroupRatio.UserGroup = originalGroups
+		GlobalUserGroupRatio.Unlock()
+	})
+
+	for _, group := range []*UserGroup{
+		{Symbol: "default", Name: "default", Ratio: 1},
+		{Symbol: "vip", Name: "vip", Ratio: 0.8},
+		{Symbol: "svip", Name: "svip", Ratio: 0.5},
+	} {
+		if err := DB.Create(group).Error; err != nil {
+			t.Fatalf("expected user group fixture to persist, got %v", err)
+		}
+	}
+	GlobalUserGroupRatio.Load()
+
+	for id := 1; id <= 2; id++ {
+		if err := DB.Create(&User{
+			Id:          id,
+			Username:    fmt.Sprintf("user%d", id),
+			Password:    "password123",
+			AccessToken: fmt.Sprintf("access-token-%d", id),
+			AffCode:     fmt.Sprintf("aff-%d", id),
+			Group:       "default",
+			Status:      config.UserStatusEnabled,
+			Role:        config.RoleCommonUser,
+		}).Error; err != nil {
+			t.Fatalf("expected user fixture to persist, got %v", err)
+		}
+	}
+}
+
+func createTestRedemption(t *testing.T, redemption *Redemption) *Redemption {
+	t.Helper()
+	if err := redemption.Insert(); err != nil {
+		t.Fatalf("expected redemption fixture to persist, got %v", err)
+	}
+	return redemption
+}
+
+func TestRedeemMultiUseCodeOncePerUser(t *testing.T) {
+	useRedemptionTestDB(t)
+	redemption := createTestRedemption(t, &Redemption{Key: "PROMO-multi", Name: "promo", Quota: 100, MaxUses: 2, OncePerUser: true})
+
+	if _, err := Redeem("PROMO-multi", 1, "127.0.0.1"); err != nil {
+		t.Fatalf("expected first redemption to succeed, got %v", err)
+	}
+	if _, err := Redeem("PROMO-multi", 1, "127.0.0.1"); err == nil {
+		t.Fatal("expected the same user to be rejected")
+	}
+	if _, err := Redeem("PROMO-multi", 2, "127.0.0.1"); err != nil {
+		t.Fatalf("expected second user to redeem, got %v", err)
+	}
+
+	stored, _ := GetRedemptionById(redemption.Id)
+	if stored.UsedCount != 2 || stored.Status != config.RedemptionCodeStatusUsed {
+		t.Fatalf("expected code to be used up, got used=%d status=%d", stored.UsedCount, stored.Status)
+	}
+	records, err := GetRedemptionRecordsList(redemption.Id, &PaginationParams{})
+	if err != nil || records.TotalCount != 2 {
+		t.Fatalf("expected two redemption records, got %+v, %v", records, err)
+	}
+}
+
+func TestRedeemRejectsExpiredCode(t *testing.T) {
+	useRedemptionTestDB(t)
+	createTestRedemption(t, &Redemption{Key: "expired", Name: "promo", Quota: 100, MaxUses: 1, ExpiredTime: time.Now().Add(-time.Hour).Unix()})
+
+	if _, err := Redeem("expired", 1, ""); err == nil {
+		t.Fatal("expected expired code to be rejected")
+	}
+	var user User
+	DB.First(&user, 1)
+	if user.Quota != 0 {
+		t.Fatalf("expected quota to stay unchanged, got %d", user.Quota)
+	}
+}
+
+func TestRedeemGroupGrantExpiresAndRestoresGroup(t *testing.T) {
+	useRedemptionTestDB(t)
+	createTestRedemption(t, &Redemption{Key: "vip", Name: "vip", Quota: 0, MaxUses: 10, Group: "vip", GroupDays: 7})
+
+	if _, err := Redeem("vip", 1, ""); err != nil {
+		t.Fatalf("expected group grant redemption to succeed, got %v", err)
+	}
+	// 再次兑换同一分组时顺延有效期
+	if _, err := Redeem("vip", 1, ""); err != nil {
+		t.Fatalf("expected same group grant to be extended, got %v", err)
+	}
+
+	var user User
+	DB.First(&user, 1)
+	if user.Group != "vip" {
+		t.Fatalf("expected user to be upgraded, got %s", user.Group)
+	}
+	var grant UserGroupGrant
+	DB.First(&grant, "user_id = ?", 1)
+	if days := time.Until(time.Unix(grant.ExpiredTime, 0)).Hours() / 24; days < 13.9 || days > 14 {
+		t.Fatalf("expected grant to last 14 days, got %.2f", days)
+	}
+
+	expired, err := ExpireUserGroupGrants(time.Now().AddDate(0, 0, 15))
+	if err != nil || expired != 1 {
+		t.Fatalf("expected one grant to expire, got %d, %v", expired, err)
+	}
+	DB.First(&user, 1)
+	if user.Group != "default" {
+		t.Fatalf("expected original group to be restored, got %s", user.Group)
+	}
+}
+
+func TestRedeemGroupGrantRejectsDowngrade(t *testing.T) {
+	useRedemptionTestDB(t)
+	createTestRedemption(t, &Redemption{Key: "vip", Name: "vip", Quota: 0, MaxUses: 10, Group: "vip", GroupDays: 7})
+	DB.Model(&User{}).Where("id = ?", 1).Update("group", "svip")
+
+	if _, err := Redeem("vip", 1, ""); err == nil {
+		t.Fatal("expected grant to a worse group to be rejected")
+	}
+	var user User
+	DB.First(&user, 1)
+	if user.Group != "svip" {
+		t.Fatalf("expected user to keep the better group, got %s", user.Group)
+	}
+	var count int64
+	DB.Model(&UserGroupGrant{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no grant to be created, got %d", count)
+	}
+}
@@ -0,0 +1,599 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"one-api/common/cache"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/common/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SubscriptionOverageBalance = "balance" // 包含额度用完后继续使用账户余额
+	SubscriptionOverageBlock   = "block"   // 包含额度用完后拒绝请求，直到下个周期
+)
+
+const (
+	SubscriptionStatusActive  = "active"
+	SubscriptionStatusExpired = "expired"
+)
+
+const (
+	SubscriptionBillSourceGateway = "gateway" // 通过支付网关购买
+	SubscriptionBillSourceBalance = "balance" // 续费时从账户余额扣除
+)
+
+var (
+	UserSubscriptionCacheKey = "user_subscription:%d"
+
+	ErrSubscriptionQuotaExhausted = errors.New("订阅额度已用完，请等待下个周期或升级套餐")
+	ErrSubscriptionConflict       = errors.New("已有生效中的其他订阅")
+	// 套餐订单已支付但无法开通订阅，订单需要退款
+	ErrSubscriptionOrderNotActivated = errors.New("套餐订单无法开通订阅")
+)
+
+// SubscriptionPlan 订阅套餐，按月计费，每个周期开始时发放包含的额度，周期结束时收回未用完的部分
+type SubscriptionPlan struct {
+	Id          int            `json:"id"`
+	Name        string         `json:"name" gorm:"type:varchar(64)" binding:"required"`
+	Description string         `json:"description" gorm:"type:varchar(255);default:''"`
+	Price       float64        `json:"price" gorm:"type:decimal(10,2);default:0"` // 每月价格，单位 USD
+	Quota       int            `json:"quota" gorm:"default:0"`                    // 每个周期包含的额度
+	Group       string         `json:"group" gorm:"type:varchar(32);default:''"`  // 订阅期间升级到的分组，为空不变
+	Overage     string         `json:"overage" gorm:"type:varchar(16);default:'balance'"`
+	Enabled     bool           `json:"enabled" gorm:"default:true"`
+	Sort        int            `json:"sort" gorm:"default:0"`
+	CreatedAt   int64          `json:"created_at" gorm:"bigint"`
+	UpdatedAt   int64          `json:"updated_at" gorm:"bigint"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+func (plan *SubscriptionPlan) Validate() error {
+	if strings.TrimSpace(plan.Name) == "" {
+		return errors.New("name is required")
+	}
+	if plan.Price <= 0 {
+		return errors.New("price must be positive")
+	}
+	if plan.Quota < 0 {
+		return errors.New("quota must not be negative")
+	}
+	if plan.Overage == "" {
+		plan.Overage = SubscriptionOverageBalance
+	}
+	if plan.Overage != SubscriptionOverageBalance && plan.Overage != SubscriptionOverageBlock {
+		return errors.New("unsupported overage mode")
+	}
+	if plan.Group != "" {
+		if GlobalUserGroupRatio.GetBySymbol(plan.Group) == nil {
+			return fmt.Errorf("group %s not found", plan.Group)
+		}
+	}
+	return nil
+}
+
+type SearchSubscriptionPlanParams struct {
+	Name string `form:"name"`
+	PaginationParams
+}
+
+var allowedSubscriptionPlanOrderFields = map[string]bool{
+	"id":    true,
+	"price": true,
+	"sort":  true,
+}
+
+func GetSubscriptionPlansList(params *SearchSubscriptionPlanParams) (*DataResult[SubscriptionPlan], error) {
+	var plans []*SubscriptionPlan
+	db := DB
+	if params.Name != "" {
+		db = db.Where("name LIKE ?", params.Name+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &plans, allowedSubscriptionPlanOrderFields)
+}
+
+func GetEnabledSubscriptionPlans() ([]*SubscriptionPlan, error) {
+	var plans []*SubscriptionPlan
+	err := DB.Where("enabled = ?", true).Order("sort asc, id asc").Find(&plans).Error
+	return plans, err
+}
+
+func GetSubscriptionPlanById(id int) (*SubscriptionPlan, error) {
+	var plan SubscriptionPlan
+	err := DB.First(&plan, "id = ?", id).Error
+	return &plan, err
+}
+
+func (plan *SubscriptionPlan) Insert() error {
+	if err := plan.Validate(); err != nil {
+		return err
+	}
+	plan.CreatedAt = utils.GetTimestamp()
+	plan.UpdatedAt = plan.CreatedAt
+	return DB.Create(plan).Error
+}
+
+// Update 修改套餐不影响已生效的订阅，续费时才会使用新的价格和额度
+func (plan *SubscriptionPlan) Update() error {
+	if err := plan.Validate(); err != nil {
+		return err
+	}
+	plan.UpdatedAt = utils.GetTimestamp()
+	return DB.Model(plan).Select("name", "description", "price", "quota", "group", "overage", "enabled", "sort", "updated_at").Updates(plan).Error
+}
+
+func (plan *SubscriptionPlan) Delete() error {
+	return DB.Delete(plan).Error
+}
+
+// UserSubscription 用户的订阅。套餐的额度、分组等在每个周期开始时从套餐复制一份，
+// PeriodBaseUsedQuota 记录周期开始时用户的已用额度，用来计算本周期的用量。
+type UserSubscription struct {
+	Id                  int     `json:"id"`
+	UserId              int     `json:"user_id" gorm:"index"`
+	PlanId              int     `json:"plan_id" gorm:"index"`
+	PlanName            string  `json:"plan_name" gorm:"type:varchar(64)"`
+	Status              string  `json:"status" gorm:"type:varchar(16);index"`
+	Price               float64 `json:"price" gorm:"type:decimal(10,2);default:0"`
+	Quota               int     `json:"quota" gorm:"default:0"`
+	Group               string  `json:"group" gorm:"type:varchar(32);default:''"`
+	OriginalGroup       string  `json:"original_group" gorm:"type:varchar(32);default:''"` // 订阅前的分组，过期后恢复
+	Overage             string  `json:"overage" gorm:"type:varchar(16);default:'balance'"`
+	AutoRenew           bool    `json:"auto_renew" gorm:"default:true"`
+	PrepaidPeriods      int     `json:"prepaid_periods" gorm:"default:0"` // 已支付但尚未开始的周期数
+	PeriodStart         int64   `json:"period_start" gorm:"bigint"`
+	PeriodEnd           int64   `json:"period_end" gorm:"bigint;index"`
+	PeriodBaseUsedQuota int     `json:"-" gorm:"default:0"`
+	CreatedAt           int64   `json:"created_at" gorm:"bigint"`
+	UpdatedAt           int64   `json:"updated_at" gorm:"bigint"`
+}
+
+// SubscriptionBill 订阅的计费记录，每个已支付的周期一条，周期结束时回填用量
+type SubscriptionBill struct {
+	Id             int     `json:"id"`
+	UserId         int     `json:"user_id" gorm:"index"`
+	SubscriptionId int     `json:"subscription_id" gorm:"index"`
+	PlanId         int     `json:"plan_id"`
+	PlanName       string  `json:"plan_name" gorm:"type:varchar(64)"`
+	Source         string  `json:"source" gorm:"type:varchar(16)"`
+	TradeNo        string  `json:"trade_no" gorm:"type:varchar(50);default:''"`
+	Amount         float64 `json:"amount" gorm:"type:decimal(10,2);default:0"` // USD
+	Quota          int     `json:"quota" gorm:"default:0"`
+	UsedQuota      int     `json:"used_quota" gorm:"default:0"`
+	OverageQuota   int     `json:"overage_quota" gorm:"default:0"`
+	PeriodStart    int64   `json:"period_start" gorm:"bigint;index"`
+	PeriodEnd      int64   `json:"period_end" gorm:"bigint"`
+	Settled        bool    `json:"settled" gorm:"default:false"`
+	CreatedAt      int64   `json:"created_at" gorm:"bigint"`
+}
+
+func (sub *UserSubscription) applyPlan(plan *SubscriptionPlan) {
+	sub.PlanId = plan.Id
+	sub.PlanName = plan.Name
+	sub.Price = plan.Price
+	sub.Quota = plan.Quota
+	sub.Group = plan.Group
+	sub.Overage = plan.Overage
+}
+
+func (sub *UserSubscription) newBill(source, tradeNo string, periodStart time.Time) *SubscriptionBill {
+	return &SubscriptionBill{
+		UserId:         sub.UserId,
+		SubscriptionId: sub.Id,
+		PlanId:         sub.PlanId,
+		PlanName:       sub.PlanName,
+		Source:         source,
+		TradeNo:        tradeNo,
+		Amount:         sub.Price,
+		Quota:          sub.Quota,
+		PeriodStart:    periodStart.Unix(),
+		PeriodEnd:      periodStart.AddDate(0, 1, 0).Unix(),
+		CreatedAt:      utils.GetTimestamp(),
+	}
+}
+
+func GetUserActiveSubscription(userId int) (*UserSubscription, error) {
+	var sub UserSubscription
+	err := DB.Where("user_id = ? AND status = ?", userId, SubscriptionStatusActive).First(&sub).Error
+	return &sub, err
+}
+
+// CacheGetUserActiveSubscription 没有生效中的订阅时返回 Id 为 0 的空订阅
+func CacheGetUserActiveSubscription(userId int) (*UserSubscription, error) {
+	load := func() (*UserSubscription, error) {
+		sub, err := GetUserActiveSubscription(userId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &UserSubscription{}, nil
+		}
+		return sub, err
+	}
+	if !config.RedisEnabled {
+		return load()
+	}
+
+	return cache.GetOrSetCache(
+		fmt.Sprintf(UserSubscriptionCacheKey, userId),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		load,
+		cache.CacheTimeout)
+}
+
+// CheckSubscriptionOverage 订阅的超额策略为 block 且本周期包含额度已用完时返回错误。
+// 查询失败时只记录日志，不影响请求。
+func CheckSubscriptionOverage(userId int) error {
+	sub, err := CacheGetUserActiveSubscription(userId)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to get subscription of user %d: %s", userId, err.Error()))
+		return nil
+	}
+	if sub.Id == 0 || sub.Overage != SubscriptionOverageBlock {
+		return nil
+	}
+	usedQuota, err := GetUserUsedQuota(userId)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to get used quota of user %d: %s", userId, err.Error()))
+		return nil
+	}
+	if usedQuota-sub.PeriodBaseUsedQuota >= sub.Quota {
+		return ErrSubscriptionQuotaExhausted
+	}
+	return nil
+}
+
+func GetUserSubscriptionBills(userId int, startTimestamp, endTimestamp int64) ([]*SubscriptionBill, error) {
+	var bills []*SubscriptionBill
+	err := DB.Where("user_id = ? AND period_start >= ? AND period_start < ?", userId, startTimestamp, endTimestamp).
+		Order("period_start desc, id desc").Find(&bills).Error
+	return bills, err
+}
+
+type SearchUserSubscriptionParams struct {
+	UserId int    `form:"user_id"`
+	PlanId int    `form:"plan_id"`
+	Status string `form:"status"`
+	PaginationParams
+}
+
+var allowedUserSubscriptionOrderFields = map[string]bool{
+	"id":         true,
+	"user_id":    true,
+	"plan_id":    true,
+	"period_end": true,
+	"created_at": true,
+}
+
+func GetUserSubscriptionsList(params *SearchUserSubscriptionParams) (*DataResult[UserSubscription], error) {
+	var subs []*UserSubscription
+	db := DB
+	if params.UserId != 0 {
+		db = db.Where("user_id = ?", params.UserId)
+	}
+	if params.PlanId != 0 {
+		db = db.Where("plan_id = ?", params.PlanId)
+	}
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &subs, allowedUserSubscriptionOrderFields)
+}
+
+// ActivateSubscription 支付成功后开通订阅。已订阅同一套餐时顺延一个周期，已订阅其他套餐时返回错误。
+func ActivateSubscription(userId int, plan *SubscriptionPlan, tradeNo string) (*UserSubscription, error) {
+	var sub *UserSubscription
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sub, err = activateSubscription(tx, userId, plan, tradeNo, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	afterSubscriptionActivated(sub)
+	return sub, nil
+}
+
+// CompletePlanOrder 在同一个事务中把套餐订单标记为成功并开通订阅，订单已被处理过时返回 false。
+// 套餐不存在或已有生效中的其他订阅时订单标记为待退款，并返回 ErrSubscriptionOrderNotActivated。
+func CompletePlanOrder(order *Order, gatewayNo string, plan *SubscriptionPlan) (bool, error) {
+	var sub *UserSubscription
+	var activateErr error
+	completed := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]any{"status": OrderStatusSuccess}
+		if gatewayNo != "" {
+			updates["gateway_no"] = gatewayNo
+		}
+		result := tx.Model(&Order{}).Where("id = ? AND status = ?", order.ID, OrderStatusPending).Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		completed = true
+
+		if plan == nil {
+			activateErr = errors.New("套餐不存在")
+		} else {
+			// 开通失败时只回滚订阅的修改，订单仍记为已支付
+			activateErr = tx.Transaction(func(tx *gorm.DB) error {
+				var err error
+				sub, err = activateSubscription(tx, order.UserId, plan, order.TradeNo, time.Now())
+				return err
+			})
+		}
+		if activateErr != nil {
+			return tx.Model(&Order{}).Where("id = ?", order.ID).Update("status", OrderStatusRefundPending).Error
+		}
+		return nil
+	})
+	if err != nil || !completed {
+		return false, err
+	}
+
+	if gatewayNo != "" {
+		order.GatewayNo = gatewayNo
+	}
+	if activateErr != nil {
+		order.Status = OrderStatusRefundPending
+		return true, fmt.Errorf("%w: %s", ErrSubscriptionOrderNotActivated, activateErr.Error())
+	}
+	order.Status = OrderStatusSuccess
+	afterSubscriptionActivated(sub)
+	return true, nil
+}
+
+func activateSubscription(tx *gorm.DB, userId int, plan *SubscriptionPlan, tradeNo string, now time.Time) (*UserSubscription, error) {
+	sub := &UserSubscription{}
+	err := tx.Where("user_id = ? AND status = ?", userId, SubscriptionStatusActive).First(sub).Error
+	if err == nil {
+		if sub.PlanId != plan.Id {
+			return nil, ErrSubscriptionConflict
+		}
+		// 顺延的周期在当前周期和已预付周期之后开始
+		periodStart := time.Unix(sub.PeriodEnd, 0).AddDate(0, sub.PrepaidPeriods, 0)
+		sub.PrepaidPeriods++
+		sub.AutoRenew = true
+		sub.UpdatedAt = now.Unix()
+		if err := tx.Save(sub).Error; err != nil {
+			return nil, err
+		}
+		bill := sub.newBill(SubscriptionBillSourceGateway, tradeNo, periodStart)
+		bill.Amount = plan.Price
+		return sub, tx.Create(bill).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	sub = &UserSubscription{
+		UserId:    userId,
+		Status:    SubscriptionStatusActive,
+		AutoRenew: true,
+		CreatedAt: now.Unix(),
+	}
+	sub.applyPlan(plan)
+	if err := tx.Create(sub).Error; err != nil {
+		return nil, err
+	}
+	if err := startSubscriptionPeriod(tx, sub, now); err != nil {
+		return nil, err
+	}
+	return sub, tx.Create(sub.newBill(SubscriptionBillSourceGateway, tradeNo, now)).Error
+}
+
+func afterSubscriptionActivated(sub *UserSubscription) {
+	refreshSubscriptionUserCache(sub.UserId)
+	RecordQuotaLog(sub.UserId, LogTypeTopup, sub.Quota, "", fmt.Sprintf("开通订阅「%s」，本周期包含额度 %d", sub.PlanName, sub.Quota))
+}
+
+// startSubscriptionPeriod 开始新的周期：发放包含的额度，记录已用额度基线并切换分组
+func startSubscriptionPeriod(tx *gorm.DB, sub *UserSubscription, start time.Time) error {
+	user := &User{}
+	if err := tx.Select("id", "quota", "used_quota", "group").First(user, "id = ?", sub.UserId).Error; err != nil {
+		return err
+	}
+
+	updates := map[string]any{"quota": gorm.Expr("quota + ?", sub.Quota)}
+	if sub.Group != "" && user.Group != sub.Group {
+		if sub.OriginalGroup == "" {
+			sub.OriginalGroup = user.Group
+		}
+		updates["group"] = sub.Group
+	}
+	if err := tx.Model(&User{}).Where("id = ?", sub.UserId).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	sub.PeriodStart = start.Unix()
+	sub.PeriodEnd = start.AddDate(0, 1, 0).Unix()
+	sub.PeriodBaseUsedQuota = user.UsedQuota
+	sub.UpdatedAt = utils.GetTimestamp()
+	return tx.Save(sub).Error
+}
+
+// settleSubscriptionPeriod 结束当前周期：收回未用完的包含额度并回填账单用量，返回收回的额度
+func settleSubscriptionPeriod(tx *gorm.DB, sub *UserSubscription) (int, error) {
+	user := &User{}
+	if err := tx.Select("id", "quota", "used_quota").First(user, "id = ?", sub.UserId).Error; err != nil {
+		return 0, err
+	}
+
+	usedQuota := max(user.UsedQuota-sub.PeriodBaseUsedQuota, 0)
+	// 余额不足时最多收回到 0，不产生欠费
+	reclaimed := min(max(sub.Quota-usedQuota, 0), max(user.Quota, 0))
+	if reclaimed > 0 {
+		if err := tx.Model(&User{}).Where("id = ?", sub.UserId).Update("quota", gorm.Expr("quota - ?", reclaimed)).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	err := tx.Model(&SubscriptionBill{}).
+		Where("subscription_id = ? AND period_start = ? AND settled = ?", sub.Id, sub.PeriodStart, false).
+		Updates(map[string]any{
+			"used_quota":    usedQuota,
+			"overage_quota": max(usedQuota-sub.Quota, 0),
+			"settled":       true,
+		}).Error
+	return reclaimed, err
+}
+
+// RenewSubscriptions 处理到期的订阅：优先使用预付周期，其次在开启自动续费时从余额扣费，否则订阅过期。
+// 返回续费和过期的数量。
+func RenewSubscriptions(now time.Time) (renewed, expired int, err error) {
+	var subs []*UserSubscription
+	err = DB.Where("status = ? AND period_end <= ?", SubscriptionStatusActive, now.Unix()).Find(&subs).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, sub := range subs {
+		ok, err := renewSubscription(sub, now)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to renew subscription #%d: %s", sub.Id, err.Error()))
+			continue
+		}
+		if ok {
+			renewed++
+		} else {
+			expired++
+		}
+		refreshSubscriptionUserCache(sub.UserId)
+	}
+	return renewed, expired, nil
+}
+
+func renewSubscription(sub *UserSubscription, now time.Time) (renewed bool, err error) {
+	var reclaimed, charged int
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		reclaimed, err = settleSubscriptionPeriod(tx, sub)
+		if err != nil {
+			return err
+		}
+
+		// 周期首尾相接，停机等原因延迟处理时也不会丢失周期
+		start := time.Unix(sub.PeriodEnd, 0)
+		if sub.PrepaidPeriods > 0 {
+			sub.PrepaidPeriods--
+			renewed = true
+			return startSubscriptionPeriod(tx, sub, start)
+		}
+
+		if sub.AutoRenew {
+			plan, err := GetSubscriptionPlanById(sub.PlanId)
+			if err == nil && plan.Enabled {
+				sub.applyPlan(plan)
+				charged = int(plan.Price * config.QuotaPerUnit)
+				result := tx.Model(&User{}).Where("id = ? AND quota >= ?", sub.UserId, charged).Update("quota", gorm.Expr("quota - ?", charged))
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected > 0 {
+					renewed = true
+					if err := tx.Create(sub.newBill(SubscriptionBillSourceBalance, "", start)).Error; err != nil {
+						return err
+					}
+					return startSubscriptionPeriod(tx, sub, start)
+				}
+			}
+			charged = 0
+		}
+
+		return expireSubscription(tx, sub)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if reclaimed > 0 {
+		RecordQuotaLog(sub.UserId, LogTypeSystem, -reclaimed, "", fmt.Sprintf("订阅「%s」周期结束，收回未使用的包含额度 %d", sub.PlanName, reclaimed))
+	}
+	if renewed {
+		content := fmt.Sprintf("订阅「%s」已续费，本周期包含额度 %d", sub.PlanName, sub.Quota)
+		if charged > 0 {
+			content += fmt.Sprintf("，从余额扣除 %d", charged)
+		}
+		RecordQuotaLog(sub.UserId, LogTypeTopup, sub.Quota-charged, "", content)
+	} else {
+		RecordLog(sub.UserId, LogTypeSystem, fmt.Sprintf("订阅「%s」已过期", sub.PlanName))
+	}
+	return renewed, nil
+}
+
+func expireSubscription(tx *gorm.DB, sub *UserSubscription) error {
+	// 用户的分组在订阅期间被管理员修改过时不再恢复
+	if sub.Group != "" && sub.OriginalGroup != "" {
+		err := tx.Model(&User{}).Where("id = ? AND "+quotePostgresField("group")+" = ?", sub.UserId, sub.Group).Update("group", sub.OriginalGroup).Error
+		if err != nil {
+			return err
+		}
+	}
+	sub.Status = SubscriptionStatusExpired
+	sub.UpdatedAt = utils.GetTimestamp()
+	return tx.Save(sub).Error
+}
+
+// cancelOrderSubscription 取消套餐订单对应的订阅周期，返回收回的额度。
+// 订单只预付了后续周期时减少一个预付周期，否则结束当前周期并让订阅过期。
+func cancelOrderSubscription(tx *gorm.DB, order *Order) (int, error) {
+	bill := &SubscriptionBill{}
+	err := tx.Where("trade_no = ? AND source = ?", order.TradeNo, SubscriptionBillSourceGateway).First(bill).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	sub := &UserSubscription{}
+	err = tx.Where("id = ? AND status = ?", bill.SubscriptionId, SubscriptionStatusActive).First(sub).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if bill.PeriodStart > sub.PeriodStart && sub.PrepaidPeriods > 0 {
+		sub.PrepaidPeriods--
+		sub.UpdatedAt = utils.GetTimestamp()
+		return 0, tx.Save(sub).Error
+	}
+
+	reclaimed, err := settleSubscriptionPeriod(tx, sub)
+	if err != nil {
+		return 0, err
+	}
+	sub.PrepaidPeriods = 0
+	return reclaimed, expireSubscription(tx, sub)
+}
+
+// SetSubscriptionAutoRenew 取消或恢复自动续费，取消后当前周期和已预付的周期仍然有效
+func SetSubscriptionAutoRenew(userId int, autoRenew bool) (*UserSubscription, error) {
+	sub, err := GetUserActiveSubscription(userId)
+	if err != nil {
+		return nil, err
+	}
+	sub.AutoRenew = autoRenew
+	sub.UpdatedAt = utils.GetTimestamp()
+	if err := DB.Model(sub).Select("auto_renew", "updated_at").Updates(sub).Error; err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func refreshSubscriptionUserCache(userId int) {
+	if !config.RedisEnabled {
+		return
+	}
+	redis.RedisDel(fmt.Sprintf(UserSubscriptionCacheKey, userId))
+	redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, userId))
+	redis.RedisDel(fmt.Sprintf(UserQuotaCacheKey, userId))
+}
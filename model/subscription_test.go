@@ -0,0 +1,220 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useSubscriptionTestDB(t *testing.T) *SubscriptionPlan {
+	t.Helper()
+
+	logger.Logger = zap.NewNop()
+
+	originalDB := DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&User{}, &Log{}, &SubscriptionPlan{}, &UserSubscription{}, &SubscriptionBill{}, &Order{}); err != nil {
+		t.Fatalf("expected subscription schema migration to succeed, got %v", err)
+	}
+
+	DB = testDB
+	t.Cleanup(func() {
+		DB = originalDB
+	})
+
+	if err := DB.Create(&User{
+		Id:          1,
+		Username:    "alice",
+		Password:    "password123",
+		AccessToken: "access-token-1",
+		AffCode:     "aff-1",
+		Quota:       1000,
+		Group:       "default",
+		Status:      config.UserStatusEnabled,
+		Role:        config.RoleCommonUser,
+	}).Error; err != nil {
+		t.Fatalf("expected user fixture to persist, got %v", err)
+	}
+
+	// 0.001 USD 按默认的 QuotaPerUnit 折合 500 额度
+	plan := &SubscriptionPlan{Name: "pro", Price: 0.001, Quota: 500, Group: "vip", Overage: SubscriptionOverageBalance, Enabled: true}
+	if err := DB.Create(plan).Error; err != nil {
+		t.Fatalf("expected plan fixture to persist, got %v", err)
+	}
+	return plan
+}
+
+func getSubscriptionTestUser(t *testing.T) *User {
+	t.Helper()
+
+	user := &User{}
+	if err := DB.First(user, "id = ?", 1).Error; err != nil {
+		t.Fatalf("expected user to load, got %v", err)
+	}
+	return user
+}
+
+func consumeSubscriptionTestQuota(t *testing.T, quota int) {
+	t.Helper()
+
+	err := DB.Model(&User{}).Where("id = ?", 1).Updates(map[string]any{
+		"quota":      gorm.Expr("quota - ?", quota),
+		"used_quota": gorm.Expr("used_quota + ?", quota),
+	}).Error
+	if err != nil {
+		t.Fatalf("expected usage to be recorded, got %v", err)
+	}
+}
+
+func TestSubscriptionRenewsFromBalanceAndReclaimsUnusedQuota(t *testing.T) {
+	plan := useSubscriptionTestDB(t)
+
+	sub, err := ActivateSubscription(1, plan, "trade-1")
+	if err != nil {
+		t.Fatalf("expected subscription to activate, got %v", err)
+	}
+	if user := getSubscriptionTestUser(t); user.Quota != 1500 || user.Group != "vip" {
+		t.Fatalf("expected included quota and group upgrade, got quota=%d group=%s", user.Quota, user.Group)
+	}
+
+	consumeSubscriptionTestQuota(t, 200)
+
+	renewed, expired, err := RenewSubscriptions(time.Unix(sub.PeriodEnd, 0))
+	if err != nil || renewed != 1 || expired != 0 {
+		t.Fatalf("expected one renewal, got renewed=%d expired=%d err=%v", renewed, expired, err)
+	}
+	// 1300 - 收回未用的 300 - 续费 500 + 新周期 500
+	if user := getSubscriptionTestUser(t); user.Quota != 1000 {
+		t.Fatalf("expected quota 1000 after renewal, got %d", user.Quota)
+	}
+
+	var bills []*SubscriptionBill
+	if err := DB.Order("id asc").Find(&bills).Error; err != nil || len(bills) != 2 {
+		t.Fatalf("expected two bills, got %d, %v", len(bills), err)
+	}
+	if !bills[0].Settled || bills[0].UsedQuota != 200 || bills[1].Source != SubscriptionBillSourceBalance {
+		t.Fatalf("unexpected bills: %+v %+v", bills[0], bills[1])
+	}
+
+	current, err := GetUserActiveSubscription(1)
+	if err != nil || current.PeriodStart != sub.PeriodEnd || current.PeriodBaseUsedQuota != 200 {
+		t.Fatalf("expected next period to start at previous end, got %+v, %v", current, err)
+	}
+}
+
+func TestSubscriptionExpiresAndRestoresGroup(t *testing.T) {
+	plan := useSubscriptionTestDB(t)
+
+	sub, err := ActivateSubscription(1, plan, "trade-1")
+	if err != nil {
+		t.Fatalf("expected subscription to activate, got %v", err)
+	}
+	if _, err := SetSubscriptionAutoRenew(1, false); err != nil {
+		t.Fatalf("expected auto renew to be cancelled, got %v", err)
+	}
+
+	renewed, expired, err := RenewSubscriptions(time.Unix(sub.PeriodEnd, 0))
+	if err != nil || renewed != 0 || expired != 1 {
+		t.Fatalf("expected one expiration, got renewed=%d expired=%d err=%v", renewed, expired, err)
+	}
+	if user := getSubscriptionTestUser(t); user.Quota != 1000 || user.Group != "default" {
+		t.Fatalf("expected unused quota reclaimed and group restored, got quota=%d group=%s", user.Quota, user.Group)
+	}
+	if _, err := GetUserActiveSubscription(1); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected no active subscription, got %v", err)
+	}
+}
+
+func TestSubscriptionPrepaidPeriodRenewsWithoutCharge(t *testing.T) {
+	plan := useSubscriptionTestDB(t)
+
+	sub, err := ActivateSubscription(1, plan, "trade-1")
+	if err != nil {
+		t.Fatalf("expected subscription to activate, got %v", err)
+	}
+	if sub, err = ActivateSubscription(1, plan, "trade-2"); err != nil || sub.PrepaidPeriods != 1 {
+		t.Fatalf("expected second purchase to prepay a period, got %+v, %v", sub, err)
+	}
+
+	other := &SubscriptionPlan{Name: "max", Price: 1, Quota: 1000, Enabled: true}
+	if err := DB.Create(other).Error; err != nil {
+		t.Fatalf("expected plan fixture to persist, got %v", err)
+	}
+	if _, err := ActivateSubscription(1, other, "trade-3"); err == nil {
+		t.Fatal("expected purchase of another plan to be rejected")
+	}
+
+	if renewed, _, err := RenewSubscriptions(time.Unix(sub.PeriodEnd, 0)); err != nil || renewed != 1 {
+		t.Fatalf("expected prepaid renewal, got renewed=%d err=%v", renewed, err)
+	}
+	// 未使用任何额度：1500 - 收回 500 + 新周期 500
+	if user := getSubscriptionTestUser(t); user.Quota != 1500 {
+		t.Fatalf("expected no balance charge for prepaid period, got quota %d", user.Quota)
+	}
+}
+
+func TestCompletePlanOrderMarksConflictForRefund(t *testing.T) {
+	plan := useSubscriptionTestDB(t)
+	if _, err := ActivateSubscription(1, plan, "trade-1"); err != nil {
+		t.Fatalf("expected subscription to activate, got %v", err)
+	}
+
+	other := &SubscriptionPlan{Name: "max", Price: 1, Quota: 1000, Enabled: true}
+	if err := DB.Create(other).Error; err != nil {
+		t.Fatalf("expected plan fixture to persist, got %v", err)
+	}
+	order := &Order{UserId: 1, TradeNo: "trade-2", PlanId: other.Id, Status: OrderStatusPending}
+	if err := DB.Create(order).Error; err != nil {
+		t.Fatalf("expected order fixture to persist, got %v", err)
+	}
+
+	completed, err := CompletePlanOrder(order, "gateway-2", other)
+	if !completed || !errors.Is(err, ErrSubscriptionOrderNotActivated) {
+		t.Fatalf("expected paid order to be kept for refund, got completed=%v err=%v", completed, err)
+	}
+	stored := &Order{}
+	DB.First(stored, "trade_no = ?", "trade-2")
+	if stored.Status != OrderStatusRefundPending || stored.GatewayNo != "gateway-2" {
+		t.Fatalf("expected order to wait for refund, got status=%s gateway_no=%s", stored.Status, stored.GatewayNo)
+	}
+	var count int64
+	DB.Model(&UserSubscription{}).Where("plan_id = ?", other.Id).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no subscription for the conflicting plan, got %d", count)
+	}
+
+	// 重复的回调不会再次处理
+	if completed, err := CompletePlanOrder(order, "gateway-2", other); completed || err != nil {
+		t.Fatalf("expected duplicated callback to be ignored, got completed=%v err=%v", completed, err)
+	}
+}
+
+func TestCheckSubscriptionOverageBlocksWhenIncludedQuotaExhausted(t *testing.T) {
+	plan := useSubscriptionTestDB(t)
+	plan.Overage = SubscriptionOverageBlock
+
+	if _, err := ActivateSubscription(1, plan, "trade-1"); err != nil {
+		t.Fatalf("expected subscription to activate, got %v", err)
+	}
+
+	consumeSubscriptionTestQuota(t, 499)
+	if err := CheckSubscriptionOverage(1); err != nil {
+		t.Fatalf("expected requests to be allowed within included quota, got %v", err)
+	}
+
+	consumeSubscriptionTestQuota(t, 1)
+	if err := CheckSubscriptionOverage(1); !errors.Is(err, ErrSubscriptionQuotaExhausted) {
+		t.Fatalf("expected exhausted subscription to block, got %v", err)
+	}
+}
@@ -46,6 +46,8 @@ type Task struct {
 	Data       datatypes.JSON `json:"data" gorm:"type:json"`
 	NotifyHook string         `json:"notify_hook"`
 	TokenID    int            `json:"token_id" gorm:"default:0"`
+	// 提交时扣费的组织，退款退回这个组织而不是令牌当前绑定的组织
+	OrganizationId int `json:"organization_id" gorm:"default:0"`
 }
 
 func GetTaskByTaskIds(platform string, userId int, taskIds []string) (task []*Task, err error) {
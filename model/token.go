@@ -10,6 +10,8 @@ import (
 	"one-api/common/redis"
 	"one-api/common/stmp"
 	"one-api/common/utils"
+	"slices"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -26,7 +28,7 @@ var (
 type Token struct {
 	Id             int            `json:"id"`
 	UserId         int            `json:"user_id"`
-	Key            string         `json:"key" gorm:"type:varchar(59);uniqueIndex"`
+	Key            string         `json:"key" gorm:"type:varchar(80);uniqueIndex"`
 	Status         int            `json:"status" gorm:"default:1"`
 	Name           string         `json:"name" gorm:"index" `
 	CreatedTime    int64          `json:"created_time" gorm:"bigint"`
@@ -40,6 +42,28 @@ type Token struct {
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 
 	Setting database.JSONType[TokenSetting] `json:"setting" form:"setting" gorm:"type:json"`
+
+	KeyPrefix   string `json:"key_prefix" gorm:"type:varchar(16);default:''"` // key 的可读前缀，便于密钥扫描工具识别
+	KeyVersion  int    `json:"key_version" gorm:"default:0"`                  // key 的轮换次数
+	RotatedTime int64  `json:"rotated_time" gorm:"bigint;default:0"`
+
+	OrganizationId int `json:"organization_id" gorm:"index;default:0"` // 绑定组织后从组织钱包扣费
+
+	SpendWindows []*TokenSpendWindow `json:"spend_windows,omitempty" gorm:"-"` // 当前窗口内的消费情况，仅查询时填充
+	PreviousKeys []*TokenPreviousKey `json:"previous_keys,omitempty" gorm:"-"` // 宽限期内的旧 key，仅查询时填充
+}
+
+// FillSpendWindows 填充令牌当前窗口内的消费情况，未配置消费上限时不填充
+func (token *Token) FillSpendWindows() {
+	setting := token.Setting.Data()
+	windows, err := GetTokenSpendWindows(token.Id, &setting.Limits.LimitsSpendSetting, time.Now())
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to get spend windows of token %d: %s", token.Id, err.Error()))
+		return
+	}
+	if len(windows) > 0 {
+		token.SpendWindows = windows
+	}
 }
 
 var allowedTokenOrderFields = map[string]bool{
@@ -54,7 +78,7 @@ var allowedTokenOrderFields = map[string]bool{
 
 // 添加 AfterCreate 钩子方法
 func (token *Token) AfterCreate(tx *gorm.DB) (err error) {
-	tokenKey, err := common.GenerateToken(token.Id, token.UserId)
+	tokenKey, err := buildTokenKey(token)
 	if err != nil {
 		return err
 	}
@@ -67,6 +91,19 @@ type TokenSetting struct {
 	Heartbeat  HeartbeatSetting `json:"heartbeat,omitempty"`
 	Limits     LimitsConfig     `json:"limits,omitempty"`
 	BillingTag *string          `json:"billing_tag,omitempty"` // 费用标签，用于按分组统计费用，仅可信内部员工和管理员可见
+	// 允许使用的客户端路由提示，仅可信用户和管理员可修改
+	RoutingHints RoutingHintsSetting `json:"routing_hints,omitempty"`
+}
+
+type RoutingHintsSetting struct {
+	Allowed []string `json:"allowed"`
+}
+
+func (setting *TokenSetting) AllowRoutingHint(name string) bool {
+	if setting == nil {
+		return false
+	}
+	return slices.Contains(setting.RoutingHints.Allowed, name)
 }
 
 type HeartbeatSetting struct {
@@ -75,8 +112,14 @@ type HeartbeatSetting struct {
 }
 
 type LimitsConfig struct {
-	LimitModelSetting LimitModelSetting `json:"limit_model_setting,omitempty"`
-	LimitsIPSetting   LimitsIPSetting   `json:"limits_ip_setting,omitempty"`
+	LimitModelSetting  LimitModelSetting  `json:"limit_model_setting,omitempty"`
+	LimitsIPSetting    LimitsIPSetting    `json:"limits_ip_setting,omitempty"`
+	LimitsSpendSetting LimitsSpendSetting `json:"limits_spend_setting,omitempty"`
+	LimitsRateSetting  LimitsRateSetting  `json:"limits_rate_setting,omitempty"`
+	// 令牌的最大并发请求数
+	LimitsConcurrencySetting LimitsConcurrencySetting `json:"limits_concurrency_setting,omitempty"`
+	LimitsScopeSetting       LimitsScopeSetting       `json:"limits_scope_setting,omitempty"`
+	LimitsParamSetting       LimitsParamSetting       `json:"limits_param_setting,omitempty"`
 }
 
 type LimitModelSetting struct {
@@ -84,6 +127,23 @@ type LimitModelSetting struct {
 	Models  []string `json:"models"`
 }
 
+const (
+	TokenRPMLimitKey = "token-rpm:%d"
+	TokenTPMLimitKey = "token-tpm:%d"
+)
+
+// LimitsRateSetting 令牌每分钟的请求数和 token 数上限，0 表示不限制
+type LimitsRateSetting struct {
+	Enabled bool `json:"enabled"`
+	RPM     int  `json:"rpm"`
+	TPM     int  `json:"tpm"`
+}
+
+type LimitsConcurrencySetting struct {
+	Enabled bool `json:"enabled"`
+	Max     int  `json:"max"`
+}
+
 type LimitsIPSetting struct {
 	Enabled   bool     `json:"enabled"`
 	Whitelist []string `json:"whitelist"`
@@ -195,7 +255,7 @@ func GetTokenModel(key string) (token *Token, err error) {
 				return nil, ErrTokenInvalid
 			}
 		}
-	case 59:
+	default:
 		tokenId, userId, err = common.ValidateToken(key)
 		if err != nil || userId == 0 || tokenId == 0 {
 			return nil, ErrTokenInvalid
@@ -203,11 +263,13 @@ func GetTokenModel(key string) (token *Token, err error) {
 		if userEnabled, err := CacheIsUserEnabled(userId); err != nil || !userEnabled {
 			return nil, ErrTokenInvalid
 		}
-	default:
-		return nil, ErrTokenInvalid
 	}
 
 	token, err = CacheGetTokenByKey(key)
+	if err != nil && !validUser {
+		// 轮换后宽限期内的旧 key
+		token, err = GetTokenByPreviousKey(key)
+	}
 	if err != nil {
 		maskedKey := key[:3] + "*********" + key[len(key)-3:]
 		logger.SysError(fmt.Sprintf("DB Not Found: userId=%d, tokenId=%d, key=%s, err=%s", userId, tokenId, maskedKey, err.Error()))
@@ -309,7 +371,7 @@ func (token *Token) Insert() error {
 
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (token *Token) Update() error {
-	err := DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "group", "backup_group", "setting").Updates(token).Error
+	err := DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "group", "backup_group", "setting", "organization_id").Updates(token).Error
 	// 防止Redis缓存不生效，直接删除
 	if err == nil && config.RedisEnabled {
 		redis.RedisDel(fmt.Sprintf(UserTokensKey, token.Key))
@@ -320,7 +382,7 @@ func (token *Token) Update() error {
 
 // UpdateByAdmin 管理员更新token，支持更新user_id字段
 func (token *Token) UpdateByAdmin() error {
-	err := DB.Model(token).Select("user_id", "name", "status", "expired_time", "remain_quota", "unlimited_quota", "group", "backup_group", "setting").Updates(token).Error
+	err := DB.Model(token).Select("user_id", "name", "status", "expired_time", "remain_quota", "unlimited_quota", "group", "backup_group", "setting", "organization_id").Updates(token).Error
 	// 防止Redis缓存不生效，直接删除
 	if err == nil && config.RedisEnabled {
 		redis.RedisDel(fmt.Sprintf(UserTokensKey, token.Key))
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/redis"
+	"one-api/common/utils"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// 旧 key 的最长宽限期
+const TokenRotationMaxGraceSeconds = 30 * 24 * 3600
+
+// 旧 key 最后使用时间的最小更新间隔，避免每次请求都写库
+const tokenKeyTouchInterval = 60
+
+var tokenKeyPrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9]{1,16}$`)
+
+// TokenPreviousKey 令牌轮换后仍在宽限期内有效的旧 key
+type TokenPreviousKey struct {
+	Id           int    `json:"id"`
+	TokenId      int    `json:"token_id" gorm:"index"`
+	Key          string `json:"-" gorm:"type:varchar(80);uniqueIndex"`
+	MaskedKey    string `json:"masked_key" gorm:"-"`
+	ExpiresAt    int64  `json:"expires_at" gorm:"bigint;index"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint;default:0"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+}
+
+func ValidateTokenKeyPrefix(prefix string) error {
+	if prefix != "" && !tokenKeyPrefixPattern.MatchString(prefix) {
+		return errors.New("key 前缀只能包含字母和数字，且不超过 16 个字符")
+	}
+	return nil
+}
+
+func maskTokenKey(key string) string {
+	prefix, rest := common.SplitTokenKeyPrefix(key)
+	if len(rest) < 8 {
+		return key
+	}
+	masked := rest[:4] + "****" + rest[len(rest)-4:]
+	if prefix != "" {
+		return prefix + common.TokenKeyPrefixSeparator + masked
+	}
+	return masked
+}
+
+func buildTokenKey(token *Token) (string, error) {
+	key, err := common.GenerateToken(token.Id, token.UserId, token.KeyVersion)
+	if err != nil {
+		return "", err
+	}
+	if token.KeyPrefix != "" {
+		key = token.KeyPrefix + common.TokenKeyPrefixSeparator + key
+	}
+	return key, nil
+}
+
+// RotateTokenKey 为令牌生成新的 key，旧 key 在 graceSeconds 内仍然有效，为 0 时立即失效。
+// prefix 为 nil 时沿用原来的前缀。
+func RotateTokenKey(token *Token, graceSeconds int, prefix *string) error {
+	if graceSeconds < 0 || graceSeconds > TokenRotationMaxGraceSeconds {
+		return fmt.Errorf("宽限期需要在 0 到 %d 秒之间", TokenRotationMaxGraceSeconds)
+	}
+	if prefix != nil {
+		if err := ValidateTokenKeyPrefix(*prefix); err != nil {
+			return err
+		}
+	}
+
+	oldKey := token.Key
+	now := utils.GetTimestamp()
+	rotated := *token
+	rotated.KeyVersion++
+	if prefix != nil {
+		rotated.KeyPrefix = *prefix
+	}
+	newKey, err := buildTokenKey(&rotated)
+	if err != nil {
+		return err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if graceSeconds > 0 && oldKey != "" {
+			previous := &TokenPreviousKey{
+				TokenId:      token.Id,
+				Key:          oldKey,
+				ExpiresAt:    now + int64(graceSeconds),
+				LastUsedTime: token.AccessedTime,
+				CreatedTime:  now,
+			}
+			if err := tx.Create(previous).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&Token{}).Where("id = ?", token.Id).Updates(map[string]any{
+			"key":          newKey,
+			"key_version":  rotated.KeyVersion,
+			"key_prefix":   rotated.KeyPrefix,
+			"rotated_time": now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	token.Key = newKey
+	token.KeyVersion = rotated.KeyVersion
+	token.KeyPrefix = rotated.KeyPrefix
+	token.RotatedTime = now
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserTokensKey, oldKey))
+	}
+	return nil
+}
+
+// ForceExpireTokenKeys 立即让令牌当前的 key 和所有宽限期内的旧 key 失效，并生成新的 key
+func ForceExpireTokenKeys(token *Token) error {
+	if err := RotateTokenKey(token, 0, nil); err != nil {
+		return err
+	}
+	return DB.Model(&TokenPreviousKey{}).
+		Where("token_id = ? AND expires_at > ?", token.Id, utils.GetTimestamp()).
+		Update("expires_at", utils.GetTimestamp()).Error
+}
+
+// GetTokenPreviousKeys 返回令牌仍在宽限期内的旧 key
+func GetTokenPreviousKeys(tokenId int) ([]*TokenPreviousKey, error) {
+	var keys []*TokenPreviousKey
+	err := DB.Where("token_id = ? AND expires_at > ?", tokenId, utils.GetTimestamp()).Order("id desc").Find(&keys).Error
+	for _, key := range keys {
+		key.MaskedKey = maskTokenKey(key.Key)
+	}
+	return keys, err
+}
+
+// GetTokenByPreviousKey 通过宽限期内的旧 key 查找令牌，并记录旧 key 的最后使用时间
+func GetTokenByPreviousKey(key string) (*Token, error) {
+	keyCol := "`key`"
+	if common.UsingPostgreSQL {
+		keyCol = `"key"`
+	}
+
+	now := utils.GetTimestamp()
+	var previous TokenPreviousKey
+	if err := DB.Where(keyCol+" = ? AND expires_at > ?", key, now).First(&previous).Error; err != nil {
+		return nil, err
+	}
+	if now-previous.LastUsedTime >= tokenKeyTouchInterval {
+		DB.Model(&previous).Update("last_used_time", now)
+	}
+	return GetTokenById(previous.TokenId)
+}
+
+// FillPreviousKeys 填充令牌宽限期内的旧 key，没有时不填充
+func (token *Token) FillPreviousKeys() {
+	keys, err := GetTokenPreviousKeys(token.Id)
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	token.PreviousKeys = keys
+}
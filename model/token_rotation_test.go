@@ -0,0 +1,118 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useTokenRotationTestDB(t *testing.T) *Token {
+	t.Helper()
+
+	logger.Logger = zap.NewNop()
+	originalRedisEnabled := config.RedisEnabled
+	config.RedisEnabled = false
+
+	originalDB := DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&User{}, &Token{}, &TokenPreviousKey{}); err != nil {
+		t.Fatalf("expected token rotation schema migration to succeed, got %v", err)
+	}
+	DB = testDB
+
+	originalTokenSecret := viper.GetString("user_token_secret")
+	viper.Set("user_token_secret", "token-rotation-test-secret")
+	if err := common.InitUserToken(); err != nil {
+		t.Fatalf("expected user-token helpers to initialize, got %v", err)
+	}
+	t.Cleanup(func() {
+		DB = originalDB
+		config.RedisEnabled = originalRedisEnabled
+		viper.Set("user_token_secret", originalTokenSecret)
+		_ = common.InitUserToken()
+	})
+
+	if err := DB.Create(&User{Id: 1, Username: "alice", Password: "password123", AccessToken: "rotation-access", AffCode: "rot", Status: config.UserStatusEnabled}).Error; err != nil {
+		t.Fatalf("expected user fixture, got %v", err)
+	}
+	token := &Token{UserId: 1, Name: "ci", KeyPrefix: "acme", Status: config.TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true}
+	if err := token.Insert(); err != nil {
+		t.Fatalf("expected token fixture, got %v", err)
+	}
+	return token
+}
+
+func TestRotateTokenKeyKeepsOldKeyDuringGrace(t *testing.T) {
+	token := useTokenRotationTestDB(t)
+	oldKey := token.Key
+	if !strings.HasPrefix(oldKey, "acme-") {
+		t.Fatalf("expected key to carry its prefix, got %s", oldKey)
+	}
+	if _, err := ValidateUserToken(oldKey); err != nil {
+		t.Fatalf("expected prefixed key to validate, got %v", err)
+	}
+
+	if err := RotateTokenKey(token, 3600, nil); err != nil {
+		t.Fatalf("expected rotation to succeed, got %v", err)
+	}
+	if token.Key == oldKey || !strings.HasPrefix(token.Key, "acme-") {
+		t.Fatalf("expected a new prefixed key, got %s", token.Key)
+	}
+
+	for _, key := range []string{oldKey, token.Key} {
+		found, err := ValidateUserToken(key)
+		if err != nil || found.Id != token.Id {
+			t.Fatalf("expected key %s to resolve to token %d, got %v %v", key, token.Id, found, err)
+		}
+	}
+
+	keys, err := GetTokenPreviousKeys(token.Id)
+	if err != nil || len(keys) != 1 || keys[0].LastUsedTime == 0 {
+		t.Fatalf("expected old key to be listed with its last use, got %v %v", keys, err)
+	}
+
+	if err := ForceExpireTokenKeys(token); err != nil {
+		t.Fatalf("expected force expire to succeed, got %v", err)
+	}
+	if _, err := ValidateUserToken(oldKey); err == nil {
+		t.Fatal("expected old key to be rejected after force expire")
+	}
+	if _, err := ValidateUserToken(token.Key); err != nil {
+		t.Fatalf("expected replacement key to validate, got %v", err)
+	}
+}
+
+func TestRotateTokenKeyWithoutGraceInvalidatesOldKey(t *testing.T) {
+	token := useTokenRotationTestDB(t)
+	oldKey := token.Key
+
+	prefix := ""
+	if err := RotateTokenKey(token, 0, &prefix); err != nil {
+		t.Fatalf("expected rotation to succeed, got %v", err)
+	}
+	if strings.Contains(token.Key, "-") && strings.Index(token.Key, "-") < strings.Index(token.Key, "_") {
+		t.Fatalf("expected prefix to be removed, got %s", token.Key)
+	}
+	if _, err := ValidateUserToken(oldKey); err == nil {
+		t.Fatal("expected old key to be rejected without grace period")
+	}
+	if err := RotateTokenKey(token, TokenRotationMaxGraceSeconds+1, nil); err == nil {
+		t.Fatal("expected grace period above the maximum to be rejected")
+	}
+	bad := "bad-prefix"
+	if err := RotateTokenKey(token, 0, &bad); err == nil {
+		t.Fatal("expected prefix with separator to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// 令牌可调用的接口范围
+const (
+	TokenScopeChat       = "chat"
+	TokenScopeResponses  = "responses"
+	TokenScopeEmbeddings = "embeddings"
+	TokenScopeImages     = "images"
+	TokenScopeAudio      = "audio"
+	TokenScopeRealtime   = "realtime"
+	TokenScopeTasks      = "tasks"
+	TokenScopeRaw        = "raw"
+)
+
+var TokenScopes = []string{
+	TokenScopeChat,
+	TokenScopeResponses,
+	TokenScopeEmbeddings,
+	TokenScopeImages,
+	TokenScopeAudio,
+	TokenScopeRealtime,
+	TokenScopeTasks,
+	TokenScopeRaw,
+}
+
+// LimitsScopeSetting 令牌允许调用的接口范围，未启用时不限制
+type LimitsScopeSetting struct {
+	Enabled bool     `json:"enabled"`
+	Scopes  []string `json:"scopes"`
+}
+
+func (setting *LimitsScopeSetting) Validate() error {
+	for _, scope := range setting.Scopes {
+		if !slices.Contains(TokenScopes, scope) {
+			return fmt.Errorf("unsupported token scope: %s", scope)
+		}
+	}
+	return nil
+}
+
+// Allows 空的 scope 表示模型列表等不需要授权的接口
+func (setting *LimitsScopeSetting) Allows(scope string) bool {
+	if !setting.Enabled || scope == "" {
+		return true
+	}
+	return slices.Contains(setting.Scopes, scope)
+}
+
+// LimitsParamSetting 请求参数限制，在选择渠道之前生效，0 或空表示不限制。
+// max_tokens 和 n 超出时会被压到上限，图片尺寸、质量不在列表中以及禁用工具时直接拒绝。
+type LimitsParamSetting struct {
+	Enabled        bool     `json:"enabled"`
+	MaxTokens      int      `json:"max_tokens"`
+	MaxN           int      `json:"max_n"`
+	ImageSizes     []string `json:"image_sizes"`
+	ImageQualities []string `json:"image_qualities"`
+	DisableTools   bool     `json:"disable_tools"`
+}
+
+func (setting *LimitsParamSetting) Validate() error {
+	if setting.MaxTokens < 0 || setting.MaxN < 0 {
+		return errors.New("parameter limits must not be negative")
+	}
+	return nil
+}
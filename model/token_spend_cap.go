@@ -0,0 +1,208 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/limit"
+)
+
+const (
+	SpendCapDaily   = "daily"
+	SpendCapWeekly  = "weekly"
+	SpendCapMonthly = "monthly"
+)
+
+var tokenSpendCounter = limit.NewWindowCounter()
+
+// LimitsSpendSetting 令牌在自然日、自然周（周一开始）、自然月内的消费上限，单位 USD，0 表示不限制
+type LimitsSpendSetting struct {
+	Enabled bool    `json:"enabled"`
+	Daily   float64 `json:"daily"`
+	Weekly  float64 `json:"weekly"`
+	Monthly float64 `json:"monthly"`
+}
+
+func (setting *LimitsSpendSetting) Validate() error {
+	if setting.Daily < 0 || setting.Weekly < 0 || setting.Monthly < 0 {
+		return errors.New("spend caps must not be negative")
+	}
+	return nil
+}
+
+type spendCap struct {
+	period string
+	limit  int
+}
+
+func (setting *LimitsSpendSetting) caps() []spendCap {
+	if setting == nil || !setting.Enabled {
+		return nil
+	}
+	caps := make([]spendCap, 0, 3)
+	for _, item := range []struct {
+		period string
+		amount float64
+	}{
+		{SpendCapDaily, setting.Daily},
+		{SpendCapWeekly, setting.Weekly},
+		{SpendCapMonthly, setting.Monthly},
+	} {
+		if item.amount > 0 {
+			caps = append(caps, spendCap{period: item.period, limit: int(item.amount * config.QuotaPerUnit)})
+		}
+	}
+	return caps
+}
+
+// spendWindow 返回 now 所在窗口的起止时间
+func spendWindow(period string, now time.Time) (start, end time.Time) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case SpendCapWeekly:
+		start = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7)
+	case SpendCapMonthly:
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+func tokenSpendKey(tokenId int, period string, start time.Time) string {
+	return fmt.Sprintf("token_spend:%d:%s:%s", tokenId, period, start.Format("20060102"))
+}
+
+// TokenSpendWindow 令牌在当前窗口内的消费情况，Limit 和 Used 为额度
+type TokenSpendWindow struct {
+	Period  string `json:"period"`
+	Limit   int    `json:"limit"`
+	Used    int    `json:"used"`
+	ResetAt int64  `json:"reset_at"`
+}
+
+type TokenSpendCapError struct {
+	Period  string
+	ResetAt time.Time
+}
+
+func (e *TokenSpendCapError) Error() string {
+	names := map[string]string{SpendCapDaily: "每日", SpendCapWeekly: "每周", SpendCapMonthly: "每月"}
+	return fmt.Sprintf("令牌已达到%s消费上限，将于 %s 重置", names[e.Period], e.ResetAt.Format("2006-01-02 15:04:05 MST"))
+}
+
+// RetryAfter 距离重置的秒数
+func (e *TokenSpendCapError) RetryAfter() int {
+	return max(int(time.Until(e.ResetAt).Seconds()), 1)
+}
+
+// GetTokenSpendWindows 返回令牌已配置的每个消费上限在当前窗口内的用量
+func GetTokenSpendWindows(tokenId int, setting *LimitsSpendSetting, now time.Time) ([]*TokenSpendWindow, error) {
+	caps := setting.caps()
+	windows := make([]*TokenSpendWindow, 0, len(caps))
+	for _, item := range caps {
+		start, end := spendWindow(item.period, now)
+		used, err := tokenSpendCounter.Get(tokenSpendKey(tokenId, item.period, start))
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, &TokenSpendWindow{
+			Period:  item.period,
+			Limit:   item.limit,
+			Used:    int(used),
+			ResetAt: end.Unix(),
+		})
+	}
+	return windows, nil
+}
+
+// CheckTokenSpendCaps 本次请求预计消费 quota 后超过任一上限时返回 *TokenSpendCapError。
+// quota 为 0 时只检查上限是否已经用完。只做检查，转发请求时使用 ReserveTokenSpend 预占
+func CheckTokenSpendCaps(tokenId int, setting *LimitsSpendSetting, quota int, now time.Time) error {
+	windows, err := GetTokenSpendWindows(tokenId, setting, now)
+	if err != nil {
+		return err
+	}
+	for _, window := range windows {
+		if window.Used >= window.Limit || (quota > 0 && window.Used+quota > window.Limit) {
+			return &TokenSpendCapError{Period: window.Period, ResetAt: time.Unix(window.ResetAt, 0)}
+		}
+	}
+	return nil
+}
+
+// RecordTokenSpend 结算后累加令牌在各个窗口内的消费，用于没有预占的请求
+func RecordTokenSpend(tokenId int, setting *LimitsSpendSetting, quota int, now time.Time) error {
+	if quota <= 0 {
+		return nil
+	}
+	for _, item := range setting.caps() {
+		start, end := spendWindow(item.period, now)
+		if _, err := tokenSpendCounter.IncrBy(tokenSpendKey(tokenId, item.period, start), int64(quota), end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TokenSpendReservation 请求开始时在各个窗口内预占的消费，结算时按实际消费修正。
+// 记录的是预占时的窗口，跨过窗口边界结算时修正的仍是原来的窗口
+type TokenSpendReservation struct {
+	Quota   int                      `json:"quota"`
+	Windows []TokenSpendReservedSlot `json:"windows"`
+}
+
+type TokenSpendReservedSlot struct {
+	Key      string `json:"key"`
+	ExpireAt int64  `json:"expire_at"`
+}
+
+// ReserveTokenSpend 在每个窗口内原子地预占 quota，任一窗口会超过上限时撤销已预占的部分并返回 *TokenSpendCapError。
+// 没有配置上限时返回 nil。
+func ReserveTokenSpend(tokenId int, setting *LimitsSpendSetting, quota int, now time.Time) (*TokenSpendReservation, error) {
+	caps := setting.caps()
+	if len(caps) == 0 {
+		return nil, nil
+	}
+
+	reservation := &TokenSpendReservation{Quota: max(quota, 0)}
+	for _, item := range caps {
+		start, end := spendWindow(item.period, now)
+		key := tokenSpendKey(tokenId, item.period, start)
+		_, ok, err := tokenSpendCounter.IncrByIfBelow(key, int64(reservation.Quota), int64(item.limit), end)
+		if err == nil && !ok {
+			err = &TokenSpendCapError{Period: item.period, ResetAt: end}
+		}
+		if err != nil {
+			reservation.Release()
+			return nil, err
+		}
+		reservation.Windows = append(reservation.Windows, TokenSpendReservedSlot{Key: key, ExpireAt: end.Unix()})
+	}
+	return reservation, nil
+}
+
+// Settle 把预占的消费修正为实际消费 quota，只能调用一次
+func (r *TokenSpendReservation) Settle(quota int) error {
+	if r == nil {
+		return nil
+	}
+	delta := max(quota, 0) - r.Quota
+	if delta == 0 {
+		return nil
+	}
+	for _, window := range r.Windows {
+		if _, err := tokenSpendCounter.IncrBy(window.Key, int64(delta), time.Unix(window.ExpireAt, 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release 请求失败时退还预占的消费
+func (r *TokenSpendReservation) Release() error {
+	return r.Settle(0)
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"one-api/common/config"
+)
+
+func TestSpendWindowBoundaries(t *testing.T) {
+	// 2026-10-18 是周日
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC)
+
+	cases := map[string][2]time.Time{
+		SpendCapDaily:   {time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		SpendCapWeekly:  {time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		SpendCapMonthly: {time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for period, expected := range cases {
+		start, end := spendWindow(period, now)
+		if !start.Equal(expected[0]) || !end.Equal(expected[1]) {
+			t.Fatalf("%s: expected %v - %v, got %v - %v", period, expected[0], expected[1], start, end)
+		}
+	}
+}
+
+func TestTokenSpendCapsBlockAfterLimit(t *testing.T) {
+	originalRedisEnabled := config.RedisEnabled
+	config.RedisEnabled = false
+	t.Cleanup(func() {
+		config.RedisEnabled = originalRedisEnabled
+	})
+
+	tokenId := 900001
+	setting := &LimitsSpendSetting{Enabled: true, Daily: 1, Monthly: 10}
+	dailyLimit := int(config.QuotaPerUnit)
+	now := time.Now()
+
+	if err := CheckTokenSpendCaps(tokenId, setting, dailyLimit, now); err != nil {
+		t.Fatalf("expected request within daily cap to pass, got %v", err)
+	}
+	if err := RecordTokenSpend(tokenId, setting, dailyLimit-10, now); err != nil {
+		t.Fatalf("expected spend to be recorded, got %v", err)
+	}
+
+	var capErr *TokenSpendCapError
+	if err := CheckTokenSpendCaps(tokenId, setting, 20, now); !errors.As(err, &capErr) || capErr.Period != SpendCapDaily {
+		t.Fatalf("expected daily cap error for estimated overspend, got %v", err)
+	}
+	if err := CheckTokenSpendCaps(tokenId, setting, 0, now); err != nil {
+		t.Fatalf("expected auth check to pass before cap is used up, got %v", err)
+	}
+
+	if err := RecordTokenSpend(tokenId, setting, 10, now); err != nil {
+		t.Fatalf("expected spend to be recorded, got %v", err)
+	}
+	if err := CheckTokenSpendCaps(tokenId, setting, 0, now); !errors.As(err, &capErr) {
+		t.Fatalf("expected exhausted daily cap to block, got %v", err)
+	}
+	_, end := spendWindow(SpendCapDaily, now)
+	if !capErr.ResetAt.Equal(end) {
+		t.Fatalf("expected reset at %v, got %v", end, capErr.ResetAt)
+	}
+
+	windows, err := GetTokenSpendWindows(tokenId, setting, now)
+	if err != nil || len(windows) != 2 || windows[1].Period != SpendCapMonthly || windows[1].Used != dailyLimit {
+		t.Fatalf("expected daily and monthly windows with recorded usage, got %+v, %v", windows, err)
+	}
+
+	if err := CheckTokenSpendCaps(tokenId, &LimitsSpendSetting{Daily: 1}, 0, now); err != nil {
+		t.Fatalf("expected disabled caps to be ignored, got %v", err)
+	}
+}
+
+func TestReserveTokenSpendHoldsCapUntilSettled(t *testing.T) {
+	originalRedisEnabled := config.RedisEnabled
+	config.RedisEnabled = false
+	t.Cleanup(func() {
+		config.RedisEnabled = originalRedisEnabled
+	})
+
+	tokenId := 900002
+	setting := &LimitsSpendSetting{Enabled: true, Daily: 1, Weekly: 10}
+	dailyLimit := int(config.QuotaPerUnit)
+	now := time.Now()
+
+	first, err := ReserveTokenSpend(tokenId, setting, dailyLimit-100, now)
+	if err != nil || first == nil || len(first.Windows) != 2 {
+		t.Fatalf("expected first request to reserve both windows, got %+v, %v", first, err)
+	}
+	// 并发的第二个请求在第一个结算前就会被拦截
+	var capErr *TokenSpendCapError
+	if _, err := ReserveTokenSpend(tokenId, setting, 200, now); !errors.As(err, &capErr) || capErr.Period != SpendCapDaily {
+		t.Fatalf("expected concurrent request to hit the daily cap, got %v", err)
+	}
+	windows, _ := GetTokenSpendWindows(tokenId, setting, now)
+	if windows[1].Used != dailyLimit-100 {
+		t.Fatalf("expected rejected reservation to be rolled back, got %+v", windows[1])
+	}
+
+	// 实际消费少于预估时退还差额
+	if err := first.Settle(dailyLimit - 300); err != nil {
+		t.Fatalf("expected reservation to settle, got %v", err)
+	}
+	second, err := ReserveTokenSpend(tokenId, setting, 200, now)
+	if err != nil {
+		t.Fatalf("expected request to fit after settlement, got %v", err)
+	}
+	if err := second.Release(); err != nil {
+		t.Fatalf("expected reservation to be released, got %v", err)
+	}
+	windows, _ = GetTokenSpendWindows(tokenId, setting, now)
+	if windows[0].Used != dailyLimit-300 {
+		t.Fatalf("expected released reservation not to count, got %+v", windows[0])
+	}
+}
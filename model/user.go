@@ -43,6 +43,7 @@ type User struct {
 	AffQuota         int            `json:"aff_quota" gorm:"type:int;default:0;column:aff_quota"`
 	AffHistoryQuota  int            `json:"aff_history_quota" gorm:"type:int;default:0;column:aff_history"`
 	InviterId        int            `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
+	MaxConcurrency   int            `json:"max_concurrency" gorm:"type:int;default:0"` // 最大并发请求数，0 跟随分组，-1 不限制
 	LastLoginTime    int64          `json:"last_login_time" gorm:"bigint;default:0"`
 	LastLoginIp      string         `json:"last_login_ip" gorm:"type:varchar(128);default:''"`
 	CreatedTime      int64          `json:"created_time" gorm:"bigint"`
@@ -146,6 +147,7 @@ func (user *User) Insert(inviterId int) error {
 		RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("新用户注册赠送 %s", common.LogQuota(config.QuotaForNewUser)))
 	}
 	if inviterId != 0 {
+		_ = DB.Model(&User{}).Where("id = ?", inviterId).Update("aff_count", gorm.Expr("aff_count + 1")).Error
 		if config.QuotaForInvitee > 0 {
 			_ = IncreaseUserQuota(user.Id, config.QuotaForInvitee)
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", common.LogQuota(config.QuotaForInvitee)))
@@ -180,11 +182,26 @@ func (user *User) Update(updatePassword bool) error {
 	// 删除缓存
 	if config.RedisEnabled {
 		redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, user.Id))
+		redis.RedisDel(fmt.Sprintf(UserConcurrencyCacheKey, user.Id))
 	}
 
 	return err
 }
 
+// UpdateUserMaxConcurrency 单独更新最大并发数，Updates 不会写入零值
+func UpdateUserMaxConcurrency(id int, maxConcurrency int) error {
+	err := DB.Model(&User{}).Where("id = ?", id).Update("max_concurrency", maxConcurrency).Error
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserConcurrencyCacheKey, id))
+	}
+	return err
+}
+
+func GetUserMaxConcurrency(id int) (maxConcurrency int, err error) {
+	err = DB.Model(&User{}).Where("id = ?", id).Select("max_concurrency").Find(&maxConcurrency).Error
+	return maxConcurrency, err
+}
+
 func UpdateUser(id int, fields map[string]interface{}) error {
 	return DB.Model(&User{}).Where("id = ?", id).Updates(fields).Error
 }
@@ -19,6 +19,8 @@ type UserGroup struct {
 	Min       int     `json:"min" form:"min" gorm:"default:0"`                 // 晋级条件最小值
 	Max       int     `json:"max" form:"max" gorm:"default:0"`                 // 晋级条件最大值
 	Enable    *bool   `json:"enable" form:"enable" gorm:"default:true"`        // 是否启用
+	// 组内每个用户的最大并发请求数，0 表示不限制
+	MaxConcurrency int `json:"max_concurrency" form:"max_concurrency" gorm:"default:0"`
 }
 
 type SearchUserGroupParams struct {
@@ -74,7 +76,7 @@ func (c *UserGroup) Create() error {
 }
 
 func (c *UserGroup) Update() error {
-	err := DB.Select("name", "ratio", "public", "api_rate", "promotion", "min", "max").Updates(c).Error
+	err := DB.Select("name", "ratio", "public", "api_rate", "promotion", "min", "max", "max_concurrency").Updates(c).Error
 	if err == nil {
 		GlobalUserGroupRatio.Load()
 	}
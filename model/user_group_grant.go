@@ -0,0 +1,140 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/common/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	UserGroupGrantStatusActive  = "active"
+	UserGroupGrantStatusExpired = "expired"
+)
+
+// UserGroupGrant 限时的分组升级，到期后恢复为升级前的分组
+type UserGroupGrant struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Group         string `json:"group" gorm:"type:varchar(32)"`
+	OriginalGroup string `json:"original_group" gorm:"type:varchar(32);default:''"`
+	RedemptionId  int    `json:"redemption_id" gorm:"default:0"`
+	Status        string `json:"status" gorm:"type:varchar(16);index"`
+	ExpiredTime   int64  `json:"expired_time" gorm:"bigint;index"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime   int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// grantUserGroup 在事务中把用户升级到 group，已有同一分组的升级时顺延有效期。
+// 已有其他分组的升级或带分组的订阅生效中时返回错误，避免到期时恢复成错误的分组；
+// 目标分组的倍率不低于当前分组时也返回错误，避免把用户降级。
+func grantUserGroup(tx *gorm.DB, userId int, group string, days int, redemptionId int, now time.Time) (*UserGroupGrant, error) {
+	var subCount int64
+	err := tx.Model(&UserSubscription{}).Where("user_id = ? AND status = ? AND "+quotePostgresField("group")+" != ''", userId, SubscriptionStatusActive).Count(&subCount).Error
+	if err != nil {
+		return nil, err
+	}
+	if subCount > 0 {
+		return nil, errors.New("订阅生效期间不能使用分组升级兑换码")
+	}
+
+	duration := time.Duration(days) * 24 * time.Hour
+	grant := &UserGroupGrant{}
+	err = tx.Where("user_id = ? AND status = ?", userId, UserGroupGrantStatusActive).First(grant).Error
+	if err == nil {
+		if grant.Group != group {
+			return nil, errors.New("已有生效中的其他分组升级")
+		}
+		grant.ExpiredTime = time.Unix(grant.ExpiredTime, 0).Add(duration).Unix()
+		grant.UpdatedTime = now.Unix()
+		return grant, tx.Save(grant).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	user := &User{}
+	if err := tx.Select("id", "group").First(user, "id = ?", userId).Error; err != nil {
+		return nil, err
+	}
+	if !isUserGroupUpgrade(user.Group, group) {
+		return nil, fmt.Errorf("当前分组 %s 已不低于分组 %s", user.Group, group)
+	}
+	grant = &UserGroupGrant{
+		UserId:        userId,
+		Group:         group,
+		OriginalGroup: user.Group,
+		RedemptionId:  redemptionId,
+		Status:        UserGroupGrantStatusActive,
+		ExpiredTime:   now.Add(duration).Unix(),
+		CreatedTime:   now.Unix(),
+		UpdatedTime:   now.Unix(),
+	}
+	if err := tx.Create(grant).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&User{}).Where("id = ?", userId).Update("group", group).Error; err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// isUserGroupUpgrade 按倍率判断 target 是否优于 current，当前分组已不存在时视为升级
+func isUserGroupUpgrade(current, target string) bool {
+	if current == target {
+		return false
+	}
+	targetGroup := GlobalUserGroupRatio.GetBySymbol(target)
+	if targetGroup == nil {
+		return false
+	}
+	currentGroup := GlobalUserGroupRatio.GetBySymbol(current)
+	if currentGroup == nil {
+		return true
+	}
+	return targetGroup.Ratio < currentGroup.Ratio
+}
+
+// ExpireUserGroupGrants 恢复到期的分组升级，返回处理的数量
+func ExpireUserGroupGrants(now time.Time) (int, error) {
+	var grants []*UserGroupGrant
+	err := DB.Where("status = ? AND expired_time <= ?", UserGroupGrantStatusActive, now.Unix()).Find(&grants).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, grant := range grants {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			// 用户的分组在升级期间被修改过时不再恢复
+			err := tx.Model(&User{}).Where("id = ? AND "+quotePostgresField("group")+" = ?", grant.UserId, grant.Group).Update("group", grant.OriginalGroup).Error
+			if err != nil {
+				return err
+			}
+			grant.Status = UserGroupGrantStatusExpired
+			grant.UpdatedTime = utils.GetTimestamp()
+			return tx.Save(grant).Error
+		})
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to expire group grant #%d: %s", grant.Id, err.Error()))
+			continue
+		}
+		expired++
+		refreshUserGroupCache(grant.UserId)
+		RecordLog(grant.UserId, LogTypeSystem, fmt.Sprintf("分组 %s 的限时升级已到期", grant.Group))
+	}
+	return expired, nil
+}
+
+func refreshUserGroupCache(userId int) {
+	if !config.RedisEnabled {
+		return
+	}
+	redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, userId))
+}
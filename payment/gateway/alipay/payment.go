@@ -1,11 +1,13 @@
 package alipay
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"one-api/model"
 	"one-api/payment/types"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/smartwalle/alipay/v3"
@@ -94,6 +96,74 @@ func (a *Alipay) HandleCallback(c *gin.Context, gatewayConfig string) (*types.Pa
 	return nil, fmt.Errorf("trade status not success")
 }
 
+func (a *Alipay) Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error) {
+	alipayConfig, err := getAlipayConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		err := a.InitClient(alipayConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var p = alipay.TradeRefund{}
+	p.OutTradeNo = config.TradeNo
+	p.RefundAmount = strconv.FormatFloat(config.RefundMoney, 'f', 2, 64)
+	p.RefundReason = config.Reason
+	p.OutRequestNo = config.RefundNo
+	alipayRes, err := client.TradeRefund(context.Background(), p)
+	if err != nil {
+		return nil, fmt.Errorf("alipay trade refund failed: %s", err.Error())
+	}
+	if !alipayRes.IsSuccess() {
+		return nil, fmt.Errorf("alipay trade refund failed: %s", alipayRes.SubMsg)
+	}
+
+	// 支付宝以 out_request_no 标识退款，不另外返回退款单号
+	return &types.RefundResult{}, nil
+}
+
+func (a *Alipay) Query(config *types.QueryConfig, gatewayConfig string) (*types.QueryResult, error) {
+	alipayConfig, err := getAlipayConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		err := a.InitClient(alipayConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	alipayRes, err := client.TradeQuery(context.Background(), alipay.TradeQuery{OutTradeNo: config.TradeNo})
+	if err != nil {
+		return nil, fmt.Errorf("alipay trade query failed: %s", err.Error())
+	}
+	if !alipayRes.IsSuccess() {
+		// 买家还没有扫码时支付宝不会创建交易
+		if alipayRes.SubCode == "ACQ.TRADE_NOT_EXIST" {
+			return &types.QueryResult{Status: types.QueryStatusPending}, nil
+		}
+		return nil, fmt.Errorf("alipay trade query failed: %s", alipayRes.SubMsg)
+	}
+
+	result := &types.QueryResult{GatewayNo: alipayRes.TradeNo}
+	switch alipayRes.TradeStatus {
+	case alipay.TradeStatusSuccess, alipay.TradeStatusFinished:
+		result.Status = types.QueryStatusPaid
+		result.Money, _ = strconv.ParseFloat(alipayRes.TotalAmount, 64)
+	case alipay.TradeStatusClosed:
+		result.Status = types.QueryStatusClosed
+	default:
+		result.Status = types.QueryStatusPending
+	}
+	return result, nil
+}
+
 func getAlipayConfig(gatewayConfig string) (*AlipayConfig, error) {
 	var alipayConfig AlipayConfig
 	if err := json.Unmarshal([]byte(gatewayConfig), &alipayConfig); err != nil {
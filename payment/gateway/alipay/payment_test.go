@@ -0,0 +1,87 @@
+package alipay
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"one-api/payment/types"
+
+	"github.com/smartwalle/alipay/v3"
+)
+
+func generateTestKey(t *testing.T) (*rsa.PrivateKey, string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected rsa key, got %v", err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicBytes, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes})
+	return key, string(privateKey), string(publicKey)
+}
+
+func TestAlipayRefundVerifiesSignedResponse(t *testing.T) {
+	_, appPrivateKey, _ := generateTestKey(t)
+	alipayKey, _, alipayPublicKey := generateTestKey(t)
+
+	var form map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		// 模拟支付宝用自己的私钥对业务数据签名
+		biz := `{"code":"10000","msg":"Success","trade_no":"2026101822001","out_trade_no":"T1","fund_change":"Y","refund_fee":"5.00"}`
+		hashed := sha256.Sum256([]byte(biz))
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, alipayKey, crypto.SHA256, hashed[:])
+		fmt.Fprintf(w, `{"alipay_trade_refund_response":%s,"sign":"%s"}`, biz, base64.StdEncoding.EncodeToString(signature))
+	}))
+	defer server.Close()
+
+	testClient, err := alipay.New("app", appPrivateKey, isProduction, alipay.WithProductionGateway(server.URL))
+	if err != nil {
+		t.Fatalf("expected alipay client, got %v", err)
+	}
+	if err := testClient.LoadAliPayPublicKey(alipayPublicKey); err != nil {
+		t.Fatalf("expected alipay public key, got %v", err)
+	}
+	originalClient := client
+	client = testClient
+	t.Cleanup(func() {
+		client = originalClient
+	})
+
+	gatewayConfig, _ := json.Marshal(AlipayConfig{AppID: "app"})
+	_, err = (&Alipay{}).Refund(&types.RefundConfig{TradeNo: "T1", RefundNo: "R1", RefundMoney: 5, Reason: "duplicate"}, string(gatewayConfig))
+	if err != nil {
+		t.Fatalf("expected refund to succeed, got %v", err)
+	}
+	if form["method"] != "alipay.trade.refund" {
+		t.Fatalf("expected refund api, got %q", form["method"])
+	}
+	var bizContent map[string]any
+	json.Unmarshal([]byte(form["biz_content"]), &bizContent)
+	if bizContent["out_trade_no"] != "T1" || bizContent["out_request_no"] != "R1" || bizContent["refund_amount"] != "5.00" {
+		t.Fatalf("unexpected refund biz content: %v", bizContent)
+	}
+
+	// 签名不匹配的响应必须被拒绝
+	_, _, otherPublicKey := generateTestKey(t)
+	if err := testClient.LoadAliPayPublicKey(otherPublicKey); err != nil {
+		t.Fatalf("expected alipay public key, got %v", err)
+	}
+	if _, err := (&Alipay{}).Refund(&types.RefundConfig{TradeNo: "T1", RefundNo: "R2", RefundMoney: 5}, string(gatewayConfig)); err == nil {
+		t.Fatal("expected refund with invalid signature to fail")
+	}
+}
@@ -3,8 +3,13 @@ package epay
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 )
@@ -78,3 +83,57 @@ func (c *Client) Sign(args map[string]string) string {
 
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Refund 调用接口原路退款，code 为 1 表示退款成功
+func (c *Client) Refund(args *RefundArgs) error {
+	form := url.Values{
+		"pid":          {c.PartnerID},
+		"key":          {c.Key},
+		"out_trade_no": {args.OutTradeNo},
+		"money":        {args.Money},
+	}
+	if args.TradeNo != "" {
+		form.Set("trade_no", args.TradeNo)
+	}
+
+	domain := strings.TrimSuffix(c.PayDomain, "/")
+	resp, err := httpClient.PostForm(domain+RefundUrl, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result RefundResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("epay refund response error: %v", err)
+	}
+	if result.Code != 1 {
+		return fmt.Errorf("epay refund failed: %s", result.Msg)
+	}
+	return nil
+}
+
+// QueryOrder 查询订单，订单不存在时 code 不为 1
+func (c *Client) QueryOrder(outTradeNo string) (*OrderResult, error) {
+	query := url.Values{
+		"act":          {"order"},
+		"pid":          {c.PartnerID},
+		"key":          {c.Key},
+		"out_trade_no": {outTradeNo},
+	}
+
+	domain := strings.TrimSuffix(c.PayDomain, "/")
+	resp, err := httpClient.Get(domain + QueryUrl + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result OrderResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("epay query response error: %v", err)
+	}
+	return &result, nil
+}
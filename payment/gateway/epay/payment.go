@@ -82,6 +82,48 @@ func (e *Epay) HandleCallback(c *gin.Context, gatewayConfig string) (*types.PayN
 	return nil, fmt.Errorf("tradeNo: %s, PaymentNo: %s,  Verify Sign failed", queryMap["out_trade_no"], queryMap["trade_no"])
 }
 
+func (e *Epay) Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error) {
+	epayConfig, err := getEpayConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	err = epayConfig.Refund(&RefundArgs{
+		OutTradeNo: config.TradeNo,
+		TradeNo:    config.GatewayNo,
+		Money:      strconv.FormatFloat(config.RefundMoney, 'f', 2, 64),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 易支付不返回退款单号
+	return &types.RefundResult{}, nil
+}
+
+func (e *Epay) Query(config *types.QueryConfig, gatewayConfig string) (*types.QueryResult, error) {
+	epayConfig, err := getEpayConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := epayConfig.QueryOrder(config.TradeNo)
+	if err != nil {
+		return nil, err
+	}
+	// 用户没有打开收银台时易支付不会创建订单
+	if order.Code != 1 || order.Status != 1 {
+		return &types.QueryResult{Status: types.QueryStatusPending}, nil
+	}
+
+	money, _ := strconv.ParseFloat(order.Money, 64)
+	return &types.QueryResult{
+		Status:    types.QueryStatusPaid,
+		GatewayNo: order.TradeNo,
+		Money:     money,
+	}, nil
+}
+
 func getEpayConfig(gatewayConfig string) (*EpayConfig, error) {
 	var epayConfig EpayConfig
 	if err := json.Unmarshal([]byte(gatewayConfig), &epayConfig); err != nil {
@@ -0,0 +1,78 @@
+package epay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"one-api/payment/types"
+)
+
+func TestEpayRefundPostsToRefundAPI(t *testing.T) {
+	var form map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api.php" || r.URL.Query().Get("act") != "refund" {
+			t.Errorf("unexpected refund url: %s", r.URL.String())
+		}
+		r.ParseForm()
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		if form["money"] == "99.00" {
+			w.Write([]byte(`{"code":-1,"msg":"退款金额超出"}`))
+			return
+		}
+		w.Write([]byte(`{"code":1,"msg":"退款成功"}`))
+	}))
+	defer server.Close()
+
+	gatewayConfig, _ := json.Marshal(EpayConfig{Client: Client{PayDomain: server.URL + "/", PartnerID: "1001", Key: "secret"}})
+	epay := &Epay{}
+
+	_, err := epay.Refund(&types.RefundConfig{TradeNo: "T1", GatewayNo: "G1", RefundMoney: 5.5}, string(gatewayConfig))
+	if err != nil {
+		t.Fatalf("expected refund to succeed, got %v", err)
+	}
+	if form["pid"] != "1001" || form["key"] != "secret" || form["out_trade_no"] != "T1" || form["trade_no"] != "G1" || form["money"] != "5.50" {
+		t.Fatalf("unexpected refund form: %v", form)
+	}
+
+	if _, err := epay.Refund(&types.RefundConfig{TradeNo: "T1", RefundMoney: 99}, string(gatewayConfig)); err == nil {
+		t.Fatal("expected refund failure to be returned")
+	}
+}
+
+func TestEpayQueryReportsPaidOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("act") != "order" || query.Get("pid") != "1001" {
+			t.Errorf("unexpected query url: %s", r.URL.String())
+		}
+		switch query.Get("out_trade_no") {
+		case "PAID":
+			w.Write([]byte(`{"code":1,"trade_no":"G1","out_trade_no":"PAID","money":"10.00","status":1}`))
+		case "UNPAID":
+			w.Write([]byte(`{"code":1,"trade_no":"G2","out_trade_no":"UNPAID","money":"10.00","status":0}`))
+		default:
+			w.Write([]byte(`{"code":-1,"msg":"订单号不存在"}`))
+		}
+	}))
+	defer server.Close()
+
+	gatewayConfig, _ := json.Marshal(EpayConfig{Client: Client{PayDomain: server.URL + "/", PartnerID: "1001", Key: "secret"}})
+	epay := &Epay{}
+
+	result, err := epay.Query(&types.QueryConfig{TradeNo: "PAID"}, string(gatewayConfig))
+	if err != nil || result.Status != types.QueryStatusPaid || result.GatewayNo != "G1" || result.Money != 10 {
+		t.Fatalf("expected paid order, got %+v, %v", result, err)
+	}
+
+	for _, tradeNo := range []string{"UNPAID", "MISSING"} {
+		result, err = epay.Query(&types.QueryConfig{TradeNo: tradeNo}, string(gatewayConfig))
+		if err != nil || result.Status != types.QueryStatusPending {
+			t.Fatalf("expected %s to stay pending, got %+v, %v", tradeNo, result, err)
+		}
+	}
+}
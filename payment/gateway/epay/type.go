@@ -23,6 +23,8 @@ var (
 const (
 	FormArgsSignType   = "MD5"
 	FormSubmitUrl      = "/submit.php"
+	RefundUrl          = "/api.php?act=refund"
+	QueryUrl           = "/api.php"
 	TradeStatusSuccess = "TRADE_SUCCESS"
 )
 
@@ -43,3 +45,23 @@ type PaymentResult struct {
 	Money       string  `mapstructure:"money"`
 	TradeStatus string  `mapstructure:"trade_status"`
 }
+
+type RefundArgs struct {
+	OutTradeNo string `json:"out_trade_no"`
+	TradeNo    string `json:"trade_no"`
+	Money      string `json:"money"`
+}
+
+type RefundResult struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+type OrderResult struct {
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+	TradeNo    string `json:"trade_no"`
+	OutTradeNo string `json:"out_trade_no"`
+	Money      string `json:"money"`
+	Status     int    `json:"status"` // 1 为已支付
+}
@@ -0,0 +1,188 @@
+package paypal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	liveAPIBase    = "https://api-m.paypal.com"
+	sandboxAPIBase = "https://api-m.sandbox.paypal.com"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+type Client struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Mode         Mode   `json:"mode"`
+}
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// access token 有效期通常为 9 小时，按 API 地址和 ClientID 缓存
+var (
+	tokenCache = make(map[string]*cachedToken)
+	tokenLock  sync.Mutex
+)
+
+func (c *Client) baseURL() string {
+	if c.Mode == Sandbox {
+		return sandboxAPIBase
+	}
+	return liveAPIBase
+}
+
+func (c *Client) getAccessToken() (string, error) {
+	key := c.baseURL() + "|" + c.ClientID
+	tokenLock.Lock()
+	defer tokenLock.Unlock()
+
+	if cached, ok := tokenCache[key]; ok && time.Now().Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL()+"/v1/oauth2/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.ClientID, c.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token TokenResponse
+	if err := c.do(req, &token); err != nil {
+		return "", fmt.Errorf("paypal get access token failed: %v", err)
+	}
+	// 提前一分钟过期，避免请求途中失效
+	tokenCache[key] = &cachedToken{
+		token:     token.AccessToken,
+		expiresAt: time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute),
+	}
+	return token.AccessToken, nil
+}
+
+// request 发送带 access token 的 JSON 请求，requestId 不为空时作为幂等键
+func (c *Client) request(method, path string, body any, requestId string, result any) error {
+	token, err := c.getAccessToken()
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL()+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	if requestId != "" {
+		req.Header.Set(PayPalRequestIdHeader, requestId)
+	}
+	return c.do(req, result)
+}
+
+func (c *Client) do(req *http.Request, result any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var errResp ErrorResponse
+		if json.Unmarshal(data, &errResp) == nil {
+			if len(errResp.Details) > 0 {
+				return fmt.Errorf("%s: %s", errResp.Details[0].Issue, errResp.Details[0].Description)
+			}
+			if errResp.Message != "" {
+				return fmt.Errorf("%s: %s", errResp.Name, errResp.Message)
+			}
+			if errResp.Error != "" {
+				return fmt.Errorf("%s", errResp.Error)
+			}
+		}
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (c *Client) CreateOrder(orderReq *OrderRequest) (*Order, error) {
+	var order Order
+	if err := c.request(http.MethodPost, "/v2/checkout/orders", orderReq, "", &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (c *Client) GetOrder(orderId string) (*Order, error) {
+	var order Order
+	if err := c.request(http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(orderId), nil, "", &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CaptureOrder 扣款，使用订单号作为幂等键，返回页和 Webhook 重复扣款时返回同一结果
+func (c *Client) CaptureOrder(orderId string) (*Order, error) {
+	var order Order
+	if err := c.request(http.MethodPost, "/v2/checkout/orders/"+url.PathEscape(orderId)+"/capture", struct{}{}, "capture-"+orderId, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (c *Client) RefundCapture(captureId, refundNo string, refundReq *RefundRequest) (*Refund, error) {
+	var refund Refund
+	if err := c.request(http.MethodPost, "/v2/payments/captures/"+url.PathEscape(captureId)+"/refund", refundReq, refundNo, &refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (c *Client) VerifyWebhookSignature(verifyReq *VerifyWebhookRequest) (bool, error) {
+	var result VerifyWebhookResponse
+	if err := c.request(http.MethodPost, "/v1/notifications/verify-webhook-signature", verifyReq, "", &result); err != nil {
+		return false, err
+	}
+	return result.VerificationStatus == VerificationStatusSuccess, nil
+}
+
+func (c *Client) ListWebhooks() ([]Webhook, error) {
+	var list WebhookList
+	if err := c.request(http.MethodGet, "/v1/notifications/webhooks", nil, "", &list); err != nil {
+		return nil, err
+	}
+	return list.Webhooks, nil
+}
+
+func (c *Client) CreateWebhook(webhook *Webhook) (*Webhook, error) {
+	var created Webhook
+	if err := c.request(http.MethodPost, "/v1/notifications/webhooks", webhook, "", &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
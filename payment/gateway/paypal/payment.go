@@ -0,0 +1,388 @@
+package paypal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-api/model"
+	"one-api/payment/types"
+	"strconv"
+	"strings"
+
+	sysconfig "one-api/common/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PayPal struct{}
+
+type PayPalConfig struct {
+	Client
+	WebhookID string `json:"webhook_id"`
+}
+
+func (p *PayPal) Name() string {
+	return "PayPal"
+}
+
+// currencyCode PayPal 使用 ISO 4217 货币代码，和 CurrencyType 的取值一致
+func currencyCode(currency model.CurrencyType) string {
+	if currency == "" {
+		return string(model.CurrencyTypeUSD)
+	}
+	return string(currency)
+}
+
+func formatMoney(money float64) string {
+	return strconv.FormatFloat(money, 'f', 2, 64)
+}
+
+// Pay 创建 PayPal 订单，买家确认后跳回通知地址完成扣款
+func (p *PayPal) Pay(config *types.PayConfig, gatewayConfig string) (*types.PayRequest, error) {
+	paypalConfig, err := getPayPalConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := &Money{
+		CurrencyCode: currencyCode(config.Currency),
+		Value:        formatMoney(config.Money),
+	}
+	orderReq := &OrderRequest{
+		Intent: OrderIntentCapture,
+		PurchaseUnits: []PurchaseUnit{
+			{
+				ReferenceID: config.TradeNo,
+				CustomID:    config.TradeNo,
+				InvoiceID:   config.TradeNo,
+				Description: sysconfig.SystemName + "-Token充值:" + amount.Value + " " + amount.CurrencyCode,
+				Amount:      amount,
+			},
+		},
+		PaymentSource: &PaymentSource{
+			PayPal: &PayPalSource{
+				ExperienceContext: &ExperienceContext{
+					BrandName:          sysconfig.SystemName,
+					ShippingPreference: ExperienceShippingNoShipping,
+					UserAction:         ExperienceUserActionPayNow,
+					ReturnURL:          config.NotifyURL,
+					CancelURL:          config.ReturnURL,
+				},
+			},
+		},
+	}
+
+	order, err := paypalConfig.CreateOrder(orderReq)
+	if err != nil {
+		return nil, fmt.Errorf("paypal create order failed: %s", err.Error())
+	}
+
+	var approveURL string
+	for _, link := range order.Links {
+		if link.Rel == LinkRelPayerAction || link.Rel == LinkRelApprove {
+			approveURL = link.Href
+			break
+		}
+	}
+	if approveURL == "" {
+		return nil, errors.New("paypal create order failed: missing approve link")
+	}
+
+	// 前端以 GET 表单提交，需要把链接中的参数拆出来
+	payURL, params, err := extractURLAndParams(approveURL)
+	if err != nil {
+		return nil, fmt.Errorf("paypal create order failed: %s", err.Error())
+	}
+	payRequest := &types.PayRequest{
+		Type:      1,
+		GatewayNo: order.ID,
+		Data: types.PayRequestData{
+			URL:    payURL,
+			Params: params,
+			Method: http.MethodGet,
+		},
+	}
+	return payRequest, nil
+}
+
+// CreatedPay 在 PayPal 后台创建 Webhook 并保存 Webhook ID，用于验证通知签名
+func (p *PayPal) CreatedPay(notifyURL string, gatewayConfig *model.Payment) error {
+	paypalConfig, err := getPayPalConfig(gatewayConfig.Config)
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := paypalConfig.ListWebhooks()
+	if err != nil {
+		return fmt.Errorf("error listing webhooks: %v", err)
+	}
+	webhookID := ""
+	for _, webhook := range webhooks {
+		if webhook.URL == notifyURL {
+			webhookID = webhook.ID
+			break
+		}
+	}
+
+	if webhookID == "" {
+		webhook := &Webhook{URL: notifyURL}
+		for _, event := range WebhookEvents {
+			webhook.EventTypes = append(webhook.EventTypes, WebhookEventType{Name: event})
+		}
+		created, err := paypalConfig.CreateWebhook(webhook)
+		if err != nil {
+			return fmt.Errorf("error creating webhook: %v", err)
+		}
+		webhookID = created.ID
+	}
+	if webhookID == paypalConfig.WebhookID {
+		return nil
+	}
+
+	paypalConfig.WebhookID = webhookID
+	config, err := json.Marshal(paypalConfig)
+	if err != nil {
+		return fmt.Errorf("error creating webhook: %v", err)
+	}
+	gatewayConfig.Config = string(config)
+	return gatewayConfig.Update(true)
+}
+
+// HandleCallback GET 为买家确认后的跳转，需要扣款后跳回面板；POST 为 Webhook 通知
+func (p *PayPal) HandleCallback(c *gin.Context, gatewayConfig string) (*types.PayNotify, error) {
+	paypalConfig, err := getPayPalConfig(gatewayConfig)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, err
+	}
+
+	if c.Request.Method == http.MethodGet {
+		return p.handleReturn(c, paypalConfig)
+	}
+	return p.handleWebhook(c, paypalConfig)
+}
+
+func (p *PayPal) handleReturn(c *gin.Context, paypalConfig *PayPalConfig) (*types.PayNotify, error) {
+	returnURL := strings.TrimSuffix(sysconfig.ServerAddress, "/") + "/panel/log"
+	defer c.Redirect(http.StatusFound, returnURL)
+
+	orderID := c.Query("token")
+	if orderID == "" {
+		return nil, errors.New("PayPal return missing order token")
+	}
+	order, err := paypalConfig.CaptureOrder(orderID)
+	if err != nil {
+		return nil, fmt.Errorf("PayPal capture order %s failed: %v", orderID, err)
+	}
+	return payNotifyFromOrder(order)
+}
+
+func (p *PayPal) handleWebhook(c *gin.Context, paypalConfig *PayPalConfig) (*types.PayNotify, error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, fmt.Errorf("failed to read request body: %v", err)
+	}
+	if paypalConfig.WebhookID == "" {
+		c.Status(http.StatusBadRequest)
+		return nil, errors.New("PayPal webhook id not configured")
+	}
+
+	verified, err := paypalConfig.VerifyWebhookSignature(&VerifyWebhookRequest{
+		AuthAlgo:         c.GetHeader("PAYPAL-AUTH-ALGO"),
+		CertURL:          c.GetHeader("PAYPAL-CERT-URL"),
+		TransmissionID:   c.GetHeader("PAYPAL-TRANSMISSION-ID"),
+		TransmissionSig:  c.GetHeader("PAYPAL-TRANSMISSION-SIG"),
+		TransmissionTime: c.GetHeader("PAYPAL-TRANSMISSION-TIME"),
+		WebhookID:        paypalConfig.WebhookID,
+		WebhookEvent:     body,
+	})
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return nil, fmt.Errorf("PayPal verify webhook failed: %v", err)
+	}
+	if !verified {
+		c.Status(http.StatusBadRequest)
+		return nil, errors.New("PayPal webhook signature verification failed")
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, fmt.Errorf("failed to parse webhook event: %v", err)
+	}
+
+	switch event.EventType {
+	case EventCheckoutOrderApproved:
+		// 买家确认后没有跳回时，由 Webhook 完成扣款
+		var order Order
+		if err := json.Unmarshal(event.Resource, &order); err != nil {
+			c.Status(http.StatusBadRequest)
+			return nil, fmt.Errorf("failed to parse order data: %v", err)
+		}
+		captured, err := paypalConfig.CaptureOrder(order.ID)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return nil, fmt.Errorf("PayPal capture order %s failed: %v", order.ID, err)
+		}
+		return payNotifyFromOrder(captured)
+	case EventPaymentCaptureCompleted:
+		var capture Capture
+		if err := json.Unmarshal(event.Resource, &capture); err != nil {
+			c.Status(http.StatusBadRequest)
+			return nil, fmt.Errorf("failed to parse capture data: %v", err)
+		}
+		if capture.CustomID == "" {
+			return nil, nil
+		}
+		return &types.PayNotify{
+			TradeNo:   capture.CustomID,
+			GatewayNo: capture.ID,
+		}, nil
+	case EventCustomerDisputeCreated:
+		var dispute Dispute
+		if err := json.Unmarshal(event.Resource, &dispute); err != nil {
+			c.Status(http.StatusBadRequest)
+			return nil, fmt.Errorf("failed to parse dispute data: %v", err)
+		}
+		if len(dispute.DisputedTransactions) == 0 || dispute.DisputedTransactions[0].SellerTransactionID == "" {
+			return nil, fmt.Errorf("dispute %s has no seller transaction", dispute.DisputeID)
+		}
+		amount := 0.0
+		if dispute.DisputeAmount != nil {
+			amount, _ = strconv.ParseFloat(dispute.DisputeAmount.Value, 64)
+		}
+		// 订单的 GatewayNo 为扣款 ID，即争议中的 seller_transaction_id
+		return &types.PayNotify{
+			GatewayNo: dispute.DisputedTransactions[0].SellerTransactionID,
+			Refund: &types.RefundNotify{
+				RefundNo:   dispute.DisputeID,
+				Amount:     amount,
+				Reason:     dispute.Reason,
+				Chargeback: true,
+			},
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// payNotifyFromOrder 从扣款结果中取出订单号和扣款 ID，扣款未完成时返回错误
+func payNotifyFromOrder(order *Order) (*types.PayNotify, error) {
+	for _, unit := range order.PurchaseUnits {
+		if unit.Payments == nil {
+			continue
+		}
+		for _, capture := range unit.Payments.Captures {
+			if capture.Status != CaptureStatusCompleted {
+				return nil, fmt.Errorf("PayPal capture %s status: %s", capture.ID, capture.Status)
+			}
+			tradeNo := capture.CustomID
+			if tradeNo == "" {
+				tradeNo = unit.ReferenceID
+			}
+			return &types.PayNotify{
+				TradeNo:   tradeNo,
+				GatewayNo: capture.ID,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("PayPal order %s has no capture, status: %s", order.ID, order.Status)
+}
+
+// Query 按下单时记录的 PayPal 订单号查询，买家已确认但还没有扣款时直接扣款
+func (p *PayPal) Query(config *types.QueryConfig, gatewayConfig string) (*types.QueryResult, error) {
+	paypalConfig, err := getPayPalConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+	if config.GatewayNo == "" {
+		return nil, errors.New("missing paypal order id")
+	}
+
+	order, err := paypalConfig.GetOrder(config.GatewayNo)
+	if err != nil {
+		return nil, fmt.Errorf("paypal query order failed: %s", err.Error())
+	}
+
+	switch order.Status {
+	case OrderStatusApproved:
+		order, err = paypalConfig.CaptureOrder(order.ID)
+		if err != nil {
+			return nil, fmt.Errorf("PayPal capture order %s failed: %v", config.GatewayNo, err)
+		}
+	case OrderStatusVoided:
+		return &types.QueryResult{Status: types.QueryStatusClosed}, nil
+	case OrderStatusCompleted:
+	default:
+		return &types.QueryResult{Status: types.QueryStatusPending}, nil
+	}
+
+	payNotify, err := payNotifyFromOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	result := &types.QueryResult{
+		Status:    types.QueryStatusPaid,
+		GatewayNo: payNotify.GatewayNo,
+	}
+	for _, unit := range order.PurchaseUnits {
+		if unit.Payments != nil && len(unit.Payments.Captures) > 0 && unit.Payments.Captures[0].Amount != nil {
+			result.Money, _ = strconv.ParseFloat(unit.Payments.Captures[0].Amount.Value, 64)
+		}
+	}
+	return result, nil
+}
+
+// Refund 按扣款 ID 退款，退款单号作为幂等键
+func (p *PayPal) Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error) {
+	paypalConfig, err := getPayPalConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+	if config.GatewayNo == "" {
+		return nil, errors.New("missing capture id")
+	}
+
+	refund, err := paypalConfig.RefundCapture(config.GatewayNo, config.RefundNo, &RefundRequest{
+		Amount: &Money{
+			CurrencyCode: currencyCode(config.Currency),
+			Value:        formatMoney(config.RefundMoney),
+		},
+		NoteToPayer: config.Reason,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("paypal refund failed: %s", err.Error())
+	}
+	if refund.Status == RefundStatusCancelled || refund.Status == RefundStatusFailed {
+		return nil, fmt.Errorf("paypal refund failed: %s", refund.Status)
+	}
+
+	return &types.RefundResult{GatewayRefundNo: refund.ID}, nil
+}
+
+func getPayPalConfig(gatewayConfig string) (*PayPalConfig, error) {
+	var paypalConfig PayPalConfig
+	if err := json.Unmarshal([]byte(gatewayConfig), &paypalConfig); err != nil {
+		return nil, errors.New("config error")
+	}
+
+	return &paypalConfig, nil
+}
+
+// extractURLAndParams 拆分链接中的网址和参数
+func extractURLAndParams(rawURL string) (string, map[string]string, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	baseURL := fmt.Sprintf("%s://%s%s", parsedURL.Scheme, parsedURL.Host, parsedURL.Path)
+	params := make(map[string]string)
+	for key, values := range parsedURL.Query() {
+		params[key] = values[0]
+	}
+	return baseURL, params, nil
+}
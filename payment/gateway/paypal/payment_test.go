@@ -0,0 +1,182 @@
+package paypal
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"one-api/model"
+	"one-api/payment/types"
+
+	sysconfig "one-api/common/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// usePayPalTestServer 用本地服务模拟 PayPal 沙箱接口，token 接口由这里统一处理
+func usePayPalTestServer(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/oauth2/token" {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "client" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"token-1","expires_in":32400}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			t.Errorf("expected bearer token on %s", r.URL.Path)
+		}
+		handler(w, r)
+	}))
+
+	originalBase := sandboxAPIBase
+	sandboxAPIBase = server.URL
+	tokenCache = make(map[string]*cachedToken)
+	t.Cleanup(func() {
+		server.Close()
+		sandboxAPIBase = originalBase
+		tokenCache = make(map[string]*cachedToken)
+	})
+
+	gatewayConfig, _ := json.Marshal(PayPalConfig{
+		Client:    Client{ClientID: "client", ClientSecret: "secret", Mode: Sandbox},
+		WebhookID: "WH-1",
+	})
+	return string(gatewayConfig)
+}
+
+func TestPayPalPayCreatesOrderWithCurrency(t *testing.T) {
+	var orderReq OrderRequest
+	gatewayConfig := usePayPalTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/checkout/orders" {
+			t.Errorf("unexpected paypal path: %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&orderReq)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"O-1","status":"PAYER_ACTION_REQUIRED","links":[{"href":"https://www.sandbox.paypal.com/checkoutnow?token=O-1","rel":"payer-action","method":"GET"}]}`))
+	})
+
+	payRequest, err := (&PayPal{}).Pay(&types.PayConfig{
+		TradeNo:   "T1",
+		Money:     12.5,
+		Currency:  model.CurrencyTypeCNY,
+		NotifyURL: "https://example.com/api/payment/notify/uuid",
+		ReturnURL: "https://example.com/panel/log",
+	}, gatewayConfig)
+	if err != nil {
+		t.Fatalf("expected order to be created, got %v", err)
+	}
+
+	unit := orderReq.PurchaseUnits[0]
+	if orderReq.Intent != OrderIntentCapture || unit.CustomID != "T1" || unit.Amount.CurrencyCode != "CNY" || unit.Amount.Value != "12.50" {
+		t.Fatalf("unexpected order request: %+v", orderReq)
+	}
+	if orderReq.PaymentSource.PayPal.ExperienceContext.ReturnURL != "https://example.com/api/payment/notify/uuid" {
+		t.Fatalf("expected buyer to return to notify url, got %+v", orderReq.PaymentSource.PayPal.ExperienceContext)
+	}
+	params := payRequest.Data.Params.(map[string]string)
+	if payRequest.Data.URL != "https://www.sandbox.paypal.com/checkoutnow" || params["token"] != "O-1" {
+		t.Fatalf("expected approve link split into url and params, got %+v", payRequest.Data)
+	}
+}
+
+func TestPayPalReturnCapturesOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var requestId string
+	gatewayConfig := usePayPalTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/checkout/orders/O-1/capture" {
+			t.Errorf("unexpected paypal path: %s", r.URL.Path)
+		}
+		requestId = r.Header.Get(PayPalRequestIdHeader)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"O-1","status":"COMPLETED","purchase_units":[{"reference_id":"T1","payments":{"captures":[{"id":"CAP-1","status":"COMPLETED","custom_id":"T1"}]}}]}`))
+	})
+	originalAddress := sysconfig.ServerAddress
+	sysconfig.ServerAddress = "https://example.com/"
+	t.Cleanup(func() {
+		sysconfig.ServerAddress = originalAddress
+	})
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/payment/notify/uuid?token=O-1&PayerID=P1", nil)
+
+	payNotify, err := (&PayPal{}).HandleCallback(c, gatewayConfig)
+	if err != nil {
+		t.Fatalf("expected capture to succeed, got %v", err)
+	}
+	if payNotify.TradeNo != "T1" || payNotify.GatewayNo != "CAP-1" {
+		t.Fatalf("unexpected pay notify: %+v", payNotify)
+	}
+	if requestId != "capture-O-1" {
+		t.Fatalf("expected capture to be idempotent per order, got %q", requestId)
+	}
+	if recorder.Code != http.StatusFound || recorder.Header().Get("Location") != "https://example.com/panel/log" {
+		t.Fatalf("expected redirect back to panel, got %d %s", recorder.Code, recorder.Header().Get("Location"))
+	}
+}
+
+func TestPayPalWebhookVerifiesSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	verification := "SUCCESS"
+	var verifyReq VerifyWebhookRequest
+	gatewayConfig := usePayPalTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/notifications/verify-webhook-signature" {
+			t.Errorf("unexpected paypal path: %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&verifyReq)
+		w.Write([]byte(`{"verification_status":"` + verification + `"}`))
+	})
+
+	event := `{"id":"WH-EVT-1","event_type":"CUSTOMER.DISPUTE.CREATED","resource":{"dispute_id":"PP-D-1","reason":"UNAUTHORISED","dispute_amount":{"currency_code":"USD","value":"8.00"},"disputed_transactions":[{"seller_transaction_id":"CAP-1"}]}}`
+	callback := func() (*types.PayNotify, int, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/payment/notify/uuid", bytes.NewReader([]byte(event)))
+		c.Request.Header.Set("PAYPAL-TRANSMISSION-ID", "tx-1")
+		c.Request.Header.Set("PAYPAL-TRANSMISSION-SIG", "sig")
+		payNotify, err := (&PayPal{}).HandleCallback(c, gatewayConfig)
+		return payNotify, c.Writer.Status(), err
+	}
+
+	payNotify, _, err := callback()
+	if err != nil {
+		t.Fatalf("expected verified dispute to be accepted, got %v", err)
+	}
+	if verifyReq.WebhookID != "WH-1" || verifyReq.TransmissionID != "tx-1" || string(verifyReq.WebhookEvent) != event {
+		t.Fatalf("unexpected verify request: %+v", verifyReq)
+	}
+	if payNotify.GatewayNo != "CAP-1" || payNotify.Refund == nil || payNotify.Refund.RefundNo != "PP-D-1" || payNotify.Refund.Amount != 8 || !payNotify.Refund.Chargeback {
+		t.Fatalf("unexpected dispute notify: %+v", payNotify)
+	}
+
+	verification = "FAILURE"
+	if _, status, err := callback(); err == nil || status != http.StatusBadRequest {
+		t.Fatalf("expected unverified webhook to be rejected, got %v %d", err, status)
+	}
+}
+
+func TestPayPalRefundCapture(t *testing.T) {
+	var refundReq RefundRequest
+	var requestId string
+	gatewayConfig := usePayPalTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/payments/captures/CAP-1/refund" {
+			t.Errorf("unexpected paypal path: %s", r.URL.Path)
+		}
+		requestId = r.Header.Get(PayPalRequestIdHeader)
+		json.NewDecoder(r.Body).Decode(&refundReq)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"REF-1","status":"COMPLETED"}`))
+	})
+
+	result, err := (&PayPal{}).Refund(&types.RefundConfig{GatewayNo: "CAP-1", RefundNo: "R1", RefundMoney: 3, Currency: model.CurrencyTypeUSD}, gatewayConfig)
+	if err != nil {
+		t.Fatalf("expected refund to succeed, got %v", err)
+	}
+	if result.GatewayRefundNo != "REF-1" || requestId != "R1" || refundReq.Amount.Value != "3.00" || refundReq.Amount.CurrencyCode != "USD" {
+		t.Fatalf("unexpected refund: %+v %q %+v", result, requestId, refundReq.Amount)
+	}
+}
@@ -0,0 +1,166 @@
+package paypal
+
+import "encoding/json"
+
+type Mode string
+
+var (
+	Live    Mode = "live"    // 正式环境
+	Sandbox Mode = "sandbox" // 沙箱环境
+)
+
+const (
+	OrderIntentCapture           = "CAPTURE"
+	ExperienceUserActionPayNow   = "PAY_NOW"
+	ExperienceShippingNoShipping = "NO_SHIPPING"
+	LinkRelPayerAction           = "payer-action"
+	LinkRelApprove               = "approve"
+
+	OrderStatusApproved       = "APPROVED"
+	OrderStatusCompleted      = "COMPLETED"
+	OrderStatusVoided         = "VOIDED"
+	CaptureStatusCompleted    = "COMPLETED"
+	RefundStatusCancelled     = "CANCELLED"
+	RefundStatusFailed        = "FAILED"
+	VerificationStatusSuccess = "SUCCESS"
+
+	PayPalRequestIdHeader = "PayPal-Request-Id"
+)
+
+const (
+	EventCheckoutOrderApproved   = "CHECKOUT.ORDER.APPROVED"
+	EventPaymentCaptureCompleted = "PAYMENT.CAPTURE.COMPLETED"
+	EventCustomerDisputeCreated  = "CUSTOMER.DISPUTE.CREATED"
+)
+
+// 需要订阅的 Webhook 事件
+var WebhookEvents = []string{EventCheckoutOrderApproved, EventPaymentCaptureCompleted, EventCustomerDisputeCreated}
+
+type Money struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type ExperienceContext struct {
+	BrandName          string `json:"brand_name,omitempty"`
+	ShippingPreference string `json:"shipping_preference,omitempty"`
+	UserAction         string `json:"user_action,omitempty"`
+	ReturnURL          string `json:"return_url,omitempty"`
+	CancelURL          string `json:"cancel_url,omitempty"`
+}
+
+type PaymentSource struct {
+	PayPal *PayPalSource `json:"paypal,omitempty"`
+}
+
+type PayPalSource struct {
+	ExperienceContext *ExperienceContext `json:"experience_context,omitempty"`
+}
+
+type PurchaseUnit struct {
+	ReferenceID string                `json:"reference_id,omitempty"`
+	CustomID    string                `json:"custom_id,omitempty"`
+	InvoiceID   string                `json:"invoice_id,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Amount      *Money                `json:"amount,omitempty"`
+	Payments    *PurchaseUnitPayments `json:"payments,omitempty"`
+}
+
+type PurchaseUnitPayments struct {
+	Captures []Capture `json:"captures,omitempty"`
+}
+
+type Capture struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	CustomID string `json:"custom_id,omitempty"`
+	Amount   *Money `json:"amount,omitempty"`
+}
+
+type OrderRequest struct {
+	Intent        string         `json:"intent"`
+	PurchaseUnits []PurchaseUnit `json:"purchase_units"`
+	PaymentSource *PaymentSource `json:"payment_source,omitempty"`
+}
+
+type Link struct {
+	Href   string `json:"href"`
+	Rel    string `json:"rel"`
+	Method string `json:"method,omitempty"`
+}
+
+type Order struct {
+	ID            string         `json:"id"`
+	Status        string         `json:"status"`
+	PurchaseUnits []PurchaseUnit `json:"purchase_units,omitempty"`
+	Links         []Link         `json:"links,omitempty"`
+}
+
+type RefundRequest struct {
+	Amount      *Money `json:"amount,omitempty"`
+	InvoiceID   string `json:"invoice_id,omitempty"`
+	NoteToPayer string `json:"note_to_payer,omitempty"`
+}
+
+type Refund struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type ErrorResponse struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+	Details []struct {
+		Issue       string `json:"issue"`
+		Description string `json:"description"`
+	} `json:"details,omitempty"`
+}
+
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"event_type"`
+	Resource  json.RawMessage `json:"resource"`
+}
+
+type VerifyWebhookRequest struct {
+	AuthAlgo         string          `json:"auth_algo"`
+	CertURL          string          `json:"cert_url"`
+	TransmissionID   string          `json:"transmission_id"`
+	TransmissionSig  string          `json:"transmission_sig"`
+	TransmissionTime string          `json:"transmission_time"`
+	WebhookID        string          `json:"webhook_id"`
+	WebhookEvent     json.RawMessage `json:"webhook_event"`
+}
+
+type VerifyWebhookResponse struct {
+	VerificationStatus string `json:"verification_status"`
+}
+
+type WebhookEventType struct {
+	Name string `json:"name"`
+}
+
+type Webhook struct {
+	ID         string             `json:"id,omitempty"`
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"event_types"`
+}
+
+type WebhookList struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type Dispute struct {
+	DisputeID            string `json:"dispute_id"`
+	Reason               string `json:"reason"`
+	DisputeAmount        *Money `json:"dispute_amount,omitempty"`
+	DisputedTransactions []struct {
+		SellerTransactionID string `json:"seller_transaction_id"`
+	} `json:"disputed_transactions,omitempty"`
+}
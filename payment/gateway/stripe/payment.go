@@ -9,6 +9,7 @@ import (
 	"strconv"
 
 	sysconfig "one-api/common/config"
+	"one-api/common/logger"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v80"
@@ -74,7 +75,8 @@ func (e *Stripe) Pay(config *types.PayConfig, gatewayConfig string) (*types.PayR
 	}
 	// 构造支付请求
 	payRequest := &types.PayRequest{
-		Type: 1,
+		Type:      1,
+		GatewayNo: result.ID,
 		Data: types.PayRequestData{
 			URL: result.URL,
 			Params: map[string]interface{}{
@@ -87,8 +89,10 @@ func (e *Stripe) Pay(config *types.PayConfig, gatewayConfig string) (*types.PayR
 	return payRequest, nil
 }
 
+// 需要订阅的 Webhook 事件，支付完成和拒付
+var webhookEvents = []string{"checkout.session.completed", "charge.dispute.created"}
+
 func (e *Stripe) CreatedPay(notifyURL string, gatewayConfig *model.Payment) error {
-	eventName := "checkout.session.completed"
 	var stripeConfig StripeConfig
 	err := json.Unmarshal([]byte(gatewayConfig.Config), &stripeConfig)
 	if err != nil {
@@ -103,7 +107,7 @@ func (e *Stripe) CreatedPay(notifyURL string, gatewayConfig *model.Payment) erro
 	var existingWebhook *stripe.WebhookEndpoint
 	for i.Next() {
 		webhook := i.WebhookEndpoint()
-		if webhook.URL == notifyURL && contains(webhook.EnabledEvents, eventName) {
+		if webhook.URL == notifyURL && contains(webhook.EnabledEvents, webhookEvents[0]) {
 			existingWebhook = webhook
 			break
 		}
@@ -117,11 +121,9 @@ func (e *Stripe) CreatedPay(notifyURL string, gatewayConfig *model.Payment) erro
 
 	if existingWebhook == nil {
 		createParams := &stripe.WebhookEndpointParams{
-			URL: stripe.String(notifyURL),
-			EnabledEvents: []*string{
-				stripe.String(eventName),
-			},
-			APIVersion: stripe.String("2024-09-30.acacia"),
+			URL:           stripe.String(notifyURL),
+			EnabledEvents: stripe.StringSlice(webhookEvents),
+			APIVersion:    stripe.String("2024-09-30.acacia"),
 		}
 		newWebhook, err := webhookendpoint.New(createParams)
 		if err != nil {
@@ -129,11 +131,25 @@ func (e *Stripe) CreatedPay(notifyURL string, gatewayConfig *model.Payment) erro
 		}
 		wh = newWebhook
 		fmt.Printf("Created new webhook: %s\n", newWebhook.ID)
+	} else if !containsAll(existingWebhook.EnabledEvents, webhookEvents) {
+		// 旧版本创建的 Webhook 只订阅了支付完成事件，补充订阅拒付事件
+		updated, err := webhookendpoint.Update(existingWebhook.ID, &stripe.WebhookEndpointParams{
+			EnabledEvents: stripe.StringSlice(webhookEvents),
+		})
+		if err != nil {
+			return fmt.Errorf("error updating webhook: %v", err)
+		}
+		logger.SysLog(fmt.Sprintf("Updated stripe webhook events: %s", updated.ID))
+		wh = updated
 	} else {
 		fmt.Printf("Webhook already exists: %s\n", existingWebhook.ID)
 		wh = existingWebhook
 	}
 
+	// 只有新建时才会返回密钥
+	if wh.Secret == "" {
+		return nil
+	}
 	stripeConfig.WebhookSecret = wh.Secret
 	config, err := json.Marshal(stripeConfig)
 	if err != nil {
@@ -158,6 +174,80 @@ func contains(slice []string, str string) bool {
 	return false
 }
 
+func containsAll(slice []string, strs []string) bool {
+	for _, str := range strs {
+		if !contains(slice, str) {
+			return false
+		}
+	}
+	return true
+}
+
+// Refund 按 PaymentIntent 退款，退款单号作为幂等键避免重复退款
+func (e *Stripe) Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error) {
+	var stripeConfig StripeConfig
+	if err := json.Unmarshal([]byte(gatewayConfig), &stripeConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse gateway config: %v", err)
+	}
+	if config.GatewayNo == "" {
+		return nil, fmt.Errorf("missing payment intent")
+	}
+
+	sc := &client.API{}
+	sc.Init(stripeConfig.SecretKey, nil)
+
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(config.GatewayNo),
+		Amount:        stripe.Int64(int64(math.Round(config.RefundMoney * 100))),
+		Metadata: map[string]string{
+			"trade_no":  config.TradeNo,
+			"refund_no": config.RefundNo,
+			"reason":    config.Reason,
+		},
+	}
+	params.SetIdempotencyKey(config.RefundNo)
+	refund, err := sc.Refunds.New(params)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status == stripe.RefundStatusFailed || refund.Status == stripe.RefundStatusCanceled {
+		return nil, fmt.Errorf("stripe refund %s: %s", refund.Status, refund.FailureReason)
+	}
+
+	return &types.RefundResult{GatewayRefundNo: refund.ID}, nil
+}
+
+// Query 按下单时记录的 Checkout Session 查询支付状态
+func (e *Stripe) Query(config *types.QueryConfig, gatewayConfig string) (*types.QueryResult, error) {
+	var stripeConfig StripeConfig
+	if err := json.Unmarshal([]byte(gatewayConfig), &stripeConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse gateway config: %v", err)
+	}
+	if config.GatewayNo == "" {
+		return nil, fmt.Errorf("missing checkout session")
+	}
+
+	sc := &client.API{}
+	sc.Init(stripeConfig.SecretKey, nil)
+	session, err := sc.CheckoutSessions.Get(config.GatewayNo, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.QueryResult{Status: types.QueryStatusPending}
+	switch {
+	case session.PaymentStatus == stripe.CheckoutSessionPaymentStatusPaid:
+		result.Status = types.QueryStatusPaid
+		result.Money = float64(session.AmountTotal) / 100
+		if session.PaymentIntent != nil {
+			result.GatewayNo = session.PaymentIntent.ID
+		}
+	case session.Status == stripe.CheckoutSessionStatusExpired:
+		result.Status = types.QueryStatusClosed
+	}
+	return result, nil
+}
+
 // HandleCallback 处理支付回调
 func (e *Stripe) HandleCallback(c *gin.Context, gatewayConfig string) (*types.PayNotify, error) {
 	body, err := c.GetRawData()
@@ -199,6 +289,25 @@ func (e *Stripe) HandleCallback(c *gin.Context, gatewayConfig string) (*types.Pa
 		}
 
 		return payNotify, nil
+	case "charge.dispute.created":
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(event.Data.Raw, &dispute); err != nil {
+			return nil, fmt.Errorf("failed to parse dispute data: %v", err)
+		}
+		if dispute.PaymentIntent == nil {
+			return nil, fmt.Errorf("dispute %s has no payment intent", dispute.ID)
+		}
+
+		// 拒付按争议金额扣回，订单通过 PaymentIntent 查找
+		return &types.PayNotify{
+			GatewayNo: dispute.PaymentIntent.ID,
+			Refund: &types.RefundNotify{
+				RefundNo:   dispute.ID,
+				Amount:     float64(dispute.Amount) / 100,
+				Reason:     string(dispute.Reason),
+				Chargeback: true,
+			},
+		}, nil
 	default:
 		return nil, nil
 	}
@@ -0,0 +1,92 @@
+package stripe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"one-api/payment/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v80"
+	"github.com/stripe/stripe-go/v80/webhook"
+)
+
+func TestStripeRefundUsesPaymentIntentAndIdempotencyKey(t *testing.T) {
+	var form map[string]string
+	var idempotencyKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/refunds" {
+			t.Errorf("unexpected stripe path: %s", r.URL.Path)
+		}
+		r.ParseForm()
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		idempotencyKey = r.Header.Get("Idempotency-Key")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"re_123","object":"refund","amount":1250,"status":"succeeded"}`))
+	}))
+	defer server.Close()
+
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(server.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+	}))
+	t.Cleanup(func() {
+		stripe.SetBackend(stripe.APIBackend, nil)
+	})
+
+	result, err := (&Stripe{}).Refund(&types.RefundConfig{
+		TradeNo:     "T1",
+		GatewayNo:   "pi_123",
+		RefundNo:    "R1",
+		TotalMoney:  20,
+		RefundMoney: 12.5,
+	}, `{"secret_key":"sk_test"}`)
+	if err != nil {
+		t.Fatalf("expected refund to succeed, got %v", err)
+	}
+	if result.GatewayRefundNo != "re_123" {
+		t.Fatalf("expected stripe refund id, got %q", result.GatewayRefundNo)
+	}
+	if form["payment_intent"] != "pi_123" || form["amount"] != "1250" || form["metadata[trade_no]"] != "T1" {
+		t.Fatalf("unexpected refund params: %v", form)
+	}
+	if idempotencyKey != "R1" {
+		t.Fatalf("expected refund number as idempotency key, got %q", idempotencyKey)
+	}
+}
+
+func TestStripeDisputeWebhookReturnsChargeback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := "whsec_test"
+	payload := fmt.Sprintf(`{"id":"evt_1","object":"event","api_version":%q,"type":"charge.dispute.created","data":{"object":{"id":"dp_1","object":"dispute","amount":1000,"reason":"fraudulent","status":"needs_response","payment_intent":"pi_123"}}}`, stripe.APIVersion)
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   []byte(payload),
+		Secret:    secret,
+		Timestamp: time.Now(),
+	})
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/payment/notify/uuid", bytes.NewReader(signed.Payload))
+	c.Request.Header.Set("Stripe-Signature", signed.Header)
+
+	gatewayConfig, _ := json.Marshal(StripeConfig{SecretKey: "sk_test", WebhookSecret: secret})
+	payNotify, err := (&Stripe{}).HandleCallback(c, string(gatewayConfig))
+	if err != nil {
+		t.Fatalf("expected dispute webhook to be accepted, got %v", err)
+	}
+	if payNotify == nil || payNotify.Refund == nil {
+		t.Fatal("expected dispute to be returned as refund notify")
+	}
+	if payNotify.GatewayNo != "pi_123" || payNotify.Refund.RefundNo != "dp_1" || payNotify.Refund.Amount != 10 || !payNotify.Refund.Chargeback {
+		t.Fatalf("unexpected dispute notify: %+v %+v", payNotify, payNotify.Refund)
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"one-api/model"
 	"one-api/payment/types"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wechatpay-apiv3/wechatpay-go/core"
@@ -17,6 +19,8 @@ import (
 	"github.com/wechatpay-apiv3/wechatpay-go/core/notify"
 	"github.com/wechatpay-apiv3/wechatpay-go/core/option"
 	"github.com/wechatpay-apiv3/wechatpay-go/services/payments"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/native"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/refunddomestic"
 	"github.com/wechatpay-apiv3/wechatpay-go/utils"
 )
 
@@ -98,13 +102,18 @@ func (w *WeChatPay) HandleCallback(c *gin.Context, gatewayConfig string) (*types
 		})
 		return nil, fmt.Errorf("WeChat Signature verification failed: %v", err)
 	}
+	if strings.HasPrefix(notifyReq.EventType, "REFUND.") {
+		c.Status(http.StatusNoContent)
+		return parseRefundNotify(notifyReq.EventType, notifyReq.Resource.Plaintext)
+	}
 	if notifyReq.EventType != "TRANSACTION.SUCCESS" {
 		c.Status(http.StatusNoContent)
 		return nil, fmt.Errorf("WeChat Transaction failed: %v", notifyReq.EventType)
 	}
 	if *transaction.TradeState != "SUCCESS" {
 		c.Status(http.StatusNoContent)
-		return nil, fmt.Errorf("tradeNo: %s, TransactionId: %s,  err: %v", transaction.OutTradeNo, transaction.TransactionId, err)
+		// 未支付成功的通知中 transaction_id 等字段可能为空
+		return nil, fmt.Errorf("tradeNo: %s, TransactionId: %s,  state: %s", stringValue(transaction.OutTradeNo), stringValue(transaction.TransactionId), *transaction.TradeState)
 	}
 
 	payNotify := &types.PayNotify{
@@ -116,6 +125,170 @@ func (w *WeChatPay) HandleCallback(c *gin.Context, gatewayConfig string) (*types
 
 }
 
+func (w *WeChatPay) Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error) {
+	wechatConfig, err := getWeChatConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		err := w.InitClient(wechatConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req := refunddomestic.CreateRequest{
+		OutTradeNo:  core.String(config.TradeNo),
+		OutRefundNo: core.String(config.RefundNo),
+		Amount: &refunddomestic.AmountReq{
+			Refund:   core.Int64(int64(math.Round(config.RefundMoney * 100))), // 转换为分
+			Total:    core.Int64(int64(math.Round(config.TotalMoney * 100))),
+			Currency: core.String("CNY"),
+		},
+	}
+	if config.Reason != "" {
+		req.Reason = core.String(config.Reason)
+	}
+	rService := refunddomestic.RefundsApiService{Client: client}
+	resp, _, err := rService.Create(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("wechat refund failed: %s", err.Error())
+	}
+	if resp.Status == nil || resp.RefundId == nil {
+		return nil, fmt.Errorf("wechat refund failed: unexpected status")
+	}
+	result := refundResult(resp)
+	if result.Status == types.RefundStatusFailed {
+		return nil, fmt.Errorf("wechat refund failed: %s", *resp.Status)
+	}
+	return result, nil
+}
+
+// QueryRefund 按本地退款单号查询退款结果
+func (w *WeChatPay) QueryRefund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error) {
+	wechatConfig, err := getWeChatConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		err := w.InitClient(wechatConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rService := refunddomestic.RefundsApiService{Client: client}
+	resp, _, err := rService.QueryByOutRefundNo(context.Background(), refunddomestic.QueryByOutRefundNoRequest{
+		OutRefundNo: core.String(config.RefundNo),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("wechat query refund failed: %s", err.Error())
+	}
+	if resp.Status == nil {
+		return nil, errors.New("wechat query refund failed: missing refund status")
+	}
+	return refundResult(resp), nil
+}
+
+// refundResult 退款受理后为处理中，最终结果由微信异步通知或查询确认
+func refundResult(resp *refunddomestic.Refund) *types.RefundResult {
+	result := &types.RefundResult{}
+	if resp.RefundId != nil {
+		result.GatewayRefundNo = *resp.RefundId
+	}
+	switch *resp.Status {
+	case refunddomestic.STATUS_SUCCESS:
+		result.Status = types.RefundStatusSuccess
+	case refunddomestic.STATUS_PROCESSING:
+		result.Status = types.RefundStatusProcessing
+	default:
+		result.Status = types.RefundStatusFailed
+	}
+	return result
+}
+
+// parseRefundNotify 解析退款结果通知，REFUND.ABNORMAL 和 REFUND.CLOSED 表示退款失败
+func parseRefundNotify(eventType, plaintext string) (*types.PayNotify, error) {
+	resource := &RefundNotifyResource{}
+	if err := json.Unmarshal([]byte(plaintext), resource); err != nil {
+		return nil, fmt.Errorf("WeChat refund notify parse failed: %v", err)
+	}
+
+	refund := &types.RefundNotify{
+		RefundNo: resource.OutRefundNo,
+		Amount:   float64(resource.Amount.Refund) / 100,
+	}
+	switch eventType {
+	case "REFUND.SUCCESS":
+	case "REFUND.ABNORMAL", "REFUND.CLOSED":
+		refund.Status = types.RefundStatusFailed
+	default:
+		return nil, fmt.Errorf("WeChat refund notify ignored: %s", eventType)
+	}
+
+	return &types.PayNotify{
+		TradeNo:   resource.OutTradeNo,
+		GatewayNo: resource.TransactionId,
+		Refund:    refund,
+	}, nil
+}
+
+func (w *WeChatPay) Query(config *types.QueryConfig, gatewayConfig string) (*types.QueryResult, error) {
+	wechatConfig, err := getWeChatConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		err := w.InitClient(wechatConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nService := native.NativeApiService{Client: client}
+	transaction, _, err := nService.QueryOrderByOutTradeNo(context.Background(), native.QueryOrderByOutTradeNoRequest{
+		OutTradeNo: core.String(config.TradeNo),
+		Mchid:      core.String(wechatConfig.MchID),
+	})
+	if err != nil {
+		if core.IsAPIError(err, "ORDER_NOT_EXIST") {
+			return &types.QueryResult{Status: types.QueryStatusPending}, nil
+		}
+		return nil, fmt.Errorf("wechat query order failed: %s", err.Error())
+	}
+	if transaction.TradeState == nil {
+		return nil, errors.New("wechat query order failed: missing trade state")
+	}
+
+	result := &types.QueryResult{}
+	switch *transaction.TradeState {
+	// REFUND 表示支付后发生了退款，订单本身已支付
+	case "SUCCESS", "REFUND":
+		result.Status = types.QueryStatusPaid
+		if transaction.TransactionId != nil {
+			result.GatewayNo = *transaction.TransactionId
+		}
+		if transaction.Amount != nil && transaction.Amount.Total != nil {
+			result.Money = float64(*transaction.Amount.Total) / 100
+		}
+	case "CLOSED", "REVOKED", "PAYERROR":
+		result.Status = types.QueryStatusClosed
+	default:
+		result.Status = types.QueryStatusPending
+	}
+	return result, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 func getWeChatConfig(gatewayConfig string) (*WeChatConfig, error) {
 	var wechatConfig WeChatConfig
 	if err := json.Unmarshal([]byte(gatewayConfig), &wechatConfig); err != nil {
@@ -0,0 +1,96 @@
+package wxpay
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"one-api/payment/types"
+
+	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/option"
+)
+
+// rewriteTransport 把发往微信支付的请求转发到本地的模拟服务
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWeChatRefundSendsAmountInFen(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/refund/domestic/refunds" {
+			t.Errorf("unexpected wechat path: %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"refund_id":"5030001","out_refund_no":"R1","out_trade_no":"T1","status":"PROCESSING"}`))
+	}))
+	defer server.Close()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected rsa key, got %v", err)
+	}
+	target, _ := url.Parse(server.URL)
+	testClient, err := core.NewClient(context.Background(),
+		option.WithMerchantCredential("1900000001", "SERIAL", privateKey),
+		option.WithoutValidator(),
+		option.WithHTTPClient(&http.Client{Transport: &rewriteTransport{target: target}}),
+	)
+	if err != nil {
+		t.Fatalf("expected wechat client, got %v", err)
+	}
+	originalClient := client
+	client = testClient
+	t.Cleanup(func() {
+		client = originalClient
+	})
+
+	gatewayConfig, _ := json.Marshal(WeChatConfig{MchID: "1900000001"})
+	result, err := (&WeChatPay{}).Refund(&types.RefundConfig{
+		TradeNo:     "T1",
+		RefundNo:    "R1",
+		TotalMoney:  20.1,
+		RefundMoney: 10.05,
+		Reason:      "duplicate",
+	}, string(gatewayConfig))
+	if err != nil {
+		t.Fatalf("expected refund to be accepted, got %v", err)
+	}
+	if result.GatewayRefundNo != "5030001" || result.Status != types.RefundStatusProcessing {
+		t.Fatalf("expected processing wechat refund, got %+v", result)
+	}
+	amount, _ := body["amount"].(map[string]any)
+	if body["out_trade_no"] != "T1" || body["out_refund_no"] != "R1" || amount["refund"] != float64(1005) || amount["total"] != float64(2010) {
+		t.Fatalf("unexpected refund request: %v", body)
+	}
+}
+
+func TestWeChatRefundNotifyReportsAbnormalRefund(t *testing.T) {
+	plaintext := `{"out_trade_no":"T1","transaction_id":"4200001","out_refund_no":"R1","refund_id":"5030001","refund_status":"ABNORMAL","amount":{"total":2010,"refund":1005}}`
+
+	payNotify, err := parseRefundNotify("REFUND.ABNORMAL", plaintext)
+	if err != nil {
+		t.Fatalf("expected refund notify to parse, got %v", err)
+	}
+	if payNotify.GatewayNo != "4200001" || payNotify.Refund == nil || payNotify.Refund.RefundNo != "R1" || payNotify.Refund.Amount != 10.05 || payNotify.Refund.Status != types.RefundStatusFailed {
+		t.Fatalf("unexpected refund notify: %+v %+v", payNotify, payNotify.Refund)
+	}
+
+	if payNotify, err = parseRefundNotify("REFUND.SUCCESS", plaintext); err != nil || payNotify.Refund.Status != "" {
+		t.Fatalf("expected successful refund notify, got %+v, %v", payNotify, err)
+	}
+}
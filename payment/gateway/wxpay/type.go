@@ -10,3 +10,16 @@ type NotifyResponse struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 }
+
+// RefundNotifyResource 退款通知解密后的内容
+type RefundNotifyResource struct {
+	OutTradeNo    string `json:"out_trade_no"`
+	TransactionId string `json:"transaction_id"`
+	OutRefundNo   string `json:"out_refund_no"`
+	RefundId      string `json:"refund_id"`
+	RefundStatus  string `json:"refund_status"`
+	Amount        struct {
+		Total  int64 `json:"total"`
+		Refund int64 `json:"refund"`
+	} `json:"amount"`
+}
@@ -4,6 +4,7 @@ import (
 	"one-api/model"
 	"one-api/payment/gateway/alipay"
 	"one-api/payment/gateway/epay"
+	"one-api/payment/gateway/paypal"
 	"one-api/payment/gateway/stripe"
 	"one-api/payment/gateway/wxpay"
 	"one-api/payment/types"
@@ -18,6 +19,21 @@ type PaymentProcessor interface {
 	HandleCallback(c *gin.Context, gatewayConfig string) (*types.PayNotify, error)
 }
 
+// PaymentRefunder 支持原路退款的支付网关
+type PaymentRefunder interface {
+	Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error)
+}
+
+// PaymentRefundQuerier 退款异步完成的网关，支持查询处理中的退款结果
+type PaymentRefundQuerier interface {
+	QueryRefund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error)
+}
+
+// PaymentQuerier 支持主动查询订单状态的支付网关
+type PaymentQuerier interface {
+	Query(config *types.QueryConfig, gatewayConfig string) (*types.QueryResult, error)
+}
+
 var Gateways = make(map[string]PaymentProcessor)
 
 func init() {
@@ -25,4 +41,5 @@ func init() {
 	Gateways["alipay"] = &alipay.Alipay{}
 	Gateways["wxpay"] = &wxpay.WeChatPay{}
 	Gateways["stripe"] = &stripe.Stripe{}
+	Gateways["paypal"] = &paypal.PayPal{}
 }
@@ -39,6 +39,24 @@ func NewPaymentService(uuid string) (*PaymentService, error) {
 	}, nil
 }
 
+// NewPaymentServiceByID 按 ID 获取支付网关，已停用的网关仍可用于退款
+func NewPaymentServiceByID(id int) (*PaymentService, error) {
+	payment, err := model.GetPaymentByID(id)
+	if err != nil {
+		return nil, errors.New("payment not found")
+	}
+
+	gateway, ok := Gateways[payment.Type]
+	if !ok {
+		return nil, errors.New("payment gateway not found")
+	}
+
+	return &PaymentService{
+		Payment: payment,
+		gateway: gateway,
+	}, nil
+}
+
 func (s *PaymentService) CreatedPay() error {
 	notifyURL := s.getNotifyURL()
 	return s.gateway.CreatedPay(notifyURL, s.Payment)
@@ -71,6 +89,74 @@ func (s *PaymentService) HandleCallback(c *gin.Context, gatewayConfig string) (*
 	return payNotify, err
 }
 
+// Refund 通过网关原路退还 money，金额单位为订单支付币种
+func (s *PaymentService) Refund(order *model.Order, refundNo string, money float64, reason string) (*types.RefundResult, error) {
+	refunder, ok := s.gateway.(PaymentRefunder)
+	if !ok {
+		return nil, fmt.Errorf("%s 不支持退款", s.gateway.Name())
+	}
+
+	config := &types.RefundConfig{
+		TradeNo:     order.TradeNo,
+		GatewayNo:   order.GatewayNo,
+		RefundNo:    refundNo,
+		TotalMoney:  order.OrderAmount,
+		RefundMoney: money,
+		Currency:    order.OrderCurrency,
+		Reason:      reason,
+	}
+	result, err := refunder.Refund(config, s.Payment.Config)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("%s refund error, trade_no: %s, err: %v", s.gateway.Name(), order.TradeNo, err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *PaymentService) CanQuery() bool {
+	_, ok := s.gateway.(PaymentQuerier)
+	return ok
+}
+
+// Query 向网关查询订单的支付状态
+func (s *PaymentService) Query(order *model.Order) (*types.QueryResult, error) {
+	querier, ok := s.gateway.(PaymentQuerier)
+	if !ok {
+		return nil, fmt.Errorf("%s 不支持查询订单", s.gateway.Name())
+	}
+
+	config := &types.QueryConfig{
+		TradeNo:   order.TradeNo,
+		GatewayNo: order.GatewayNo,
+		Currency:  order.OrderCurrency,
+	}
+	return querier.Query(config, s.Payment.Config)
+}
+
+func (s *PaymentService) CanQueryRefund() bool {
+	_, ok := s.gateway.(PaymentRefundQuerier)
+	return ok
+}
+
+// QueryRefund 向网关查询处理中退款的结果
+func (s *PaymentService) QueryRefund(order *model.Order, refund *model.OrderRefund) (*types.RefundResult, error) {
+	querier, ok := s.gateway.(PaymentRefundQuerier)
+	if !ok {
+		return nil, fmt.Errorf("%s 不支持查询退款", s.gateway.Name())
+	}
+
+	config := &types.RefundConfig{
+		TradeNo:     order.TradeNo,
+		GatewayNo:   order.GatewayNo,
+		RefundNo:    refund.RefundNo,
+		TotalMoney:  order.OrderAmount,
+		RefundMoney: refund.Money,
+		Currency:    order.OrderCurrency,
+	}
+	return querier.QueryRefund(config, s.Payment.Config)
+}
+
 func (s *PaymentService) getNotifyURL() string {
 	notifyDomain := s.Payment.NotifyDomain
 	if notifyDomain == "" {
@@ -12,10 +12,11 @@ type PayConfig struct {
 	User      *model.User        `json:"user"`
 }
 
-// 请求支付时的数据结构
+// 请求支付时的数据结构，GatewayNo 为网关侧的订单号，支付前需要它才能查询订单状态时返回
 type PayRequest struct {
-	Type int            `json:"type"` // 支付类型 1 url 2 qrcode
-	Data PayRequestData `json:"data"`
+	Type      int            `json:"type"` // 支付类型 1 url 2 qrcode
+	Data      PayRequestData `json:"data"`
+	GatewayNo string         `json:"-"`
 }
 
 type PayRequestData struct {
@@ -24,8 +25,66 @@ type PayRequestData struct {
 	Params any    `json:"params,omitempty"`
 }
 
-// 支付回调时的数据结构
+// 支付回调时的数据结构，Refund 不为空时表示网关通知的退款或拒付
 type PayNotify struct {
-	TradeNo   string `json:"trade_no"`
-	GatewayNo string `json:"gateway_no"`
+	TradeNo   string        `json:"trade_no"`
+	GatewayNo string        `json:"gateway_no"`
+	Refund    *RefundNotify `json:"refund,omitempty"`
+}
+
+// 网关主动通知的退款或拒付，RefundNo 为网关侧的编号，用于重复通知去重。
+// 确认本地发起的处理中退款时 RefundNo 为本地退款单号，Status 为空表示退款成功
+type RefundNotify struct {
+	RefundNo   string       `json:"refund_no"`
+	Amount     float64      `json:"amount"`
+	Reason     string       `json:"reason"`
+	Chargeback bool         `json:"chargeback"`
+	Status     RefundStatus `json:"status,omitempty"`
+}
+
+// 发起退款时的数据结构，金额单位为订单支付币种
+type RefundConfig struct {
+	TradeNo     string             `json:"trade_no"`
+	GatewayNo   string             `json:"gateway_no"`
+	RefundNo    string             `json:"refund_no"`
+	TotalMoney  float64            `json:"total_money"`
+	RefundMoney float64            `json:"refund_money"`
+	Currency    model.CurrencyType `json:"currency"`
+	Reason      string             `json:"reason"`
+}
+
+type RefundStatus string
+
+const (
+	RefundStatusSuccess    RefundStatus = "success"
+	RefundStatusProcessing RefundStatus = "processing" // 网关已受理，等待退款通知或查询确认
+	RefundStatusFailed     RefundStatus = "failed"
+)
+
+// 网关受理退款后的结果，Status 为空表示退款成功
+type RefundResult struct {
+	GatewayRefundNo string       `json:"gateway_refund_no"`
+	Status          RefundStatus `json:"status,omitempty"`
+}
+
+type QueryStatus string
+
+const (
+	QueryStatusPending QueryStatus = "pending" // 未支付
+	QueryStatusPaid    QueryStatus = "paid"
+	QueryStatusClosed  QueryStatus = "closed" // 已关闭或已过期，不会再支付
+)
+
+// 查询订单状态时的数据结构，GatewayNo 为下单时或支付后记录的网关订单号
+type QueryConfig struct {
+	TradeNo   string             `json:"trade_no"`
+	GatewayNo string             `json:"gateway_no"`
+	Currency  model.CurrencyType `json:"currency"`
+}
+
+// 网关订单状态，Money 为网关返回的支付金额，无法获取时为 0
+type QueryResult struct {
+	Status    QueryStatus `json:"status"`
+	GatewayNo string      `json:"gateway_no"`
+	Money     float64     `json:"money"`
 }
@@ -0,0 +1,41 @@
+package base
+
+import (
+	"errors"
+	"strings"
+
+	"one-api/common/config"
+)
+
+// ErrBalanceNotSupported 供应商没有可以用 API Key 访问的余额接口
+var ErrBalanceNotSupported = errors.New("不支持余额查询")
+
+const (
+	BalanceCurrencyUSD = "USD"
+	BalanceCurrencyCNY = "CNY"
+)
+
+// BalanceCurrencyInterface 返回余额接口的原始币种，Balance() 的返回值统一换算为美元
+type BalanceCurrencyInterface interface {
+	BalanceCurrency() string
+}
+
+// GetBalanceCurrency 返回供应商余额的原始币种，没有实现 BalanceCurrencyInterface 时为美元
+func GetBalanceCurrency(provider any) string {
+	if currencyProvider, ok := provider.(BalanceCurrencyInterface); ok {
+		return currencyProvider.BalanceCurrency()
+	}
+	return BalanceCurrencyUSD
+}
+
+// ConvertBalanceToUSD 按配置的美元汇率（PaymentUSDRate）将余额换算为美元
+func ConvertBalanceToUSD(amount float64, currency string) float64 {
+	switch strings.ToUpper(strings.TrimSpace(currency)) {
+	case BalanceCurrencyCNY, "RMB":
+		if config.PaymentUSDRate <= 0 {
+			return amount
+		}
+		return amount / config.PaymentUSDRate
+	}
+	return amount
+}
@@ -0,0 +1,42 @@
+package base
+
+import (
+	"testing"
+
+	"one-api/common/config"
+)
+
+func TestConvertBalanceToUSD(t *testing.T) {
+	originalRate := config.PaymentUSDRate
+	t.Cleanup(func() {
+		config.PaymentUSDRate = originalRate
+	})
+
+	config.PaymentUSDRate = 7.2
+	if balance := ConvertBalanceToUSD(72, "cny"); balance != 10 {
+		t.Fatalf("expected CNY balance to be converted, got %v", balance)
+	}
+	if balance := ConvertBalanceToUSD(72, BalanceCurrencyUSD); balance != 72 {
+		t.Fatalf("expected USD balance to be kept, got %v", balance)
+	}
+
+	config.PaymentUSDRate = 0
+	if balance := ConvertBalanceToUSD(72, BalanceCurrencyCNY); balance != 72 {
+		t.Fatalf("expected missing rate not to divide by zero, got %v", balance)
+	}
+}
+
+type cnyBalanceProvider struct{}
+
+func (cnyBalanceProvider) BalanceCurrency() string {
+	return BalanceCurrencyCNY
+}
+
+func TestGetBalanceCurrency(t *testing.T) {
+	if currency := GetBalanceCurrency(cnyBalanceProvider{}); currency != BalanceCurrencyCNY {
+		t.Fatalf("expected provider currency to be reported, got %s", currency)
+	}
+	if currency := GetBalanceCurrency(nil); currency != BalanceCurrencyUSD {
+		t.Fatalf("expected USD by default, got %s", currency)
+	}
+}
@@ -3,6 +3,8 @@ package deepseek
 import (
 	"errors"
 	"strconv"
+
+	"one-api/providers/base"
 )
 
 type Response struct {
@@ -15,6 +17,11 @@ type BalanceInfo struct {
 	TotalBalance string `json:"total_balance"`
 }
 
+// BalanceCurrency DeepSeek 可能同时返回人民币和美元余额，各自换算为美元后相加
+func (p *DeepseekProvider) BalanceCurrency() string {
+	return base.BalanceCurrencyCNY
+}
+
 func (p *DeepseekProvider) Balance() (float64, error) {
 
 	fullRequestURL := p.GetFullRequestURL("/user/balance", "")
@@ -36,9 +43,14 @@ func (p *DeepseekProvider) Balance() (float64, error) {
 		return 0, errors.New("获取余额失败")
 	}
 
-	balance, err := strconv.ParseFloat(info.BalanceInfo[0].TotalBalance, 64)
-	if err != nil {
-		return 0, err
+	// 账户可能同时有人民币和美元余额，按各自币种换算后相加
+	balance := 0.0
+	for _, balanceInfo := range info.BalanceInfo {
+		amount, err := strconv.ParseFloat(balanceInfo.TotalBalance, 64)
+		if err != nil {
+			return 0, err
+		}
+		balance += base.ConvertBalanceToUSD(amount, balanceInfo.Currency)
 	}
 
 	p.Channel.UpdateBalance(balance)
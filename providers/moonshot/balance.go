@@ -3,6 +3,8 @@ package moonshot
 import (
 	"errors"
 	"strings"
+
+	"one-api/providers/base"
 )
 
 type Response struct {
@@ -18,6 +20,10 @@ type BalanceInfo struct {
 	CashBalance      float64 `json:"cash_balance"`
 }
 
+func (p *MoonshotProvider) BalanceCurrency() string {
+	return base.BalanceCurrencyCNY
+}
+
 func (p *MoonshotProvider) Balance() (float64, error) {
 
 	fullRequestURL := p.GetFullRequestURL("/v1/users/me/balance", "")
@@ -36,7 +42,7 @@ func (p *MoonshotProvider) Balance() (float64, error) {
 	if info.Code != 0 || !strings.EqualFold(info.Scode, "0x0") {
 		return 0, errors.New("获取余额失败")
 	}
-	balance := info.Data.AvailableBalance / 7 //RMB TO USD
+	balance := base.ConvertBalanceToUSD(info.Data.AvailableBalance, p.BalanceCurrency())
 	p.Channel.UpdateBalance(balance)
 	return balance, nil
 }
@@ -4,11 +4,17 @@ import (
 	"errors"
 	"fmt"
 	"time"
+
+	"one-api/providers/base"
 )
 
+func (p *OpenAIProvider) BalanceCurrency() string {
+	return base.BalanceCurrencyUSD
+}
+
 func (p *OpenAIProvider) Balance() (float64, error) {
 	if !p.BalanceAction {
-		return 0, errors.New("不支持余额查询")
+		return 0, base.ErrBalanceNotSupported
 	}
 
 	fullRequestURL := p.GetFullRequestURL("/v1/dashboard/billing/subscription", "")
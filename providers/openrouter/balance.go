@@ -0,0 +1,39 @@
+package openrouter
+
+import (
+	"errors"
+
+	"one-api/providers/base"
+)
+
+// https://openrouter.ai/docs/api-reference/get-credits
+type CreditsResponse struct {
+	Data struct {
+		TotalCredits float64 `json:"total_credits"`
+		TotalUsage   float64 `json:"total_usage"`
+	} `json:"data"`
+}
+
+func (p *OpenRouterProvider) BalanceCurrency() string {
+	return base.BalanceCurrencyUSD
+}
+
+func (p *OpenRouterProvider) Balance() (float64, error) {
+	fullRequestURL := p.GetFullRequestURL("/v1/credits", "")
+	headers := p.GetRequestHeaders()
+
+	req, err := p.Requester.NewRequest("GET", fullRequestURL, p.Requester.WithHeader(headers))
+	if err != nil {
+		return 0, err
+	}
+
+	var credits CreditsResponse
+	_, errWithCode := p.Requester.SendRequest(req, &credits, false)
+	if errWithCode != nil {
+		return 0, errors.New(errWithCode.OpenAIError.Message)
+	}
+
+	balance := credits.Data.TotalCredits - credits.Data.TotalUsage
+	p.Channel.UpdateBalance(balance)
+	return balance, nil
+}
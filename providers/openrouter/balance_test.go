@@ -0,0 +1,66 @@
+package openrouter_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"one-api/common/config"
+	"one-api/common/test"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useOpenRouterTestDB(t *testing.T) {
+	t.Helper()
+
+	originalDB := model.DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&model.Channel{}); err != nil {
+		t.Fatalf("expected channel schema migration for test database, got %v", err)
+	}
+
+	model.DB = testDB
+	t.Cleanup(func() {
+		model.DB = originalDB
+	})
+}
+
+func TestBalanceUsesRemainingCredits(t *testing.T) {
+	useOpenRouterTestDB(t)
+
+	server := test.NewTestServer()
+	ts := server.TestServer(test.OpenAICheck)
+	ts.Start()
+	defer ts.Close()
+	server.RegisterHandler("/api/v1/credits", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data":{"total_credits":25.5,"total_usage":10.25}}`)
+	})
+
+	channel := test.GetChannel(config.ChannelTypeOpenRouter, ts.URL+"/api", "", "", "")
+	channel.Id = 1
+	assert.Nil(t, model.DB.Create(&channel).Error)
+
+	context, _ := test.GetContext("GET", "/", test.RequestJSONConfig(), nil)
+	provider := providers.GetProvider(&channel, context)
+	balanceProvider, ok := provider.(providers_base.BalanceInterface)
+	assert.True(t, ok)
+
+	balance, err := balanceProvider.Balance()
+	assert.Nil(t, err)
+	assert.Equal(t, 15.25, balance)
+
+	stored, err := model.GetChannelById(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 15.25, stored.Balance)
+}
@@ -2,12 +2,19 @@ package recraftAI
 
 import (
 	"errors"
+
+	"one-api/providers/base"
 )
 
 type Response struct {
 	Credits int `json:"credits"`
 }
 
+// BalanceCurrency Recraft 返回的是积分，按 1000 积分 = 1 美元换算
+func (p *RecraftProvider) BalanceCurrency() string {
+	return base.BalanceCurrencyUSD
+}
+
 func (p *RecraftProvider) Balance() (float64, error) {
 
 	fullRequestURL := p.GetFullRequestURL("/v1/users/me")
@@ -3,6 +3,8 @@ package siliconflow
 import (
 	"errors"
 	"strconv"
+
+	"one-api/providers/base"
 )
 
 type Response struct {
@@ -17,6 +19,10 @@ type UserInfo struct {
 	TotalBalance string `json:"totalBalance"`
 }
 
+func (p *SiliconflowProvider) BalanceCurrency() string {
+	return base.BalanceCurrencyCNY
+}
+
 func (p *SiliconflowProvider) Balance() (float64, error) {
 
 	fullRequestURL := p.GetFullRequestURL("/v1/user/info", "")
@@ -42,6 +48,7 @@ func (p *SiliconflowProvider) Balance() (float64, error) {
 	if err != nil {
 		return 0, err
 	}
+	balance = base.ConvertBalanceToUSD(balance, p.BalanceCurrency())
 
 	p.Channel.UpdateBalance(balance)
 	return balance, nil
@@ -0,0 +1,40 @@
+package stabilityAI
+
+import (
+	"errors"
+	"strings"
+
+	"one-api/providers/base"
+)
+
+// https://platform.stability.ai/docs/api-reference#tag/User/paths/~1v1~1user~1balance/get
+type BalanceResponse struct {
+	Credits float64 `json:"credits"`
+}
+
+// BalanceCurrency Stability AI 返回的是积分，按 100 积分 = 1 美元换算
+func (p *StabilityAIProvider) BalanceCurrency() string {
+	return base.BalanceCurrencyUSD
+}
+
+func (p *StabilityAIProvider) Balance() (float64, error) {
+	// 余额接口在 v1 下，不在默认的 v2beta 地址下
+	baseURL := strings.TrimSuffix(strings.TrimSuffix(p.GetBaseURL(), "/"), "/v2beta")
+	headers := p.GetRequestHeaders()
+	headers["Accept"] = "application/json"
+
+	req, err := p.Requester.NewRequest("GET", baseURL+"/v1/user/balance", p.Requester.WithHeader(headers))
+	if err != nil {
+		return 0, err
+	}
+
+	var info BalanceResponse
+	_, errWithCode := p.Requester.SendRequest(req, &info, false)
+	if errWithCode != nil {
+		return 0, errors.New(errWithCode.OpenAIError.Message)
+	}
+
+	balance := info.Credits / 100
+	p.Channel.UpdateBalance(balance)
+	return balance, nil
+}
@@ -0,0 +1,68 @@
+package stabilityAI_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"one-api/common/config"
+	"one-api/common/test"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useStabilityAITestDB(t *testing.T) {
+	t.Helper()
+
+	originalDB := model.DB
+	testDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("expected in-memory sqlite database, got %v", err)
+	}
+	if err := testDB.AutoMigrate(&model.Channel{}); err != nil {
+		t.Fatalf("expected channel schema migration for test database, got %v", err)
+	}
+
+	model.DB = testDB
+	t.Cleanup(func() {
+		model.DB = originalDB
+	})
+}
+
+func TestBalanceConvertsCreditsToUSD(t *testing.T) {
+	useStabilityAITestDB(t)
+
+	server := test.NewTestServer()
+	ts := server.TestServer(test.OpenAICheck)
+	ts.Start()
+	defer ts.Close()
+	server.RegisterHandler("/v1/user/balance", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"credits":1250}`)
+	})
+
+	// 渠道地址使用默认的 v2beta 路径时余额接口仍然请求 v1
+	channel := test.GetChannel(config.ChannelTypeStabilityAI, ts.URL+"/v2beta", "", "", "")
+	channel.Id = 1
+	assert.Nil(t, model.DB.Create(&channel).Error)
+
+	context, _ := test.GetContext("GET", "/", test.RequestJSONConfig(), nil)
+	provider := providers.GetProvider(&channel, context)
+	balanceProvider, ok := provider.(providers_base.BalanceInterface)
+	assert.True(t, ok)
+	assert.Equal(t, providers_base.BalanceCurrencyUSD, providers_base.GetBalanceCurrency(provider))
+
+	balance, err := balanceProvider.Balance()
+	assert.Nil(t, err)
+	assert.Equal(t, 12.5, balance)
+
+	stored, err := model.GetChannelById(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 12.5, stored.Balance)
+}
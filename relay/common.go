@@ -522,13 +522,24 @@ func fetchChannelByModelWithSelection(c *gin.Context, modelName string, selectio
 		filters = append(filters, model.FilterDisabledStream(modelName))
 	}
 
+	hintFilters, hintPreferredChannelID, err := clientRoutingHints(c, modelName)
+	if err != nil {
+		return nil, err
+	}
+	filters = append(filters, hintFilters...)
+	// 亲和性绑定的渠道优先于客户端提示的渠道，提示的渠道不可用时正常回退
+	preferredChannelID := selection.preferredChannelID
+	if preferredChannelID <= 0 {
+		preferredChannelID = hintPreferredChannelID
+	}
+
 	// 使用统一的分组管理器
 	groupManager := NewGroupManager(c)
 	return groupManager.TryWithGroups(modelName, filters, func(group string) (*model.Channel, error) {
 		if err := waitForPreferredChannelCooldown(c, group, modelName, selection, filters); err != nil {
 			return nil, err
 		}
-		channel, err := model.ChannelGroup.NextWithPreferred(group, modelName, selection.preferredChannelID, selection.ignorePreferredCooldown, filters...)
+		channel, err := model.ChannelGroup.NextWithPreferred(group, modelName, preferredChannelID, selection.ignorePreferredCooldown, filters...)
 		if err != nil {
 			return nil, err
 		}
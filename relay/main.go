@@ -34,6 +34,12 @@ func Relay(c *gin.Context) {
 		return
 	}
 
+	if err := applyTokenParamClamps(c); err != nil {
+		openaiErr := wrapRelaySetupError(relay, "request", err, "one_hub_error", http.StatusBadRequest)
+		relay.HandleJsonError(openaiErr)
+		return
+	}
+
 	// Apply pre-mapping before setRequest to ensure request body modifications take effect
 	applyPreMappingBeforeRequest(c)
 
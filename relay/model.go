@@ -237,11 +237,20 @@ type AvailableModelResponse struct {
 
 func AvailableModel(c *gin.Context) {
 	groupName := c.GetString("group")
+	availableModels := getAvailableModels(groupName)
+
+	// 登录用户看到的是应用了协议价之后的价格
+	userId := c.GetInt("id")
+	if userId > 0 || groupName != "" {
+		for modelName, available := range availableModels {
+			available.Price, _ = model.GlobalPriceOverrides.ResolvePrice(available.Price, modelName, userId, groupName)
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    getAvailableModels(groupName),
+		"data":    availableModels,
 	})
 }
 
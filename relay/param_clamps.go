@@ -0,0 +1,172 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// paramClampRule 各个接口中受限参数的位置，嵌套字段用 . 分隔。
+// 请求中没有 maxTokens 里的任何字段时，按 maxTokensFill 补上限
+type paramClampRule struct {
+	prefix        string
+	maxTokens     []string
+	maxTokensFill string
+	n             []string
+	image         bool
+}
+
+var paramClampRules = []paramClampRule{
+	{prefix: "/v1/chat/completions", maxTokens: []string{"max_tokens", "max_completion_tokens"}, maxTokensFill: "max_completion_tokens", n: []string{"n"}},
+	{prefix: "/v1/completions", maxTokens: []string{"max_tokens"}, maxTokensFill: "max_tokens", n: []string{"n"}},
+	{prefix: "/v1/responses", maxTokens: []string{"max_output_tokens"}, maxTokensFill: "max_output_tokens"},
+	{prefix: "/claude/", maxTokens: []string{"max_tokens"}, maxTokensFill: "max_tokens"},
+	{prefix: "/gemini/", maxTokens: []string{"generationConfig.maxOutputTokens"}, maxTokensFill: "generationConfig.maxOutputTokens", n: []string{"generationConfig.candidateCount"}},
+	{prefix: "/v1/images/generations", n: []string{"n"}, image: true},
+	{prefix: "/recraftAI/v1/images/generations", n: []string{"n"}, image: true},
+}
+
+// applyTokenParamClamps 在选择渠道之前按令牌的参数限制改写请求体，
+// 原始请求体同时被替换，重新选择渠道后的预映射也以限制后的请求为准。
+func applyTokenParamClamps(c *gin.Context) error {
+	setting, ok := c.Value("token_setting").(*model.TokenSetting)
+	if !ok || setting == nil || !setting.Limits.LimitsParamSetting.Enabled {
+		return nil
+	}
+	if !strings.Contains(c.ContentType(), "json") {
+		return nil
+	}
+
+	requestBody, err := common.CacheRequestBody(c)
+	if err != nil || len(requestBody) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(requestBody))
+	decoder.UseNumber()
+	var requestMap map[string]any
+	if err := decoder.Decode(&requestMap); err != nil {
+		// 交给后续的请求解析返回错误
+		return nil
+	}
+
+	changed, err := clampRequestParams(c.Request.URL.Path, requestMap, &setting.Limits.LimitsParamSetting)
+	if err != nil || !changed {
+		return err
+	}
+
+	clamped, err := json.Marshal(requestMap)
+	if err != nil {
+		return err
+	}
+	wireBody, _ := common.GetWireRequestBody(c)
+	meta, _ := common.GetRequestBodyDecodeMeta(c)
+	common.SetDecodedRequestState(c, wireBody, clamped, meta)
+	return nil
+}
+
+func clampRequestParams(path string, requestMap map[string]any, setting *model.LimitsParamSetting) (changed bool, err error) {
+	if setting.DisableTools {
+		for _, key := range []string{"tools", "functions"} {
+			if value, ok := requestMap[key].([]any); ok && len(value) > 0 {
+				return false, errors.New("Tools are not allowed for current token")
+			}
+		}
+	}
+
+	index := slices.IndexFunc(paramClampRules, func(rule paramClampRule) bool {
+		return strings.HasPrefix(path, rule.prefix)
+	})
+	if index < 0 {
+		return false, nil
+	}
+	rule := paramClampRules[index]
+
+	if setting.MaxTokens > 0 {
+		present := false
+		for _, key := range rule.maxTokens {
+			present = hasJSONField(requestMap, key) || present
+			changed = clampJSONNumber(requestMap, key, setting.MaxTokens) || changed
+		}
+		// 没有传最大输出长度时上游会使用模型的默认值，补上限制
+		if !present && rule.maxTokensFill != "" {
+			changed = setJSONField(requestMap, rule.maxTokensFill, setting.MaxTokens) || changed
+		}
+	}
+	if setting.MaxN > 0 {
+		for _, key := range rule.n {
+			changed = clampJSONNumber(requestMap, key, setting.MaxN) || changed
+		}
+	}
+
+	if rule.image {
+		if size, ok := requestMap["size"].(string); ok && len(setting.ImageSizes) > 0 && !slices.Contains(setting.ImageSizes, size) {
+			return false, fmt.Errorf("Image size %s is not allowed for current token", size)
+		}
+		if quality, ok := requestMap["quality"].(string); ok && len(setting.ImageQualities) > 0 && !slices.Contains(setting.ImageQualities, quality) {
+			return false, fmt.Errorf("Image quality %s is not allowed for current token", quality)
+		}
+	}
+
+	return changed, nil
+}
+
+// clampJSONNumber 字段存在且超过上限时改为上限
+func clampJSONNumber(requestMap map[string]any, key string, limit int) bool {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := requestMap[part].(map[string]any)
+		if !ok {
+			return false
+		}
+		requestMap = next
+	}
+
+	last := parts[len(parts)-1]
+	value, ok := requestMap[last].(json.Number)
+	if !ok {
+		return false
+	}
+	number, err := value.Float64()
+	if err != nil || number <= float64(limit) {
+		return false
+	}
+	requestMap[last] = limit
+	return true
+}
+
+// hasJSONField 字段存在且不为 null
+func hasJSONField(requestMap map[string]any, key string) bool {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := requestMap[part].(map[string]any)
+		if !ok {
+			return false
+		}
+		requestMap = next
+	}
+	return requestMap[parts[len(parts)-1]] != nil
+}
+
+// setJSONField 设置字段，缺少的上级对象会被创建，上级字段不是对象时不做修改
+func setJSONField(requestMap map[string]any, key string, value any) bool {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		if requestMap[part] == nil {
+			requestMap[part] = map[string]any{}
+		}
+		next, ok := requestMap[part].(map[string]any)
+		if !ok {
+			return false
+		}
+		requestMap = next
+	}
+	requestMap[parts[len(parts)-1]] = value
+	return true
+}
@@ -0,0 +1,127 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"one-api/common"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newParamClampTestContext(path, body string, setting model.LimitsParamSetting) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	tokenSetting := &model.TokenSetting{}
+	tokenSetting.Limits.LimitsParamSetting = setting
+	ctx.Set("token_setting", tokenSetting)
+	return ctx
+}
+
+func TestApplyTokenParamClampsRewritesRequestBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setting := model.LimitsParamSetting{Enabled: true, MaxTokens: 1024, MaxN: 1}
+	ctx := newParamClampTestContext("/v1/chat/completions", `{"model":"gpt-4o","max_tokens":4096,"n":3,"seed":9007199254740993}`, setting)
+	if err := applyTokenParamClamps(ctx); err != nil {
+		t.Fatalf("expected clamps to apply, got %v", err)
+	}
+
+	body, _ := common.GetOriginalRequestBody(ctx)
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatalf("expected json body, got %v", err)
+	}
+	if string(request["max_tokens"]) != "1024" || string(request["n"]) != "1" {
+		t.Fatalf("expected max_tokens and n to be clamped, got %s", body)
+	}
+	if string(request["seed"]) != "9007199254740993" {
+		t.Fatalf("expected other numbers to keep their precision, got %s", request["seed"])
+	}
+
+	ctx = newParamClampTestContext("/gemini/v1beta/models/gemini-pro:generateContent", `{"generationConfig":{"maxOutputTokens":512}}`, setting)
+	if err := applyTokenParamClamps(ctx); err != nil {
+		t.Fatalf("expected gemini request to pass, got %v", err)
+	}
+	body, _ = common.GetCanonicalRequestBody(ctx)
+	if !strings.Contains(string(body), `"maxOutputTokens":512`) {
+		t.Fatalf("expected values under the limit to be kept, got %s", body)
+	}
+}
+
+func TestApplyTokenParamClampsFillsMissingMaxTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setting := model.LimitsParamSetting{Enabled: true, MaxTokens: 1024}
+	cases := map[string]struct {
+		body     string
+		expected string
+	}{
+		"/v1/chat/completions":  {`{"model":"gpt-4o"}`, `"max_completion_tokens":1024`},
+		"/v1/completions":       {`{"model":"gpt-3.5-turbo-instruct"}`, `"max_tokens":1024`},
+		"/v1/responses":         {`{"model":"gpt-4o"}`, `"max_output_tokens":1024`},
+		"/claude/v1/messages":   {`{"model":"claude"}`, `"max_tokens":1024`},
+		"/gemini/v1beta/models": {`{"contents":[]}`, `"generationConfig":{"maxOutputTokens":1024}`},
+	}
+	for path, tc := range cases {
+		ctx := newParamClampTestContext(path, tc.body, setting)
+		if err := applyTokenParamClamps(ctx); err != nil {
+			t.Fatalf("expected %s to pass, got %v", path, err)
+		}
+		body, _ := common.GetOriginalRequestBody(ctx)
+		if !strings.Contains(string(body), tc.expected) {
+			t.Fatalf("expected %s to get %s, got %s", path, tc.expected, body)
+		}
+	}
+
+	// 已经传了其中一个字段时不再补充
+	ctx := newParamClampTestContext("/v1/chat/completions", `{"model":"gpt-4o","max_tokens":512}`, setting)
+	if err := applyTokenParamClamps(ctx); err != nil {
+		t.Fatalf("expected request to pass, got %v", err)
+	}
+	body, _ := common.GetCanonicalRequestBody(ctx)
+	if strings.Contains(string(body), "max_completion_tokens") {
+		t.Fatalf("expected existing max_tokens to be kept alone, got %s", body)
+	}
+
+	// 图片接口没有最大输出长度
+	ctx = newParamClampTestContext("/v1/images/generations", `{"model":"dall-e-3"}`, setting)
+	if err := applyTokenParamClamps(ctx); err != nil {
+		t.Fatalf("expected image request to pass, got %v", err)
+	}
+	body, _ = common.GetCanonicalRequestBody(ctx)
+	if strings.Contains(string(body), "1024") {
+		t.Fatalf("expected image request not to be changed, got %s", body)
+	}
+}
+
+func TestApplyTokenParamClampsRejectsDisallowedParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setting := model.LimitsParamSetting{Enabled: true, DisableTools: true, ImageSizes: []string{"1024x1024"}, ImageQualities: []string{"standard"}}
+	cases := map[string]string{
+		"/v1/chat/completions":   `{"model":"gpt-4o","tools":[{"type":"function"}]}`,
+		"/v1/images/generations": `{"model":"dall-e-3","size":"1792x1024"}`,
+		"/claude/v1/messages":    `{"model":"claude","max_tokens":10,"tools":[{"name":"search"}]}`,
+	}
+	for path, body := range cases {
+		if err := applyTokenParamClamps(newParamClampTestContext(path, body, setting)); err == nil {
+			t.Fatalf("expected %s to be rejected", path)
+		}
+	}
+
+	ctx := newParamClampTestContext("/v1/images/generations", `{"model":"dall-e-3","size":"1024x1024","quality":"hd"}`, setting)
+	if err := applyTokenParamClamps(ctx); err == nil || !strings.Contains(err.Error(), "quality") {
+		t.Fatalf("expected image quality to be rejected, got %v", err)
+	}
+
+	ctx = newParamClampTestContext("/v1/chat/completions", `{"model":"gpt-4o","tools":[]}`, setting)
+	if err := applyTokenParamClamps(ctx); err != nil {
+		t.Fatalf("expected empty tools to be allowed, got %v", err)
+	}
+}
@@ -3,12 +3,14 @@ package relay_util
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math"
 	"net/http"
 	"one-api/common"
 	"one-api/common/authutil"
 	"one-api/common/config"
 	"one-api/common/groupctx"
+	"one-api/common/limit"
 	"one-api/common/logger"
 	"one-api/common/utils"
 	"one-api/internal/billing"
@@ -33,11 +35,21 @@ type Quota struct {
 	groupRatio         float64
 	inputRatio         float64
 	outputRatio        float64
+	appliedTier        *model.PriceTier // 结算时命中的分段价格
+	timeModifier       *model.PriceTimeModifier
+	priceOverride      *model.PriceOverride
 	preConsumedQuota   int
 	cacheQuota         int
 	userId             int
 	channelId          int
 	tokenId            int
+	organizationId     int                          // 令牌绑定的组织，非 0 时从组织钱包扣费
+	spendCaps          *model.LimitsSpendSetting    // 令牌的消费上限
+	spendReservation   *model.TokenSpendReservation // 预扣时预占的消费，结算时修正
+	billingTag         string                       // 令牌的费用标签
+	tpmLimit           int                          // 令牌的 TPM 限制
+	tpmDebited         int                          // 已从 TPM 令牌桶扣除的 token 数
+	responseHeader     http.Header
 	callerNS           string
 	unlimitedQuota     bool
 	HandelStatus       bool
@@ -68,6 +80,7 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 		userId:         c.GetInt("id"),
 		channelId:      c.GetInt("channel_id"),
 		tokenId:        c.GetInt("token_id"),
+		organizationId: c.GetInt("token_organization_id"),
 		callerNS:       readQuotaCallerNamespace(c),
 		unlimitedQuota: c.GetBool("token_unlimited_quota"),
 		HandelStatus:   false,
@@ -79,6 +92,20 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 	if c.Request != nil {
 		quota.userAgent = utils.NormalizeUserAgent(c.Request.UserAgent())
 	}
+	if setting, ok := c.Get("token_setting"); ok {
+		if typed, ok := setting.(*model.TokenSetting); ok && typed != nil {
+			quota.spendCaps = &typed.Limits.LimitsSpendSetting
+			if typed.BillingTag != nil {
+				quota.billingTag = *typed.BillingTag
+			}
+			if rateSetting := typed.Limits.LimitsRateSetting; rateSetting.Enabled && rateSetting.TPM > 0 {
+				quota.tpmLimit = rateSetting.TPM
+			}
+		}
+	}
+	if c.Writer != nil {
+		quota.responseHeader = c.Writer.Header()
+	}
 	if meta, ok := c.Get(config.GinChannelAffinityMetaKey); ok {
 		if typed, ok := meta.(map[string]any); ok && len(typed) > 0 {
 			quota.affinityMeta = make(map[string]any, len(typed))
@@ -88,8 +115,11 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 		}
 	}
 
-	quota.price = *model.PricingInstance.GetPrice(quota.modelName)
 	quota.groupName = groupctx.CurrentRoutingGroup(c)
+	// 用户或分组的协议价先于分组倍率生效
+	price, override := model.GlobalPriceOverrides.ResolvePrice(model.PricingInstance.GetPrice(quota.modelName), quota.modelName, quota.userId, quota.groupName)
+	quota.price = *price
+	quota.priceOverride = override
 	quota.tokenGroupName = groupctx.DeclaredTokenGroup(c)
 	quota.backupGroupName = groupctx.BackupGroup(c)
 	quota.routingGroupSource = groupctx.CurrentRoutingGroupSource(c)
@@ -97,10 +127,34 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 	quota.inputRatio = quota.price.GetInput() * quota.groupRatio
 	quota.outputRatio = quota.price.GetOutput() * quota.groupRatio
 
+	// 分时价格按请求开始的时间确定，整个请求内保持不变
+	requestStartTime := c.GetTime("requestStartTime")
+	if requestStartTime.IsZero() {
+		requestStartTime = time.Now()
+	}
+	if modifier := model.GetPriceTimeModifier(quota.modelName, requestStartTime); modifier != nil {
+		quota.timeModifier = modifier
+		quota.inputRatio *= modifier.Multiplier
+		quota.outputRatio *= modifier.Multiplier
+	}
+
 	return quota
 
 }
 
+// tieredRatios 按提示词长度选择分段价格，命中时返回分段价格乘以分组倍率后的输入、输出倍率
+func (q *Quota) tieredRatios(promptTokens int) (inputRatio, outputRatio float64, tier *model.PriceTier) {
+	tier = q.price.GetTier(promptTokens)
+	if tier == nil {
+		return q.inputRatio, q.outputRatio, nil
+	}
+	multiplier := q.groupRatio
+	if q.timeModifier != nil {
+		multiplier *= q.timeModifier.Multiplier
+	}
+	return max(tier.Input, 0) * multiplier, max(tier.Output, 0) * multiplier, tier
+}
+
 func readQuotaCallerNamespace(c *gin.Context) string {
 	if c != nil {
 		if tokenID := c.GetInt("token_id"); tokenID > 0 {
@@ -131,6 +185,9 @@ func (q *Quota) Clone() *Quota {
 	cloned.requestDuration = 0
 	cloned.requestFrozen = false
 	cloned.extraBillingData = nil
+	cloned.appliedTier = nil
+	cloned.tpmDebited = 0
+	cloned.spendReservation = nil
 	cloned.requestContext = detachQuotaContext(q.requestContext)
 
 	return &cloned
@@ -146,16 +203,104 @@ func (q *Quota) ForcePreConsume() {
 }
 
 func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
+	if errWithCode := q.debitTokensPerMinute(); errWithCode != nil {
+		return errWithCode
+	}
+
+	errWithCode := q.preQuotaConsumption()
+	if errWithCode != nil {
+		q.adjustTokensPerMinute(0)
+		q.releaseTokenSpend()
+	}
+	return errWithCode
+}
+
+// debitTokensPerMinute 按预估的提示词 token 数扣除令牌的 TPM 额度，结算后再按实际用量修正
+func (q *Quota) debitTokensPerMinute() *types.OpenAIErrorWithStatusCode {
+	if q.tpmLimit <= 0 {
+		return nil
+	}
+
+	result, err := limit.NewTokenBucket(q.tpmLimit).Take(fmt.Sprintf(model.TokenTPMLimitKey, q.tokenId), q.promptTokens)
+	if err != nil {
+		// 限流服务异常时不拦截请求
+		logger.LogError(q.requestContext, "token tpm limiter error: "+err.Error())
+		return nil
+	}
+	if q.responseHeader != nil {
+		result.SetHeaders(q.responseHeader, "tokens")
+	}
+	if !result.Allowed {
+		return common.ErrorWrapper(errors.New("令牌的 token 速率达到上限，请稍后再试"), "token_rate_limit_exceeded", http.StatusTooManyRequests)
+	}
+	q.tpmDebited = q.promptTokens
+	return nil
+}
+
+// releaseTokenSpend 请求失败时退还预占的消费
+func (q *Quota) releaseTokenSpend() {
+	if q.spendReservation == nil {
+		return
+	}
+	if err := q.spendReservation.Release(); err != nil {
+		logger.LogError(q.requestContext, "release token spend failed: "+err.Error())
+	}
+	q.spendReservation = nil
+}
+
+// adjustTokensPerMinute 按实际使用的 token 数修正已扣除的 TPM 额度，请求失败时传 0 退还
+func (q *Quota) adjustTokensPerMinute(actualTokens int) {
+	if q.tpmLimit <= 0 || actualTokens == q.tpmDebited {
+		return
+	}
+
+	delta := actualTokens - q.tpmDebited
+	if _, err := limit.NewTokenBucket(q.tpmLimit).Adjust(fmt.Sprintf(model.TokenTPMLimitKey, q.tokenId), delta); err != nil {
+		logger.LogError(q.requestContext, "token tpm limiter error: "+err.Error())
+		return
+	}
+	q.tpmDebited = actualTokens
+}
+
+func (q *Quota) preQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 	if q.price.Type == model.TimesPriceType {
 		q.preConsumedQuota = int(1000 * q.inputRatio)
 	} else if q.price.Input != 0 || q.price.Output != 0 {
-		q.preConsumedQuota = int(float64(q.promptTokens)*q.inputRatio) + config.PreConsumedQuota
+		// 预扣费按预估的提示词长度选择分段价格，结算时再按实际用量重新选择
+		inputRatio, _, _ := q.tieredRatios(q.promptTokens)
+		q.preConsumedQuota = int(float64(q.promptTokens)*inputRatio) + config.PreConsumedQuota
 	}
 
+	// 不需要预扣费的请求同样受订阅额度限制
+	if q.organizationId == 0 {
+		if err := model.CheckSubscriptionOverage(q.userId); err != nil {
+			return common.ErrorWrapper(err, "subscription_quota_exhausted", http.StatusPaymentRequired)
+		}
+	}
+
+	if err := model.CheckBillingTagBudget(q.billingTag, time.Now()); err != nil {
+		return common.ErrorWrapper(err, "billing_tag_budget_exhausted", http.StatusPaymentRequired)
+	}
+
+	// 在窗口计数中预占预估的消费，并发请求不会一起越过上限
+	reservation, err := model.ReserveTokenSpend(q.tokenId, q.spendCaps, q.preConsumedQuota, time.Now())
+	if err != nil {
+		var capErr *model.TokenSpendCapError
+		if errors.As(err, &capErr) {
+			return common.ErrorWrapper(err, "token_spend_cap_exceeded", http.StatusTooManyRequests)
+		}
+		logger.LogError(q.requestContext, "reserve token spend failed: "+err.Error())
+	}
+	q.spendReservation = reservation
+
 	if q.preConsumedQuota == 0 {
 		return nil
 	}
 
+	if q.organizationId > 0 {
+		return q.preOrganizationQuotaConsumption()
+	}
+
 	userQuota, err := model.CacheGetUserQuota(q.userId)
 	if err != nil {
 		return common.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
@@ -189,6 +334,52 @@ func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 	return nil
 }
 
+// preOrganizationQuotaConsumption 组织令牌从组织钱包预扣费，成员的个人额度不受影响
+func (q *Quota) preOrganizationQuotaConsumption() *types.OpenAIErrorWithStatusCode {
+	organization, err := model.GetOrganizationById(q.organizationId)
+	if err != nil {
+		return common.ErrorWrapper(errors.New("organization not found"), "organization_not_found", http.StatusForbidden)
+	}
+	member, err := model.GetOrganizationMember(q.organizationId, q.userId)
+	if err != nil {
+		return common.ErrorWrapper(err, "organization_membership_invalid", http.StatusForbidden)
+	}
+
+	if organization.Quota < q.preConsumedQuota {
+		return common.ErrorWrapper(errors.New("organization quota is not enough"), "insufficient_organization_quota", http.StatusPaymentRequired)
+	}
+	if member.QuotaLimit > 0 && member.UsedQuota+q.preConsumedQuota > member.QuotaLimit {
+		return common.ErrorWrapper(model.ErrOrganizationMemberQuotaExhausted, "organization_member_quota_exceeded", http.StatusPaymentRequired)
+	}
+
+	// 成员设置了额度上限时需要预扣，才能在并发请求下守住上限
+	if !q.forcePreConsume && member.QuotaLimit == 0 && organization.Quota > 100*q.preConsumedQuota {
+		q.preConsumedQuota = 0
+		return nil
+	}
+
+	if err := model.PreConsumeOrganizationQuota(q.organizationId, q.userId, q.tokenId, q.unlimitedQuota, q.preConsumedQuota); err != nil {
+		if errors.Is(err, model.ErrOrganizationMemberQuotaExhausted) {
+			return common.ErrorWrapper(err, "organization_member_quota_exceeded", http.StatusPaymentRequired)
+		}
+		return common.ErrorWrapper(err, "pre_consume_organization_quota_failed", http.StatusForbidden)
+	}
+	q.HandelStatus = true
+	return nil
+}
+
+// availableQuota 当前请求可用的额度，组织令牌为组织钱包的余额
+func (q *Quota) availableQuota() (int, error) {
+	if q.organizationId > 0 {
+		organization, err := model.GetOrganizationById(q.organizationId)
+		if err != nil {
+			return 0, err
+		}
+		return organization.Quota, nil
+	}
+	return model.CacheGetUserQuota(q.userId)
+}
+
 // 更新用户实时配额
 func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types.UsageEvent) error {
 	usage.Merge(nowUsage)
@@ -199,7 +390,7 @@ func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types
 	}
 
 	promptTokens, completionTokens := q.getComputeTokensByUsageEvent(nowUsage)
-	increaseQuota := q.GetTotalQuota(promptTokens, completionTokens, nowUsage.ExtraBilling)
+	increaseQuota := q.getTotalQuota(promptTokens, nowUsage.InputTokens, completionTokens, nowUsage.ExtraBilling)
 
 	cacheQuota, err := model.CacheIncreaseUserRealtimeQuota(q.userId, increaseQuota)
 	if err != nil {
@@ -207,7 +398,7 @@ func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types
 	}
 
 	q.cacheQuota += increaseQuota
-	userQuota, err := model.CacheGetUserQuota(q.userId)
+	userQuota, err := q.availableQuota()
 	if err != nil {
 		return errors.New("error get user quota cache: " + err.Error())
 	}
@@ -220,10 +411,17 @@ func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types
 }
 
 func (q *Quota) Undo(c *gin.Context) {
+	q.adjustTokensPerMinute(0)
+	q.releaseTokenSpend()
 	if q.HandelStatus {
 		go func(ctx context.Context) {
 			// return pre-consumed quota
-			err := model.PostConsumeTokenQuotaWithInfo(q.tokenId, q.userId, q.unlimitedQuota, -q.preConsumedQuota)
+			var err error
+			if q.organizationId > 0 {
+				err = model.ApplyOrganizationQuotaDelta(q.organizationId, q.userId, q.tokenId, q.unlimitedQuota, -q.preConsumedQuota)
+			} else {
+				err = model.PostConsumeTokenQuotaWithInfo(q.tokenId, q.userId, q.unlimitedQuota, -q.preConsumedQuota)
+			}
 			if err != nil {
 				logger.LogError(ctx, "error return pre-consumed quota: "+err.Error())
 			}
@@ -278,6 +476,7 @@ func (q *Quota) BuildSettlementEnvelope(usage *types.Usage, isStream bool, reque
 			RequestKind:      requestKind,
 			UserID:           q.userId,
 			TokenID:          q.tokenId,
+			OrganizationID:   q.organizationId,
 			ChannelID:        q.channelId,
 			ModelName:        q.modelName,
 			PreConsumedQuota: q.preConsumedQuota,
@@ -289,14 +488,17 @@ func (q *Quota) BuildSettlementEnvelope(usage *types.Usage, isStream bool, reque
 			Deduplicate: deduplicate,
 			Cleanup: billing.SettlementCleanup{
 				RealtimeQuotaDelta:    q.cacheQuota,
-				RefreshUserQuotaCache: config.RedisEnabled,
+				RefreshUserQuotaCache: config.RedisEnabled && q.organizationId == 0,
 			},
 			Projection: billing.SettlementProjection{
-				TokenName:   q.tokenName,
-				RequestTime: q.getRequestTime(),
-				IsStream:    isStream,
-				Metadata:    q.GetLogMeta(usage),
-				SourceIP:    q.sourceIP,
+				TokenName:        q.tokenName,
+				RequestTime:      q.getRequestTime(),
+				IsStream:         isStream,
+				Metadata:         q.GetLogMeta(usage),
+				SourceIP:         q.sourceIP,
+				SpendCaps:        q.spendCaps,
+				SpendReservation: q.spendReservation,
+				BillingTag:       q.billingTag,
 			},
 		},
 	}
@@ -316,6 +518,11 @@ func (q *Quota) consumeUsageSettlement(usage *types.Usage, isStream bool, reques
 	if envelope == nil {
 		return nil
 	}
+	// 预占只在第一次结算时修正，之后的结算直接累加消费
+	q.spendReservation = nil
+	if usage != nil {
+		q.adjustTokensPerMinute(usage.PromptTokens + usage.CompletionTokens)
+	}
 
 	result, err := billing.ApplySettlement(q.requestContext, envelope.Command, &envelope.Options)
 	if err != nil {
@@ -362,6 +569,10 @@ func cloneQuotaPrice(price model.Price) model.Price {
 		extraRatios := datatypes.NewJSONType(copied)
 		cloned.ExtraRatios = &extraRatios
 	}
+	if price.Tiers != nil {
+		tiers := datatypes.NewJSONSlice(append([]model.PriceTier(nil), (*price.Tiers)...))
+		cloned.Tiers = &tiers
+	}
 	if price.ModelInfo != nil {
 		modelInfo := *price.ModelInfo
 		modelInfo.InputModalities = append([]string(nil), price.ModelInfo.InputModalities...)
@@ -377,6 +588,10 @@ func (q *Quota) GetInputRatio() float64 {
 	return q.inputRatio
 }
 
+func (q *Quota) GetOrganizationId() int {
+	return q.organizationId
+}
+
 func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 	meta := map[string]any{
 		"group_name":           q.groupName,
@@ -391,6 +606,22 @@ func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 		"output_ratio":         q.price.GetOutput(),
 	}
 
+	if q.price.VersionId > 0 {
+		meta["price_version_id"] = q.price.VersionId
+	}
+	if q.priceOverride != nil {
+		meta["price_override_id"] = q.priceOverride.Id
+	}
+	if q.timeModifier != nil {
+		meta["price_modifier"] = q.timeModifier.Name
+		meta["price_multiplier"] = q.timeModifier.Multiplier
+	}
+	if q.appliedTier != nil {
+		meta["price_tier"] = q.appliedTier.PromptTokensAbove
+		meta["input_ratio"] = q.appliedTier.Input
+		meta["output_ratio"] = q.appliedTier.Output
+	}
+
 	firstResponseTime := q.GetFirstResponseTime()
 	if firstResponseTime > 0 {
 		meta["first_response"] = firstResponseTime
@@ -435,10 +666,18 @@ func (q *Quota) getRequestTime() int {
 
 // 通过 token 数获取消费配额
 func (q *Quota) GetTotalQuota(promptTokens, completionTokens int, extraBilling map[string]types.ExtraBilling) (quota int) {
+	return q.getTotalQuota(promptTokens, promptTokens, completionTokens, extraBilling)
+}
+
+// getTotalQuota tierPromptTokens 为上游实际的提示词 token 数，用于选择分段价格，
+// promptTokens 为计入额外倍率后的计费 token 数
+func (q *Quota) getTotalQuota(promptTokens, tierPromptTokens, completionTokens int, extraBilling map[string]types.ExtraBilling) (quota int) {
+	inputRatio, outputRatio := q.inputRatio, q.outputRatio
 	if q.price.Type == model.TimesPriceType {
-		quota = int(1000 * q.inputRatio)
+		quota = int(1000 * inputRatio)
 	} else {
-		quota = int(math.Ceil((float64(promptTokens) * q.inputRatio) + (float64(completionTokens) * q.outputRatio)))
+		inputRatio, outputRatio, q.appliedTier = q.tieredRatios(tierPromptTokens)
+		quota = int(math.Ceil((float64(promptTokens) * inputRatio) + (float64(completionTokens) * outputRatio)))
 	}
 
 	q.GetExtraBillingData(extraBilling)
@@ -457,7 +696,7 @@ func (q *Quota) GetTotalQuota(promptTokens, completionTokens int, extraBilling m
 		))
 	}
 
-	if q.inputRatio != 0 && quota <= 0 {
+	if inputRatio != 0 && quota <= 0 {
 		quota = 1
 	}
 	totalTokens := promptTokens + completionTokens
@@ -509,7 +748,7 @@ func (q *Quota) getComputeTokensByUsageEvent(usage *types.UsageEvent) (promptTok
 // 通过 usage 获取消费配额
 func (q *Quota) GetTotalQuotaByUsage(usage *types.Usage) (quota int) {
 	promptTokens, completionTokens := q.getComputeTokensByUsage(usage)
-	return q.GetTotalQuota(promptTokens, completionTokens, usage.ExtraBilling)
+	return q.getTotalQuota(promptTokens, usage.PromptTokens, completionTokens, usage.ExtraBilling)
 }
 
 func (q *Quota) GetFirstResponseTime() int64 {
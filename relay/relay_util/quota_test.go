@@ -2,9 +2,11 @@ package relay_util
 
 import (
 	"context"
+	"net/http"
 	"testing"
 	"time"
 
+	"one-api/common/config"
 	"one-api/model"
 	"one-api/types"
 
@@ -162,3 +164,84 @@ func TestQuotaGetExtraBillingDataSeparatesImageGenerationVariantPricing(t *testi
 		t.Fatalf("expected high variant to keep its own price, got %+v", got)
 	}
 }
+
+func TestQuotaAppliesPromptTokenTier(t *testing.T) {
+	tiers := datatypes.NewJSONSlice([]model.PriceTier{{PromptTokensAbove: 200000, Input: 2, Output: 4}})
+	quota := &Quota{
+		price:       model.Price{Type: model.TokensPriceType, Input: 1, Output: 2, Tiers: &tiers},
+		groupRatio:  1,
+		inputRatio:  1,
+		outputRatio: 2,
+	}
+
+	if total := quota.GetTotalQuotaByUsage(&types.Usage{PromptTokens: 1000, CompletionTokens: 100}); total != 1200 {
+		t.Fatalf("expected base price below the threshold, got %d", total)
+	}
+	if meta := quota.GetLogMeta(nil); meta["price_tier"] != nil {
+		t.Fatalf("expected no tier metadata below the threshold, got %v", meta["price_tier"])
+	}
+
+	if total := quota.GetTotalQuotaByUsage(&types.Usage{PromptTokens: 250000, CompletionTokens: 100}); total != 500400 {
+		t.Fatalf("expected tier price above the threshold, got %d", total)
+	}
+	if meta := quota.GetLogMeta(nil); meta["price_tier"] != 200000 || meta["input_ratio"] != 2.0 {
+		t.Fatalf("expected tier to be recorded in log metadata, got %v", meta)
+	}
+}
+
+func TestQuotaTierRespectsTimeModifier(t *testing.T) {
+	tiers := datatypes.NewJSONSlice([]model.PriceTier{{PromptTokensAbove: 100, Input: 2, Output: 4}})
+	quota := &Quota{
+		price:        model.Price{Type: model.TokensPriceType, Input: 1, Output: 2, Tiers: &tiers},
+		groupRatio:   1,
+		inputRatio:   0.5,
+		outputRatio:  1,
+		timeModifier: &model.PriceTimeModifier{Name: "night", Multiplier: 0.5},
+	}
+
+	if total := quota.GetTotalQuotaByUsage(&types.Usage{PromptTokens: 200, CompletionTokens: 100}); total != 400 {
+		t.Fatalf("expected tier price to be discounted by the time modifier, got %d", total)
+	}
+	if meta := quota.GetLogMeta(nil); meta["price_modifier"] != "night" || meta["price_multiplier"] != 0.5 {
+		t.Fatalf("expected time modifier in log metadata, got %v", meta)
+	}
+}
+
+func TestQuotaTokensPerMinuteDebitsAndRefunds(t *testing.T) {
+	originalRedisEnabled := config.RedisEnabled
+	config.RedisEnabled = false
+	t.Cleanup(func() {
+		config.RedisEnabled = originalRedisEnabled
+	})
+
+	header := http.Header{}
+	newQuota := func(promptTokens int) *Quota {
+		return &Quota{tokenId: 910001, promptTokens: promptTokens, tpmLimit: 100, responseHeader: header, requestContext: context.Background()}
+	}
+
+	first := newQuota(60)
+	if errWithCode := first.debitTokensPerMinute(); errWithCode != nil {
+		t.Fatalf("expected first request within tpm, got %v", errWithCode)
+	}
+	if header.Get("x-ratelimit-limit-tokens") != "100" || header.Get("x-ratelimit-remaining-tokens") != "40" {
+		t.Fatalf("expected rate limit headers, got %v", header)
+	}
+
+	second := newQuota(60)
+	if errWithCode := second.debitTokensPerMinute(); errWithCode == nil || errWithCode.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected second request to exceed tpm, got %v", errWithCode)
+	}
+
+	// 实际只用了 30 个 token，退还多扣的部分
+	first.adjustTokensPerMinute(30)
+	if errWithCode := second.debitTokensPerMinute(); errWithCode != nil {
+		t.Fatalf("expected corrected usage to free tpm, got %v", errWithCode)
+	}
+	second.adjustTokensPerMinute(0)
+	if second.tpmDebited != 0 {
+		t.Fatalf("expected failed request to be refunded, got debited=%d", second.tpmDebited)
+	}
+	if errWithCode := newQuota(70).debitTokensPerMinute(); errWithCode != nil {
+		t.Fatalf("expected refunded tpm to be available, got %v", errWithCode)
+	}
+}
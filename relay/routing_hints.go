@@ -0,0 +1,52 @@
+package relay
+
+import (
+	"fmt"
+	"strconv"
+
+	"one-api/internal/requesthints"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientRoutingHints 将客户端请求头中的路由提示转换为渠道过滤条件。
+// 令牌未允许的提示直接拒绝，避免客户端绕过权限把请求固定到高价渠道。
+func clientRoutingHints(c *gin.Context, modelName string) (filters []model.ChannelsFilterFunc, preferredChannelID int, err error) {
+	hints := requesthints.ResolveClient(c)
+	if len(hints) == 0 {
+		return nil, 0, nil
+	}
+
+	setting, _ := c.Value("token_setting").(*model.TokenSetting)
+	for _, hint := range requesthints.ClientHints {
+		value, ok := hints[hint.Name]
+		if !ok {
+			continue
+		}
+		if !setting.AllowRoutingHint(hint.Name) {
+			return nil, 0, fmt.Errorf("当前令牌不允许使用请求头 %s", hint.Header)
+		}
+
+		switch hint.Name {
+		case requesthints.ClientChannelTag:
+			filters = append(filters, model.FilterChannelTag(value))
+		case requesthints.ClientRegion:
+			filters = append(filters, model.FilterChannelRegion(value))
+		case requesthints.ClientMaxPrice:
+			maxPrice, parseErr := strconv.ParseFloat(value, 64)
+			if parseErr != nil || maxPrice <= 0 {
+				return nil, 0, fmt.Errorf("请求头 %s 必须为正数", hint.Header)
+			}
+			filters = append(filters, model.FilterMaxUpstreamPrice(modelName, maxPrice))
+		case requesthints.ClientPreferChannel:
+			channelID, parseErr := strconv.Atoi(value)
+			if parseErr != nil || channelID <= 0 {
+				return nil, 0, fmt.Errorf("请求头 %s 必须为有效的渠道 Id", hint.Header)
+			}
+			preferredChannelID = channelID
+		}
+	}
+
+	return filters, preferredChannelID, nil
+}
@@ -0,0 +1,90 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"one-api/internal/requesthints"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRoutingHintTestContext(headers map[string]string, allowed ...string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	for key, value := range headers {
+		ctx.Request.Header.Set(key, value)
+	}
+	ctx.Set("token_group", "default")
+	ctx.Set("token_setting", &model.TokenSetting{
+		RoutingHints: model.RoutingHintsSetting{Allowed: allowed},
+	})
+	return ctx
+}
+
+func TestClientRoutingHintsRequireTokenPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx := newRoutingHintTestContext(map[string]string{"X-OneHub-Prefer-Channel": "12"}, requesthints.ClientRegion)
+	if _, _, err := clientRoutingHints(ctx, "gpt-5"); err == nil || !strings.Contains(err.Error(), "X-OneHub-Prefer-Channel") {
+		t.Fatalf("expected disallowed hint to be rejected, got %v", err)
+	}
+
+	ctx = newRoutingHintTestContext(map[string]string{"X-OneHub-Max-Price": "abc"}, requesthints.ClientMaxPrice)
+	if _, _, err := clientRoutingHints(ctx, "gpt-5"); err == nil {
+		t.Fatal("expected invalid max price to be rejected")
+	}
+
+	ctx = newRoutingHintTestContext(nil)
+	if filters, preferred, err := clientRoutingHints(ctx, "gpt-5"); err != nil || filters != nil || preferred != 0 {
+		t.Fatalf("expected no hints without headers, got %v %v %v", filters, preferred, err)
+	}
+}
+
+func TestFetchChannelByModelWithSelectionAppliesClientRoutingHints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	channelGroupSnapshot := snapshotChannelGroup()
+	t.Cleanup(func() {
+		restoreChannelGroup(channelGroupSnapshot)
+	})
+
+	us := newRelayTestCodexChannel(21)
+	us.Region = "us"
+	us.Tag = "premium"
+	eu := newRelayTestCodexChannel(22)
+	eu.Region = "eu"
+	asia := newRelayTestCodexChannel(23)
+	asia.Region = "asia"
+	model.ChannelGroup = buildRealtimeTestChannelGroupForChannels(us, eu, asia)
+
+	ctx := newRoutingHintTestContext(map[string]string{"X-OneHub-Region": "EU"}, requesthints.ClientRegion)
+	for i := 0; i < 10; i++ {
+		channel, err := fetchChannelByModelWithSelection(ctx, "gpt-5", currentRealtimeChannelSelection(ctx))
+		if err != nil || channel.Id != 22 {
+			t.Fatalf("expected region hint to select channel 22, got %+v, %v", channel, err)
+		}
+	}
+
+	ctx = newRoutingHintTestContext(map[string]string{"X-OneHub-Channel-Tag": "premium"}, requesthints.ClientChannelTag)
+	if channel, err := fetchChannelByModelWithSelection(ctx, "gpt-5", currentRealtimeChannelSelection(ctx)); err != nil || channel.Id != 21 {
+		t.Fatalf("expected tag hint to select channel 21, got %+v, %v", channel, err)
+	}
+
+	ctx = newRoutingHintTestContext(map[string]string{"X-OneHub-Prefer-Channel": "23"}, requesthints.ClientPreferChannel)
+	for i := 0; i < 10; i++ {
+		channel, err := fetchChannelByModelWithSelection(ctx, "gpt-5", currentRealtimeChannelSelection(ctx))
+		if err != nil || channel.Id != 23 {
+			t.Fatalf("expected preferred hint to select channel 23, got %+v, %v", channel, err)
+		}
+	}
+
+	// 提示的渠道不存在时回退到正常选择
+	ctx = newRoutingHintTestContext(map[string]string{"X-OneHub-Prefer-Channel": "99"}, requesthints.ClientPreferChannel)
+	if channel, err := fetchChannelByModelWithSelection(ctx, "gpt-5", currentRealtimeChannelSelection(ctx)); err != nil || channel == nil {
+		t.Fatalf("expected unknown preferred channel to fall back, got %+v, %v", channel, err)
+	}
+}
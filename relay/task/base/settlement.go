@@ -249,5 +249,8 @@ func refundLegacyTaskReserve(task *model.Task, quota int) error {
 	if err != nil {
 		return err
 	}
+	if task.OrganizationId > 0 {
+		return model.ApplyOrganizationQuotaDelta(task.OrganizationId, task.UserId, task.TokenID, token.UnlimitedQuota, -quota)
+	}
 	return model.ApplyTokenUserQuotaDeltaDirect(task.TokenID, task.UserId, token.UnlimitedQuota, -quota)
 }
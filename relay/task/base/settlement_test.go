@@ -609,3 +609,50 @@ func TestFailTaskWithSettlementLegacyFallbackRestoresTokenTruth(t *testing.T) {
 		t.Fatalf("expected legacy failure task row to persist terminal state, got status=%s progress=%d", stored.Status, stored.Progress)
 	}
 }
+
+func TestFailTaskWithSettlementLegacyRefundsChargedDeletedOrganizationOwner(t *testing.T) {
+	useTaskSettlementTestDB(t)
+	insertTaskSettlementFixtures(t)
+	if err := model.DB.AutoMigrate(&model.Organization{}, &model.OrganizationMember{}); err != nil {
+		t.Fatalf("expected organization schema migration to succeed, got %v", err)
+	}
+	organization := &model.Organization{Id: 7, Name: "acme", OwnerId: 1}
+	if err := model.DB.Create(organization).Error; err != nil {
+		t.Fatalf("expected organization fixture to persist, got %v", err)
+	}
+	if err := model.DB.Delete(organization).Error; err != nil {
+		t.Fatalf("expected organization to be deleted, got %v", err)
+	}
+
+	// 令牌已经不再绑定组织，退款仍按提交时扣费的组织处理
+	legacyTask := &model.Task{
+		Platform:       model.TaskPlatformSuno,
+		UserId:         1,
+		ChannelId:      1,
+		TokenID:        1,
+		OrganizationId: organization.Id,
+		TaskID:         "legacy-org-1",
+		Quota:          300,
+		Status:         model.TaskStatusSubmitted,
+		SubmitTime:     1,
+	}
+	if err := legacyTask.Insert(); err != nil {
+		t.Fatalf("expected legacy task insert to succeed, got %v", err)
+	}
+	if err := FailTaskWithSettlement(context.Background(), legacyTask, "legacy failure"); err != nil {
+		t.Fatalf("expected legacy task failure settlement to succeed, got %v", err)
+	}
+
+	var user model.User
+	if err := model.DB.First(&user, 1).Error; err != nil {
+		t.Fatalf("expected user lookup to succeed, got %v", err)
+	}
+	if user.Quota != 5300 {
+		t.Fatalf("expected refund to fall back to the organization owner, got %d", user.Quota)
+	}
+	var stored model.Organization
+	model.DB.Unscoped().First(&stored, organization.Id)
+	if stored.Quota != 0 {
+		t.Fatalf("expected deleted organization quota to stay untouched, got %d", stored.Quota)
+	}
+}
@@ -160,6 +160,7 @@ func preparePendingTaskAttempt(quotaInstance *relay_util.Quota, taskAdaptor base
 	task.Progress = 0
 	task.FailReason = ""
 	task.SubmitTime = time.Now().Unix()
+	task.OrganizationId = quotaInstance.GetOrganizationId()
 
 	if task.ID == 0 {
 		if err := task.Insert(); err != nil {
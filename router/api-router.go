@@ -28,7 +28,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/status", controller.GetStatus)
 		apiRouter.GET("/notice", controller.GetNotice)
 		apiRouter.GET("/about", controller.GetAbout)
-		apiRouter.GET("/prices", middleware.PricesAuth(), middleware.CORS(), controller.GetPricesList)
+		apiRouter.GET("/prices", middleware.PricesAuth(), middleware.CORS(), middleware.TrySetUserBySession(), controller.GetPricesList)
 		apiRouter.GET("/ownedby", relay.GetModelOwnedBy)
 		apiRouter.GET("/available_model", middleware.CORS(), middleware.TrySetUserBySession(), relay.AvailableModel)
 		apiRouter.GET("/user_group_map", middleware.TrySetUserBySession(), controller.GetUserGroupRatio)
@@ -79,16 +79,25 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/dashboard/uptimekuma/status-page/heartbeat", controller.UptimeKumaStatusPageHeartbeat)
 				selfRoute.GET("/invoice", controller.GetUserInvoice)
 				selfRoute.GET("/invoice/detail", controller.GetUserInvoiceDetail)
+				selfRoute.GET("/invoice/download", controller.DownloadUserInvoice)
+				selfRoute.GET("/invoice/subscription", controller.GetUserSubscriptionInvoice)
 				selfRoute.GET("/self", controller.GetSelf)
 				selfRoute.PUT("/self", controller.UpdateSelf)
 				selfRoute.POST("/unbind", controller.Unbind)
 				// selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateAccessToken)
 				selfRoute.GET("/aff", controller.GetAffCode)
+				selfRoute.GET("/aff/dashboard", controller.GetAffDashboard)
+				selfRoute.GET("/aff/commission", controller.GetAffCommissions)
+				selfRoute.POST("/aff/transfer", controller.TransferAffQuota)
 				selfRoute.POST("/topup", controller.TopUp)
 				selfRoute.GET("/payment", controller.GetUserPaymentList)
 				selfRoute.POST("/order", controller.CreateOrder)
 				selfRoute.GET("/order/status", controller.CheckOrderStatus)
+				selfRoute.GET("/subscription", controller.GetSelfSubscription)
+				selfRoute.GET("/subscription/plans", controller.GetAvailableSubscriptionPlans)
+				selfRoute.POST("/subscription/order", controller.CreateSubscriptionOrder)
+				selfRoute.PUT("/subscription/auto_renew", controller.UpdateSelfSubscriptionAutoRenew)
 			}
 
 			adminRoute := userRoute.Group("/")
@@ -121,6 +130,7 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.DELETE("/channel_affinity_cache", controller.ClearChannelAffinityCache)
 			optionRoute.POST("/invoice/gen/:time", controller.GenInvoice)
 			optionRoute.POST("/invoice/update/:time", controller.UpdateInvoice)
+			optionRoute.POST("/invoice/deliver/:time", controller.DeliverInvoice)
 			optionRoute.POST("/system_info/log", controller.SystemLog)
 		}
 
@@ -155,6 +165,16 @@ func SetApiRouter(router *gin.Engine) {
 			userGroup.DELETE("/:id", controller.DeleteUserGroup)
 
 		}
+		priceOverrideRoute := apiRouter.Group("/price_override")
+		priceOverrideRoute.Use(middleware.AdminAuth())
+		{
+			priceOverrideRoute.GET("/", controller.GetPriceOverrides)
+			priceOverrideRoute.GET("/:id", controller.GetPriceOverrideById)
+			priceOverrideRoute.POST("/", controller.AddPriceOverride)
+			priceOverrideRoute.PUT("/", controller.UpdatePriceOverride)
+			priceOverrideRoute.DELETE("/:id", controller.DeletePriceOverride)
+		}
+
 		channelRoute := apiRouter.Group("/channel")
 		channelRoute.Use(middleware.AdminAuth())
 		{
@@ -162,8 +182,13 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/models", relay.ListModelsForAdmin)
 			channelRoute.POST("/codex/usage/previews", controller.GetCodexUsagePreviews)
 			channelRoute.POST("/provider_models_list", controller.GetModelList)
+			channelRoute.GET("/model_sync", controller.GetChannelModelSyncDiffs)
+			channelRoute.PUT("/model_sync/:id/:action", controller.ReviewChannelModelSyncDiff)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.GET("/:id/codex/usage", controller.GetCodexChannelUsage)
+			channelRoute.GET("/:id/health", controller.GetChannelHealthHistory)
+			channelRoute.GET("/:id/balance_history", controller.GetChannelBalanceHistory)
+			channelRoute.POST("/:id/model_sync", controller.SyncChannelModels)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
@@ -211,18 +236,49 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
+			tokenRoute.POST("/:id/rotate", controller.RotateToken)
 		}
 		tokenAdminRoute := apiRouter.Group("/token")
 		tokenAdminRoute.Use(middleware.AdminAuth())
 		{
 			tokenAdminRoute.GET("/admin/search", controller.GetTokensListByAdmin)
 			tokenAdminRoute.PUT("/admin", controller.UpdateTokenByAdmin)
+			tokenAdminRoute.POST("/admin/:id/rotate", controller.RotateTokenByAdmin)
+			tokenAdminRoute.POST("/admin/:id/expire", controller.ForceExpireTokenKeys)
+		}
+		organizationRoute := apiRouter.Group("/organization")
+		organizationRoute.Use(middleware.UserAuth())
+		{
+			organizationRoute.GET("/", controller.GetUserOrganizations)
+			organizationRoute.POST("/", controller.CreateOrganization)
+			organizationRoute.POST("/invitation/accept", controller.AcceptOrganizationInvitation)
+			organizationRoute.GET("/:id", controller.GetOrganization)
+			organizationRoute.PUT("/:id", controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", controller.DeleteOrganization)
+			organizationRoute.GET("/:id/members", controller.GetOrganizationMembers)
+			organizationRoute.PUT("/:id/member", controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/member/:user_id", controller.RemoveOrganizationMember)
+			organizationRoute.POST("/:id/leave", controller.LeaveOrganization)
+			organizationRoute.POST("/:id/transfer", controller.TransferOrganizationQuota)
+			organizationRoute.GET("/:id/invitations", controller.GetOrganizationInvitations)
+			organizationRoute.POST("/:id/invitation", controller.CreateOrganizationInvitation)
+			organizationRoute.DELETE("/:id/invitation/:invitation_id", controller.RevokeOrganizationInvitation)
+			organizationRoute.GET("/:id/statistics", controller.GetOrganizationStatistics)
+			organizationRoute.GET("/:id/invoice", controller.GetOrganizationInvoice)
+		}
+		organizationAdminRoute := apiRouter.Group("/organization")
+		organizationAdminRoute.Use(middleware.AdminAuth())
+		{
+			organizationAdminRoute.GET("/admin/search", controller.GetOrganizationsList)
+			organizationAdminRoute.POST("/admin/:id/quota", controller.AdjustOrganizationQuota)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
 		{
 			redemptionRoute.GET("/", controller.GetRedemptionsList)
+			redemptionRoute.GET("/export", controller.ExportRedemptionsCSV)
 			redemptionRoute.GET("/:id", controller.GetRedemption)
+			redemptionRoute.GET("/:id/records", controller.GetRedemptionRecords)
 			redemptionRoute.POST("/", controller.AddRedemption)
 			redemptionRoute.PUT("/", controller.UpdateRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
@@ -246,8 +302,14 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			analyticsRoute.GET("/statistics", controller.GetStatisticsDetail)
 			analyticsRoute.GET("/period", controller.GetStatisticsByPeriod)
+			analyticsRoute.GET("/channel_margin", controller.GetChannelMarginStatistics)
 			analyticsRoute.GET("/multi_user_stats", controller.GetMultiUserStatistics)
 			analyticsRoute.GET("/multi_user_stats/export", controller.ExportMultiUserStatisticsCSV)
+			analyticsRoute.GET("/billing_tag_stats", controller.GetBillingTagStatistics)
+			analyticsRoute.GET("/billing_tag_stats/export", controller.ExportBillingTagStatisticsCSV)
+			analyticsRoute.GET("/billing_tag_budget", controller.GetBillingTagBudgets)
+			analyticsRoute.POST("/billing_tag_budget", controller.SaveBillingTagBudget)
+			analyticsRoute.DELETE("/billing_tag_budget/:id", controller.DeleteBillingTagBudget)
 		}
 		pricesRoute := apiRouter.Group("/prices")
 		pricesRoute.Use(middleware.AdminAuth())
@@ -260,13 +322,32 @@ func SetApiRouter(router *gin.Engine) {
 			pricesRoute.PUT("/multiple/delete", controller.BatchDeletePrices)
 			pricesRoute.POST("/sync", controller.SyncPricing)
 			pricesRoute.GET("/updateService", controller.GetUpdatePriceService)
+			pricesRoute.GET("/history/*model", controller.GetPriceHistory)
+			pricesRoute.GET("/version/:id", controller.GetPriceVersion)
+			pricesRoute.GET("/scheduled", controller.GetScheduledPrices)
+			pricesRoute.POST("/scheduled", controller.SchedulePrice)
+			pricesRoute.DELETE("/scheduled/:id", controller.CancelScheduledPrice)
 
 		}
 
+		subscriptionRoute := apiRouter.Group("/subscription")
+		subscriptionRoute.Use(middleware.AdminAuth())
+		{
+			subscriptionRoute.GET("/", controller.GetUserSubscriptions)
+			subscriptionRoute.GET("/plan", controller.GetSubscriptionPlans)
+			subscriptionRoute.GET("/plan/:id", controller.GetSubscriptionPlanById)
+			subscriptionRoute.POST("/plan", controller.AddSubscriptionPlan)
+			subscriptionRoute.PUT("/plan", controller.UpdateSubscriptionPlan)
+			subscriptionRoute.DELETE("/plan/:id", controller.DeleteSubscriptionPlan)
+		}
+
 		paymentRoute := apiRouter.Group("/payment")
 		paymentRoute.Use(middleware.AdminAuth())
 		{
 			paymentRoute.GET("/order", controller.GetOrderList)
+			paymentRoute.POST("/order/reconcile", controller.ReconcileOrders)
+			paymentRoute.GET("/order/:id/refund", controller.GetOrderRefunds)
+			paymentRoute.POST("/order/:id/refund", controller.RefundOrder)
 			paymentRoute.GET("/", controller.GetPaymentList)
 			paymentRoute.GET("/:id", controller.GetPayment)
 			paymentRoute.POST("/", controller.AddPayment)